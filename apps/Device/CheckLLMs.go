@@ -14,17 +14,25 @@ func (st *CheckLLMs) run(caller *ToolCaller, ui *UI) error {
 		return err
 	}
 
-	providerErr := source_dev.CheckProvider(source_dev.App_provider)
-	if providerErr != nil {
-		st.Out_AppProvider_error = providerErr.Error()
-	}
+	if source_dev.LLM_cassette != "replay" { //replay doesn't need provider
+		providerErr := source_dev.CheckProvider(source_dev.App_provider)
+		if providerErr == nil {
+			providerErr = source_dev.CheckModel(source_dev.App_provider, source_dev.App_model)
+		}
+		if providerErr != nil {
+			st.Out_AppProvider_error = providerErr.Error()
+		}
 
-	providerErr = source_dev.CheckProvider(source_dev.Code_provider)
-	if providerErr != nil {
-		st.Out_CodeProvider_error = providerErr.Error()
+		providerErr = source_dev.CheckProvider(source_dev.Code_provider)
+		if providerErr == nil {
+			providerErr = source_dev.CheckModel(source_dev.Code_provider, source_dev.Code_model)
+		}
+		if providerErr != nil {
+			st.Out_CodeProvider_error = providerErr.Error()
+		}
 	}
 
-	providerErr = source_dev.CheckProvider(source_dev.Image_provider)
+	providerErr := source_dev.CheckProvider(source_dev.Image_provider)
 	if providerErr != nil {
 		st.Out_ImageProvider_error = providerErr.Error()
 	}
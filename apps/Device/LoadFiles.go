@@ -10,8 +10,10 @@ func (st *LoadFiles) run(caller *ToolCaller, ui *UI) error {
 	NewLLMxAI("")
 	NewLLMMistral("")
 	NewLLMOpenai("")
+	NewLLMAnthropic("")
 	NewLLMWhispercpp("")
 	NewLLMLlamacpp("")
+	NewLLMOllama("")
 	NewMapSettings("")
 	NewMicrophoneSettings("")
 
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Show Ollama settings.
+type ShowLLMOllamaSettings struct {
+}
+
+func (st *ShowLLMOllamaSettings) run(caller *ToolCaller, ui *UI) error {
+	source_oll, err := NewLLMOllama("")
+	if err != nil {
+		return err
+	}
+
+	source_oll.Check()
+
+	ui.SetColumn(0, 1, 5)
+	ui.SetColumn(1, 1, 20)
+
+	ui.AddTextLabel(0, 0, 2, 1, "Ollama")
+
+	y := 1
+
+	fnSetError := func(err error) {
+		source_oll.last_error = ""
+		if err != nil {
+			source_oll.last_error = err.Error()
+		}
+	}
+
+	ui.AddText(0, y, 1, 1, "Address : port")
+	AddrDiv := ui.AddLayout(1, y, 1, 1)
+	{
+		AddrDiv.SetColumn(0, 1, Layout_MAX_SIZE)
+		AddrDiv.SetColumn(1, 0.5, 0.5)
+		AddrDiv.SetColumn(2, 1, 4)
+		AddrDiv.SetColumn(3, 1, 4)
+
+		AddrDiv.AddEditboxString(0, 0, 1, 1, &source_oll.Address)
+		AddrDiv.AddText(1, 0, 1, 1, ":")
+		AddrDiv.AddEditboxInt(2, 0, 1, 1, &source_oll.Port)
+
+		TestOKDia := ui.AddDialog("test_ok")
+		TestOKDia.UI.SetColumn(0, 5, 7)
+		tx := TestOKDia.UI.AddText(0, 0, 1, 1, "OK - Server is running!")
+		tx.Align_h = 1
+
+		TestErrDia := ui.AddDialog("test_err")
+		TestErrDia.UI.SetColumn(0, 5, 5)
+		TestErrDia.UI.Border_cd = UI_GetPalette().E
+		tx = TestErrDia.UI.AddText(0, 0, 1, 1, "Error - Server not found")
+		tx.Align_h = 1
+		tx.Cd = UI_GetPalette().E
+
+		TestBt := AddrDiv.AddButton(3, 0, 1, 1, "Test")
+		TestBt.clicked = func() error {
+			_, err := source_oll.GetVersion()
+			if err == nil {
+				TestOKDia.OpenRelative(TestBt.layout, caller)
+			} else {
+				TestErrDia.OpenRelative(TestBt.layout, caller)
+			}
+			return nil
+		}
+	}
+	y++
+
+	ui.AddSwitch(1, y, 1, 1, "Use OpenAI-compatible API", &source_oll.OpenAI_api)
+	y++
+
+	y++ //space
+
+	//models
+	ui.AddText(0, y, 1, 1, "Models")
+	RefreshBt := ui.AddButton(1, y, 1, 1, "Refresh")
+	RefreshBt.Background = 0.5
+	RefreshBt.clicked = func() error {
+		fnSetError(source_oll.ReloadModels())
+		return nil
+	}
+	y++
+
+	ui.SetRowFromSub(y, 1, 100, true)
+	ModelsDiv := ui.AddLayout(0, y, 2, 1)
+	{
+		ModelsDiv.SetColumn(0, 1, Layout_MAX_SIZE)
+		ModelsDiv.SetColumn(1, 1, 3)
+		ModelsDiv.SetColumn(2, 1, 3)
+		ModelsDiv.SetColumn(3, 1, 8)
+		ModelsDiv.SetColumn(4, 1, 3)
+
+		if len(source_oll.Models) == 0 {
+			tx := ModelsDiv.AddText(0, 0, 5, 1, "No models. Press Refresh or pull a model.")
+			tx.Cd = UI_GetPalette().GetGrey(0.5)
+		}
+
+		for i, model := range source_oll.Models {
+			ModelsDiv.AddText(0, i, 1, 1, model.Name)
+			ModelsDiv.AddText(1, i, 1, 1, fmt.Sprintf("%.1fGB", float64(model.Size)/(1024*1024*1024)))
+			ModelsDiv.AddText(2, i, 1, 1, fmt.Sprintf("%dK", model.Context_length/1024))
+
+			capTx := ModelsDiv.AddText(3, i, 1, 1, strings.Join(model.Capabilities, ", "))
+			if !model.HasCapability("tools") {
+				capTx.Cd = UI_GetPalette().E
+				capTx.layout.Tooltip = "Model doesn't support tool calling"
+			}
+
+			if model.Loaded {
+				UnloadBt := ModelsDiv.AddButton(4, i, 1, 1, "Unload")
+				UnloadBt.Background = 0.5
+				UnloadBt.layout.Tooltip = "Remove model from memory"
+				UnloadBt.clicked = func() error {
+					fnSetError(source_oll.UnloadModel(model.Name))
+					return nil
+				}
+			}
+		}
+	}
+	y++
+
+	y++ //space
+
+	ui.AddText(0, y, 1, 1, "Pull model")
+	PullDiv := ui.AddLayout(1, y, 1, 1)
+	{
+		PullDiv.SetColumn(0, 1, Layout_MAX_SIZE)
+		PullDiv.SetColumn(1, 1, 4)
+
+		PullDiv.AddEditboxString(0, 0, 1, 1, &source_oll.pull_model)
+
+		PullBt := PullDiv.AddButton(1, 0, 1, 1, "Pull")
+		PullBt.layout.Enable = (source_oll.pull_model != "")
+		PullBt.clicked = func() error {
+			fnSetError(source_oll.PullModel(source_oll.pull_model))
+			return nil
+		}
+	}
+	y++
+
+	if source_oll.last_error != "" {
+		errTx := ui.AddText(1, y, 1, 1, source_oll.last_error)
+		errTx.Cd = UI_GetPalette().E
+		errTx.setMultilined()
+		y++
+	}
+
+	ui.AddText(0, y, 1, 1, "Command example")
+	ui.AddText(1, y, 1, 1, "ollama pull qwen3:8b")
+	y++
+
+	return nil
+}
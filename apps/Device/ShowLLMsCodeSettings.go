@@ -1,9 +1,5 @@
 package main
 
-import (
-	"fmt"
-)
-
 // Show LLMs settings.
 type ShowLLMsCodeSettings struct {
 }
@@ -30,12 +26,10 @@ func (st *ShowLLMsCodeSettings) run(caller *ToolCaller, ui *UI) error {
 		CodeDiv.AddDropDown(0, 1, 1, 1, &source_dev.Code_provider, DeviceSettings_getAppProviders(), DeviceSettings_getAppProviders())
 		source_dev.BuildProvider(CodeDiv, source_dev.Code_provider, caller)
 
-		smarterSw := CodeDiv.AddSwitch(0, 2, 1, 1, "Smarter", &source_dev.Code_smarter)
-		smarterSw.layout.Enable = (source_dev.Code_provider != "")
+		source_dev.BuildModel(CodeDiv, source_dev.Code_provider, &source_dev.Code_model, &source_dev.Code_smarter)
 
-		pricing, tooltip := source_dev.GetPricingString(source_dev.Code_provider, source_dev.Code_model)
-		mdl := CodeDiv.AddText(1, 2, 1, 1, source_dev.Code_model+fmt.Sprintf(" (<i>%s</i>)", pricing))
-		mdl.layout.Tooltip = tooltip
+		CodeDiv.SetRowFromSub(4, 1, Layout_MAX_SIZE, true)
+		source_dev.BuildFallbacks(CodeDiv.AddLayout(0, 4, 2, 1), "Fallbacks for coding", &source_dev.Code_fallbacks)
 	}
 
 	return nil
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"slices"
 )
 
 // Show LLMs settings.
@@ -32,12 +32,12 @@ func (st *ShowLLMsSettings) run(caller *ToolCaller, ui *UI) error {
 		AppDiv.AddDropDown(0, 1, 1, 1, &source_dev.App_provider, DeviceSettings_getAppProviders(), DeviceSettings_getAppProviders())
 		source_dev.BuildProvider(AppDiv, source_dev.App_provider, caller)
 
-		smarterSw := AppDiv.AddSwitch(0, 2, 1, 1, "Smarter", &source_dev.App_smarter)
-		smarterSw.layout.Enable = (source_dev.App_provider != "")
+		source_dev.BuildModel(AppDiv, source_dev.App_provider, &source_dev.App_model, &source_dev.App_smarter)
 
-		pricing, tooltip := source_dev.GetPricingString(source_dev.App_provider, source_dev.App_model)
-		mdl := AppDiv.AddText(1, 2, 1, 1, source_dev.App_model+fmt.Sprintf(" (<i>%s</i>)", pricing))
-		mdl.layout.Tooltip = tooltip
+		AppDiv.SetRowFromSub(4, 1, Layout_MAX_SIZE, true)
+		source_dev.BuildFallbacks(AppDiv.AddLayout(0, 4, 2, 1), "Fallbacks for apps", &source_dev.Tools_fallbacks)
+		AppDiv.SetRowFromSub(5, 1, Layout_MAX_SIZE, true)
+		source_dev.BuildFallbacks(AppDiv.AddLayout(0, 5, 2, 1), "Fallbacks for chat", &source_dev.Chat_fallbacks)
 	}
 	y++
 	ui.AddDivider(0, y, 1, 1, true)
@@ -84,6 +84,81 @@ func (st *ShowLLMsSettings) run(caller *ToolCaller, ui *UI) error {
 		source_dev.BuildProvider(ChatDiv, source_dev.STT_provider, caller)
 	}
 	y++
+	ui.AddDivider(0, y, 1, 1, true)
+	y++ //space
+
+	//Budget
+	{
+		ui.SetRowFromSub(y, 1, Layout_MAX_SIZE, true)
+		BudgetDiv := ui.AddLayout(0, y, 1, 1)
+		BudgetDiv.SetColumn(0, 1, 4)
+		BudgetDiv.SetColumn(1, 1, Layout_MAX_SIZE)
+
+		tx := BudgetDiv.AddText(0, 0, 2, 1, "Budget")
+		tx.Align_h = 1
+
+		BudgetDiv.AddText(0, 1, 1, 1, "Daily($)")
+		BudgetDiv.AddEditboxFloat(1, 1, 1, 1, &source_dev.Budget_daily, 2)
+		BudgetDiv.AddText(0, 2, 1, 1, "Monthly($)")
+		BudgetDiv.AddEditboxFloat(1, 2, 1, 1, &source_dev.Budget_monthly, 2)
+
+		BudgetDiv.SetRowFromSub(3, 1, Layout_MAX_SIZE, true)
+		AppsDiv := BudgetDiv.AddLayout(0, 3, 2, 1)
+		{
+			AppsDiv.SetColumn(0, 1, 4)
+			AppsDiv.SetColumn(1, 1, Layout_MAX_SIZE)
+			AppsDiv.SetColumn(2, 1, 2)
+
+			atx := AppsDiv.AddText(0, 0, 2, 1, "Monthly limits per app($)")
+			atx.Cd = UI_GetPalette().GetGrey(0.5)
+
+			AddBt := AppsDiv.AddButton(2, 0, 1, 1, "Add")
+			AddBt.Background = 0.5
+			AddBt.clicked = func() error {
+				source_dev.Budget_apps = append(source_dev.Budget_apps, DeviceSettingsAppBudget{})
+				return nil
+			}
+
+			for i := range source_dev.Budget_apps {
+				it := &source_dev.Budget_apps[i]
+				AppsDiv.AddEditboxString(0, i+1, 1, 1, &it.App)
+				AppsDiv.AddEditboxFloat(1, i+1, 1, 1, &it.Monthly, 2)
+
+				RemoveBt := AppsDiv.AddButton(2, i+1, 1, 1, "X")
+				RemoveBt.Background = 0.5
+				RemoveBt.layout.Tooltip = "Remove"
+				RemoveBt.clicked = func() error {
+					source_dev.Budget_apps = slices.Delete(source_dev.Budget_apps, i, i+1)
+					return nil
+				}
+			}
+		}
+
+		info := BudgetDiv.AddText(1, 4, 1, 1, "LLM call is blocked when spendings + estimated price would cross the limit. 0 = no limit.")
+		info.Cd = UI_GetPalette().GetGrey(0.5)
+		info.setMultilined()
+	}
+	y++
+	ui.AddDivider(0, y, 1, 1, true)
+	y++ //space
+
+	//Record/Replay
+	{
+		ui.SetRowFromSub(y, 1, Layout_MAX_SIZE, true)
+		CassetteDiv := ui.AddLayout(0, y, 1, 1)
+		CassetteDiv.SetColumn(0, 1, 4)
+		CassetteDiv.SetColumn(1, 1, Layout_MAX_SIZE)
+
+		tx := CassetteDiv.AddText(0, 0, 2, 1, "Record/Replay")
+		tx.Align_h = 1
+
+		CassetteDiv.AddDropDown(0, 1, 1, 1, &source_dev.LLM_cassette, []string{"Off", "Record", "Replay"}, DeviceSettings_getCassetteModes())
+
+		info := CassetteDiv.AddText(1, 1, 1, 1, "LLM answers are saved into(or loaded from) apps/<app>/cassettes. Replay doesn't use network and fails when answer was not recorded.")
+		info.Cd = UI_GetPalette().GetGrey(0.5)
+		info.setMultilined()
+	}
+	y++
 	//y++ //space
 
 	//number of tries to fix error ....
@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"image/color"
 	"io"
 	"net/http"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -47,6 +49,29 @@ type DeviceSettings struct {
 	Image_model    string
 
 	STT_provider string
+
+	//used in order, when main provider fails
+	Tools_fallbacks []DeviceSettingsLLMFallback
+	Chat_fallbacks  []DeviceSettingsLLMFallback
+	Code_fallbacks  []DeviceSettingsLLMFallback
+
+	//USD, 0 = no limit
+	Budget_daily   float64
+	Budget_monthly float64
+	Budget_apps    []DeviceSettingsAppBudget
+
+	LLM_cassette string //"", "record", "replay"
+}
+
+type DeviceSettingsAppBudget struct {
+	App     string
+	Monthly float64
+}
+
+type DeviceSettingsLLMFallback struct {
+	Provider string
+	Smarter  bool
+	Model    string
 }
 
 func NewDeviceSettings(file string) (*DeviceSettings, error) {
@@ -79,6 +104,16 @@ func (st *DeviceSettings) GetPricingString(provider, model string) (pricing stri
 		if err == nil {
 			pricing, tooltip = st.GetPricingString(model)
 		}
+	case "anthropic":
+		st, err := NewLLMAnthropic("")
+		if err == nil {
+			pricing, tooltip = st.GetPricingString(model)
+		}
+	case "ollama":
+		st, err := NewLLMOllama("")
+		if err == nil {
+			pricing, tooltip = st.GetInfoString(model)
+		}
 	}
 
 	return
@@ -110,6 +145,16 @@ func (st *DeviceSettings) CheckProvider(provider string) error {
 		if err == nil {
 			return st.Check()
 		}
+	case "anthropic":
+		st, err := NewLLMAnthropic("")
+		if err == nil {
+			return st.Check()
+		}
+	case "ollama":
+		st, err := NewLLMOllama("")
+		if err == nil {
+			return st.Check()
+		}
 	case "llama.cpp":
 		return nil
 	case "whisper.cpp":
@@ -119,70 +164,128 @@ func (st *DeviceSettings) CheckProvider(provider string) error {
 	return fmt.Errorf("Unknown provider '%s'", provider)
 }
 
+// returns error when provider's model can't be used(missing tool calling, etc.)
+func (st *DeviceSettings) CheckModel(provider, model string) error {
+	switch strings.ToLower(provider) {
+	case "ollama":
+		st, err := NewLLMOllama("")
+		if err == nil {
+			return st.CheckModel(model)
+		}
+	}
+	return nil
+}
+
 func (st *DeviceSettings) UpdateModels() {
-	switch strings.ToLower(st.App_provider) {
+	st.App_model = DeviceSettings_getAppModel(st.App_provider, st.App_smarter, st.App_model)
+	st.Code_model = DeviceSettings_getCodeModel(st.Code_provider, st.Code_smarter, st.Code_model)
+
+	for i := range st.Tools_fallbacks {
+		it := &st.Tools_fallbacks[i]
+		it.Model = DeviceSettings_getAppModel(it.Provider, it.Smarter, it.Model)
+	}
+	for i := range st.Chat_fallbacks {
+		it := &st.Chat_fallbacks[i]
+		it.Model = DeviceSettings_getAppModel(it.Provider, it.Smarter, it.Model)
+	}
+	for i := range st.Code_fallbacks {
+		it := &st.Code_fallbacks[i]
+		it.Model = DeviceSettings_getCodeModel(it.Provider, it.Smarter, it.Model)
+	}
+}
+
+func DeviceSettings_getAppModel(provider string, smarter bool, model string) string {
+	switch strings.ToLower(provider) {
 	case "xai":
-		st.App_model = "grok-3-mini"
-		if st.App_smarter {
-			st.App_model = "grok-4"
+		model = "grok-3-mini"
+		if smarter {
+			model = "grok-4"
 		}
 
 	case "mistral":
-		st.App_model = "mistral-small-latest"
-		if st.App_smarter {
-			st.App_model = "mistral-large-latest"
+		model = "mistral-small-latest"
+		if smarter {
+			model = "mistral-large-latest"
 		}
 
 	case "openai":
-		st.App_model = "gpt-4.1-mini"
-		if st.App_smarter {
-			st.App_model = "o4-mini"
+		model = "gpt-4.1-mini"
+		if smarter {
+			model = "o4-mini"
 		}
 
 	case "groq":
-		st.App_model = "openai/gpt-oss-20b"
-		if st.App_smarter {
-			st.App_model = "openai/gpt-oss-120b"
+		model = "openai/gpt-oss-20b"
+		if smarter {
+			model = "openai/gpt-oss-120b"
+		}
+
+	case "anthropic":
+		model = "claude-haiku-4-5"
+		if smarter {
+			model = "claude-sonnet-4-5"
+		}
+
+	case "ollama":
+		oll, err := NewLLMOllama("")
+		if err == nil {
+			model = oll.GetDefaultModel(model)
 		}
 
 	case "llama.cpp":
-		st.App_model = "" //....
+		model = "" //....
 
 	}
+	return model
+}
 
-	switch strings.ToLower(st.Code_provider) {
+func DeviceSettings_getCodeModel(provider string, smarter bool, model string) string {
+	switch strings.ToLower(provider) {
 	case "xai":
-		st.Code_model = "grok-3-mini"
-		if st.Code_smarter {
-			st.Code_model = "grok-code-fast-1"
+		model = "grok-3-mini"
+		if smarter {
+			model = "grok-code-fast-1"
 		}
 
 	case "mistral":
-		st.Code_model = "devstral-small-latest"
-		if st.Code_smarter {
-			st.Code_model = "codestral-latest"
+		model = "devstral-small-latest"
+		if smarter {
+			model = "codestral-latest"
 		}
 
 	case "openai":
-		st.Code_model = "gpt-4.1-mini"
-		if st.Code_smarter {
-			st.Code_model = "o4-mini"
+		model = "gpt-4.1-mini"
+		if smarter {
+			model = "o4-mini"
 		}
 
 	case "groq":
-		st.Code_model = "qwen/qwen3-32b"
-		if st.Code_smarter {
-			st.Code_model = "openai/gpt-oss-120b"
+		model = "qwen/qwen3-32b"
+		if smarter {
+			model = "openai/gpt-oss-120b"
+		}
+
+	case "anthropic":
+		model = "claude-sonnet-4-5"
+		if smarter {
+			model = "claude-opus-4-1"
+		}
+
+	case "ollama":
+		oll, err := NewLLMOllama("")
+		if err == nil {
+			model = oll.GetDefaultModel(model)
 		}
 
 	case "llama.cpp":
-		st.Code_model = "" //....
+		model = "" //....
 
 	}
+	return model
 }
 
 func DeviceSettings_getAppProviders() []string {
-	return []string{"", "xAI", "Mistral", "OpenAI", "Groq", "Llama.cpp"}
+	return []string{"", "xAI", "Mistral", "OpenAI", "Groq", "Anthropic", "Ollama", "Llama.cpp"}
 }
 func DeviceSettings_getImageProviders() []string {
 	return []string{"", "xAI", "OpenAI"}
@@ -190,6 +293,9 @@ func DeviceSettings_getImageProviders() []string {
 func DeviceSettings_getSTTProviders() []string {
 	return []string{"", "Whisper.cpp", "OpenAI"}
 }
+func DeviceSettings_getCassetteModes() []string {
+	return []string{"", "record", "replay"}
+}
 
 func (st *DeviceSettings) BuildProvider(ChatDiv *UI, provider string, caller *ToolCaller) {
 	ChatDia := ChatDiv.AddDialog(provider + "_settings")
@@ -205,6 +311,10 @@ func (st *DeviceSettings) BuildProvider(ChatDiv *UI, provider string, caller *To
 		ChatDia.UI.AddTool(0, 0, 1, 1, "groq", (&ShowLLMGroqSettings{}).run, caller)
 	case "openai":
 		ChatDia.UI.AddTool(0, 0, 1, 1, "openai", (&ShowLLMOpenAISettings{}).run, caller)
+	case "anthropic":
+		ChatDia.UI.AddTool(0, 0, 1, 1, "anthropic", (&ShowLLMAnthropicSettings{}).run, caller)
+	case "ollama":
+		ChatDia.UI.AddTool(0, 0, 1, 1, "ollama", (&ShowLLMOllamaSettings{}).run, caller)
 	case "llama.cpp":
 		ChatDia.UI.AddTool(0, 0, 1, 1, "llama.cpp", (&ShowLLMLlamacppSettings{}).run, caller)
 	case "whisper.cpp":
@@ -232,6 +342,98 @@ func (st *DeviceSettings) BuildProvider(ChatDiv *UI, provider string, caller *To
 	}
 }
 
+func (st *DeviceSettings) BuildModel(ChatDiv *UI, provider string, model *string, smarter *bool) {
+	if strings.ToLower(provider) == "ollama" {
+		//installed models
+		oll, err := NewLLMOllama("")
+		if err == nil {
+			names := oll.GetModelNames()
+			ChatDiv.AddDropDown(0, 2, 1, 1, model, names, names)
+		}
+	} else {
+		smarterSw := ChatDiv.AddSwitch(0, 2, 1, 1, "Smarter", smarter)
+		smarterSw.layout.Enable = (provider != "")
+	}
+
+	pricing, tooltip := st.GetPricingString(provider, *model)
+	mdl := ChatDiv.AddText(1, 2, 1, 1, *model+fmt.Sprintf(" (<i>%s</i>)", pricing))
+	mdl.layout.Tooltip = tooltip
+
+	modelErr := st.CheckModel(provider, *model)
+	if modelErr != nil {
+		mdl.Cd = UI_GetPalette().E
+		errTx := ChatDiv.AddText(1, 3, 1, 1, modelErr.Error())
+		errTx.Cd = UI_GetPalette().E
+	}
+}
+
+// editable list of fallback providers
+func (st *DeviceSettings) BuildFallbacks(Div *UI, label string, fallbacks *[]DeviceSettingsLLMFallback) {
+	Div.SetColumn(0, 1, 4)
+	Div.SetColumn(1, 1, 4)
+	Div.SetColumn(2, 1, Layout_MAX_SIZE)
+	Div.SetColumn(3, 1, 2)
+	Div.SetColumn(4, 1, 2)
+
+	tx := Div.AddText(0, 0, 3, 1, label)
+	tx.Cd = UI_GetPalette().GetGrey(0.5)
+
+	AddBt := Div.AddButton(3, 0, 2, 1, "Add")
+	AddBt.Background = 0.5
+	AddBt.layout.Tooltip = "Add provider, which is used when previous ones fail"
+	AddBt.clicked = func() error {
+		*fallbacks = append(*fallbacks, DeviceSettingsLLMFallback{})
+		return nil
+	}
+
+	providers := DeviceSettings_getAppProviders()
+	y := 1
+	for i := range *fallbacks {
+		it := &(*fallbacks)[i]
+
+		Div.AddDropDown(0, y, 1, 1, &it.Provider, providers, providers)
+
+		if strings.ToLower(it.Provider) == "ollama" {
+			oll, err := NewLLMOllama("")
+			if err == nil {
+				names := oll.GetModelNames()
+				Div.AddDropDown(1, y, 1, 1, &it.Model, names, names)
+			}
+		} else {
+			smarterSw := Div.AddSwitch(1, y, 1, 1, "Smarter", &it.Smarter)
+			smarterSw.layout.Enable = (it.Provider != "")
+		}
+
+		mdl := Div.AddText(2, y, 1, 1, it.Model)
+		providerErr := st.CheckProvider(it.Provider)
+		if providerErr == nil {
+			providerErr = st.CheckModel(it.Provider, it.Model)
+		}
+		if providerErr != nil {
+			mdl.Cd = UI_GetPalette().E
+			mdl.layout.Tooltip = providerErr.Error()
+		}
+
+		UpBt := Div.AddButton(3, y, 1, 1, "Up")
+		UpBt.Background = 0.5
+		UpBt.layout.Enable = (i > 0)
+		UpBt.clicked = func() error {
+			(*fallbacks)[i-1], (*fallbacks)[i] = (*fallbacks)[i], (*fallbacks)[i-1]
+			return nil
+		}
+
+		RemoveBt := Div.AddButton(4, y, 1, 1, "X")
+		RemoveBt.Background = 0.5
+		RemoveBt.layout.Tooltip = "Remove"
+		RemoveBt.clicked = func() error {
+			*fallbacks = slices.Delete(*fallbacks, i, i+1)
+			return nil
+		}
+
+		y++
+	}
+}
+
 func (st *DeviceSettings) GetPalette() *DeviceSettingsPalette {
 	switch st.Theme {
 	case "light":
@@ -310,6 +512,290 @@ func (wsp *LLMLlamacpp) GetUrlHealth() string {
 	return fmt.Sprintf("%s:%d/health", wsp.Address, wsp.Port)
 }
 
+type LLMOllamaModel struct {
+	Name               string
+	Size               int64 //bytes
+	Parameter_size     string
+	Quantization_level string
+
+	Capabilities   []string //"completion", "tools", "vision", "thinking", "embedding"
+	Context_length int
+
+	Loaded bool
+}
+
+// Ollama settings. Models are read from Ollama server.
+type LLMOllama struct {
+	lock sync.Mutex
+
+	pull_model string
+	last_error string
+
+	Address    string
+	Port       int
+	OpenAI_api bool //use OpenAI-compatible endpoint(/v1) instead of native(/api/chat)
+
+	Models []*LLMOllamaModel
+
+	Stats []LLMMsgStats
+}
+
+func NewLLMOllama(file string) (*LLMOllama, error) {
+	st := &LLMOllama{}
+	return LoadFile(file, "LLMOllama", "json", st, true)
+}
+
+func (oll *LLMOllama) Check() error {
+	if oll.Address == "" {
+		return fmt.Errorf("Ollama address is empty")
+	}
+
+	return nil
+}
+
+func (oll *LLMOllama) GetUrl(path string) string {
+	return fmt.Sprintf("%s:%d/api/%s", oll.Address, oll.Port, path)
+}
+
+func (oll *LLMOllama) FindModel(name string) *LLMOllamaModel {
+	name = strings.ToLower(name)
+	for _, model := range oll.Models {
+		if strings.ToLower(model.Name) == name {
+			return model
+		}
+	}
+	return nil
+}
+
+func (model *LLMOllamaModel) HasCapability(capability string) bool {
+	return slices.Contains(model.Capabilities, capability)
+}
+
+// returns error when model can't be used by apps(not installed, no tool calling)
+func (oll *LLMOllama) CheckModel(name string) error {
+	if name == "" {
+		return fmt.Errorf("No model selected")
+	}
+	model := oll.FindModel(name)
+	if model == nil {
+		return fmt.Errorf("Model '%s' is not installed", name)
+	}
+	if !model.HasCapability("tools") {
+		return fmt.Errorf("Model '%s' doesn't support tool calling", name)
+	}
+	return nil
+}
+
+func (oll *LLMOllama) GetModelNames() []string {
+	var names []string
+	for _, model := range oll.Models {
+		if model.HasCapability("embedding") && !model.HasCapability("completion") {
+			continue
+		}
+		names = append(names, model.Name)
+	}
+	return names
+}
+
+// returns model, if it's installed, otherwise first model with tool calling
+func (oll *LLMOllama) GetDefaultModel(name string) string {
+	if oll.FindModel(name) != nil {
+		return name
+	}
+	for _, model := range oll.Models {
+		if model.HasCapability("tools") {
+			return model.Name
+		}
+	}
+	return ""
+}
+
+func (oll *LLMOllama) GetInfoString(name string) (string, string) {
+	model := oll.FindModel(name)
+	if model == nil {
+		return fmt.Sprintf("model %s not found", name), ""
+	}
+
+	return fmt.Sprintf("%s, %dK context", model.Parameter_size, model.Context_length/1024),
+		"Capabilities: " + strings.Join(model.Capabilities, ", ")
+}
+
+func (oll *LLMOllama) call(method string, path string, props interface{}) ([]byte, error) {
+	oll.lock.Lock()
+	defer oll.lock.Unlock()
+
+	var body io.Reader
+	if props != nil {
+		js, err := json.Marshal(props)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, oll.GetUrl(path), body)
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest() failed: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Do() failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ReadAll() failed: %w", err)
+	}
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("statusCode %d != 200, response: %s", res.StatusCode, resBody)
+	}
+
+	return resBody, nil
+}
+
+func (oll *LLMOllama) GetVersion() (string, error) {
+	js, err := oll.call(http.MethodGet, "version", nil)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Version string
+	}
+	err = json.Unmarshal(js, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.Version, nil
+}
+
+// reads installed models, their capabilities and which are loaded in memory
+func (oll *LLMOllama) ReloadModels() error {
+	js, err := oll.call(http.MethodGet, "tags", nil)
+	if err != nil {
+		return err
+	}
+	var tags struct {
+		Models []struct {
+			Name    string
+			Size    int64
+			Details struct {
+				Parameter_size     string
+				Quantization_level string
+			}
+		}
+	}
+	err = json.Unmarshal(js, &tags)
+	if err != nil {
+		return err
+	}
+
+	var models []*LLMOllamaModel
+	for _, it := range tags.Models {
+		model := &LLMOllamaModel{Name: it.Name, Size: it.Size, Parameter_size: it.Details.Parameter_size, Quantization_level: it.Details.Quantization_level}
+
+		//capabilities
+		type Show struct {
+			Model string `json:"model"`
+		}
+		js, err := oll.call(http.MethodPost, "show", Show{Model: it.Name})
+		if err != nil {
+			return err
+		}
+		var show struct {
+			Capabilities []string
+			Model_info   map[string]interface{}
+		}
+		err = json.Unmarshal(js, &show)
+		if err != nil {
+			return err
+		}
+		model.Capabilities = show.Capabilities
+		for key, val := range show.Model_info {
+			if strings.HasSuffix(key, ".context_length") {
+				ctx, ok := val.(float64)
+				if ok {
+					model.Context_length = int(ctx)
+				}
+			}
+		}
+
+		models = append(models, model)
+	}
+
+	//loaded
+	js, err = oll.call(http.MethodGet, "ps", nil)
+	if err != nil {
+		return err
+	}
+	var ps struct {
+		Models []struct {
+			Name string
+		}
+	}
+	err = json.Unmarshal(js, &ps)
+	if err != nil {
+		return err
+	}
+	for _, it := range ps.Models {
+		for _, model := range models {
+			if model.Name == it.Name {
+				model.Loaded = true
+			}
+		}
+	}
+
+	oll.Models = models
+	return nil
+}
+
+// downloads model into Ollama server. Can take long time.
+func (oll *LLMOllama) PullModel(name string) error {
+	type Pull struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	js, err := oll.call(http.MethodPost, "pull", Pull{Model: name, Stream: false})
+	if err != nil {
+		return err
+	}
+	var out struct {
+		Status string
+		Error  string
+	}
+	err = json.Unmarshal(js, &out)
+	if err != nil {
+		return err
+	}
+	if out.Error != "" {
+		return fmt.Errorf("%s", out.Error)
+	}
+
+	return oll.ReloadModels()
+}
+
+// removes model from memory
+func (oll *LLMOllama) UnloadModel(name string) error {
+	type Unload struct {
+		Model      string `json:"model"`
+		Keep_alive int    `json:"keep_alive"`
+	}
+	_, err := oll.call(http.MethodPost, "generate", Unload{Model: name, Keep_alive: 0})
+	if err != nil {
+		return err
+	}
+
+	model := oll.FindModel(name)
+	if model != nil {
+		model.Loaded = false
+	}
+	return nil
+}
+
 type LLMMistralLanguageModel struct {
 	Id               string
 	Created          int64
@@ -806,6 +1292,130 @@ func (model *LLMOpenaiLanguageModel) GetTextPrice(in, reason, cached, out int) (
 	return float64(in) * Input_price, float64(reason) * Reason_price, float64(cached) * Cached_price, float64(out) * Output_price
 }
 
+type LLMAnthropicLanguageModel struct {
+	Id               string
+	Created          int64
+	Version          string
+	Input_modalities []string //"text", "image"
+
+	Max_output_tokens int
+
+	Prompt_text_token_price             int //USD cents per million token
+	Cached_prompt_text_token_price      int //cache read
+	Cache_write_prompt_text_token_price int
+	Completion_text_token_price         int
+
+	Aliases []string
+}
+
+// Anthropic LLM settings.
+type LLMAnthropic struct {
+	Provider      string
+	Anthropic_url string
+	DevUrl        string
+	API_key       string
+
+	LanguageModels []*LLMAnthropicLanguageModel
+
+	Stats []LLMMsgStats
+}
+
+func NewLLMAnthropic(file string) (*LLMAnthropic, error) {
+	ant := &LLMAnthropic{}
+	var err error
+	ant, err = LoadFile(file, "LLMAnthropic", "json", ant, true)
+	if err == nil {
+		ant.ReloadModels()
+	}
+	return ant, err
+}
+
+func (ant *LLMAnthropic) Check() error {
+
+	if ant.API_key == "" {
+		return fmt.Errorf("%s API key is empty", ant.Provider)
+	}
+
+	return nil
+}
+
+func (ant *LLMAnthropic) FindModel(name string) *LLMAnthropicLanguageModel {
+	name = strings.ToLower(name)
+
+	for _, model := range ant.LanguageModels {
+		if strings.ToLower(model.Id) == name {
+			return model
+		}
+		for _, alias := range model.Aliases {
+			if strings.ToLower(alias) == name {
+				return model
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ant *LLMAnthropic) ReloadModels() error {
+
+	//reset
+	ant.LanguageModels = nil
+
+	ant.LanguageModels = append(ant.LanguageModels, &LLMAnthropicLanguageModel{
+		Id:                                  "claude-haiku-4-5",
+		Input_modalities:                    []string{"text", "image"},
+		Max_output_tokens:                   64000,
+		Prompt_text_token_price:             10000,
+		Cached_prompt_text_token_price:      1000,
+		Cache_write_prompt_text_token_price: 12500,
+		Completion_text_token_price:         50000,
+		Aliases:                             []string{"claude-haiku-4-5-20251001"},
+	})
+
+	ant.LanguageModels = append(ant.LanguageModels, &LLMAnthropicLanguageModel{
+		Id:                                  "claude-sonnet-4-5",
+		Input_modalities:                    []string{"text", "image"},
+		Max_output_tokens:                   64000,
+		Prompt_text_token_price:             30000,
+		Cached_prompt_text_token_price:      3000,
+		Cache_write_prompt_text_token_price: 37500,
+		Completion_text_token_price:         150000,
+		Aliases:                             []string{"claude-sonnet-4-5-20250929"},
+	})
+
+	ant.LanguageModels = append(ant.LanguageModels, &LLMAnthropicLanguageModel{
+		Id:                                  "claude-opus-4-1",
+		Input_modalities:                    []string{"text", "image"},
+		Max_output_tokens:                   32000,
+		Prompt_text_token_price:             150000,
+		Cached_prompt_text_token_price:      15000,
+		Cache_write_prompt_text_token_price: 187500,
+		Completion_text_token_price:         750000,
+		Aliases:                             []string{"claude-opus-4-1-20250805"},
+	})
+
+	return nil
+}
+
+func (ant *LLMAnthropic) GetPricingString(model string) (string, string) {
+	model = strings.ToLower(model)
+
+	convert_to_dolars := float64(10000)
+
+	lang := ant.FindModel(model)
+	if lang != nil {
+		//in, cache read, cache write, out
+		return fmt.Sprintf("$%.2f/$%.2f/$%.2f/$%.2f",
+				float64(lang.Prompt_text_token_price)/convert_to_dolars,
+				float64(lang.Cached_prompt_text_token_price)/convert_to_dolars,
+				float64(lang.Cache_write_prompt_text_token_price)/convert_to_dolars,
+				float64(lang.Completion_text_token_price)/convert_to_dolars),
+			"Price of Input_text, Input_cache_read, Input_cache_write, Output(per 1M tokens)"
+	}
+
+	return fmt.Sprintf("model %s not found", model), ""
+}
+
 // Whisper.cpp settings.
 type LLMWhispercpp struct {
 	lock sync.Mutex
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -49,6 +50,11 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 		Line int
 		Col  int
 		Msg  string
+		Test bool
+
+		Runtime bool
+		Params  string
+		Stack   string
 	}
 	type SdkToolsMessages struct {
 		Message   string
@@ -58,6 +64,7 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 		Code   string
 		Errors []SdkToolsCodeError
 		Usage  LLMMsgUsage
+		Reason string
 	}
 
 	type ToolsPromptTYPE int
@@ -65,8 +72,15 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 		ToolsPrompt_STORAGE ToolsPromptTYPE = iota
 		ToolsPrompt_FUNCTION
 		ToolsPrompt_TOOL
+		ToolsPrompt_START
+		ToolsPrompt_TEST
 	)
 
+	type SdkToolsPromptDep struct {
+		Prompt string
+		Symbol string
+	}
+
 	type SdkToolsPrompt struct {
 		Type   ToolsPromptTYPE
 		Prompt string //LLM input
@@ -80,6 +94,8 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 		//from code
 		Name   string
 		Schema json.RawMessage
+
+		Deps []SdkToolsPromptDep
 		//Errors []SdkToolsCodeError
 
 		//Usage LLMMsgUsage
@@ -89,6 +105,31 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 		Message   string
 		StartTime int64
 	}
+	type SdkToolsDenial struct {
+		Time    int64
+		Command string
+		Detail  string
+	}
+	type SdkToolsCrashFrame struct {
+		Func   string
+		File   string
+		Line   int
+		Prompt string
+	}
+	type SdkToolsCrash struct {
+		Time   int64
+		Uptime float64
+		Error  string
+		Frames []SdkToolsCrashFrame
+		Prompt string
+	}
+	type SdkToolsRuntimeError struct {
+		Time   int64
+		Tool   string
+		Params string
+		Error  string
+		Frames []SdkToolsCrashFrame
+	}
 	type SdkToolsPrompts struct {
 		Changed bool
 
@@ -99,6 +140,13 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 		StartPrompt string
 
 		Generating_items []*SdkToolsPromptGen
+
+		Denials []SdkToolsDenial
+		Crashes []SdkToolsCrash
+
+		SandboxWarning string
+
+		RuntimeErrors []SdkToolsRuntimeError
 	}
 	var sdk_app SdkToolsPrompts
 	appJs, err := callFuncGetToolData(app.Name)
@@ -315,7 +363,7 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 				for _, prompt := range sdk_app.Prompts {
 					for _, it := range prompt.CodeVersions {
 						time_sum += it.Usage.DTime
-						price_sum += it.Usage.Prompt_price + it.Usage.Input_cached_price + it.Usage.Completion_price + it.Usage.Reasoning_price + it.Usage.Sources_price
+						price_sum += it.Usage.Prompt_price + it.Usage.Input_cached_price + it.Usage.Input_cache_write_price + it.Usage.Completion_price + it.Usage.Reasoning_price + it.Usage.Sources_price
 					}
 				}
 				tx := FooterRightDiv.AddText(0, 1, 1, 1, fmt.Sprintf("<i>Total: $%.4f, %s", price_sum, SdkGetDTime(time_sum)))
@@ -336,11 +384,114 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 				}
 			}
 			if n_errors > 0 {
-				tx := FooterDiv.AddText(0, 1, 2, 1, fmt.Sprintf("%d file(s) has compilation error(s)", n_errors))
+				tx := FooterDiv.AddText(0, 1, 2, 1, fmt.Sprintf("%d file(s) has compilation error(s) or failed test(s)", n_errors))
 				tx.Cd = UI_GetPalette().E
 			}
 		}
 
+		//Permissions
+		if len(sdk_app.Denials) > 0 {
+			var calls []string
+			for _, it := range sdk_app.Denials {
+				call := it.Command
+				if it.Detail != "" {
+					call += "(" + it.Detail + ")"
+				}
+				if !slices.Contains(calls, call) {
+					calls = append(calls, call)
+				}
+			}
+
+			tx := FooterDiv.AddText(0, 2, 2, 1, fmt.Sprintf("Blocked by #permissions or sandbox: %s", strings.Join(calls, ", ")))
+			tx.Cd = UI_GetPalette().E
+			tx.layout.Tooltip = fmt.Sprintf("Last blocked call: %s", SdkGetDateTime(sdk_app.Denials[len(sdk_app.Denials)-1].Time))
+		}
+
+		//Sandbox
+		if sdk_app.SandboxWarning != "" {
+			tx := FooterDiv.AddText(0, 5, 2, 1, fmt.Sprintf("Sandbox: %s", sdk_app.SandboxWarning))
+			tx.Cd = UI_GetPalette().E
+			tx.layout.Tooltip = "App has 'network: no' in #permissions, but runs with SKYALT_SANDBOX_WEAK_NETWORK=1"
+		}
+
+		//Crashes
+		if len(sdk_app.Crashes) > 0 {
+			last := sdk_app.Crashes[len(sdk_app.Crashes)-1]
+
+			CrashDiv := FooterDiv.AddLayout(0, 3, 2, 1)
+			CrashDiv.SetColumn(0, 1, Layout_MAX_SIZE)
+			CrashDiv.SetColumn(1, 3, 3)
+
+			str := fmt.Sprintf("App crashed %dx, last: %s", len(sdk_app.Crashes), last.Error)
+			if last.Prompt != "" {
+				str += fmt.Sprintf(" in '%s'", last.Prompt)
+			}
+			tx := CrashDiv.AddText(0, 0, 1, 1, str)
+			tx.Cd = UI_GetPalette().E
+			tx.layout.Tooltip = fmt.Sprintf("Last crash: %s", SdkGetDateTime(last.Time))
+
+			CrashDia := CrashDiv.AddDialog("crashes")
+			CrashDia.UI.SetColumn(0, 5, 30)
+			y := 0
+			for i := len(sdk_app.Crashes) - 1; i >= 0; i-- { //newest first
+				crash := sdk_app.Crashes[i]
+
+				tx := CrashDia.UI.AddText(0, y, 1, 1, fmt.Sprintf("<b>%s</b>, after %s", SdkGetDateTime(crash.Time), SdkGetDTime(crash.Uptime)))
+				tx.Linewrapping = false
+				y++
+
+				tx = CrashDia.UI.AddText(0, y, 1, 1, crash.Error)
+				tx.Cd = UI_GetPalette().E
+				y++
+
+				for _, fr := range crash.Frames {
+					tx = CrashDia.UI.AddText(0, y, 1, 1, fmt.Sprintf("    %s:%d %s() - prompt '%s'", fr.File, fr.Line, fr.Func, fr.Prompt))
+					tx.Linewrapping = false
+					y++
+				}
+				if len(crash.Frames) == 0 {
+					tx = CrashDia.UI.AddText(0, y, 1, 1, "    no stack trace in app's code")
+					tx.Cd = UI_GetPalette().GetGrey(0.5)
+					y++
+				}
+
+				CrashDia.UI.AddDivider(0, y, 1, 1, true)
+				y++
+			}
+
+			CrashBt := CrashDiv.AddButton(1, 0, 1, 1, "Crashes")
+			CrashBt.Background = 0.5
+			CrashBt.layout.Tooltip = "Show crash history"
+			CrashBt.clicked = func() error {
+				CrashDia.OpenCentered(caller)
+				return nil
+			}
+		}
+
+		//Runtime errors
+		if len(sdk_app.RuntimeErrors) > 0 && !isGenerating {
+			RuntimeDiv := FooterDiv.AddLayout(0, 4, 2, 1)
+			RuntimeDiv.SetColumn(0, 1, Layout_MAX_SIZE)
+			RuntimeDiv.SetColumn(1, 3, 3)
+
+			for i, rerr := range sdk_app.RuntimeErrors {
+				tx := RuntimeDiv.AddText(0, i, 1, 1, fmt.Sprintf("'%s' failed at runtime: %s", rerr.Tool, rerr.Error))
+				tx.Cd = UI_GetPalette().E
+				tx.Linewrapping = false
+				tx.layout.Tooltip = fmt.Sprintf("%s\nParams: %s", SdkGetDateTime(rerr.Time), rerr.Params)
+
+				FixBt := RuntimeDiv.AddButton(1, i, 1, 1, "Fix")
+				FixBt.layout.Tooltip = "Ask code model to fix it, then re-run same call"
+				FixBt.clicked = func() error {
+					caller.SetMsgName(generate_msg_uid)
+					callFuncFixRuntimeError(app.Name, rerr.Tool, caller)
+
+					app.Dev.SideFile_version = -1 //reset
+					return nil
+				}
+			}
+		}
+
 		/*FooterDiv.SetRow(1, 10, 10)
 		FooterDiv.AddMediaPath(0, 1, 1, 1, "vid.mkv")
 
@@ -447,6 +598,9 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 					case ToolsPrompt_TOOL:
 						ic.Path = "resources/tools.png"
 						ic.Margin = 0.2
+					case ToolsPrompt_TEST:
+						ic.Path = "resources/target.png"
+						ic.Margin = 0.2
 					}
 					icons = append(icons, ic)
 				}
@@ -514,6 +668,7 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 					TabsDiv.SetColumn(0, 2, 3)
 					TabsDiv.SetColumn(1, 2, 3)
 					TabsDiv.SetColumn(2, 2, 3)
+					TabsDiv.SetColumn(3, 2, 3)
 					TabsDiv.Back_cd = UI_GetPalette().GetGrey(0.1)
 					//TabsDiv.Border_cd = UI_GetPalette().P
 					TabsDiv.Back_rounding = true
@@ -545,11 +700,21 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 						return nil
 					}
 
+					DepsBt := TabsDiv.AddButton(3, 0, 1, 1, "Deps")
+					DepsBt.Background = 0.0
+					DepsBt.layout.Tooltip = "Storage types and functions used by code"
+					DepsBt.clicked = func() error {
+						app.Dev.SideMode = "deps"
+						return nil
+					}
+
 					switch app.Dev.SideMode {
 					case "schema":
 						SchemaBt.Background = 1
 					case "msg":
 						MsgBt.Background = 1
+					case "deps":
+						DepsBt.Background = 1
 					default: //"code"
 						CodeBt.Background = 1
 					}
@@ -602,6 +767,39 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 						tx.Align_v = 0
 						tx.layout.Back_cd = codeBackCd
 
+					case "deps":
+						depsStr := "<b>Uses:</b>\n"
+						for _, dep := range side_prompt.Deps {
+							depsStr += fmt.Sprintf("%s.go: %s\n", dep.Prompt, dep.Symbol)
+						}
+						if len(side_prompt.Deps) == 0 {
+							depsStr += "<i>nothing</i>\n"
+						}
+
+						depsStr += "\n<b>Used by:</b>\n"
+						n_used := 0
+						for _, prompt := range sdk_app.Prompts {
+							var symbols []string
+							for _, dep := range prompt.Deps {
+								if dep.Prompt == side_prompt.Name {
+									symbols = append(symbols, dep.Symbol)
+								}
+							}
+							if len(symbols) > 0 {
+								depsStr += fmt.Sprintf("%s.go: %s\n", prompt.Name, strings.Join(symbols, ", "))
+								n_used++
+							}
+						}
+						if n_used == 0 {
+							depsStr += "<i>nothing</i>\n"
+						}
+
+						tx := SideDiv.AddText(0, 1, 1, 1, depsStr)
+						tx.setMultilined()
+						tx.Linewrapping = false
+						tx.Align_v = 0
+						tx.layout.Back_cd = codeBackCd
+
 					default: //"code"
 						code := side_promptCode.Code
 						if len(side_promptCode.Errors) > 0 {
@@ -632,11 +830,11 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 
 						//Price
 						{
-							tx := StatsDiv.AddText(0, 0, 1, 1, fmt.Sprintf("<i>%s, $%f", side_promptCode.Usage.Model, side_promptCode.Usage.Prompt_price+side_promptCode.Usage.Input_cached_price+side_promptCode.Usage.Completion_price+side_promptCode.Usage.Reasoning_price))
+							tx := StatsDiv.AddText(0, 0, 1, 1, fmt.Sprintf("<i>%s, $%f", side_promptCode.Usage.Model, side_promptCode.Usage.Prompt_price+side_promptCode.Usage.Input_cached_price+side_promptCode.Usage.Input_cache_write_price+side_promptCode.Usage.Completion_price+side_promptCode.Usage.Reasoning_price))
 							tx.Align_h = 2
 
 							in := side_promptCode.Usage.Prompt_price
-							inCached := side_promptCode.Usage.Input_cached_price
+							inCached := side_promptCode.Usage.Input_cached_price + side_promptCode.Usage.Input_cache_write_price
 							out := side_promptCode.Usage.Completion_price + side_promptCode.Usage.Reasoning_price
 							sources := side_promptCode.Usage.Sources_price
 							tx.layout.Tooltip = fmt.Sprintf("<b>%s</b>\n%s\nTime to first token: %s sec\nTime: %s\n%s tokens/sec\nTotal: $%s\n- Input: $%s(%d toks)\n- Cached: $%s(%d toks)\n- Output: $%s(%d+%d toks)\n- Sources: $%s(%d links)",
@@ -657,6 +855,12 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 								side_promptCode.Usage.Num_sources_used)
 						}
 
+						//Reason
+						if side_promptCode.Reason != "" {
+							tx := StatsDiv.AddText(0, 1, 2, 1, "<i>Fix of "+side_promptCode.Reason)
+							tx.Linewrapping = false
+						}
+
 						//Code model picker
 						{
 							CodeDia := StatsDiv.AddDialog("code_picker")
@@ -683,7 +887,17 @@ func (st *ShowDev) run(caller *ToolCaller, ui *UI) error {
 							ErrsDiv.ScrollH.Narrow = true
 							ErrsDiv.SetColumnFromSub(0, 1, Layout_MAX_SIZE, true)
 							for i, er := range side_promptCode.Errors {
-								tx := ErrsDiv.AddText(0, i, 1, 1, fmt.Sprintf("%d:%d: %s", er.Line, er.Col, er.Msg))
+								str := fmt.Sprintf("%d:%d: %s", er.Line, er.Col, er.Msg)
+								if er.Test {
+									str = "Test failed: " + er.Msg
+								}
+								if er.Runtime {
+									str = "Runtime error: " + er.Msg
+									if er.Params != "" {
+										str += ", params: " + er.Params
+									}
+								}
+								tx := ErrsDiv.AddText(0, i, 1, 1, str)
 								tx.Linewrapping = false
 								tx.Cd = UI_GetPalette().E
 							}
@@ -779,8 +993,12 @@ func (st *ShowDev) buildDocumentation(ui *UI, caller *ToolCaller) {
 			greyStr+"Describe background function.</rgba>\n\n"+
 			"#tool <name>\n"+
 			greyStr+"Describe app's feature.</rgba>\n\n"+
+			"#test <tool name>\n"+
+			greyStr+"Lines 'Input: <json>' and 'Expected: <json with Out_ attributes>' or describe expected behavior. Tests run after compilation.</rgba>\n\n"+
 			"#start\n"+
-			greyStr+"Prompt with tool call, which is executed when new tab is created.</rgba>")
+			greyStr+"Prompt with tool call, which is executed when new tab is created.</rgba>\n\n"+
+			"#permissions\n"+
+			greyStr+"Lines 'commands: <router commands>', 'apps: <apps called by CallToolApp()>', 'llm: <chat, tools, transcribe>' and 'network: <yes/no>'(used by sandbox). Other calls are blocked.</rgba>")
 		y++
 		tx.setMultilined()
 	}
@@ -392,13 +392,18 @@ func (st *ShowRoot) run(caller *ToolCaller, ui *UI) error {
 		//Spendings
 		{
 			usageJs := callFuncGetLLMUsage()
+			spendings := callFuncGetLLMSpendings()
 			UsageDia := AppsDiv.AddDialog("usage")
-			st.buildUsage(&UsageDia.UI, usageJs)
+			st.buildUsage(&UsageDia.UI, usageJs, &spendings)
 
 			UsageBt := AppsDiv.AddButton(0, y, 1, 1, "$")
 			y++
 			UsageBt.Background = 0.25
 			UsageBt.layout.Tooltip = "Spendings"
+			if (spendings.Daily_limit > 0 && spendings.Daily_spent >= spendings.Daily_limit) || (spendings.Monthly_limit > 0 && spendings.Monthly_spent >= spendings.Monthly_limit) {
+				UsageBt.Cd = UI_GetPalette().E
+				UsageBt.layout.Tooltip = "Spendings - budget limit reached"
+			}
 			UsageBt.clicked = func() error {
 				UsageDia.OpenRelative(UsageBt.layout, caller)
 				return nil
@@ -1358,7 +1363,7 @@ func (st *ShowRoot) buildAbout(ui *UI) {
 	y++
 }
 
-func (st *ShowRoot) buildUsage(ui *UI, usageJs []byte) {
+func (st *ShowRoot) buildUsage(ui *UI, usageJs []byte, spendings *SdkLLMSpendings) {
 
 	var usages []LLMMsgUsage
 	err := json.Unmarshal(usageJs, &usages)
@@ -1372,11 +1377,18 @@ func (st *ShowRoot) buildUsage(ui *UI, usageJs []byte) {
 	//label
 	ui.AddTextLabel(0, 0, 1, 1, "Spendings")
 
+	//budget
+	{
+		ui.SetRowFromSub(1, 1, 15, true)
+		BudgetDiv := ui.AddLayout(0, 1, 1, 1)
+		st.buildBudget(BudgetDiv, spendings)
+	}
+
 	//spendings
 	total_price := 0.0
 	{
-		ui.SetRow(1, 1, 15) //ui.SetRowFromSub(1, 1, 15, true)
-		ListDiv := ui.AddLayout(0, 1, 1, 1)
+		ui.SetRow(2, 1, 15) //ui.SetRowFromSub(1, 1, 15, true)
+		ListDiv := ui.AddLayout(0, 2, 1, 1)
 		ListDiv.SetColumnFromSub(0, 1, 10, true)
 		ListDiv.SetColumnFromSub(1, 1, 10, true)
 		ListDiv.SetColumnFromSub(2, 1, 10, true)
@@ -1391,11 +1403,11 @@ func (st *ShowRoot) buildUsage(ui *UI, usageJs []byte) {
 			ListDiv.AddText(1, y, 1, 1, SdkGetDateTime(int64(usg.CreatedTimeSec)))
 			ListDiv.AddText(2, y, 1, 1, SdkGetDTime(usg.DTime))
 
-			price := (usg.Prompt_price + usg.Input_cached_price + usg.Completion_price + usg.Reasoning_price)
+			price := (usg.Prompt_price + usg.Input_cached_price + usg.Input_cache_write_price + usg.Completion_price + usg.Reasoning_price)
 			tx := ListDiv.AddText(3, y, 1, 1, fmt.Sprintf("$%f", price))
 
 			in := usg.Prompt_price
-			inCached := usg.Input_cached_price
+			inCached := usg.Input_cached_price + usg.Input_cache_write_price
 			out := usg.Completion_price + usg.Reasoning_price
 			sources := usg.Sources_price
 			tx.layout.Tooltip = fmt.Sprintf("<b>%s</b>\n%s\nTime to first token: %s sec\nTime: %s\n%s tokens/sec\nTotal: $%s\n- Input: $%s(%d toks)\n- Cached: $%s(%d toks)\n- Output: $%s(%d+%d toks)\n- Sources: $%s(%d links)",
@@ -1422,18 +1434,72 @@ func (st *ShowRoot) buildUsage(ui *UI, usageJs []byte) {
 	}
 
 	//space
-	ui.SetRow(2, 0.1, 0.1)
-	ui.AddDivider(0, 2, 1, 1, true)
+	ui.SetRow(3, 0.1, 0.1)
+	ui.AddDivider(0, 3, 1, 1, true)
 
 	//Sum
-	ui.AddText(0, 3, 1, 1, fmt.Sprintf("Total(%d): $%f", len(usages), total_price)).Align_h = 2
+	ui.AddText(0, 4, 1, 1, fmt.Sprintf("Total(%d): $%f", len(usages), total_price)).Align_h = 2
 
 	//Note
-	noteTx := ui.AddText(0, 4, 1, 1, "<i>numbers may not be accurate.")
+	noteTx := ui.AddText(0, 5, 1, 1, "<i>numbers may not be accurate.")
 	noteTx.Align_h = 1
 	noteTx.Cd = UI_GetPalette().GetGrey(0.5)
 }
 
+func (st *ShowRoot) buildBudget(ui *UI, spendings *SdkLLMSpendings) {
+	ui.SetColumn(0, 1, 8)
+	ui.SetColumn(1, 1, Layout_MAX_SIZE)
+	ui.SetColumn(2, 1, 3)
+
+	fnLimit := func(spent, limit float64) string {
+		if limit <= 0 {
+			return fmt.Sprintf("$%.4f", spent)
+		}
+		return fmt.Sprintf("$%.4f / $%.2f", spent, limit)
+	}
+	fnRow := func(y int, name string, spent, limit float64, calls int) {
+		ui.AddText(0, y, 1, 1, name)
+		tx := ui.AddText(1, y, 1, 1, fnLimit(spent, limit))
+		if limit > 0 && spent >= limit {
+			tx.Cd = UI_GetPalette().E
+			tx.layout.Tooltip = "Budget limit reached"
+		}
+		if calls > 0 {
+			ui.AddText(2, y, 1, 1, fmt.Sprintf("%dx", calls)).Align_h = 2
+		}
+	}
+
+	y := 0
+	fnRow(y, "Today", spendings.Daily_spent, spendings.Daily_limit, 0)
+	y++
+	fnRow(y, "This month", spendings.Monthly_spent, spendings.Monthly_limit, 0)
+	y++
+
+	if len(spendings.Apps) > 0 {
+		ui.AddText(0, y, 3, 1, "<b>Apps(this month)")
+		y++
+		for _, it := range spendings.Apps {
+			name := it.Name
+			if name == "" {
+				name = "<i>other"
+			}
+			fnRow(y, name, it.Spent, it.Limit, it.Calls)
+			y++
+		}
+	}
+
+	if len(spendings.Models) > 0 {
+		ui.AddText(0, y, 3, 1, "<b>Models(this month)")
+		y++
+		for _, it := range spendings.Models {
+			fnRow(y, it.Name, it.Spent, 0, it.Calls)
+			y++
+		}
+	}
+
+	ui.AddDivider(0, y, 3, 1, true)
+}
+
 func (st *ShowRoot) buildLog(ui *UI, logs []SdkLog, caller *ToolCaller) {
 	ui.SetColumnFromSub(0, 1, 30, true)
 
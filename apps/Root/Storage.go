@@ -559,7 +559,7 @@ func (msgs *ChatMsgs) GetTotalPrice(st_i, en_i int) (input, inCached, output, so
 	}
 	for i := st_i; i < en_i; i++ {
 		input += msgs.Messages[i].Usage.Prompt_price
-		inCached += msgs.Messages[i].Usage.Input_cached_price
+		inCached += msgs.Messages[i].Usage.Input_cached_price + msgs.Messages[i].Usage.Input_cache_write_price
 		output += msgs.Messages[i].Usage.Completion_price + msgs.Messages[i].Usage.Reasoning_price
 		sources += msgs.Messages[i].Usage.Sources_price
 	}
@@ -41,6 +41,17 @@ type ToolsApp struct {
 	Prompts ToolsPrompts
 
 	storage_changes int64
+
+	permissions_lock sync.Mutex
+	Permissions      *ToolsAppPermissions //snapshot from 'skyalt' file, taken when app was generated
+	Generated        bool                 //app had 'skyalt' file, so missing file doesn't widen permissions
+	denials          []ToolsAppDenial
+	sandbox_warning  string //sandbox can't block network, app runs anyway(SKYALT_SANDBOX_WEAK_NETWORK=1)
+
+	runtime_lock   sync.Mutex
+	runtime_errors []ToolsAppRuntimeError
+	runtime_calls  map[uint64]ToolsAppRuntimeCall //[msg_id]
+	failed_calls   []ToolsAppRuntimeCall          //connection failed, maybe crash
 }
 
 func NewToolsApp(appName string, router *AppsRouter) (*ToolsApp, error) {
@@ -56,6 +67,12 @@ func NewToolsApp(appName string, router *AppsRouter) (*ToolsApp, error) {
 		}
 	}
 
+	//generated by older version without snapshot, taken before app runs
+	if !app.Generated && Tools_IsFileExists(app.getPromptFilePath()) {
+		app.SnapshotPermissions()
+		app._save()
+	}
+
 	return app, nil
 }
 
@@ -102,6 +119,9 @@ func (app *ToolsApp) Rename(newName string) (string, error) {
 }
 
 func (app *ToolsApp) _save() error {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
 	_, err := Tools_WriteJSONFile(app.GetToolsJsonPath(), app)
 	if err != nil {
 		return err
@@ -145,6 +165,21 @@ func (app *ToolsApp) CheckRun() error {
 	app.lock.Lock()
 	defer app.lock.Unlock()
 
+	return app._checkRun()
+}
+
+// called by supervisor. Busy app(generating, compiling) is skipped and tried again in next round.
+func (app *ToolsApp) RestartCrashed() error {
+	if !app.lock.TryLock() {
+		return nil
+	}
+	defer app.lock.Unlock()
+
+	fmt.Printf("Restarting crashed '%s' app\n", app.Process.Compile.appName)
+	return app._checkRun()
+}
+
+func (app *ToolsApp) _checkRun() error {
 	if app.Process.Compile.Error != "" {
 		return fmt.Errorf("'%s' app has compilation error: %s", app.Process.Compile.GetFolderPath(), app.Process.Compile.Error) //don't log
 	}
@@ -153,7 +188,7 @@ func (app *ToolsApp) CheckRun() error {
 		return fmt.Errorf("'%s' app is waiting for compilation", app.Process.Compile.GetFolderPath()) //don't log
 	}
 
-	return app.Process.CheckRun(app.router)
+	return app.Process.CheckRun(app.router, app.GetPermissions())
 }
 
 func (app *ToolsApp) getPromptFilePath() string {
@@ -203,6 +238,36 @@ func (app *ToolsApp) NeedRefresh() bool {
 	return false
 }
 
+// hot reload: updates 'Changed' flag and returns true, if Tick(nil) would recompile app. Busy app(generating, compiling) is skipped.
+func (app *ToolsApp) CheckChanges() bool {
+	if !app.lock.TryLock() {
+		return false
+	}
+	defer app.lock.Unlock()
+
+	sdkFileTime, appFilesTime, hasPrompts, err := app.getPromptFileTime()
+	if err != nil {
+		return false
+	}
+
+	binFileMissing := !Tools_IsFileExists(app.Process.Compile.GetBinPath()) && app.Process.Compile.Error == ""
+
+	old := app.Prompts.Changed
+	app.Prompts.Changed = (app.Process.Compile.AppFileTime != appFilesTime || binFileMissing)
+	if old != app.Prompts.Changed {
+		app.Prompts.refresh = true
+	}
+	if !app.Prompts.Changed {
+		return false
+	}
+
+	if hasPrompts {
+		//generated app is only recompiled, prompts are generated by user
+		return len(app.Prompts.Prompts) > 0 && (app.Process.Compile.SdkFileTime != sdkFileTime || binFileMissing)
+	}
+	return true
+}
+
 func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 	app.lock.Lock()
 	defer app.lock.Unlock()
@@ -278,6 +343,7 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 			if err != nil {
 				return err
 			}
+			app.SnapshotPermissions() //user approved 'skyalt' file by generating app
 			if saved {
 				sdkFileTime, appFilesTime, _, err = app.getPromptFileTime() //refresh after save
 				if err != nil {
@@ -308,13 +374,13 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 			//Storage
 			storagePrompt := app.Prompts.FindStorage()
 			hasStorage := (storagePrompt != nil && storagePrompt.Prompt != "")
-			if hasStorage {
+			if hasStorage && app.Prompts.CheckChanges(ToolsPrompt_STORAGE) {
 
 				for i := range MAX_Errors_tries {
 					if i == 0 {
-						msg.progress_label = "Generating Storage code"
+						msg.SetProgressLabel("Generating Storage code")
 					} else {
-						msg.progress_label = "Fixing Storage code"
+						msg.SetProgressLabel("Fixing Storage code")
 					}
 
 					err = app.Prompts.generatePromptCode(storagePrompt, msg, app.router.services.llms)
@@ -322,12 +388,12 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 						return err
 					}
 
-					err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets) //rewrite(remove old) files
+					err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets, ToolsPrompt_STORAGE) //rewrite(remove old) files
 					if err != nil {
 						return err
 					}
 
-					codeErrors, err := app.Process.Compile._compile(sdkFileTime, appFilesTime, true, msg)
+					codeErrors, err := app.Process.Compile._check(sdkFileTime, appFilesTime, true, msg)
 					if err != nil {
 						return err
 					}
@@ -342,19 +408,19 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 			}
 
 			//Functions
-			if app.Prompts.HasFunction() {
+			if app.Prompts.HasFunction() && app.Prompts.CheckChanges(ToolsPrompt_FUNCTION) {
 				for i := range MAX_Errors_tries {
 					if i == 0 {
-						msg.progress_label = "Generating Functions code"
+						msg.SetProgressLabel("Generating Functions code")
 					} else {
-						msg.progress_label = "Fixing Functions code"
+						msg.SetProgressLabel("Fixing Functions code")
 					}
 
 					//generate code
 					var wg sync.WaitGroup
 					var genErr error
 					for _, prompt := range app.Prompts.Prompts {
-						if prompt.Type != ToolsPrompt_FUNCTION || prompt.IsCodeWithoutErrors() {
+						if prompt.Type != ToolsPrompt_FUNCTION || !prompt.NeedsGenerate() {
 							continue
 						}
 
@@ -375,12 +441,12 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 						break
 					}
 
-					err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets) //rewrite(remove old) files
+					err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets, ToolsPrompt_FUNCTION) //rewrite(remove old) files
 					if err != nil {
 						return err
 					}
 
-					codeErrors, err := app.Process.Compile._compile(sdkFileTime, appFilesTime, true, msg)
+					codeErrors, err := app.Process.Compile._check(sdkFileTime, appFilesTime, true, msg)
 					if err != nil {
 						return err
 					}
@@ -389,26 +455,27 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 						break
 					}
 					if i+1 == MAX_Errors_tries {
-						return fmt.Errorf("failed to generage Storage.go")
+						return fmt.Errorf("failed to generage Functions")
 					}
 				}
 			}
 
 			//Tools
 			if msg.GetContinue() {
+				app.Prompts.CheckChanges(ToolsPrompt_TOOL)
 				for i := range MAX_Errors_tries {
 
 					if i == 0 {
-						msg.progress_label = "Generating Tools code"
+						msg.SetProgressLabel("Generating Tools code")
 					} else {
-						msg.progress_label = "Fixing Tools code"
+						msg.SetProgressLabel("Fixing Tools code")
 					}
 
 					//generate code
 					var wg sync.WaitGroup
 					var genErr error
 					for _, prompt := range app.Prompts.Prompts {
-						if prompt.Type != ToolsPrompt_TOOL || prompt.IsCodeWithoutErrors() {
+						if prompt.Type != ToolsPrompt_TOOL || !prompt.NeedsGenerate() {
 							continue
 						}
 
@@ -429,7 +496,31 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 						break
 					}
 
-					err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets)
+					//generate tests(after tools, because they use tool's code)
+					app.Prompts.CheckChanges(ToolsPrompt_TEST)
+					for _, prompt := range app.Prompts.Prompts {
+						if prompt.Type != ToolsPrompt_TEST || !prompt.NeedsGenerate() {
+							continue
+						}
+
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							err := app.Prompts.generatePromptCode(prompt, msg, app.router.services.llms)
+							if err != nil {
+								genErr = err
+							}
+						}()
+					}
+					wg.Wait()
+					if genErr != nil {
+						return genErr
+					}
+					if !msg.GetContinue() {
+						break
+					}
+
+					err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets, ToolsPrompt_TEST)
 					if err != nil {
 						return err
 					}
@@ -439,11 +530,21 @@ func (app *ToolsApp) Tick(msg *AppsRouterMsg) error {
 						return err
 					}
 
-					codeErrors, err := app.Process.Compile._compile(sdkFileTime, appFilesTime, false, msg)
+					codeErrors, err := app.Process.Compile._check(sdkFileTime, appFilesTime, false, msg)
 					if err != nil {
 						return err
 					}
 					app.Prompts.SetCodeErrors(codeErrors, app)
+
+					//run tests, failed test is fixed same way as compile error
+					if len(codeErrors) == 0 && app.Prompts.HasTest() {
+						codeErrors, err = app.Process.Compile._test(app.router.server, app.GetPermissions(), msg)
+						if err != nil {
+							return err
+						}
+						app.Prompts.SetCodeErrors(codeErrors, app)
+					}
+
 					if len(codeErrors) == 0 {
 						restart = true
 						break
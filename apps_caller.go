@@ -18,10 +18,12 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"time"
 )
 
 func _ToolsCaller_UpdateDev(port int) error {
-	cl, err := NewToolsClient("localhost", port)
+	cl, err := AppsConns_Open(port)
 	if err != nil {
 		return err
 	}
@@ -37,12 +39,43 @@ func _ToolsCaller_UpdateDev(port int) error {
 }
 
 func _ToolsCaller_CallBuild(port int, msg_id uint64, ui_uid uint64, toolName string, paramsJs []byte) ([]byte, []byte, []byte, error) {
-	cl, err := NewToolsClient("localhost", port)
+	return _ToolsCaller_callBuild(port, msg_id, ui_uid, toolName, paramsJs, nil)
+}
+
+var ErrToolsCaller_interrupted = errors.New("interrupted")
+
+// runs LLM's tool call. Router stops waiting when msg is stopped, tool inside app is stopped by its next progress report.
+func _ToolsCaller_CallTool(port int, msg *AppsRouterMsg, toolName string, paramsJs []byte) ([]byte, []byte, []byte, error) {
+	return _ToolsCaller_callBuild(port, msg.msg_id, 0, toolName, paramsJs, msg)
+}
+
+func _ToolsCaller_callBuild(port int, msg_id uint64, ui_uid uint64, toolName string, paramsJs []byte, msg *AppsRouterMsg) ([]byte, []byte, []byte, error) {
+	cl, err := AppsConns_Open(port)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	defer cl.Destroy()
 
+	if msg != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(50 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if !msg.GetContinue() {
+						cl.setError(ErrToolsCaller_interrupted) //unblocks reading
+						return
+					}
+				}
+			}
+		}()
+	}
+
 	//send
 	err = cl.WriteArray([]byte("build"))
 	if err != nil {
@@ -94,7 +127,7 @@ func _ToolsCaller_CallBuild(port int, msg_id uint64, ui_uid uint64, toolName str
 }
 
 func _ToolsCaller_CallChange(port int, msg_id uint64, ui_uid uint64, change ToolsSdkChange) ([]byte, []byte, error) {
-	cl, err := NewToolsClient("localhost", port)
+	cl, err := AppsConns_Open(port)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -141,7 +174,7 @@ func _ToolsCaller_CallChange(port int, msg_id uint64, ui_uid uint64, change Tool
 }
 
 func _ToolsCaller_CallUpdate(port int, msg_id uint64, ui_uid uint64, sub_uid uint64) ([]byte, []byte, error) {
-	cl, err := NewToolsClient("localhost", port)
+	cl, err := AppsConns_Open(port)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -183,3 +216,33 @@ func _ToolsCaller_CallUpdate(port int, msg_id uint64, ui_uid uint64, sub_uid uin
 
 	return subUiGob, cmdsGob, nil
 }
+
+// returns error, if app doesn't answer in time
+func _ToolsCaller_Ping(port int, timeout time.Duration) error {
+	cl, err := AppsConns_Open(port)
+	if err != nil {
+		return err
+	}
+	defer cl.Destroy()
+
+	timer := time.AfterFunc(timeout, func() {
+		cl.setError(fmt.Errorf("ping timeout(%v)", timeout))
+	})
+	defer timer.Stop()
+
+	//send
+	err = cl.WriteArray([]byte("ping"))
+	if err != nil {
+		return err
+	}
+
+	//recv
+	pong, err := cl.ReadArray()
+	if err != nil {
+		return err
+	}
+	if string(pong) != "pong" {
+		return errors.New("invalid ping answer")
+	}
+	return nil
+}
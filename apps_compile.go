@@ -18,10 +18,17 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go/version"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +39,12 @@ type ToolsCodeError struct {
 	Line int
 	Col  int
 	Msg  string
+	Test bool //failed test(not compiler error)
+	Vet  bool `json:",omitempty"` //'go vet' diagnostic
+
+	Runtime bool   //tool failed when it was called
+	Params  string `json:",omitempty"` //runtime: JSON input of failed call
+	Stack   string `json:",omitempty"` //runtime: frames from app's code
 }
 
 type ToolsAppCompile struct {
@@ -40,6 +53,7 @@ type ToolsAppCompile struct {
 	Error       string
 	SdkFileTime int64
 	AppFileTime int64
+	ImportsHash string //'go mod tidy' runs only when imports change
 }
 
 func NewToolsAppCompile(appName string) *ToolsAppCompile {
@@ -47,6 +61,68 @@ func NewToolsAppCompile(appName string) *ToolsAppCompile {
 	return cmpl
 }
 
+var ErrToolsAppCompile_canceled = errors.New("build canceled")
+
+const ToolsAppCompile_minGoVersion = "go1.24" //'go build -json'
+
+// checks installed Go once at router start. Builds run with GOTOOLCHAIN=local, so newer toolchain isn't downloaded.
+func ToolsAppCompile_CheckGoVersion() error {
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Env = AppsModules_Env()
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("can't run 'go'(%w). Apps are built with Go, install %s+ from https://go.dev/dl", err, ToolsAppCompile_minGoVersion)
+	}
+
+	ver := strings.TrimSpace(string(out))
+	if !_ToolsAppCompile_isGoVersionSupported(ver) {
+		return fmt.Errorf("installed Go is '%s', but apps need %s+('go build -json'). Update Go(GOTOOLCHAIN=local is used, so toolchain isn't downloaded)", ver, ToolsAppCompile_minGoVersion)
+	}
+	return nil
+}
+
+func _ToolsAppCompile_isGoVersionSupported(ver string) bool {
+	if strings.HasPrefix(ver, "devel ") {
+		return true //built from source
+	}
+	return version.IsValid(ver) && version.Compare(ver, ToolsAppCompile_minGoVersion) >= 0
+}
+
+// runs command, kills it when msg is stopped(user or stale build)
+func _ToolsAppCompile_run(cmd *exec.Cmd, msg *AppsRouterMsg) error {
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			if !msg.GetContinue() {
+				cmd.Process.Kill()
+				<-done
+				return ErrToolsAppCompile_canceled
+			}
+		}
+	}
+}
+
+// canceled build must run again
+func (cmpl *ToolsAppCompile) _canceled() error {
+	cmpl.SdkFileTime = 0
+	cmpl.AppFileTime = 0
+	return ErrToolsAppCompile_canceled
+}
+
 func (cmpl *ToolsAppCompile) GetFolderPath() string {
 	return filepath.Join("apps", cmpl.appName)
 }
@@ -168,45 +244,43 @@ func (cmpl *ToolsAppCompile) _compile(sdkFileTime, appFileTime int64, noBinary b
 	cmpl.AppFileTime = appFileTime
 
 	//fix files
-	msg.progress_label = "Checking tools imports " + cmpl.GetFolderPath()
+	msg.SetProgressLabel("Checking tools imports " + cmpl.GetFolderPath())
 	{
 		fmt.Printf("Fixing '%s' ...\n", cmpl.GetFolderPath())
 		st := float64(time.Now().UnixMilli()) / 1000
 		cmd := exec.Command("goimports", "-l", "-w", ".")
 		cmd.Dir = cmpl.GetFolderPath()
+		cmd.Env = AppsModules_Env()
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr //os.Stderr
 		cmd.Stdout = os.Stdout
-		err := cmd.Run() //can rewrite the file
+		err := _ToolsAppCompile_run(cmd, msg) //can rewrite the file
+		if err == ErrToolsAppCompile_canceled {
+			return nil, cmpl._canceled()
+		}
 		if err != nil {
-
-			var codeErrors []ToolsCodeError
-			lines := strings.Split(stderr.String(), "\n")
-			for _, line := range lines {
-				codeErrLn, err := _ToolsAppCompile_parseErrorString(line)
-				if err == nil {
-					codeErrors = append(codeErrors, codeErrLn)
-				}
-			}
-
+			codeErrors := cmpl._mapMainErrors(_ToolsAppCompile_parseErrorLines(strings.Split(stderr.String(), "\n")))
 			cmpl.Error = stderr.String()
 			return codeErrors, nil
 		}
 		fmt.Printf("Fixing '%s' done in %.3fsec\n", cmpl.GetFolderPath(), (float64(time.Now().UnixMilli())/1000)-st)
 	}
 
-	msg.progress_done = 0.25
+	msg.SetProgressDone(0.25)
 
 	//update packages
-	msg.progress_label = "Updating tools packages " + cmpl.GetFolderPath()
+	msg.SetProgressLabel("Updating tools packages " + cmpl.GetFolderPath())
 	{
 		fmt.Printf("Updating packages '%s' ...\n", cmpl.GetFolderPath())
 		st := float64(time.Now().UnixMilli()) / 1000
 
 		if !Tools_IsFileExists(filepath.Join(cmpl.GetFolderPath(), "go.mod")) {
+			cmpl.ImportsHash = "" //tidy new go.mod
+
 			//create
 			cmd := exec.Command("go", "mod", "init", "skyalt_tool")
 			cmd.Dir = cmpl.GetFolderPath()
+			cmd.Env = AppsModules_Env()
 			var stderr bytes.Buffer
 			cmd.Stderr = &stderr //os.Stderr
 			cmd.Stdout = os.Stdout
@@ -218,25 +292,42 @@ func (cmpl *ToolsAppCompile) _compile(sdkFileTime, appFileTime int64, noBinary b
 			}
 		}
 
-		//update
-		cmd := exec.Command("go", "mod", "tidy")
-		cmd.Dir = cmpl.GetFolderPath()
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr //os.Stderr
-		cmd.Stdout = os.Stdout
-		err := cmd.Run()
+		//only standard library and approved modules
+		mods, err := AppsModules_GetApproved()
 		if err != nil {
-			err = LogsErrorf("go mod tidy failed: %s", stderr.String())
 			cmpl.Error = err.Error()
-			return nil, err
+			return nil, LogsError(err)
+		}
+		codeErrors, used, importsHash, err := AppsModules_CheckImports(cmpl.GetFolderPath(), mods)
+		if err != nil {
+			cmpl.Error = err.Error()
+			return nil, LogsError(err)
+		}
+		if len(codeErrors) > 0 {
+			cmpl.Error = codeErrors[0].Msg
+			return codeErrors, nil
+		}
+
+		//update, only when imports changed
+		if importsHash != cmpl.ImportsHash {
+			if len(used) > 0 {
+				LogsError(AppsModules_Download(mods)) //offline is ok, if modules are already in cache
+			}
+
+			err = AppsModules_Tidy(cmpl.GetFolderPath(), used)
+			if err != nil {
+				cmpl.Error = err.Error()
+				return nil, err
+			}
+			cmpl.ImportsHash = importsHash
 		}
 
 		fmt.Printf("Updating '%s' done in %.3fsec\n", cmpl.GetFolderPath(), (float64(time.Now().UnixMilli())/1000)-st)
 	}
-	msg.progress_done = 0.5
+	msg.SetProgressDone(0.5)
 
 	//compile
-	msg.progress_label = "Compiling tools code " + cmpl.GetFolderPath()
+	msg.SetProgressLabel("Compiling tools code " + cmpl.GetFolderPath())
 	{
 		fmt.Printf("Compiling '%s' ...\n", cmpl.GetFolderPath())
 		st := float64(time.Now().UnixMilli()) / 1000
@@ -245,32 +336,344 @@ func (cmpl *ToolsAppCompile) _compile(sdkFileTime, appFileTime int64, noBinary b
 		if noBinary {
 			outName = "/dev/null"
 		}
-		cmd := exec.Command("go", "build", "-gcflags=-e", "-o", outName) //(-gcflags="-e") = show all errors
+		cmd := exec.Command("go", "build", "-json", "-gcflags=-e", "-o", outName) //(-gcflags="-e") = show all errors
 		cmd.Dir = cmpl.GetFolderPath()
+		cmd.Env = AppsModules_Env()
+		var stdout bytes.Buffer
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr //os.Stderr
-		cmd.Stdout = os.Stdout
-		err := cmd.Run()
+		cmd.Stdout = &stdout //build events
+		err := _ToolsAppCompile_run(cmd, msg)
+		if err == ErrToolsAppCompile_canceled {
+			return nil, cmpl._canceled()
+		}
 		if err != nil {
-			var codeErrors []ToolsCodeError
-			lines := strings.Split(stderr.String(), "\n")
-			for _, line := range lines {
-				itErr, err := _ToolsAppCompile_parseErrorString(line)
-				if err == nil {
-					codeErrors = append(codeErrors, itErr)
+			output := _ToolsAppCompile_parseBuildJSON(stdout.Bytes())
+			codeErrors := cmpl._mapMainErrors(_ToolsAppCompile_parseErrorLines(strings.Split(output, "\n")))
+
+			cmpl.Error = output + stderr.String()
+			if len(codeErrors) == 0 { //linker, toolchain, etc.
+				if cmpl.Error == "" {
+					cmpl.Error = err.Error()
 				}
+				return nil, LogsErrorf("go build failed: %s", cmpl.Error)
 			}
-
-			cmpl.Error = stderr.String()
 			return codeErrors, nil
 		}
 		fmt.Printf("Compiling '%s' done in %.3fsec\n", cmpl.GetFolderPath(), (float64(time.Now().UnixMilli())/1000)-st)
 	}
-	msg.progress_done = 1.0
+	msg.SetProgressDone(1.0)
+
+	return nil, nil
+}
+
+// runs 'go vet' analyzers on compiled code. Diagnostics in sdk's part of main.go are ignored.
+func (cmpl *ToolsAppCompile) _vet(msg *AppsRouterMsg) ([]ToolsCodeError, error) {
+	msg.SetProgressLabel("Vetting tools code " + cmpl.GetFolderPath())
+
+	cmd := exec.Command("go", "vet", "-json", ".")
+	cmd.Dir = cmpl.GetFolderPath()
+	cmd.Env = AppsModules_Env()
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := _ToolsAppCompile_run(cmd, msg)
+	if err == ErrToolsAppCompile_canceled {
+		return nil, cmpl._canceled()
+	}
+	if err != nil {
+		return nil, LogsErrorf("go vet failed: %s", stderr.String())
+	}
+
+	absFolder, _ := filepath.Abs(cmpl.GetFolderPath())
+	codeErrors, err := _ToolsAppCompile_parseVetJSON(stdout.Bytes(), absFolder)
+	if err != nil {
+		return nil, err
+	}
+	return cmpl._mapMainErrors(codeErrors), nil
+}
+
+// compile + vet
+func (cmpl *ToolsAppCompile) _check(sdkFileTime, appFileTime int64, noBinary bool, msg *AppsRouterMsg) ([]ToolsCodeError, error) {
+	codeErrors, err := cmpl._compile(sdkFileTime, appFileTime, noBinary, msg)
+	if err != nil || len(codeErrors) > 0 {
+		return codeErrors, err
+	}
+	return cmpl._vet(msg)
+}
+
+// returns compiler output from 'go build -json' events
+func _ToolsAppCompile_parseBuildJSON(js []byte) string {
+	type BuildEvent struct {
+		ImportPath string
+		Action     string
+		Output     string
+	}
+
+	var output strings.Builder
+	dec := json.NewDecoder(bytes.NewReader(js))
+	for {
+		var ev BuildEvent
+		err := dec.Decode(&ev)
+		if err != nil {
+			break
+		}
+		if ev.Action == "build-output" {
+			output.WriteString(ev.Output)
+		}
+	}
+	return output.String()
+}
+
+// parses 'file:line:col: msg' lines, indented lines continue previous message
+func _ToolsAppCompile_parseErrorLines(lines []string) []ToolsCodeError {
+	var codeErrors []ToolsCodeError
+	for _, line := range lines {
+		if len(codeErrors) > 0 && (strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ")) {
+			codeErrors[len(codeErrors)-1].Msg += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		itErr, err := _ToolsAppCompile_parseErrorString(line)
+		if err == nil {
+			codeErrors = append(codeErrors, itErr)
+		}
+	}
+	return codeErrors
+}
+
+// parses 'go vet -json' output: {"<package>": {"<analyzer>": [{"posn": "<file>:<line>:<col>", "message": ...}]}}
+func _ToolsAppCompile_parseVetJSON(js []byte, absFolder string) ([]ToolsCodeError, error) {
+	type VetDiag struct {
+		Posn    string `json:"posn"`
+		Message string `json:"message"`
+	}
+
+	var codeErrors []ToolsCodeError
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	for dec.More() { //one object per package
+		var pkgs map[string]map[string]json.RawMessage
+		err := dec.Decode(&pkgs)
+		if err != nil {
+			return nil, LogsErrorf("go vet: invalid output: %w", err)
+		}
+
+		for _, analyzers := range pkgs {
+			names := slices.Sorted(maps.Keys(analyzers))
+			for _, name := range names {
+				var diags []VetDiag
+				if json.Unmarshal(analyzers[name], &diags) != nil {
+					continue //{"error": ...}
+				}
+				for _, d := range diags {
+					itErr, err := _ToolsAppCompile_parseErrorString(d.Posn + ": " + d.Message)
+					if err != nil {
+						continue
+					}
+					if rel, err := filepath.Rel(absFolder, itErr.File); err == nil && !strings.HasPrefix(rel, "..") {
+						itErr.File = rel
+					}
+					itErr.Msg = fmt.Sprintf("vet(%s): %s", name, itErr.Msg)
+					itErr.Vet = true
+					codeErrors = append(codeErrors, itErr)
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(codeErrors, func(a, b ToolsCodeError) int {
+		return cmp.Or(strings.Compare(a.File, b.File), a.Line-b.Line, a.Col-b.Col)
+	})
+	return codeErrors, nil
+}
+
+// main.go is sdk.go + generated code(BuildMainFile()). Errors in generated code are moved to tool, which caused them, errors in sdk part are dropped, if app's code has other errors.
+func (cmpl *ToolsAppCompile) _mapMainErrors(codeErrors []ToolsCodeError) []ToolsCodeError {
+	mainFl, err := os.ReadFile(filepath.Join(cmpl.GetFolderPath(), "main.go"))
+	if err != nil {
+		return codeErrors
+	}
+	mainLines := strings.Split(string(mainFl), "\n")
+	sdkLines := len(mainLines) //generated code starts with _callGlobalInits()
+	for i, ln := range mainLines {
+		if strings.HasPrefix(ln, "func _callGlobalInits()") {
+			sdkLines = i
+			break
+		}
+	}
+
+	var out []ToolsCodeError
+	var sdkErrors []ToolsCodeError
+	for _, er := range codeErrors {
+		if filepath.Base(er.File) != "main.go" {
+			out = append(out, er)
+			continue
+		}
+
+		if er.Line <= sdkLines {
+			if !er.Vet {
+				sdkErrors = append(sdkErrors, er)
+			}
+			continue
+		}
+
+		toolName := _ToolsAppCompile_findGeneratedTool(mainLines, er.Line)
+		if toolName == "" {
+			sdkErrors = append(sdkErrors, er)
+			continue
+		}
+		out = append(out, ToolsCodeError{File: toolName + ".go", Line: -1, Msg: fmt.Sprintf("generated code which calls '%s' failed: %s", toolName, er.Msg), Vet: er.Vet})
+	}
+
+	if len(out) == 0 {
+		return sdkErrors //probably sdk.go bug
+	}
+	return out
+}
+
+var g_appsCompile_generatedRe = regexp.MustCompile(`^\s*(?:case "([A-Za-z0-9_]+)":|err := ([A-Za-z0-9_]+)_global_(?:init|destroy)\(\))`)
+
+// returns tool, which generated code is on line(1-based)
+func _ToolsAppCompile_findGeneratedTool(mainLines []string, line int) string {
+	for i := min(line, len(mainLines)) - 1; i >= 0; i-- {
+		m := g_appsCompile_generatedRe.FindStringSubmatch(mainLines[i])
+		if m != nil {
+			return m[1] + m[2]
+		}
+		if strings.HasPrefix(mainLines[i], "func ") {
+			break
+		}
+	}
+	return ""
+}
+
+// runs generated tests(<Tool>_test.go). Failed tests are returned as errors of tested tool, compile errors in tests as errors of test file.
+// Test binary runs in sandbox same as app's process, perm is nil for hand-written apps.
+func (cmpl *ToolsAppCompile) _test(server *AppsServer, perm *ToolsAppPermissions, msg *AppsRouterMsg) ([]ToolsCodeError, error) {
+
+	msg.SetProgressLabel("Testing tools code " + cmpl.GetFolderPath())
+
+	fmt.Printf("Testing '%s' ...\n", cmpl.GetFolderPath())
+	st := float64(time.Now().UnixMilli()) / 1000
+
+	//test binary is outside app's folder, so app can't rewrite it
+	tmpDir, err := os.MkdirTemp("", "skyalt_test_")
+	if err != nil {
+		return nil, LogsError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	bin := filepath.Join(tmpDir, cmpl.appName+".test")
+
+	var output bytes.Buffer
+
+	//build
+	cmd := exec.Command("go", "test", "-c", "-o", bin, ".")
+	cmd.Dir = cmpl.GetFolderPath()
+	cmd.Env = AppsModules_Env()
+	cmd.Stderr = &output
+	cmd.Stdout = &output
+	err = _ToolsAppCompile_run(cmd, msg)
+
+	//run
+	if err == nil {
+		secret := server.NewSecret(cmpl.appName)
+		defer server.RemoveSecret(secret)
+
+		args := []string{"-test.count=1", "-test.timeout=120s"}
+		if perm != nil && AppsSandbox_enabled() {
+			routerPort := OsTrn(server.sock_dir == "", server.port, 0)
+			cmd, err = AppsSandbox_command(bin, args, perm.Network, routerPort)
+			if err != nil {
+				return nil, LogsError(err)
+			}
+		} else {
+			cmd = exec.Command(bin, args...)
+		}
+		//tests start with empty storage, so results don't depend on user's data and user's data isn't changed
+		testData := filepath.Join(tmpDir, AppsSandbox_dataFolder)
+		err = os.MkdirAll(testData, 0700)
+		if err != nil {
+			return nil, LogsError(err)
+		}
+
+		cmd.Dir = cmpl.GetFolderPath()
+		cmd.Env = append(os.Environ(),
+			"SKYALT_APP_NAME="+cmpl.appName,
+			"SKYALT_ROUTER_ADDR="+server.addr,
+			"SKYALT_SESSION_SECRET="+secret,
+			"SKYALT_MSG_ID="+strconv.FormatUint(msg.msg_id, 10),
+			"SKYALT_APP_DATA="+testData)
+		cmd.Stderr = &output
+		cmd.Stdout = &output
+		err = _ToolsAppCompile_run(cmd, msg)
+	}
+
+	if err == ErrToolsAppCompile_canceled {
+		return nil, cmpl._canceled()
+	}
+	if err != nil {
+		absFolder, _ := filepath.Abs(cmpl.GetFolderPath())
+		codeErrors := _ToolsAppCompile_parseTestOutput(output.String(), absFolder)
+		cmpl.Error = output.String()
+		if len(codeErrors) == 0 {
+			return nil, LogsErrorf("go test failed: %s", output.String())
+		}
+		return codeErrors, nil
+	}
+	fmt.Printf("Testing '%s' done in %.3fsec\n", cmpl.GetFolderPath(), (float64(time.Now().UnixMilli())/1000)-st)
 
 	return nil, nil
 }
 
+func _ToolsAppCompile_parseTestOutput(output string, absFolder string) []ToolsCodeError {
+	var codeErrors []ToolsCodeError
+
+	failRe := regexp.MustCompile(`^\s+([A-Za-z0-9_]+_test\.go):(\d+): (.*)$`) //t.Errorf() output
+	frameRe := regexp.MustCompile(`^\s+(.+\.go):(\d+)`)                       //panic stack frame
+
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		//failed test -> error of tested tool
+		if m := failRe.FindStringSubmatch(line); m != nil {
+			toolFile, _ := strings.CutSuffix(m[1], "_test.go")
+
+			itErr := ToolsCodeError{File: toolFile + ".go", Line: -1, Msg: fmt.Sprintf("%s:%s: %s", m[1], m[2], m[3]), Test: true}
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "        ") { //multi-line message
+				i++
+				itErr.Msg += "\n" + strings.TrimSpace(lines[i])
+			}
+			codeErrors = append(codeErrors, itErr)
+			continue
+		}
+
+		//panic -> error in first app's file from call stack
+		if strings.HasPrefix(line, "panic: ") {
+			for j := i + 1; j < len(lines); j++ {
+				m := frameRe.FindStringSubmatch(lines[j])
+				if m == nil || filepath.Dir(m[1]) != absFolder || filepath.Base(m[1]) == "main.go" || strings.HasSuffix(m[1], "_test.go") {
+					continue
+				}
+				ln, _ := strconv.Atoi(m[2])
+				codeErrors = append(codeErrors, ToolsCodeError{File: filepath.Base(m[1]), Line: ln, Msg: "test panicked: " + strings.TrimPrefix(line, "panic: "), Test: true})
+				break
+			}
+			continue
+		}
+
+		//compile error in test file
+		itErr, err := _ToolsAppCompile_parseErrorString(strings.TrimSpace(line))
+		if err == nil {
+			codeErrors = append(codeErrors, itErr)
+		}
+	}
+
+	return codeErrors
+}
+
 func _ToolsAppCompile_parseErrorString(errStr string) (ToolsCodeError, error) {
 	// Split the string by colons
 	parts := strings.SplitN(errStr, ":", 3)
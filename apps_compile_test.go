@@ -0,0 +1,145 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolsAppCompile_parseBuildJSON(t *testing.T) {
+	js := `{"ImportPath":"skyalt_tool","Action":"build-output","Output":"# skyalt_tool\n"}
+{"ImportPath":"skyalt_tool","Action":"build-output","Output":"./Sum.go:9:19: too many arguments in call to add\n"}
+{"ImportPath":"skyalt_tool","Action":"build-output","Output":"\thave (string, number)\n"}
+{"ImportPath":"skyalt_tool","Action":"build-output","Output":"\twant (int)\n"}
+{"ImportPath":"skyalt_tool","Action":"build-output","Output":"./Storage.go:4:2: undefined: x\n"}
+{"ImportPath":"skyalt_tool","Action":"build-fail"}
+`
+	errs := _ToolsAppCompile_parseErrorLines(strings.Split(_ToolsAppCompile_parseBuildJSON([]byte(js)), "\n"))
+	if len(errs) != 2 {
+		t.Fatalf("errors: %+v", errs)
+	}
+	if errs[0].File != "./Sum.go" || errs[0].Line != 9 || errs[0].Col != 19 || errs[0].Msg != "too many arguments in call to add\nhave (string, number)\nwant (int)" {
+		t.Errorf("multi-line error: %+v", errs[0])
+	}
+	if errs[1].File != "./Storage.go" || errs[1].Line != 4 {
+		t.Errorf("error: %+v", errs[1])
+	}
+}
+
+func TestToolsAppCompile_parseVetJSON(t *testing.T) {
+	js := `# skyalt_tool
+{
+	"skyalt_tool": {
+		"printf": [
+			{"posn": "/app/Sum.go:12:14", "end": "/app/Sum.go:12:16", "message": "fmt.Printf format %d has arg \"s\" of wrong type string"}
+		],
+		"assign": [
+			{"posn": "/app/Sum.go:10:2", "message": "self-assignment of x"}
+		],
+		"tests": {"error": "analysis skipped"}
+	}
+}
+`
+	js = js[strings.IndexByte(js, '{'):] //header is on stderr
+
+	errs, err := _ToolsAppCompile_parseVetJSON([]byte(js), "/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("errors: %+v", errs)
+	}
+	if errs[0].File != "Sum.go" || errs[0].Line != 10 || errs[0].Col != 2 || !errs[0].Vet || errs[0].Msg != "vet(assign): self-assignment of x" {
+		t.Errorf("error 0: %+v", errs[0])
+	}
+	if errs[1].Line != 12 || !strings.HasPrefix(errs[1].Msg, "vet(printf): ") {
+		t.Errorf("error 1: %+v", errs[1])
+	}
+}
+
+func TestToolsAppCompile_mapMainErrors(t *testing.T) {
+	os.MkdirAll("apps", 0755)
+	cmpl := NewToolsAppCompile("_test_map_main")
+	os.Mkdir(cmpl.GetFolderPath(), 0755)
+	defer os.RemoveAll(cmpl.GetFolderPath())
+
+	mainGo := `package main
+
+func main() {}
+
+func _callGlobalInits() {
+
+	{
+		err := Sum_global_init()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+func FindToolRunFunc(toolName string, jsParams []byte) (func(caller *ToolCaller, ui *UI) error, interface{}, error) {
+	switch toolName {
+	case "Sum":
+				st := Sum{}
+				err := json.Unmarshal(jsParams, &st)
+				if err != nil {
+					return nil, nil, err
+				}
+				return st.run, &st, nil
+		}
+}
+`
+	err := os.WriteFile(filepath.Join(cmpl.GetFolderPath(), "main.go"), []byte(mainGo), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := cmpl._mapMainErrors([]ToolsCodeError{
+		{File: "./main.go", Line: 3, Col: 1, Msg: "sdk error"},
+		{File: "main.go", Line: 3, Col: 1, Msg: "vet(printf): sdk", Vet: true},
+		{File: "./main.go", Line: 8, Col: 10, Msg: "undefined: Sum_global_init"},
+		{File: "./main.go", Line: 22, Col: 15, Msg: "st.run undefined"},
+		{File: "./Sum.go", Line: 5, Col: 2, Msg: "undefined: x"},
+	})
+	if len(errs) != 3 {
+		t.Fatalf("errors: %+v", errs)
+	}
+	for _, er := range errs {
+		if er.File != "Sum.go" && er.File != "./Sum.go" {
+			t.Errorf("not mapped to tool: %+v", er)
+		}
+	}
+	if errs[1].Line != -1 || !strings.Contains(errs[1].Msg, "st.run undefined") {
+		t.Errorf("generated error: %+v", errs[1])
+	}
+
+	//only sdk error
+	errs = cmpl._mapMainErrors([]ToolsCodeError{{File: "./main.go", Line: 3, Col: 1, Msg: "sdk error"}})
+	if len(errs) != 1 || errs[0].File != "./main.go" {
+		t.Errorf("sdk error: %+v", errs)
+	}
+}
+
+func TestToolsAppCompile_isGoVersionSupported(t *testing.T) {
+	for ver, expected := range map[string]bool{"go1.24": true, "go1.24.3": true, "go1.25rc1": true, "go1.23.2": false, "go1.24rc1": true, "devel go1.26-abc123 Mon Jan 1": true, "": false, "1.24": false} {
+		if got := _ToolsAppCompile_isGoVersionSupported(ver); got != expected {
+			t.Errorf("'%s': got %v, expected %v", ver, got, expected)
+		}
+	}
+}
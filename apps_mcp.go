@@ -0,0 +1,530 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newest first
+var AppsMCP_protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const AppsMCP_uriScheme = "skyalt"
+
+const AppsMCP_callTimeout = 10 * time.Minute
+
+// JSON-RPC error codes
+const (
+	AppsMCP_errParse            = -32700
+	AppsMCP_errInvalidRequest   = -32600
+	AppsMCP_errMethodNotFound   = -32601
+	AppsMCP_errInvalidParams    = -32602
+	AppsMCP_errResourceNotFound = -32002
+)
+
+type AppsMCPRequest struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"` //empty = notification
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type AppsMCPError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type AppsMCPResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *AppsMCPError   `json:"error,omitempty"`
+}
+
+type AppsMCPTool struct {
+	Name        string                             `json:"name"`
+	Description string                             `json:"description,omitempty"`
+	InputSchema ToolsOpenAI_completion_tool_schema `json:"inputSchema"`
+}
+
+type AppsMCPContent struct {
+	Type     string `json:"type"` //"text", "image"
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"` //base64
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+type AppsMCPToolResult struct {
+	Content           []AppsMCPContent `json:"content"`
+	StructuredContent json.RawMessage  `json:"structuredContent,omitempty"`
+	IsError           bool             `json:"isError,omitempty"`
+}
+
+type AppsMCPResource struct {
+	Uri      string `json:"uri"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+type AppsMCPResourceContent struct {
+	Uri      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// Model Context Protocol server. Publishes tools of all apps as '<app>_<tool>' and apps' storage files as resources.
+type AppsMCPServer struct {
+	fnGetApps   func() []string
+	fnGetTools  func(appName string) []*ToolsOpenAI_completion_tool
+	fnGetFolder func(appName string) string
+	fnCallTool  func(appName, toolName string, argsJs []byte, fnDone func(dataJs []byte, uiGob []byte, err error)) (*AppsRouterMsg, error)
+
+	lock    sync.Mutex
+	running map[AppsMCPCallId]*AppsRouterMsg
+
+	timeout time.Duration //tool call, 0 = AppsMCP_callTimeout
+}
+
+// request ids are unique only inside session(client)
+type AppsMCPCallId struct {
+	session string
+	id      string
+}
+
+func NewAppsMCPServer(router *AppsRouter) *AppsMCPServer {
+	mcp := &AppsMCPServer{}
+
+	mcp.fnGetApps = router.GetAppNames
+	mcp.fnGetTools = func(appName string) []*ToolsOpenAI_completion_tool {
+		app := router.FindApp(appName)
+		if app == nil {
+			return nil
+		}
+		return app.GetAllSchemas()
+	}
+	mcp.fnGetFolder = func(appName string) string {
+		app := router.FindApp(appName)
+		if app == nil {
+			return ""
+		}
+		return app.Process.Compile.GetFolderPath()
+	}
+	mcp.fnCallTool = func(appName, toolName string, argsJs []byte, fnDone func(dataJs []byte, uiGob []byte, err error)) (*AppsRouterMsg, error) {
+		app := router.FindApp(appName)
+		if app == nil {
+			return nil, fmt.Errorf("app '%s' not found", appName)
+		}
+		err := _Headless_compile(app) //apps are compiled on demand
+		if err != nil {
+			return nil, err
+		}
+
+		msg := router.CallBuildAsync(0, appName, toolName, json.RawMessage(argsJs),
+			func(cmdsGob [][]byte, err error, start_time float64) {},
+			func(dataJs []byte, uiGob []byte, cmdsGob []byte, err error, start_time float64) {
+				fnDone(dataJs, uiGob, err)
+			})
+		if msg == nil {
+			return nil, fmt.Errorf("calling '%s' failed", toolName)
+		}
+		return msg, nil
+	}
+
+	return mcp
+}
+
+// handles JSON-RPC message from session("" for stdio). Tool call is stopped when ctx is done(client disconnected). Returns nil for notifications.
+func (mcp *AppsMCPServer) Handle(ctx context.Context, session string, data []byte) []byte {
+	var req AppsMCPRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return mcp._marshal(AppsMCPResponse{Id: json.RawMessage("null"), Error: &AppsMCPError{Code: AppsMCP_errParse, Message: err.Error()}})
+	}
+	if req.Jsonrpc != "2.0" || req.Method == "" {
+		if len(req.Id) == 0 {
+			req.Id = json.RawMessage("null")
+		}
+		return mcp._marshal(AppsMCPResponse{Id: req.Id, Error: &AppsMCPError{Code: AppsMCP_errInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}})
+	}
+
+	if len(req.Id) == 0 {
+		mcp.notification(session, &req)
+		return nil
+	}
+
+	result, rpcErr := mcp.call(ctx, session, &req)
+	if rpcErr != nil {
+		return mcp._marshal(AppsMCPResponse{Id: req.Id, Error: rpcErr})
+	}
+	return mcp._marshal(AppsMCPResponse{Id: req.Id, Result: result})
+}
+
+func (mcp *AppsMCPServer) _marshal(resp AppsMCPResponse) []byte {
+	resp.Jsonrpc = "2.0"
+	js, err := json.Marshal(resp)
+	if LogsError(err) != nil {
+		return []byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32603,"message":"marshal failed"}}`)
+	}
+	return js
+}
+
+func (mcp *AppsMCPServer) notification(session string, req *AppsMCPRequest) {
+	switch req.Method {
+	case "notifications/cancelled":
+		var params struct {
+			RequestId json.RawMessage `json:"requestId"`
+		}
+		if json.Unmarshal(req.Params, &params) == nil {
+			mcp.lock.Lock()
+			msg := mcp.running[AppsMCPCallId{session: session, id: string(params.RequestId)}]
+			mcp.lock.Unlock()
+			if msg != nil {
+				msg.Stop()
+			}
+		}
+	}
+	//"notifications/initialized", etc. are ignored
+}
+
+func (mcp *AppsMCPServer) call(ctx context.Context, session string, req *AppsMCPRequest) (any, *AppsMCPError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+
+		version := AppsMCP_protocolVersions[0]
+		if slices.Contains(AppsMCP_protocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities": map[string]any{
+				"tools":     map[string]any{"listChanged": false},
+				"resources": map[string]any{"listChanged": false, "subscribe": false},
+			},
+			"serverInfo":   map[string]any{"name": "skyalt", "version": "1.0"},
+			"instructions": "Tools are named '<app>_<tool>'. Storage files of apps are available as resources.",
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": mcp.GetTools()}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		err := json.Unmarshal(req.Params, &params)
+		if err != nil {
+			return nil, &AppsMCPError{Code: AppsMCP_errInvalidParams, Message: err.Error()}
+		}
+		appName, tool := mcp.findTool(params.Name)
+		if tool == nil {
+			return nil, &AppsMCPError{Code: AppsMCP_errInvalidParams, Message: fmt.Sprintf("unknown tool '%s'", params.Name)}
+		}
+		return mcp.callTool(ctx, AppsMCPCallId{session: session, id: string(req.Id)}, appName, tool, params.Arguments), nil
+
+	case "resources/list":
+		return map[string]any{"resources": mcp.GetResources()}, nil
+
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": []any{}}, nil
+
+	case "resources/read":
+		var params struct {
+			Uri string `json:"uri"`
+		}
+		err := json.Unmarshal(req.Params, &params)
+		if err != nil {
+			return nil, &AppsMCPError{Code: AppsMCP_errInvalidParams, Message: err.Error()}
+		}
+		content, err := mcp.ReadResource(params.Uri)
+		if err != nil {
+			return nil, &AppsMCPError{Code: AppsMCP_errResourceNotFound, Message: err.Error()}
+		}
+		return map[string]any{"contents": []AppsMCPResourceContent{content}}, nil
+	}
+
+	return nil, &AppsMCPError{Code: AppsMCP_errMethodNotFound, Message: fmt.Sprintf("method '%s' not found", req.Method)}
+}
+
+func AppsMCP_toolName(appName, toolName string) string {
+	return appName + "_" + toolName
+}
+
+func (mcp *AppsMCPServer) GetTools() []AppsMCPTool {
+	tools := []AppsMCPTool{}
+	for _, appName := range mcp.fnGetApps() {
+		for _, tool := range mcp.fnGetTools(appName) {
+			tools = append(tools, AppsMCPTool{Name: AppsMCP_toolName(appName, tool.Function.Name), Description: tool.GetModelDescription(), InputSchema: tool.Function.Parameters})
+		}
+	}
+	return tools
+}
+
+func (mcp *AppsMCPServer) findTool(name string) (string, *ToolsOpenAI_completion_tool) {
+	for _, appName := range mcp.fnGetApps() {
+		if !strings.HasPrefix(name, appName+"_") {
+			continue
+		}
+		for _, tool := range mcp.fnGetTools(appName) {
+			if AppsMCP_toolName(appName, tool.Function.Name) == name {
+				return appName, tool
+			}
+		}
+	}
+	return "", nil
+}
+
+func _AppsMCP_errorResult(text string) *AppsMCPToolResult {
+	return &AppsMCPToolResult{Content: []AppsMCPContent{{Type: "text", Text: text}}, IsError: true}
+}
+
+// validates arguments, calls app and waits for result. Tool errors are returned as result, so agent can fix the call.
+func (mcp *AppsMCPServer) callTool(ctx context.Context, callId AppsMCPCallId, appName string, tool *ToolsOpenAI_completion_tool, argsJs json.RawMessage) *AppsMCPToolResult {
+	if len(argsJs) == 0 || string(argsJs) == "null" {
+		argsJs = json.RawMessage("{}")
+	}
+	argsJs, verrs := tool.ValidateArguments(string(argsJs))
+	if len(verrs) > 0 {
+		return _AppsMCP_errorResult(ToolsSchema_ValidationResult(tool.Function.Name, verrs))
+	}
+
+	type Out struct {
+		dataJs []byte
+		uiGob  []byte
+		err    error
+	}
+	done := make(chan Out, 1)
+	msg, err := mcp.fnCallTool(appName, tool.Function.Name, argsJs, func(dataJs []byte, uiGob []byte, err error) {
+		done <- Out{dataJs, uiGob, err}
+	})
+	if err != nil {
+		return _AppsMCP_errorResult(err.Error())
+	}
+
+	mcp.lock.Lock()
+	if mcp.running == nil {
+		mcp.running = make(map[AppsMCPCallId]*AppsRouterMsg)
+	}
+	mcp.running[callId] = msg
+	mcp.lock.Unlock()
+
+	timeout := mcp.timeout
+	if timeout <= 0 {
+		timeout = AppsMCP_callTimeout
+	}
+
+	var out Out
+	select {
+	case out = <-done:
+	case <-time.After(timeout):
+		msg.Stop()
+		out.err = fmt.Errorf("'%s' didn't finish in %v", tool.Function.Name, timeout)
+	case <-ctx.Done():
+		msg.Stop()
+		out.err = fmt.Errorf("client disconnected")
+	}
+
+	mcp.lock.Lock()
+	delete(mcp.running, callId)
+	mcp.lock.Unlock()
+
+	if out.err != nil {
+		return _AppsMCP_errorResult(out.err.Error())
+	}
+
+	var tool_ui UI
+	if len(out.uiGob) > 0 {
+		LogsGobUnmarshal(out.uiGob, &tool_ui)
+	}
+	text, images, err := LLMToolResult_build(out.dataJs, &tool_ui, true, mcp.fnGetFolder(appName))
+	if err != nil {
+		return _AppsMCP_errorResult(err.Error())
+	}
+
+	res := &AppsMCPToolResult{Content: []AppsMCPContent{{Type: "text", Text: text}}}
+	if strings.HasPrefix(text, "{") && json.Valid([]byte(text)) {
+		res.StructuredContent = json.RawMessage(text)
+	}
+	if images != nil {
+		for _, it := range images.Content {
+			if it.Image_url == nil {
+				continue
+			}
+			media_type, data, found := strings.Cut(strings.TrimPrefix(it.Image_url.Url, "data:"), ";base64,")
+			if found {
+				res.Content = append(res.Content, AppsMCPContent{Type: "image", Data: data, MimeType: media_type})
+			}
+		}
+	}
+	return res
+}
+
+// hidden folders(data/.tmp) are skipped
+func _AppsMCP_isStorageFolder(relDir string) bool {
+	for _, it := range strings.Split(relDir, "/") {
+		if strings.HasPrefix(it, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// storage files are .json and .xml files in app folder
+func _AppsMCP_isStorageFile(relPath string) bool {
+	if relPath == "tools.json" {
+		return false
+	}
+	if dir := path.Dir(relPath); dir != "." && !_AppsMCP_isStorageFolder(dir) {
+		return false
+	}
+	switch strings.ToLower(path.Ext(relPath)) {
+	case ".json", ".xml":
+		return true
+	}
+	return false
+}
+
+func _AppsMCP_mimeType(relPath string) string {
+	if strings.ToLower(path.Ext(relPath)) == ".xml" {
+		return "application/xml"
+	}
+	return "application/json"
+}
+
+func AppsMCP_resourceUri(appName, relPath string) string {
+	u := url.URL{Scheme: AppsMCP_uriScheme, Host: appName, Path: "/" + relPath}
+	return u.String()
+}
+
+func (mcp *AppsMCPServer) GetResources() []AppsMCPResource {
+	resources := []AppsMCPResource{}
+	for _, appName := range mcp.fnGetApps() {
+		folder := mcp.fnGetFolder(appName)
+		if folder == "" {
+			continue
+		}
+
+		filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel, err := filepath.Rel(folder, p)
+			if err != nil {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+
+			if d.IsDir() {
+				if p != folder && !_AppsMCP_isStorageFolder(rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !_AppsMCP_isStorageFile(rel) {
+				return nil
+			}
+
+			var size int64
+			if info, err := d.Info(); err == nil {
+				size = info.Size()
+			}
+			resources = append(resources, AppsMCPResource{Uri: AppsMCP_resourceUri(appName, rel), Name: appName + "/" + rel, MimeType: _AppsMCP_mimeType(rel), Size: size})
+			return nil
+		})
+	}
+	return resources
+}
+
+func (mcp *AppsMCPServer) ReadResource(uri string) (AppsMCPResourceContent, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return AppsMCPResourceContent{}, err
+	}
+	if u.Scheme != AppsMCP_uriScheme || u.Host == "" {
+		return AppsMCPResourceContent{}, fmt.Errorf("invalid resource uri '%s'", uri)
+	}
+
+	appName := u.Host
+	if !slices.Contains(mcp.fnGetApps(), appName) {
+		return AppsMCPResourceContent{}, fmt.Errorf("app '%s' not found", appName)
+	}
+
+	rel := strings.TrimPrefix(path.Clean("/"+u.Path), "/") //no '..'
+	if !_AppsMCP_isStorageFile(rel) {
+		return AppsMCPResourceContent{}, fmt.Errorf("resource '%s' not found", uri)
+	}
+
+	data, err := os.ReadFile(filepath.Join(mcp.fnGetFolder(appName), filepath.FromSlash(rel)))
+	if err != nil {
+		return AppsMCPResourceContent{}, fmt.Errorf("resource '%s' not found", uri)
+	}
+	return AppsMCPResourceContent{Uri: uri, MimeType: _AppsMCP_mimeType(rel), Text: string(data)}, nil
+}
+
+// newline-delimited JSON-RPC. Requests run concurrently, so long tool call doesn't block others.
+func (mcp *AppsMCPServer) RunStdio(in io.Reader, out io.Writer) error {
+	var outLock sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		data := slices.Clone(line)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp := mcp.Handle(context.Background(), "", data)
+			if resp == nil {
+				return
+			}
+
+			outLock.Lock()
+			defer outLock.Unlock()
+			out.Write(append(resp, '\n'))
+		}()
+	}
+	return scanner.Err()
+}
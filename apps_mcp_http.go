@@ -0,0 +1,259 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+const AppsMCPHttp_maxBody = 64 * 1024 * 1024
+
+// MCP over HTTP: 'POST /mcp'(streamable HTTP, JSON responses) and 'GET /sse' + 'POST /messages'(SSE transport).
+// Every request must have 'Authorization: Bearer <token>' header.
+type AppsMCPHttp struct {
+	mcp      *AppsMCPServer
+	server   *http.Server
+	listener net.Listener
+	token    string
+
+	lock     sync.Mutex
+	sessions map[string]*AppsMCPHttpSession //[session id]
+}
+
+type AppsMCPHttpSession struct {
+	out    chan []byte
+	closed chan struct{}
+	ctx    context.Context //event stream
+}
+
+// listens only on addr, use loopback address(127.0.0.1:port) to keep it local. Empty token is refused.
+func NewAppsMCPHttp(mcp *AppsMCPServer, addr string, token string) (*AppsMCPHttp, error) {
+	if token == "" {
+		return nil, LogsErrorf("MCP token is empty")
+	}
+	hs := &AppsMCPHttp{mcp: mcp, token: token, sessions: make(map[string]*AppsMCPHttpSession)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", hs.handleMcp)
+	mux.HandleFunc("/sse", hs.handleSse)
+	mux.HandleFunc("/messages", hs.handleMessages)
+	hs.server = &http.Server{Handler: mux}
+
+	var err error
+	hs.listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		return nil, LogsError(err)
+	}
+	return hs, nil
+}
+
+func (hs *AppsMCPHttp) Destroy() {
+	hs.server.Close()
+}
+
+func (hs *AppsMCPHttp) GetAddr() string {
+	return hs.listener.Addr().String()
+}
+
+// blocks until Destroy()
+func (hs *AppsMCPHttp) Run() error {
+	err := hs.server.Serve(hs.listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// token from env SKYALT_MCP_TOKEN or random one
+func AppsMCPHttp_GetToken() string {
+	token := os.Getenv("SKYALT_MCP_TOKEN")
+	if token != "" {
+		return token
+	}
+	return _AppsMCPHttp_randomId()
+}
+
+func _AppsMCPHttp_isLocalHost(host string) bool {
+	switch host {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// rejects requests without token and browser pages from other sites(DNS rebinding)
+func (hs *AppsMCPHttp) checkRequest(w http.ResponseWriter, r *http.Request) bool {
+	//Host must be local or listening address
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	listenHost, _, _ := net.SplitHostPort(hs.GetAddr())
+	if !_AppsMCPHttp_isLocalHost(host) && host != listenHost {
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !_AppsMCPHttp_isLocalHost(u.Hostname()) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return false
+		}
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(hs.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func _AppsMCPHttp_randomId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func _AppsMCPHttp_readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, AppsMCPHttp_maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+func (hs *AppsMCPHttp) handleMcp(w http.ResponseWriter, r *http.Request) {
+	if !hs.checkRequest(w, r) {
+		return
+	}
+	body, ok := _AppsMCPHttp_readBody(w, r)
+	if !ok {
+		return
+	}
+
+	//client sends session id back, so it can cancel its calls
+	sessionId := r.Header.Get("Mcp-Session-Id")
+	if sessionId == "" {
+		sessionId = _AppsMCPHttp_randomId()
+	}
+	w.Header().Set("Mcp-Session-Id", sessionId)
+
+	resp := hs.mcp.Handle(r.Context(), sessionId, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted) //notification
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+func (hs *AppsMCPHttp) handleSse(w http.ResponseWriter, r *http.Request) {
+	if !hs.checkRequest(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sessionId := _AppsMCPHttp_randomId()
+	session := &AppsMCPHttpSession{out: make(chan []byte, 16), closed: make(chan struct{}), ctx: r.Context()}
+
+	hs.lock.Lock()
+	hs.sessions[sessionId] = session
+	hs.lock.Unlock()
+	defer func() {
+		hs.lock.Lock()
+		delete(hs.sessions, sessionId)
+		hs.lock.Unlock()
+		close(session.closed)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: endpoint\ndata: /messages?session_id=%s\n\n", sessionId)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case resp := <-session.out:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", resp)
+			flusher.Flush()
+		}
+	}
+}
+
+func (hs *AppsMCPHttp) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if !hs.checkRequest(w, r) {
+		return
+	}
+
+	sessionId := r.URL.Query().Get("session_id")
+	hs.lock.Lock()
+	session := hs.sessions[sessionId]
+	hs.lock.Unlock()
+	if session == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	body, ok := _AppsMCPHttp_readBody(w, r)
+	if !ok {
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	//response goes into event stream, call is stopped when stream is closed
+	go func() {
+		resp := hs.mcp.Handle(session.ctx, sessionId, body)
+		if resp == nil {
+			return
+		}
+		select {
+		case session.out <- resp:
+		case <-session.closed:
+		}
+	}()
+}
@@ -0,0 +1,377 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fake apps: Events(folder with storage) and Calculator
+func _test_newMCPServer(t *testing.T) *AppsMCPServer {
+	folder := t.TempDir()
+	os.WriteFile(filepath.Join(folder, "tools.json"), []byte(`{}`), 0644)
+	os.WriteFile(filepath.Join(folder, "Events-Events.json"), []byte(`{"Events":[]}`), 0644)
+	os.MkdirAll(filepath.Join(folder, "data"), os.ModePerm)
+	os.WriteFile(filepath.Join(folder, "data", "Groups.xml"), []byte(`<Groups/>`), 0644)
+	os.WriteFile(filepath.Join(folder, "ListEvents.go"), []byte(`package main`), 0644)
+	os.MkdirAll(filepath.Join(folder, "data", ".tmp"), os.ModePerm)
+	os.WriteFile(filepath.Join(folder, "data", ".tmp", "part.json"), []byte(`{}`), 0644)
+
+	sum := NewToolsOpenAI_completion_tool("Sum", "Returns sum.")
+	sum.Function.Parameters.AddParam("A", "int", "First")
+	sum.Function.Parameters.AddParam("B", "int", "Second")
+	wait := NewToolsOpenAI_completion_tool("Wait", "Waits until it's canceled.")
+	list := NewToolsOpenAI_completion_tool("ListEvents", "Returns events.")
+
+	mcp := &AppsMCPServer{}
+	mcp.fnGetApps = func() []string { return []string{"Calculator", "Events"} }
+	mcp.fnGetTools = func(appName string) []*ToolsOpenAI_completion_tool {
+		switch appName {
+		case "Calculator":
+			return []*ToolsOpenAI_completion_tool{sum, wait}
+		case "Events":
+			return []*ToolsOpenAI_completion_tool{list}
+		}
+		return nil
+	}
+	mcp.fnGetFolder = func(appName string) string {
+		if appName == "Events" {
+			return folder
+		}
+		return ""
+	}
+	mcp.fnCallTool = func(appName, toolName string, argsJs []byte, fnDone func(dataJs []byte, uiGob []byte, err error)) (*AppsRouterMsg, error) {
+		msg := NewAppsRouterMsg(1, "build", nil, nil)
+		go func() {
+			switch toolName {
+			case "Sum":
+				var args struct{ A, B int }
+				json.Unmarshal(argsJs, &args)
+				fnDone([]byte(fmt.Sprintf(`{"A": %d, "B": %d, "Out_sum": %d}`, args.A, args.B, args.A+args.B)), nil, nil)
+			case "Wait":
+				for msg.GetContinue() {
+					time.Sleep(time.Millisecond)
+				}
+				fnDone(nil, nil, errors.New("canceled"))
+			case "ListEvents":
+				uiGob, _ := LogsGobMarshal(&UI{Items: []*UI{{Text: &UIText{Label: "Meeting"}}}})
+				fnDone([]byte(`{"Out_count": 1, "Out_titles": ["Meeting"]}`), uiGob, nil)
+			}
+		}()
+		return msg, nil
+	}
+	return mcp
+}
+
+func _test_mcpCall(t *testing.T, mcp *AppsMCPServer, id int, method string, params string) map[string]any {
+	req := fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "method": "%s", "params": %s}`, id, method, params)
+	var resp map[string]any
+	err := json.Unmarshal(mcp.Handle(context.Background(), "", []byte(req)), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp["jsonrpc"] != "2.0" || resp["id"] != float64(id) {
+		t.Errorf("%s: response: %v", method, resp)
+	}
+	return resp
+}
+
+func _test_toJson(v any) string {
+	js, _ := json.Marshal(v)
+	return string(js)
+}
+
+func TestAppsMCPServer_tools(t *testing.T) {
+	mcp := _test_newMCPServer(t)
+
+	resp := _test_mcpCall(t, mcp, 1, "initialize", `{"protocolVersion": "2025-03-26", "capabilities": {}, "clientInfo": {"name": "test"}}`)
+	if got := _test_toJson(resp["result"].(map[string]any)["protocolVersion"]); got != `"2025-03-26"` {
+		t.Errorf("protocol version: %s", got)
+	}
+	if resp := mcp.Handle(context.Background(), "", []byte(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)); resp != nil {
+		t.Errorf("notification has response: %s", resp)
+	}
+
+	//list
+	resp = _test_mcpCall(t, mcp, 2, "tools/list", `{}`)
+	var names []string
+	for _, tool := range resp["result"].(map[string]any)["tools"].([]any) {
+		names = append(names, tool.(map[string]any)["name"].(string))
+	}
+	if strings.Join(names, ",") != "Calculator_Sum,Calculator_Wait,Events_ListEvents" {
+		t.Errorf("tools: %v", names)
+	}
+
+	tests := []struct {
+		name   string
+		params string
+		want   string
+	}{
+		{"call", `{"name": "Calculator_Sum", "arguments": {"A": 1, "B": "2"}}`, `{"content":[{"text":"3","type":"text"}]}`},
+		{"invalid arguments", `{"name": "Calculator_Sum", "arguments": {"A": 1}}`, `"isError":true`},
+		{"structured", `{"name": "Events_ListEvents"}`, `"structuredContent":{"Out_count":1,"Out_titles":["Meeting"],"shown_on_screen":"Text: Meeting"}`},
+		{"unknown tool", `{"name": "Calculator_Div", "arguments": {}}`, `"code":-32602`},
+	}
+	for i, tt := range tests {
+		resp := _test_mcpCall(t, mcp, 10+i, "tools/call", tt.params)
+		if got := _test_toJson(resp); !strings.Contains(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	resp = _test_mcpCall(t, mcp, 20, "prompts/list", `{}`)
+	if got := _test_toJson(resp["error"]); !strings.Contains(got, `"code":-32601`) {
+		t.Errorf("unknown method: %s", got)
+	}
+}
+
+func TestAppsMCPServer_cancel(t *testing.T) {
+	mcp := _test_newMCPServer(t)
+
+	done := make(chan map[string]any)
+	go func() {
+		done <- _test_mcpCall(t, mcp, 7, "tools/call", `{"name": "Calculator_Wait"}`)
+	}()
+
+	for {
+		mcp.lock.Lock()
+		n := len(mcp.running)
+		mcp.lock.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	//same request id from other client
+	mcp.Handle(context.Background(), "other", []byte(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": 7}}`))
+	select {
+	case <-done:
+		t.Fatal("call was canceled by other session")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mcp.Handle(context.Background(), "", []byte(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": 7}}`))
+
+	select {
+	case resp := <-done:
+		if got := _test_toJson(resp["result"]); got != `{"content":[{"text":"canceled","type":"text"}],"isError":true}` {
+			t.Errorf("result: %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call was not canceled")
+	}
+}
+
+func TestAppsMCPServer_timeout(t *testing.T) {
+	mcp := _test_newMCPServer(t)
+	mcp.timeout = 50 * time.Millisecond
+
+	resp := _test_mcpCall(t, mcp, 1, "tools/call", `{"name": "Calculator_Wait"}`)
+	if got := _test_toJson(resp["result"]); !strings.Contains(got, "didn't finish") || !strings.Contains(got, `"isError":true`) {
+		t.Errorf("result: %s", got)
+	}
+
+	//client disconnected
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	var resp2 map[string]any
+	json.Unmarshal(mcp.Handle(ctx, "", []byte(`{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "Calculator_Wait"}}`)), &resp2)
+	if got := _test_toJson(resp2["result"]); !strings.Contains(got, "client disconnected") {
+		t.Errorf("result: %s", got)
+	}
+
+	mcp.lock.Lock()
+	if len(mcp.running) != 0 {
+		t.Errorf("running calls: %d", len(mcp.running))
+	}
+	mcp.lock.Unlock()
+}
+
+func TestAppsMCPServer_resources(t *testing.T) {
+	mcp := _test_newMCPServer(t)
+
+	resp := _test_mcpCall(t, mcp, 1, "resources/list", `{}`)
+	var uris []string
+	for _, res := range resp["result"].(map[string]any)["resources"].([]any) {
+		uris = append(uris, res.(map[string]any)["uri"].(string))
+	}
+	if strings.Join(uris, ",") != "skyalt://Events/Events-Events.json,skyalt://Events/data/Groups.xml" {
+		t.Errorf("resources: %v", uris)
+	}
+
+	resp = _test_mcpCall(t, mcp, 2, "resources/read", `{"uri": "skyalt://Events/data/Groups.xml"}`)
+	if got := _test_toJson(resp["result"]); got != `{"contents":[{"mimeType":"application/xml","text":"\u003cGroups/\u003e","uri":"skyalt://Events/data/Groups.xml"}]}` {
+		t.Errorf("read: %s", got)
+	}
+
+	for _, uri := range []string{"skyalt://Events/tools.json", "skyalt://Events/data/.tmp/part.json", "skyalt://Events/ListEvents.go", "skyalt://Events/../Events/Events-Events.json/../../../etc/passwd.json", "skyalt://Chats/Chats.json", "file:///etc/passwd"} {
+		resp = _test_mcpCall(t, mcp, 3, "resources/read", `{"uri": "`+uri+`"}`)
+		if resp["error"] == nil {
+			t.Errorf("%s: expected error, got %s", uri, _test_toJson(resp["result"]))
+		}
+	}
+}
+
+func TestAppsMCPServer_stdio(t *testing.T) {
+	mcp := _test_newMCPServer(t)
+
+	in := strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n" +
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}` + "\n\n" +
+		`not json` + "\n")
+	out := &strings.Builder{}
+	err := mcp.RunStdio(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(out.String(), `{"jsonrpc":"2.0","id":1,"result":{}}`) || !strings.Contains(out.String(), `"code":-32700`) {
+		t.Errorf("output: %s", out.String())
+	}
+}
+
+func TestAppsMCPHttp(t *testing.T) {
+	if _, err := NewAppsMCPHttp(_test_newMCPServer(t), "127.0.0.1:0", ""); err == nil {
+		t.Errorf("empty token was accepted")
+	}
+
+	const token = "secret"
+	hs, err := NewAppsMCPHttp(_test_newMCPServer(t), "127.0.0.1:0", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hs.Destroy()
+	go hs.Run()
+	url := "http://" + hs.GetAddr()
+
+	newRequest := func(method string, path string, body string, auth string) *http.Request {
+		req, _ := http.NewRequest(method, url+path, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		return req
+	}
+	post := func(path string, body string, origin string) (int, string) {
+		req := newRequest(http.MethodPost, path, body, token)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(data)
+	}
+
+	//streamable HTTP
+	code, body := post("/mcp", `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "Calculator_Sum", "arguments": {"A": 2, "B": 3}}}`, "http://localhost:3000")
+	if code != http.StatusOK || !strings.Contains(body, `"text":"5"`) {
+		t.Errorf("/mcp: %d %s", code, body)
+	}
+	if code, _ := post("/mcp", `{"jsonrpc": "2.0", "method": "notifications/initialized"}`, ""); code != http.StatusAccepted {
+		t.Errorf("/mcp notification: %d", code)
+	}
+	//token and host
+	for _, tt := range []struct {
+		auth string
+		host string
+		code int
+	}{{"", "", http.StatusUnauthorized}, {"wrong", "", http.StatusUnauthorized}, {token, "evil.example.com", http.StatusForbidden}, {token, "localhost", http.StatusOK}} {
+		req := newRequest(http.MethodPost, "/mcp", `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`, tt.auth)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.code {
+			t.Errorf("/mcp auth '%s' host '%s': %d, expected %d", tt.auth, tt.host, res.StatusCode, tt.code)
+		}
+	}
+
+	res, err := http.DefaultClient.Do(newRequest(http.MethodPost, "/mcp", `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`, token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(res.Header.Get("Mcp-Session-Id")) != 32 {
+		t.Errorf("/mcp session id: '%s'", res.Header.Get("Mcp-Session-Id"))
+	}
+	if code, _ := post("/mcp", `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`, "http://evil.example.com"); code != http.StatusForbidden {
+		t.Errorf("/mcp origin: %d", code)
+	}
+
+	//SSE
+	res, err = http.DefaultClient.Do(newRequest(http.MethodGet, "/sse", "", token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	events := bufio.NewReader(res.Body)
+	readEvent := func() (string, string) {
+		var event, data string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				return event, data
+			}
+			if v, found := strings.CutPrefix(line, "event: "); found {
+				event = v
+			}
+			if v, found := strings.CutPrefix(line, "data: "); found {
+				data = v
+			}
+		}
+	}
+
+	event, endpoint := readEvent()
+	if event != "endpoint" || !strings.HasPrefix(endpoint, "/messages?session_id=") {
+		t.Fatalf("endpoint event: %s %s", event, endpoint)
+	}
+	if code, _ := post(endpoint, `{"jsonrpc": "2.0", "id": 2, "method": "ping"}`, ""); code != http.StatusAccepted {
+		t.Errorf("/messages: %d", code)
+	}
+	event, data := readEvent()
+	if event != "message" || data != `{"jsonrpc":"2.0","id":2,"result":{}}` {
+		t.Errorf("message event: %s %s", event, data)
+	}
+	if code, _ := post("/messages?session_id=unknown", `{"jsonrpc": "2.0", "id": 3, "method": "ping"}`, ""); code != http.StatusNotFound {
+		t.Errorf("/messages unknown session: %d", code)
+	}
+}
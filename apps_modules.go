@@ -0,0 +1,283 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Apps share one module cache(modules/cache) and compile offline(GOPROXY=off). Third-party modules must be
+// approved in 'modules/approved'(line: '<module path> <version>'), they are downloaded once, after file changes.
+const AppsModules_approvedPath = "modules/approved"
+const AppsModules_cacheDir = "modules/cache"
+
+type AppsModule struct {
+	Path    string
+	Version string
+}
+
+var g_appsModules_lock sync.Mutex
+
+func AppsModules_parse(str string) ([]AppsModule, error) {
+	var mods []AppsModule
+
+	sc := bufio.NewScanner(strings.NewReader(str))
+	ln := 0
+	for sc.Scan() {
+		ln++
+		line, _, _ := strings.Cut(sc.Text(), "#") //comment
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "v") {
+			return nil, fmt.Errorf("line %d: expected '<module path> <version>'", ln)
+		}
+		mods = append(mods, AppsModule{Path: fields[0], Version: fields[1]})
+	}
+	return mods, nil
+}
+
+func AppsModules_GetApproved() ([]AppsModule, error) {
+	fl, err := os.ReadFile(AppsModules_approvedPath)
+	if os.IsNotExist(err) {
+		return nil, nil //only standard library
+	}
+	if err != nil {
+		return nil, err
+	}
+	mods, err := AppsModules_parse(string(fl))
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", AppsModules_approvedPath, err)
+	}
+	return mods, nil
+}
+
+// text for code prompts
+func AppsModules_PromptText() string {
+	mods, _ := AppsModules_GetApproved()
+	if len(mods) == 0 {
+		return "Import only packages from the Go standard library."
+	}
+
+	var paths []string
+	for _, mod := range mods {
+		paths = append(paths, mod.Path)
+	}
+	return "Import only packages from the Go standard library or from these approved modules: " + strings.Join(paths, ", ") + "."
+}
+
+// environment for 'go' commands: shared cache, no network
+func AppsModules_Env() []string {
+	return _AppsModules_env(true)
+}
+
+func _AppsModules_env(offline bool) []string {
+	cache, _ := filepath.Abs(AppsModules_cacheDir)
+	env := append(os.Environ(), "GOMODCACHE="+cache, "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if offline {
+		env = append(env, "GOPROXY=off", "GOSUMDB=off")
+	}
+	return env
+}
+
+// standard library packages don't have dot in first path element
+func _AppsModules_isStd(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+func _AppsModules_find(mods []AppsModule, importPath string) *AppsModule {
+	for i := range mods {
+		if importPath == mods[i].Path || strings.HasPrefix(importPath, mods[i].Path+"/") {
+			return &mods[i]
+		}
+	}
+	return nil
+}
+
+// returns imports of all .go files in folder
+func _AppsModules_getImports(folderPath string) (map[string]ToolsCodeError, error) {
+	files, err := os.ReadDir(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make(map[string]ToolsCodeError) //[path]first use
+	fset := token.NewFileSet()
+	for _, info := range files {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".go" {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(folderPath, info.Name()), nil, parser.ImportsOnly)
+		if err != nil {
+			continue //compiler will report it
+		}
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			if _, found := imports[path]; !found {
+				pos := fset.Position(imp.Path.Pos())
+				imports[path] = ToolsCodeError{File: info.Name(), Line: pos.Line, Col: pos.Column}
+			}
+		}
+	}
+	return imports, nil
+}
+
+// checks app's imports against approved modules. Returns code errors for unapproved imports, and hash of import set(with versions).
+func AppsModules_CheckImports(folderPath string, mods []AppsModule) ([]ToolsCodeError, []AppsModule, string, error) {
+	imports, err := _AppsModules_getImports(folderPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var codeErrors []ToolsCodeError
+	var used []AppsModule
+	h := sha256.New()
+	for _, path := range paths {
+		if _AppsModules_isStd(path) {
+			h.Write([]byte(path + "\n"))
+			continue
+		}
+
+		mod := _AppsModules_find(mods, path)
+		if mod == nil {
+			er := imports[path]
+			er.Msg = fmt.Sprintf("import \"%s\" is not allowed, it's not standard library or approved module(%s)", path, AppsModules_approvedPath)
+			codeErrors = append(codeErrors, er)
+			continue
+		}
+		h.Write([]byte(path + " " + mod.Version + "\n"))
+		if !slices.Contains(used, *mod) {
+			used = append(used, *mod)
+		}
+	}
+
+	return codeErrors, used, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// downloads approved modules into shared cache(needs network), only when 'modules/approved' has changed
+func AppsModules_Download(mods []AppsModule) error {
+	g_appsModules_lock.Lock()
+	defer g_appsModules_lock.Unlock()
+
+	var list strings.Builder
+	for _, mod := range mods {
+		list.WriteString(mod.Path + " " + mod.Version + "\n")
+	}
+	stampPath := filepath.Join(AppsModules_cacheDir, "approved.downloaded")
+	stamp, _ := os.ReadFile(stampPath)
+	if string(stamp) == list.String() {
+		return nil //up to date
+	}
+	if len(mods) == 0 {
+		return nil
+	}
+
+	err := os.MkdirAll(AppsModules_cacheDir, 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "skyalt_modules")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	env := _AppsModules_env(false)
+
+	fnRun := func(args ...string) error {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = tmp
+		cmd.Env = env
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err != nil {
+			return fmt.Errorf("go %s failed: %s", strings.Join(args, " "), stderr.String())
+		}
+		return nil
+	}
+
+	fmt.Printf("Downloading %d approved module(s) ...\n", len(mods))
+	err = fnRun("mod", "init", "skyalt_modules")
+	if err != nil {
+		return LogsError(err)
+	}
+	for _, mod := range mods {
+		err = fnRun("mod", "edit", "-require="+mod.Path+"@"+mod.Version)
+		if err != nil {
+			return LogsError(err)
+		}
+	}
+	err = fnRun("mod", "download", "all")
+	if err != nil {
+		return LogsError(err)
+	}
+
+	return os.WriteFile(stampPath, []byte(list.String()), 0644)
+}
+
+// pins used approved modules in app's go.mod and runs 'go mod tidy' offline
+func AppsModules_Tidy(folderPath string, used []AppsModule) error {
+	env := AppsModules_Env()
+
+	for _, mod := range used {
+		cmd := exec.Command("go", "mod", "edit", "-require="+mod.Path+"@"+mod.Version)
+		cmd.Dir = folderPath
+		cmd.Env = env
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err != nil {
+			return LogsErrorf("go mod edit failed: %s", stderr.String())
+		}
+	}
+
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = folderPath
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return LogsErrorf("go mod tidy failed(approved modules may not be downloaded yet): %s", stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppsModules_parse(t *testing.T) {
+	mods, err := AppsModules_parse("# comment\n\ngolang.org/x/text v0.21.0 # i18n\ngithub.com/google/uuid v1.6.0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 2 || mods[0] != (AppsModule{Path: "golang.org/x/text", Version: "v0.21.0"}) || mods[1].Path != "github.com/google/uuid" {
+		t.Errorf("modules: %+v", mods)
+	}
+
+	for _, str := range []string{"golang.org/x/text", "golang.org/x/text 0.21.0", "a v1 b"} {
+		_, err := AppsModules_parse(str)
+		if err == nil {
+			t.Errorf("'%s' should be invalid", str)
+		}
+	}
+}
+
+func TestAppsModules_CheckImports(t *testing.T) {
+	dir := t.TempDir()
+	fnWrite := func(name string, code string) {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fnWrite("Tool.go", "package main\n\nimport (\n\t\"fmt\"\n\t\"golang.org/x/text/language\"\n)\n")
+	fnWrite("Other.go", "package main\n\nimport \"github.com/evil/pkg\"\n")
+
+	mods := []AppsModule{{Path: "golang.org/x/text", Version: "v0.21.0"}, {Path: "github.com/google/uuid", Version: "v1.6.0"}}
+	errs, used, _, err := AppsModules_CheckImports(dir, mods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].File != "Other.go" || errs[0].Line != 3 || errs[0].Col != 8 {
+		t.Errorf("errors: %+v", errs)
+	}
+	if len(used) != 1 || used[0].Path != "golang.org/x/text" {
+		t.Errorf("used: %+v", used)
+	}
+
+	//same imports in other order = same hash
+	fnWrite("Other.go", "package main\n\nimport \"golang.org/x/text/language\"\nimport \"fmt\"\n")
+	_, _, hash2, _ := AppsModules_CheckImports(dir, mods)
+	fnWrite("Other.go", "package main\n")
+	_, _, hash3, _ := AppsModules_CheckImports(dir, mods)
+	if hash2 != hash3 {
+		t.Errorf("hashes: %s %s", hash2, hash3)
+	}
+	fnWrite("Tool.go", "package main\n\nimport \"fmt\"\n")
+	_, used, hash1, _ := AppsModules_CheckImports(dir, mods)
+	if hash1 == hash3 || len(used) != 0 {
+		t.Errorf("removed import didn't change hash")
+	}
+	fnWrite("Tool.go", "package main\n\nimport \"golang.org/x/text/language\"\nimport \"fmt\"\n")
+
+	//new version = new hash
+	mods[0].Version = "v0.22.0"
+	_, _, hash4, _ := AppsModules_CheckImports(dir, mods)
+	if hash4 == hash3 {
+		t.Errorf("version change didn't change hash")
+	}
+}
@@ -17,123 +17,680 @@ limitations under the License.
 package main
 
 import (
+	"crypto/rand"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
-type AppsClient struct {
-	conn *net.TCPConn
+// Wire protocol between router, apps(sdk/sdk.go) and media process(media/net.go). All three must be in sync.
+//
+// Connection starts with handshake:
+//   - client: magic(4) | version(u32) | name size(u32) | name | secret size(u32) | secret
+//   - server: magic(4) | version(u32) | status(u32)
+//
+// Secret is created by router for every process it starts(env SKYALT_SESSION_SECRET). Connections without valid secret are rejected.
+//
+// After that, both sides send frames: stream id(u64) | type(u8) | size(u32) | data.
+// Every call is a stream with own id, so many calls share one connection. Client uses odd ids, server even ids. Router closes connection, which sends frame bigger than 64MB.
+const AppsProtocol_version = 3
+
+var AppsProtocol_magic = [4]byte{'S', 'K', 'Y', 'A'}
+
+const (
+	AppsFrame_int   = 1
+	AppsFrame_bytes = 2
+	AppsFrame_end   = 3 //sender will not write into stream anymore
+
+	AppsFrame_headerSize = 8 + 1 + 4
+
+	AppsFrame_maxSize    = 64 * 1024 * 1024      //bigger frame closes connection
+	AppsConn_maxBuffered = 4 * AppsFrame_maxSize //received, but not read frames of all streams
+)
+
+// handshake status
+const (
+	AppsHandshake_ok           = 0
+	AppsHandshake_version      = 1
+	AppsHandshake_unauthorized = 2
+)
+
+func AppsFrame_getTypeName(tp byte) string {
+	switch tp {
+	case AppsFrame_int:
+		return "int"
+	case AppsFrame_bytes:
+		return "array"
+	case AppsFrame_end:
+		return "end"
+	}
+	return fmt.Sprintf("unknown(%d)", tp)
+}
+
+type AppsFrame struct {
+	Type byte
+	Data []byte
+}
+
+// peer speaks different protocol version or sent invalid frame(Msg)
+type AppsProtocolError struct {
+	Name           string
+	Version        int
+	Router_version int
+	Msg            string
+}
+
+func (e *AppsProtocolError) Error() string {
+	name := e.Name
+	if name == "" {
+		name = "unknown peer"
+	}
+	if e.Msg != "" {
+		return fmt.Sprintf("'%s' broke protocol: %s", name, e.Msg)
+	}
+	return fmt.Sprintf("'%s' uses protocol version %d, but router uses version %d. Recompile it", name, e.Version, e.Router_version)
+}
+
+// peer didn't send valid session secret
+type AppsAuthError struct {
+	Name string
+}
+
+func (e *AppsAuthError) Error() string {
+	return fmt.Sprintf("connection from '%s' was rejected: invalid session secret", e.Name)
+}
+
+type AppsServerInfo struct {
+	bytes_written atomic.Int64
+	bytes_read    atomic.Int64
+}
+
+func (info *AppsServerInfo) AddReadBytes(size int) {
+	info.bytes_read.Add(int64(size))
+}
+func (info *AppsServerInfo) AddWrittenBytes(size int) {
+	info.bytes_written.Add(int64(size))
+}
+
+func (info *AppsServerInfo) Print() {
+	fmt.Println("Server stats: written", Tools_FormatBytes(int(info.bytes_written.Load())))
+	fmt.Println("Server stats: read", Tools_FormatBytes(int(info.bytes_read.Load())))
+}
+
+// connections accepted by router. Id is used as app's port.
+var g_appsConns_lock sync.Mutex
+var g_appsConns = make(map[int]*AppsConn)
+var g_appsConns_last_id int
+
+func AppsConns_add(conn *AppsConn) {
+	g_appsConns_lock.Lock()
+	defer g_appsConns_lock.Unlock()
+
+	g_appsConns_last_id++
+	conn.id = g_appsConns_last_id
+	g_appsConns[conn.id] = conn
+}
+func AppsConns_remove(id int) {
+	g_appsConns_lock.Lock()
+	defer g_appsConns_lock.Unlock()
+
+	delete(g_appsConns, id)
+}
+
+// opens new call to app
+func AppsConns_Open(id int) (*AppsStream, error) {
+	g_appsConns_lock.Lock()
+	conn, found := g_appsConns[id]
+	g_appsConns_lock.Unlock()
+
+	if !found {
+		return nil, LogsErrorf("app connection %d not found", id)
+	}
+	return conn.Open()
+}
+
+// One persistent connection with multiplexed streams
+type AppsConn struct {
+	id   int
+	name string //peer
+	conn net.Conn
+	info *AppsServerInfo
+
+	write_lock sync.Mutex
+
+	lock         sync.Mutex
+	streams      map[uint64]*AppsStream
+	next_id      uint64
+	last_peer_id uint64
+	accepted     []*AppsStream //opened by peer, waiting for Accept()
+	err          error         //connection is closed
+
+	buffered atomic.Int64 //bytes of received frames, which weren't read yet
+
+	accepted_ready chan struct{}
+	closed         chan struct{}
+}
+
+func _newAppsConn(conn net.Conn, name string, info *AppsServerInfo, isClient bool) *AppsConn {
+	c := &AppsConn{name: name, conn: conn, info: info}
+	c.streams = make(map[uint64]*AppsStream)
+	c.accepted_ready = make(chan struct{}, 1)
+	c.closed = make(chan struct{})
+
+	c.next_id = 2
+	if isClient {
+		c.next_id = 1
+	}
+
+	go c.run()
+	return c
+}
+
+// "unix:<path>" or "<port>"
+func AppsConn_dial(addr string) (net.Conn, error) {
+	path, isUnix := strings.CutPrefix(addr, "unix:")
+	if isUnix {
+		return net.Dial("unix", path)
+	}
+	return net.Dial("tcp", net.JoinHostPort("localhost", addr))
 }
 
-func NewToolsClient(addr string, port int) (*AppsClient, error) {
-	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", addr, port))
+// connects to server. 'name' and 'secret' are sent in handshake.
+func NewAppsConn(addr string, name string, secret string) (*AppsConn, error) {
+	conn, err := AppsConn_dial(addr)
 	if LogsError(err) != nil {
 		return nil, err
 	}
 
-	conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	hello := make([]byte, 16+len(name)+len(secret))
+	copy(hello, AppsProtocol_magic[:])
+	binary.LittleEndian.PutUint32(hello[4:], AppsProtocol_version)
+	binary.LittleEndian.PutUint32(hello[8:], uint32(len(name)))
+	copy(hello[12:], name)
+	binary.LittleEndian.PutUint32(hello[12+len(name):], uint32(len(secret)))
+	copy(hello[16+len(name):], secret)
+	_, err = conn.Write(hello)
 	if LogsError(err) != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	return &AppsClient{conn: conn}, nil
+	var reply [12]byte
+	_, err = io.ReadFull(conn, reply[:8])
+	if err != nil {
+		conn.Close()
+		return nil, LogsErrorf("handshake failed: %w", err)
+	}
+	version := int(binary.LittleEndian.Uint32(reply[4:]))
+	if [4]byte(reply[:4]) != AppsProtocol_magic || version != AppsProtocol_version {
+		conn.Close()
+		return nil, LogsError(&AppsProtocolError{Name: name, Version: AppsProtocol_version, Router_version: version})
+	}
+	_, err = io.ReadFull(conn, reply[8:])
+	if err != nil {
+		conn.Close()
+		return nil, LogsErrorf("handshake failed: %w", err)
+	}
+	if binary.LittleEndian.Uint32(reply[8:]) != AppsHandshake_ok {
+		conn.Close()
+		return nil, LogsError(&AppsAuthError{Name: name})
+	}
+
+	return _newAppsConn(conn, "router", &AppsServerInfo{}, true), nil
 }
-func (client *AppsClient) Destroy() {
-	client.conn.Close()
+
+func _AppsConn_readString(conn net.Conn) (string, error) {
+	var size [4]byte
+	_, err := io.ReadFull(conn, size[:])
+	if err != nil {
+		return "", err
+	}
+	if binary.LittleEndian.Uint32(size[:]) > 1024 {
+		return "", fmt.Errorf("handshake: string is too long")
+	}
+	str := make([]byte, binary.LittleEndian.Uint32(size[:]))
+	_, err = io.ReadFull(conn, str)
+	if err != nil {
+		return "", err
+	}
+	return string(str), nil
 }
 
-func (client *AppsClient) ReadInt() (uint64, error) {
-	var sz [8]byte
-	_, err := client.conn.Read(sz[:])
-	if LogsError(err) != nil {
-		return 0, err
+// returns client's name
+func (server *AppsServer) _readHello(conn net.Conn) (string, error) {
+	var hdr [8]byte
+	_, err := io.ReadFull(conn, hdr[:])
+	if err != nil {
+		return "", err
+	}
+
+	if [4]byte(hdr[:4]) != AppsProtocol_magic {
+		//version 1 sent: array("register"), array(app name), int(port)
+		name := ""
+		if binary.LittleEndian.Uint64(hdr[:]) == 8 {
+			var cmd [8]byte
+			var size [8]byte
+			if _, err := io.ReadFull(conn, cmd[:]); err == nil && string(cmd[:]) == "register" {
+				if _, err := io.ReadFull(conn, size[:]); err == nil && binary.LittleEndian.Uint64(size[:]) < 1024 {
+					nm := make([]byte, binary.LittleEndian.Uint64(size[:]))
+					if _, err := io.ReadFull(conn, nm); err == nil {
+						name = string(nm)
+					}
+				}
+			}
+		}
+		return name, &AppsProtocolError{Name: name, Version: 1, Router_version: AppsProtocol_version}
+	}
+
+	version := int(binary.LittleEndian.Uint32(hdr[4:]))
+
+	name, err := _AppsConn_readString(conn)
+	if err != nil {
+		return "", err
+	}
+
+	var retErr error
+	status := AppsHandshake_ok
+	if version != AppsProtocol_version {
+		status = AppsHandshake_version
+		retErr = &AppsProtocolError{Name: name, Version: version, Router_version: AppsProtocol_version}
+	} else {
+		secret, err := _AppsConn_readString(conn)
+		if err != nil {
+			return name, err
+		}
+		if !server.CheckSecret(name, secret) {
+			status = AppsHandshake_unauthorized
+			retErr = &AppsAuthError{Name: name}
+		}
+	}
+
+	var reply [12]byte
+	copy(reply[:], AppsProtocol_magic[:])
+	binary.LittleEndian.PutUint32(reply[4:], AppsProtocol_version)
+	binary.LittleEndian.PutUint32(reply[8:], uint32(status))
+	_, err = conn.Write(reply[:])
+	if err != nil {
+		return name, err
 	}
 
-	return binary.LittleEndian.Uint64(sz[:]), nil
+	return name, retErr
 }
 
-func (client *AppsClient) WriteInt(value uint64) error {
-	var val [8]byte
-	binary.LittleEndian.PutUint64(val[:], value)
-	_, err := client.conn.Write(val[:])
-	if LogsError(err) != nil {
-		return err
+func (c *AppsConn) Destroy() {
+	c.conn.Close()
+	<-c.closed
+}
+
+// reads frames and sends them into streams
+func (c *AppsConn) run() {
+	var err error
+	var hdr [AppsFrame_headerSize]byte
+	for {
+		_, err = io.ReadFull(c.conn, hdr[:])
+		if err != nil {
+			break
+		}
+		id := binary.LittleEndian.Uint64(hdr[0:])
+		tp := hdr[8]
+		size := binary.LittleEndian.Uint32(hdr[9:])
+
+		if tp != AppsFrame_int && tp != AppsFrame_bytes && tp != AppsFrame_end {
+			err = &AppsProtocolError{Name: c.name, Msg: fmt.Sprintf("unknown frame type %d", tp)}
+			break
+		}
+		if size > AppsFrame_maxSize {
+			err = &AppsProtocolError{Name: c.name, Msg: fmt.Sprintf("frame has %s, limit is %s", Tools_FormatBytes(int(size)), Tools_FormatBytes(AppsFrame_maxSize))}
+			break
+		}
+		if c.buffered.Load()+int64(size) > AppsConn_maxBuffered {
+			err = &AppsProtocolError{Name: c.name, Msg: fmt.Sprintf("more than %s was sent, but not read", Tools_FormatBytes(AppsConn_maxBuffered))}
+			break
+		}
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(c.conn, data)
+		if err != nil {
+			break
+		}
+		c.info.AddReadBytes(AppsFrame_headerSize + int(size))
+
+		c.lock.Lock()
+		st, found := c.streams[id]
+		if !found && id%2 != c.next_id%2 && id > c.last_peer_id {
+			//new call from peer
+			c.last_peer_id = id
+			st = c._newStream(id, true)
+			c.accepted = append(c.accepted, st)
+			select {
+			case c.accepted_ready <- struct{}{}:
+			default:
+			}
+		}
+		c.lock.Unlock()
+
+		if st != nil { //nil = stream was destroyed
+			st.push(AppsFrame{Type: tp, Data: data})
+		}
+	}
+
+	c.conn.Close()
+
+	c.lock.Lock()
+	c.err = fmt.Errorf("connection with '%s' closed: %w", c.name, err)
+	streams := c.streams
+	c.streams = make(map[uint64]*AppsStream)
+	c.lock.Unlock()
+
+	for _, st := range streams {
+		st.setError(c.err)
 	}
+	if c.id > 0 {
+		AppsConns_remove(c.id)
+	}
+	close(c.closed)
+}
+
+// returns next stream opened by peer
+func (c *AppsConn) Accept() (*AppsStream, error) {
+	for {
+		c.lock.Lock()
+		if len(c.accepted) > 0 {
+			st := c.accepted[0]
+			c.accepted = c.accepted[1:]
+			c.lock.Unlock()
+			return st, nil
+		}
+		err := c.err
+		c.lock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-c.accepted_ready:
+		case <-c.closed:
+		}
+	}
+}
+
+// starts new call
+func (c *AppsConn) Open() (*AppsStream, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return nil, LogsError(c.err)
+	}
+
+	st := c._newStream(c.next_id, false)
+	c.next_id += 2
+	return st, nil
+}
+
+func (c *AppsConn) _newStream(id uint64, opened_by_peer bool) *AppsStream {
+	st := &AppsStream{conn: c, id: id, written: opened_by_peer}
+	st.ready = make(chan struct{}, 1)
+	c.streams[id] = st
+	return st
+}
+
+func (c *AppsConn) writeFrame(id uint64, tp byte, data []byte) error {
+	if len(data) > AppsFrame_maxSize {
+		return fmt.Errorf("connection with '%s': frame has %s, limit is %s", c.name, Tools_FormatBytes(len(data)), Tools_FormatBytes(AppsFrame_maxSize))
+	}
+
+	var hdr [AppsFrame_headerSize]byte
+	binary.LittleEndian.PutUint64(hdr[0:], id)
+	hdr[8] = tp
+	binary.LittleEndian.PutUint32(hdr[9:], uint32(len(data)))
+
+	c.write_lock.Lock()
+	defer c.write_lock.Unlock()
+
+	bufs := net.Buffers{hdr[:], data}
+	_, err := bufs.WriteTo(c.conn)
+	if err != nil {
+		return fmt.Errorf("connection with '%s': %w", c.name, err)
+	}
+	c.info.AddWrittenBytes(AppsFrame_headerSize + len(data))
 	return nil
 }
 
-func (client *AppsClient) ReadArray() ([]byte, error) {
-	//recv size
-	size, err := client.ReadInt()
+// One call. Values must be read in same order and type as they were written.
+type AppsStream struct {
+	conn *AppsConn
+	id   uint64
+
+	lock      sync.Mutex
+	cmd       string //1st array, for errors
+	frames    []AppsFrame
+	err       error
+	written   bool //peer knows about stream
+	ended     bool
+	destroyed bool
+
+	ready chan struct{}
+}
+
+func (st *AppsStream) push(frame AppsFrame) {
+	st.lock.Lock()
+	if st.destroyed {
+		st.lock.Unlock()
+		return
+	}
+	if st.cmd == "" && frame.Type == AppsFrame_bytes {
+		st.cmd = string(frame.Data)
+	}
+	st.frames = append(st.frames, frame)
+	st.conn.buffered.Add(int64(len(frame.Data)))
+	st.lock.Unlock()
+
+	select {
+	case st.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (st *AppsStream) setError(err error) {
+	st.lock.Lock()
+	st.err = err
+	st.lock.Unlock()
+
+	select {
+	case st.ready <- struct{}{}:
+	default:
+	}
+}
+
+// waits for next frame. End frame is kept, so all next reads fail too.
+func (st *AppsStream) next() (AppsFrame, error) {
+	for {
+		st.lock.Lock()
+		if len(st.frames) > 0 {
+			frame := st.frames[0]
+			if frame.Type != AppsFrame_end {
+				st.frames = st.frames[1:]
+				st.conn.buffered.Add(-int64(len(frame.Data)))
+			}
+			st.lock.Unlock()
+			return frame, nil
+		}
+		err := st.err
+		st.lock.Unlock()
+
+		if err != nil {
+			return AppsFrame{}, err
+		}
+		<-st.ready
+	}
+}
+
+func (st *AppsStream) read(tp byte) ([]byte, error) {
+	frame, err := st.next()
 	if err != nil {
-		return nil, err
+		return nil, LogsError(err)
 	}
 
-	//recv data
-	data := make([]byte, size)
-	p := 0
-	for p < int(size) {
-		n, err := client.conn.Read(data[p:])
-		if LogsError(err) != nil {
-			return nil, err
+	if frame.Type != tp {
+		st.lock.Lock()
+		cmd := st.cmd
+		st.lock.Unlock()
+
+		if frame.Type == AppsFrame_end {
+			return nil, LogsErrorf("protocol: '%s' call ended by '%s', but %s was expected", cmd, st.conn.name, AppsFrame_getTypeName(tp))
 		}
-		p += n
+		return nil, LogsErrorf("protocol: '%s' call received %s from '%s', but %s was expected", cmd, AppsFrame_getTypeName(frame.Type), st.conn.name, AppsFrame_getTypeName(tp))
 	}
+	return frame.Data, nil
+}
 
-	return data, nil
+func (st *AppsStream) write(tp byte, data []byte) error {
+	st.lock.Lock()
+	if st.ended {
+		st.lock.Unlock()
+		return LogsErrorf("protocol: write into ended '%s' call", st.cmd)
+	}
+	if st.cmd == "" && tp == AppsFrame_bytes {
+		st.cmd = string(data)
+	}
+	st.written = true
+	st.lock.Unlock()
+
+	return LogsError(st.conn.writeFrame(st.id, tp, data))
 }
 
-func (client *AppsClient) WriteArray(data []byte) error {
-	//send size
-	err := client.WriteInt(uint64(len(data)))
+func (st *AppsStream) ReadInt() (uint64, error) {
+	data, err := st.read(AppsFrame_int)
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	//send data
-	_, err = client.conn.Write(data)
-	if LogsError(err) != nil {
-		return err
+	if len(data) != 8 {
+		return 0, LogsErrorf("protocol: int has %d bytes", len(data))
 	}
+	return binary.LittleEndian.Uint64(data), nil
+}
 
-	return nil
+func (st *AppsStream) WriteInt(value uint64) error {
+	var val [8]byte
+	binary.LittleEndian.PutUint64(val[:], value)
+	return st.write(AppsFrame_int, val[:])
 }
 
-type AppsServerInfo struct {
-	bytes_written atomic.Int64
-	bytes_read    atomic.Int64
+func (st *AppsStream) ReadArray() ([]byte, error) {
+	return st.read(AppsFrame_bytes)
 }
 
-func (info *AppsServerInfo) AddReadBytes(size int) {
-	info.bytes_read.Add(int64(size))
+func (st *AppsStream) WriteArray(data []byte) error {
+	return st.write(AppsFrame_bytes, data)
 }
-func (info *AppsServerInfo) AddWrittenBytes(size int) {
-	info.bytes_written.Add(int64(size))
+
+// tells peer, that nothing more will be written. Stream can still be read.
+func (st *AppsStream) CloseWrite() {
+	st.lock.Lock()
+	send := st.written && !st.ended
+	st.ended = true
+	st.lock.Unlock()
+
+	if send {
+		st.conn.writeFrame(st.id, AppsFrame_end, nil)
+	}
 }
 
-func (info *AppsServerInfo) Print() {
-	fmt.Println("Server stats: written", Tools_FormatBytes(int(info.bytes_written.Load())))
-	fmt.Println("Server stats: read", Tools_FormatBytes(int(info.bytes_read.Load())))
+func (st *AppsStream) Destroy() {
+	st.CloseWrite()
+
+	st.conn.lock.Lock()
+	delete(st.conn.streams, st.id)
+	st.conn.lock.Unlock()
+
+	//unread frames
+	st.lock.Lock()
+	for _, frame := range st.frames {
+		st.conn.buffered.Add(-int64(len(frame.Data)))
+	}
+	st.frames = nil
+	st.destroyed = true
+	st.lock.Unlock()
 }
 
-type AppsServerClient struct {
-	info *AppsServerInfo
-	conn net.Conn
+// copies frames both ways until both sides end
+func AppsStream_Pipe(a *AppsStream, b *AppsStream) {
+	var wg sync.WaitGroup
+	fnCopy := func(src, dst *AppsStream) {
+		defer wg.Done()
+		defer dst.CloseWrite()
+
+		for {
+			frame, err := src.next()
+			if err != nil || frame.Type == AppsFrame_end {
+				return
+			}
+			if dst.write(frame.Type, frame.Data) != nil {
+				return
+			}
+		}
+	}
+
+	wg.Add(2)
+	go fnCopy(a, b)
+	go fnCopy(b, a)
+	wg.Wait()
 }
 
 type AppsServer struct {
 	port     int
+	addr     string //passed to processes
+	sock_dir string //unix socket only
 	listener net.Listener
 	exiting  bool
 
+	secrets_lock sync.Mutex
+	secrets      map[string]string //[secret]name
+
 	info *AppsServerInfo
 }
 
+// SKYALT_UNIX_SOCKETS=1 switches from TCP ports to unix domain sockets, which can be opened only by same user
+func AppsServer_useUnixSockets() bool {
+	return os.Getenv("SKYALT_UNIX_SOCKETS") == "1"
+}
+
 func NewAppsServer(port int) *AppsServer {
 	server := &AppsServer{}
+	server.secrets = make(map[string]string)
+
+	if AppsServer_useUnixSockets() {
+		var err error
+		server.sock_dir, err = os.MkdirTemp("", "skyalt-") //0700
+		if err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join(server.sock_dir, fmt.Sprintf("%d.sock", port))
+		server.listener, err = net.Listen("unix", path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Chmod(path, 0600)
+
+		server.port = port
+		server.addr = "unix:" + path
+		server.info = &AppsServerInfo{}
+
+		fmt.Printf("Server is running on socket: %s\n", path)
+		return server
+	}
 
 	port_last := port + 1000
 	for port < port_last {
@@ -148,6 +705,7 @@ func NewAppsServer(port int) *AppsServer {
 		log.Fatal("can not Listen()")
 	}
 	server.port = port
+	server.addr = strconv.Itoa(port)
 	server.info = &AppsServerInfo{}
 
 	fmt.Printf("Server is running on port: %d\n", server.port)
@@ -157,85 +715,75 @@ func NewAppsServer(port int) *AppsServer {
 func (server *AppsServer) Destroy() {
 	server.exiting = true
 	server.listener.Close()
+	if server.sock_dir != "" {
+		os.RemoveAll(server.sock_dir)
+	}
 
 	server.info.Print()
 	fmt.Printf("App server port: %d closed\n", server.port)
 }
 
-func (server *AppsServer) Accept() (*AppsServerClient, error) {
-	conn, err := server.listener.Accept()
-	if err != nil {
-		if server.exiting {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &AppsServerClient{info: server.info, conn: conn}, nil
-}
+// creates secret for new process
+func (server *AppsServer) NewSecret(name string) string {
+	var b [32]byte
+	rand.Read(b[:])
+	secret := hex.EncodeToString(b[:])
+
+	server.secrets_lock.Lock()
+	defer server.secrets_lock.Unlock()
 
-func (client *AppsServerClient) Destroy() {
-	client.conn.Close()
+	server.secrets[secret] = name
+	return secret
 }
 
-func (client *AppsServerClient) ReadInt() (uint64, error) {
-	var sz [8]byte
-	_, err := client.conn.Read(sz[:])
-	if LogsError(err) != nil {
-		return 0, err
-	}
-	client.info.AddReadBytes(8)
+// call when process exited
+func (server *AppsServer) RemoveSecret(secret string) {
+	server.secrets_lock.Lock()
+	defer server.secrets_lock.Unlock()
 
-	return binary.LittleEndian.Uint64(sz[:]), nil
+	delete(server.secrets, secret)
 }
 
-func (client *AppsServerClient) WriteInt(value uint64) error {
-	var val [8]byte
-	binary.LittleEndian.PutUint64(val[:], value)
-	_, err := client.conn.Write(val[:])
-	if LogsError(err) != nil {
-		return err
-	}
+func (server *AppsServer) CheckSecret(name string, secret string) bool {
+	server.secrets_lock.Lock()
+	defer server.secrets_lock.Unlock()
 
-	client.info.AddWrittenBytes(8)
-	return nil
+	owner, found := server.secrets[secret]
+	return found && secret != "" && owner == name
 }
 
-func (client *AppsServerClient) ReadArray() ([]byte, error) {
-	//recv size
-	size, err := client.ReadInt()
-	if err != nil {
+// waits for new connection and does handshake. Returns *AppsProtocolError when versions are different and *AppsAuthError when secret is invalid.
+func (server *AppsServer) Accept() (*AppsConn, error) {
+	conn, err := server.AcceptRaw()
+	if conn == nil {
 		return nil, err
 	}
+	return server.Handshake(conn)
+}
 
-	//recv data
-	data := make([]byte, size)
-	p := 0
-	for p < int(size) {
-		n, err := client.conn.Read(data[p:])
-		if LogsError(err) != nil {
-			return nil, err
+// waits for new connection without handshake, so slow peer doesn't block others. Returns nil, nil when server is closed.
+func (server *AppsServer) AcceptRaw() (net.Conn, error) {
+	conn, err := server.listener.Accept()
+	if err != nil {
+		if server.exiting {
+			return nil, nil
 		}
-		p += n
+		return nil, err
 	}
-
-	client.info.AddReadBytes(int(size))
-
-	return data, nil
+	return conn, nil
 }
 
-func (client *AppsServerClient) WriteArray(data []byte) error {
-	//send size
-	err := client.WriteInt(uint64(len(data)))
+// reads hello from connection returned by AcceptRaw(). Connection is closed on error.
+func (server *AppsServer) Handshake(conn net.Conn) (*AppsConn, error) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	name, err := server._readHello(conn)
 	if err != nil {
-		return err
-	}
-
-	//send data
-	_, err = client.conn.Write(data)
-	if LogsError(err) != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
-	client.info.AddWrittenBytes(len(data))
+	conn.SetDeadline(time.Time{})
 
-	return nil
+	c := _newAppsConn(conn, name, server.info, false)
+	AppsConns_add(c)
+	return c, nil
 }
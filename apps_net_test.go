@@ -0,0 +1,430 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// returns router's and app's side of one connection
+func _test_connectApp(t *testing.T, appName string) (*AppsConn, *AppsConn) {
+	server := NewAppsServer(19000)
+	t.Cleanup(server.Destroy)
+	return _test_connectServer(t, server, appName)
+}
+
+func _test_connectServer(t *testing.T, server *AppsServer, appName string) (*AppsConn, *AppsConn) {
+	accepted := make(chan *AppsConn)
+	go func() {
+		conn, err := server.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+
+	app, err := NewAppsConn(server.addr, appName, server.NewSecret(appName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Destroy)
+
+	conn := <-accepted
+	if conn == nil {
+		t.FailNow()
+	}
+	return conn, app
+}
+
+// fails test instead of hanging
+func _test_withTimeout(t *testing.T, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+// serves "echo": reads int and array, waits and sends them back
+func _test_serveEcho(conn *AppsConn) {
+	for {
+		cl, err := conn.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer cl.Destroy()
+
+			cmd, _ := cl.ReadArray()
+			i, err := cl.ReadInt()
+			if err != nil {
+				return
+			}
+			data, err := cl.ReadArray()
+			if err != nil {
+				return
+			}
+
+			time.Sleep(time.Duration(20-i) * time.Millisecond) //later calls answer first
+			cl.WriteArray(cmd)
+			cl.WriteInt(i * 10)
+			cl.WriteArray(data)
+		}()
+	}
+}
+
+func TestAppsConn_multiplex(t *testing.T) {
+	router, app := _test_connectApp(t, "Test")
+	go _test_serveEcho(app)
+	go _test_serveEcho(router)
+
+	_test_withTimeout(t, func() {
+		var wg sync.WaitGroup
+		for i := range 20 {
+			for _, conn := range []*AppsConn{router, app} { //both directions
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					cl, err := conn.Open()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer cl.Destroy()
+
+					payload := strings.Repeat(fmt.Sprintf("%d,", i), 1000*i)
+					cl.WriteArray([]byte("echo"))
+					cl.WriteInt(uint64(i))
+					cl.WriteArray([]byte(payload))
+
+					cmd, err1 := cl.ReadArray()
+					v, err2 := cl.ReadInt()
+					data, err3 := cl.ReadArray()
+					if err := errors.Join(err1, err2, err3); err != nil {
+						t.Error(err)
+						return
+					}
+					if string(cmd) != "echo" || v != uint64(i*10) || string(data) != payload {
+						t.Errorf("call %d: wrong answer: %s, %d, %d bytes", i, cmd, v, len(data))
+					}
+				}()
+			}
+		}
+		wg.Wait()
+	})
+
+	//app exits
+	app.Destroy()
+	_test_withTimeout(t, func() {
+		<-router.closed
+	})
+	if _, err := AppsConns_Open(router.id); err == nil {
+		t.Errorf("closed connection should be unregistered")
+	}
+}
+
+func TestAppsConn_missingField(t *testing.T) {
+	router, app := _test_connectApp(t, "Test")
+
+	//app expects int, but array is sent
+	handlerErr := make(chan error, 1)
+	go func() {
+		cl, err := app.Accept()
+		if err != nil {
+			return
+		}
+		defer cl.Destroy()
+
+		cl.ReadArray()
+		_, err = cl.ReadInt()
+		handlerErr <- err
+	}()
+
+	_test_withTimeout(t, func() {
+		cl, err := router.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Destroy()
+
+		cl.WriteArray([]byte("build"))
+		cl.WriteArray([]byte("Sum"))
+
+		err = <-handlerErr
+		if err == nil || !strings.Contains(err.Error(), "'build' call received array") {
+			t.Errorf("handler error: %v", err)
+		}
+
+		//caller gets error instead of hang
+		_, err = cl.ReadArray()
+		if err == nil || !strings.Contains(err.Error(), "call ended by 'Test'") {
+			t.Errorf("caller error: %v", err)
+		}
+	})
+
+	//connection is still usable
+	go _test_serveEcho(app)
+	_test_withTimeout(t, func() {
+		cl, _ := router.Open()
+		defer cl.Destroy()
+		cl.WriteArray([]byte("echo"))
+		cl.WriteInt(1)
+		cl.WriteArray(nil)
+		cmd, err := cl.ReadArray()
+		if err != nil || string(cmd) != "echo" {
+			t.Errorf("echo: %s, %v", cmd, err)
+		}
+	})
+}
+
+func TestAppsConn_frameLimit(t *testing.T) {
+	router, app := _test_connectApp(t, "Test")
+
+	//read frames aren't counted
+	_test_withTimeout(t, func() {
+		cl, _ := app.Open()
+		cl.WriteArray([]byte("echo"))
+		rcl, err := router.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rcl.ReadArray()
+		if router.buffered.Load() != 0 {
+			t.Errorf("%d bytes are buffered after read", router.buffered.Load())
+		}
+		cl.WriteArray([]byte("unread"))
+		cl.Destroy()
+		time.Sleep(50 * time.Millisecond)
+		rcl.Destroy()
+		if router.buffered.Load() != 0 {
+			t.Errorf("%d bytes are buffered after destroy", router.buffered.Load())
+		}
+	})
+
+	if app.writeFrame(1, AppsFrame_bytes, make([]byte, AppsFrame_maxSize+1)) == nil {
+		t.Errorf("too big frame was written")
+	}
+
+	//header of too big frame, data aren't allocated
+	var hdr [AppsFrame_headerSize]byte
+	binary.LittleEndian.PutUint64(hdr[0:], 3)
+	hdr[8] = AppsFrame_bytes
+	binary.LittleEndian.PutUint32(hdr[9:], AppsFrame_maxSize+1)
+	app.conn.Write(hdr[:])
+
+	_test_withTimeout(t, func() {
+		_, err := router.Accept()
+		var protoErr *AppsProtocolError
+		if !errors.As(err, &protoErr) || protoErr.Name != "Test" || !strings.Contains(err.Error(), "limit is 64.0 MB") {
+			t.Errorf("expected protocol error, got: %v", err)
+		}
+	})
+}
+
+func TestAppsServer_versionMismatch(t *testing.T) {
+	server := NewAppsServer(19000)
+	defer server.Destroy()
+
+	fnAccept := func(fnClient func(conn net.Conn)) error {
+		conn, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(server.port)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		go fnClient(conn)
+
+		_, err = server.Accept()
+		return err
+	}
+
+	//newer sdk.go
+	var reply [8]byte
+	err := fnAccept(func(conn net.Conn) {
+		hello := make([]byte, 12+len("Calendar"))
+		copy(hello, AppsProtocol_magic[:])
+		binary.LittleEndian.PutUint32(hello[4:], AppsProtocol_version+1)
+		binary.LittleEndian.PutUint32(hello[8:], uint32(len("Calendar")))
+		copy(hello[12:], "Calendar")
+		conn.Write(hello)
+		io.ReadFull(conn, reply[:])
+	})
+	var protoErr *AppsProtocolError
+	if !errors.As(err, &protoErr) || protoErr.Name != "Calendar" || protoErr.Version != AppsProtocol_version+1 || !strings.Contains(err.Error(), "Recompile") {
+		t.Errorf("error: %v", err)
+	}
+
+	//app compiled before protocol had version
+	err = fnAccept(func(conn net.Conn) {
+		fnWriteArray := func(data []byte) {
+			var sz [8]byte
+			binary.LittleEndian.PutUint64(sz[:], uint64(len(data)))
+			conn.Write(sz[:])
+			conn.Write(data)
+		}
+		fnWriteArray([]byte("register"))
+		fnWriteArray([]byte("Notes"))
+	})
+	if !errors.As(err, &protoErr) || protoErr.Name != "Notes" || protoErr.Version != 1 {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestAppsServer_secret(t *testing.T) {
+	server := NewAppsServer(19000)
+	defer server.Destroy()
+
+	secret := server.NewSecret("Calendar")
+
+	fnConnect := func(name string, secret string) (error, error) {
+		accepted := make(chan error)
+		go func() {
+			_, err := server.Accept()
+			accepted <- err
+		}()
+		conn, err := NewAppsConn(server.addr, name, secret)
+		if conn != nil {
+			conn.Destroy()
+		}
+		return err, <-accepted
+	}
+
+	var authErr *AppsAuthError
+	for _, tc := range []struct{ name, secret string }{
+		{"Calendar", ""},           //missing
+		{"Calendar", "0123abcd"},   //invalid
+		{"Notes", secret},          //secret of other app
+		{"Calendar", secret + "0"}, //modified
+	} {
+		clientErr, serverErr := fnConnect(tc.name, tc.secret)
+		if !errors.As(clientErr, &authErr) || !errors.As(serverErr, &authErr) || authErr.Name != tc.name {
+			t.Errorf("'%s' with secret '%s': client: %v, server: %v", tc.name, tc.secret, clientErr, serverErr)
+		}
+	}
+
+	clientErr, serverErr := fnConnect("Calendar", secret)
+	if clientErr != nil || serverErr != nil {
+		t.Errorf("valid secret: client: %v, server: %v", clientErr, serverErr)
+	}
+
+	//process exited
+	server.RemoveSecret(secret)
+	clientErr, _ = fnConnect("Calendar", secret)
+	if !errors.As(clientErr, &authErr) {
+		t.Errorf("removed secret: %v", clientErr)
+	}
+}
+
+func TestAppsServer_unixSocket(t *testing.T) {
+	t.Setenv("SKYALT_UNIX_SOCKETS", "1")
+
+	server := NewAppsServer(19000)
+	t.Cleanup(server.Destroy)
+
+	path, isUnix := strings.CutPrefix(server.addr, "unix:")
+	if !isUnix {
+		t.Fatalf("address: %s", server.addr)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		t.Errorf("socket permissions: %v", info.Mode().Perm())
+	}
+
+	router, app := _test_connectServer(t, server, "Test")
+	go _test_serveEcho(app)
+	_test_withTimeout(t, func() {
+		cl, _ := router.Open()
+		defer cl.Destroy()
+		cl.WriteArray([]byte("echo"))
+		cl.WriteInt(1)
+		cl.WriteArray(nil)
+		cmd, err := cl.ReadArray()
+		if err != nil || string(cmd) != "echo" {
+			t.Errorf("echo: %s, %v", cmd, err)
+		}
+	})
+}
+
+func TestAppsRouter_acceptCalls(t *testing.T) {
+	server := NewAppsServer(19000)
+	defer server.Destroy()
+
+	router := &AppsRouter{server: server, apps: map[string]*ToolsApp{"Calendar": {}}, protocol_errors: make(map[string]error)}
+	calls := make(chan *AppsStream)
+	go router.acceptCalls(calls)
+
+	//silent peer doesn't block handshake of others
+	silent, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(server.port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	app, err := NewAppsConn(server.addr, "Calendar", server.NewSecret("Calendar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Destroy()
+
+	cl, err := app.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Destroy()
+	cl.WriteArray([]byte("print"))
+
+	select {
+	case got := <-calls:
+		if got == nil || got.conn.name != "Calendar" {
+			t.Errorf("call: %v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("call was blocked by silent peer")
+	}
+
+	//only existing apps have protocol error
+	for _, name := range []string{"Calendar", "Unknown"} {
+		_, err := NewAppsConn(server.addr, name, "invalid")
+		if err == nil {
+			t.Fatalf("'%s' connected with invalid secret", name)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	var authErr *AppsAuthError
+	if !errors.As(router.GetProtocolError("Calendar"), &authErr) || router.GetProtocolError("Unknown") != nil {
+		t.Errorf("protocol errors: %v", router.protocol_errors)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Capabilities of generated app, declared in 'skyalt' file:
+//
+//	#Permissions
+//	commands: get_mic_info, stop_mic
+//	apps: Calculator, Translator
+//	llm: chat, transcribe
+//	network: yes
+//
+// Apps without 'skyalt' file(hand-written) can call everything. Permissions are snapshot when app is generated,
+// app which had 'skyalt' file keeps them, even when file is deleted.
+type ToolsAppPermissions struct {
+	Commands []string
+	Apps     []string //other apps, which can be called with CallToolApp()
+	LLM      []string //use cases
+	Network  bool     //used by sandbox
+}
+
+// every app can call these
+var ToolsAppPermissions_base = []string{"register", "print", "progress", "add_cmds", "storage_changed", "set_msg_uid", "find_msg", "stop_msg", "sandbox_violation"}
+
+// must be declared
+var ToolsAppPermissions_commands = []string{"generate_app", "fix_runtime_error", "rename_app", "get_llm_usage", "get_llm_spendings", "get_msgs", "get_logs", "get_mic_info", "stop_mic", "get_media_info", "set_text_highlight", "get_tools_shemas", "get_tool_data"}
+
+// granted by 'apps' and 'llm'
+var ToolsAppPermissions_appCommands = []string{"run_app", "forward"}
+var ToolsAppPermissions_llmCommands = []string{"llm_complete", "llm_find", "llm_transcribe"}
+
+var ToolsAppPermissions_llmUsecases = []string{"chat", "tools", "transcribe"}
+
+type ToolsAppDenial struct {
+	Time    int64
+	Command string
+	Detail  string
+}
+
+func (d *ToolsAppDenial) String() string {
+	if d.Detail != "" {
+		return fmt.Sprintf("%s(%s)", d.Command, d.Detail)
+	}
+	return d.Command
+}
+
+// extracts '#permissions' block from 'skyalt' file. Returns nil, if block is missing. Error line starts from 1.
+func ToolsAppPermissions_parse(skyaltFile string) (*ToolsAppPermissions, int, error) {
+	var perm *ToolsAppPermissions
+
+	inBlock := false
+	lines := strings.Split(skyaltFile, "\n")
+	for i, ln := range lines {
+		ln = strings.TrimSpace(ln)
+
+		if strings.HasPrefix(ln, "#") {
+			inBlock = strings.HasPrefix(strings.ToLower(ln), "#permissions")
+			if inBlock {
+				if perm != nil {
+					return nil, i + 1, fmt.Errorf("second '#permissions' is not allowed")
+				}
+				perm = &ToolsAppPermissions{}
+			}
+			continue
+		}
+		if !inBlock || ln == "" {
+			continue
+		}
+
+		key, values, found := strings.Cut(ln, ":")
+		if !found {
+			return nil, i + 1, fmt.Errorf("permission line must be '<commands|apps|llm|network>: <values>'")
+		}
+
+		var items []string
+		for _, it := range strings.Split(values, ",") {
+			it = strings.TrimSpace(it)
+			if it != "" {
+				items = append(items, it)
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "commands":
+			for _, it := range items {
+				if !slices.Contains(ToolsAppPermissions_commands, it) {
+					return nil, i + 1, fmt.Errorf("unknown command '%s', options: %s", it, strings.Join(ToolsAppPermissions_commands, ", "))
+				}
+			}
+			perm.Commands = append(perm.Commands, items...)
+		case "apps":
+			perm.Apps = append(perm.Apps, items...)
+		case "llm":
+			for _, it := range items {
+				if !slices.Contains(ToolsAppPermissions_llmUsecases, strings.ToLower(it)) {
+					return nil, i + 1, fmt.Errorf("unknown LLM use case '%s', options: %s", it, strings.Join(ToolsAppPermissions_llmUsecases, ", "))
+				}
+				perm.LLM = append(perm.LLM, strings.ToLower(it))
+			}
+		case "network":
+			switch strings.ToLower(strings.TrimSpace(values)) {
+			case "yes", "true":
+				perm.Network = true
+			case "no", "false":
+				perm.Network = false
+			default:
+				return nil, i + 1, fmt.Errorf("network must be 'yes' or 'no'")
+			}
+		default:
+			return nil, i + 1, fmt.Errorf("unknown permission '%s', options: commands, apps, llm, network", key)
+		}
+	}
+
+	return perm, 0, nil
+}
+
+// nil = everything is allowed
+func (perm *ToolsAppPermissions) IsCommandAllowed(cmd string) bool {
+	if perm == nil {
+		return true
+	}
+
+	switch {
+	case slices.Contains(ToolsAppPermissions_base, cmd):
+		return true
+	case slices.Contains(ToolsAppPermissions_appCommands, cmd):
+		return len(perm.Apps) > 0
+	case slices.Contains(ToolsAppPermissions_llmCommands, cmd):
+		return len(perm.LLM) > 0
+	}
+	return slices.Contains(perm.Commands, cmd)
+}
+
+func (perm *ToolsAppPermissions) IsAppAllowed(appName string) bool {
+	return perm == nil || slices.Contains(perm.Apps, appName)
+}
+
+func (perm *ToolsAppPermissions) IsLLMAllowed(usecase string) bool {
+	return perm == nil || slices.Contains(perm.LLM, usecase)
+}
+
+// returns snapshot taken by SnapshotPermissions(), nil for hand-written app. Changed or deleted 'skyalt' file is ignored until app is generated again.
+func (app *ToolsApp) GetPermissions() *ToolsAppPermissions {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
+	return app.Permissions
+}
+
+// reads permissions from 'skyalt' file. Broken or missing block allows only base commands.
+func (app *ToolsApp) SnapshotPermissions() {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
+	app._snapshotPermissions()
+}
+
+func (app *ToolsApp) _snapshotPermissions() {
+	app.Generated = true
+	app.Permissions = &ToolsAppPermissions{}
+
+	fl, err := os.ReadFile(app.getPromptFilePath())
+	if LogsError(err) == nil {
+		perm, line, err := ToolsAppPermissions_parse(string(fl))
+		if err != nil {
+			LogsErrorf("'%s' app has invalid permissions on line %d: %w", app.Process.Compile.appName, line, err)
+		} else if perm != nil {
+			app.Permissions = perm
+		}
+	}
+}
+
+func (app *ToolsApp) AddDenial(cmd string, detail string) {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
+	d := ToolsAppDenial{Time: time.Now().Unix(), Command: cmd, Detail: detail}
+	if cmd == "sandbox" {
+		LogsErrorf("'%s' app was blocked by sandbox: %s", app.Process.Compile.appName, detail)
+	} else {
+		LogsErrorf("'%s' app isn't allowed to call '%s'. Add it into '#permissions'", app.Process.Compile.appName, d.String())
+	}
+
+	app.denials = append(app.denials, d)
+	if len(app.denials) > 20 {
+		app.denials = app.denials[len(app.denials)-20:]
+	}
+}
+
+func (app *ToolsApp) SetSandboxWarning(warning string) {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
+	app.sandbox_warning = warning
+}
+
+func (app *ToolsApp) GetSandboxWarning() string {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
+	return app.sandbox_warning
+}
+
+func (app *ToolsApp) GetDenials() []ToolsAppDenial {
+	app.permissions_lock.Lock()
+	defer app.permissions_lock.Unlock()
+
+	return slices.Clone(app.denials)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolsAppPermissions_parse(t *testing.T) {
+	skyalt := "#Storage\nText string\n\n#Permissions\ncommands: get_mic_info, stop_mic\napps: Calculator\nLLM: Chat\nnetwork: yes\n\n#Tool ShowText\nShow text.\n"
+
+	perm, _, err := ToolsAppPermissions_parse(skyalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cmd, allowed := range map[string]bool{
+		"register":     true, //base
+		"progress":     true,
+		"get_mic_info": true,
+		"stop_mic":     true,
+		"run_app":      true, //'apps'
+		"llm_complete": true, //'llm'
+		"generate_app": false,
+		"rename_app":   false,
+		"get_logs":     false,
+	} {
+		if perm.IsCommandAllowed(cmd) != allowed {
+			t.Errorf("command '%s' should be allowed=%v", cmd, allowed)
+		}
+	}
+	if !perm.IsAppAllowed("Calculator") || perm.IsAppAllowed("Device") {
+		t.Errorf("apps: %v", perm.Apps)
+	}
+	if !perm.IsLLMAllowed("chat") || perm.IsLLMAllowed("tools") || perm.IsLLMAllowed("transcribe") {
+		t.Errorf("llm: %v", perm.LLM)
+	}
+	if !perm.Network {
+		t.Errorf("network should be granted")
+	}
+
+	//missing block
+	perm, _, err = ToolsAppPermissions_parse("#Tool ShowText\nShow text.\n")
+	if err != nil || perm != nil {
+		t.Fatalf("%v, %v", perm, err)
+	}
+
+	//hand-written app
+	var all *ToolsAppPermissions
+	if !all.IsCommandAllowed("generate_app") || !all.IsAppAllowed("Device") || !all.IsLLMAllowed("tools") {
+		t.Errorf("nil permissions should allow everything")
+	}
+}
+
+func TestToolsAppPermissions_parseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		skyalt string
+		line   int
+		msg    string
+	}{
+		{"#Permissions\ncommands: format_disk\n", 2, "unknown command 'format_disk'"},
+		{"#Permissions\nllm: code\n", 2, "unknown LLM use case 'code'"},
+		{"#Permissions\nllm chat\n", 2, "must be"},
+		{"#Permissions\nnetwork: maybe\n", 2, "'yes' or 'no'"},
+		{"#Tool A\n\n#Permissions\nfilesystem: any\n", 4, "unknown permission 'filesystem'"},
+		{"#Permissions\nllm: chat\n#Permissions\n", 3, "second '#permissions'"},
+	} {
+		_, line, err := ToolsAppPermissions_parse(tc.skyalt)
+		if err == nil || line != tc.line || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("%q: line %d, %v", tc.skyalt, line, err)
+		}
+	}
+}
+
+func TestToolsPrompts_permissionsBlock(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "skyalt"), []byte("#Storage\nText string\n\n#Permissions\nllm: chat\n\n#Tool ShowText\nShow text.\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prompts ToolsPrompts
+	_, err = prompts._reloadFromPromptFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts.Prompts) != 2 || prompts.Prompts[0].Prompt != "Text string" || prompts.Prompts[1].Name != "ShowText" {
+		t.Errorf("permissions block should be skipped: %+v", prompts.Prompts)
+	}
+}
+
+func TestToolsApp_GetPermissions(t *testing.T) {
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	app := &ToolsApp{Process: NewToolsAppProcess("Notes")}
+	os.MkdirAll(app.Process.Compile.GetFolderPath(), 0700)
+
+	//hand-written
+	if app.GetPermissions() != nil {
+		t.Errorf("hand-written app should have nil permissions")
+	}
+
+	err := os.WriteFile(app.getPromptFilePath(), []byte("#Permissions\nllm: chat\n\n#Tool ShowText\nShow text.\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//generated by older version, snapshot is taken when app is loaded
+	legacy, err := NewToolsApp("Notes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := legacy.GetPermissions(); perm == nil || !perm.IsLLMAllowed("chat") {
+		t.Errorf("legacy: %+v", perm)
+	}
+	os.Remove(legacy.GetToolsJsonPath())
+
+	app.SnapshotPermissions()
+	if perm := app.GetPermissions(); perm == nil || !perm.IsLLMAllowed("chat") || perm.IsCommandAllowed("generate_app") {
+		t.Errorf("snapshot: %+v", perm)
+	}
+
+	//rewritten file is ignored until next generation
+	os.WriteFile(app.getPromptFilePath(), []byte("#Permissions\ncommands: generate_app\nnetwork: yes\n"), 0644)
+	if perm := app.GetPermissions(); perm.IsCommandAllowed("generate_app") || perm.Network {
+		t.Errorf("rewritten file: %+v", perm)
+	}
+
+	//deleted file doesn't widen permissions
+	os.Remove(app.getPromptFilePath())
+	if perm := app.GetPermissions(); perm == nil || perm.IsCommandAllowed("generate_app") {
+		t.Errorf("deleted file: %+v", perm)
+	}
+
+	//snapshot is saved
+	err = app._save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewToolsApp("Notes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := loaded.GetPermissions(); perm == nil || !perm.IsLLMAllowed("chat") {
+		t.Errorf("loaded: %+v", perm)
+	}
+}
@@ -20,20 +20,35 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
 type ToolsAppProcess struct {
 	Compile *ToolsAppCompile
 
-	port       int
+	lock       sync.Mutex //guards process state below, it's read by router, supervisor and cmd.Wait() goroutine
+	port       int        //id of app's connection, 0 = not registered yet
 	cmd        *exec.Cmd
 	cmd_exited bool
 	cmd_error  string
+
+	start_time     time.Time
+	exit_requested bool   //exit wasn't crash
+	kill_reason    string //set by supervisor
+	ping_fails     int
+
+	crash_lock    sync.Mutex
+	crashes       []ToolsAppCrash //last ToolsAppProcess_maxCrashes
+	restart_delay time.Duration
+	restart_time  time.Time //zero = no restart is pending
 }
 
+const ToolsAppProcess_maxCrashes = 10
+
 func NewToolsAppProcess(appName string) *ToolsAppProcess {
 	app := &ToolsAppProcess{}
 
@@ -43,15 +58,54 @@ func NewToolsAppProcess(appName string) *ToolsAppProcess {
 }
 
 func (app *ToolsAppProcess) IsRunning() bool {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
 	return app.cmd != nil && !app.cmd_exited
 }
 
+func (app *ToolsAppProcess) GetPort() int {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	return app.port
+}
+
+func (app *ToolsAppProcess) SetPort(port int) {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	app.port = port
+}
+
+func (app *ToolsAppProcess) isExited() bool {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	return app.cmd_exited
+}
+
 func (app *ToolsAppProcess) Destroy(waitTillEnd bool) error {
-	if app.IsRunning() {
-		cl, err := NewToolsClient("localhost", app.port)
+	app.crash_lock.Lock()
+	app.restart_delay = 0
+	app.restart_time = time.Time{}
+	app.crash_lock.Unlock()
+
+	app.lock.Lock()
+	running := (app.cmd != nil && !app.cmd_exited)
+	if running {
+		app.exit_requested = true
+	}
+	port := app.port
+	app.lock.Unlock()
+
+	if running {
+		cl, err := AppsConns_Open(port)
 		if err != nil {
 			return err
 		}
+		defer cl.Destroy()
+
 		err = cl.WriteArray([]byte("exit"))
 		if err != nil {
 			return err
@@ -59,7 +113,7 @@ func (app *ToolsAppProcess) Destroy(waitTillEnd bool) error {
 	}
 
 	if waitTillEnd {
-		if !app.cmd_exited {
+		if !app.isExited() {
 			app.WaitUntilExited()
 		}
 	}
@@ -69,43 +123,120 @@ func (app *ToolsAppProcess) Destroy(waitTillEnd bool) error {
 
 func (app *ToolsAppProcess) WaitUntilExited() string {
 	n := 0
-	for n < 100 && !app.cmd_exited {
+	for n < 100 && !app.isExited() {
 		time.Sleep(10 * time.Millisecond)
 		n++
 	}
+
+	app.lock.Lock()
+	defer app.lock.Unlock()
 	return app.cmd_error
 }
 
-func (app *ToolsAppProcess) CheckRun(router *AppsRouter) error {
+// perm is nil for hand-written apps, they never run in sandbox
+func (app *ToolsAppProcess) CheckRun(router *AppsRouter, perm *ToolsAppPermissions) error {
 	if !app.IsRunning() {
+		app.crash_lock.Lock()
+		wait := time.Until(app.restart_time)
+		app.crash_lock.Unlock()
+		if wait > 0 {
+			return fmt.Errorf("'%s' app crashed, restart in %.1fsec", app.Compile.appName, wait.Seconds())
+		}
 
-		if app.cmd_exited {
+		if app.isExited() {
 			app.WaitUntilExited()
 		}
 
+		app.lock.Lock()
 		app.cmd_exited = false
 		app.cmd_error = ""
 		app.port = 0
 		app.cmd = nil
+		app.exit_requested = false
+		app.kill_reason = ""
+		app.ping_fails = 0
+		app.lock.Unlock()
 
 		//start
-		cmd := exec.Command("./"+app.Compile.GetBinName(), app.Compile.appName, strconv.Itoa(router.server.port))
+		bin := "./" + app.Compile.GetBinName()
+		args := []string{app.Compile.appName, router.server.addr}
+		sandboxed := (perm != nil && AppsSandbox_enabled())
+		var cmd *exec.Cmd
+		if sandboxed {
+			routerPort := OsTrn(router.server.sock_dir == "", router.server.port, 0)
+
+			//'network: no' must be enforced
+			warning := ""
+			if !perm.Network {
+				weak := AppsSandbox_networkWeakness(routerPort)
+				if weak != "" {
+					if !AppsSandbox_allowWeakNetwork() {
+						return LogsErrorf("'%s' app has 'network: no', but sandbox can't block network: %s. Set SKYALT_SANDBOX_WEAK_NETWORK=1 to run it without network restriction", app.Compile.appName, weak)
+					}
+					warning = "network isn't blocked: " + weak
+				}
+			}
+			tapp := router.FindApp(app.Compile.appName)
+			if tapp != nil {
+				tapp.SetSandboxWarning(warning)
+			}
+
+			var err error
+			cmd, err = AppsSandbox_command(bin, args, perm.Network, routerPort)
+			if err != nil {
+				return LogsError(err)
+			}
+		} else {
+			cmd = exec.Command(bin, args...)
+		}
+
+		secret := router.server.NewSecret(app.Compile.appName)
 		cmd.Dir = app.Compile.GetFolderPath()
+		cmd.Env = append(os.Environ(), "SKYALT_SESSION_SECRET="+secret)
+		if perm != nil {
+			cmd.Env = append(cmd.Env, "SKYALT_APP_DATA="+AppsSandbox_dataFolder)
+		}
 		OutStr := new(strings.Builder)
 		ErrStr := new(strings.Builder)
 		cmd.Stdout = OutStr
 		cmd.Stderr = ErrStr
 		err := cmd.Start()
 		if err != nil {
+			router.server.RemoveSecret(secret)
 			return LogsErrorf("'%s' start failed: %w", app.Compile.GetFolderPath(), err)
 		}
+		app.lock.Lock()
 		app.cmd = cmd //running
+		app.start_time = time.Now()
+		app.lock.Unlock()
+		app.crash_lock.Lock()
+		app.restart_time = time.Time{}
+		app.crash_lock.Unlock()
 
 		fmt.Printf("App '%s' has started\n", app.Compile.GetFolderPath())
 
 		//run tool
 		go func() {
-			app.cmd.Wait()
+			waitErr := cmd.Wait()
+			router.server.RemoveSecret(secret)
+
+			reason := ""
+			if sandboxed {
+				reason = AppsSandbox_exitReason(cmd.ProcessState, ErrStr.String())
+				if reason != "" {
+					tapp := router.FindApp(app.Compile.appName)
+					if tapp != nil {
+						tapp.AddDenial("sandbox", reason)
+					}
+				}
+			}
+			crash := app.addExit(waitErr, ErrStr.String(), reason)
+			if crash != nil {
+				tapp := router.FindApp(app.Compile.appName)
+				if tapp != nil {
+					tapp.AddRuntimeErrorFromStack(crash.Error, crash.Frames)
+				}
+			}
 
 			if OutStr.Len() > 0 {
 				fmt.Printf("'%s' app output: %s\n", app.Compile.GetFolderPath(), OutStr.String())
@@ -115,19 +246,25 @@ func (app *ToolsAppProcess) CheckRun(router *AppsRouter) error {
 			}
 
 			wd, _ := os.Getwd()
+			app.lock.Lock()
 			app.cmd_error = strings.ReplaceAll(ErrStr.String(), wd, "")
 			app.cmd_exited = true
 			app.cmd = nil
+			app.lock.Unlock()
 		}()
 
 		//wait one second for recv a port
 		{
 			n := 0
-			for n < 100 && app.port == 0 {
+			for n < 100 && app.GetPort() == 0 {
 				time.Sleep(10 * time.Millisecond)
 				n++
 			}
-			if app.port == 0 {
+			if app.GetPort() == 0 {
+				err := router.GetProtocolError(app.Compile.appName)
+				if err != nil {
+					return LogsError(err)
+				}
 				fmt.Printf("'%s' app process hasn't connected in time\n", app.Compile.GetFolderPath())
 			}
 		}
@@ -136,3 +273,93 @@ func (app *ToolsAppProcess) CheckRun(router *AppsRouter) error {
 
 	return nil //ok
 }
+
+// records crash and plans restart with exponential backoff. sandboxReason is from AppsSandbox_exitReason(). Returns nil for clean exit.
+func (app *ToolsAppProcess) addExit(waitErr error, stderr string, sandboxReason string) *ToolsAppCrash {
+	app.lock.Lock()
+	uptime := time.Since(app.start_time)
+	exit_requested := app.exit_requested
+	kill_reason := app.kill_reason
+	app.lock.Unlock()
+
+	app.crash_lock.Lock()
+	defer app.crash_lock.Unlock()
+
+	if exit_requested || (waitErr == nil && kill_reason == "") {
+		app.restart_delay = 0 //clean exit
+		app.restart_time = time.Time{}
+		return nil
+	}
+
+	absFolder, _ := filepath.Abs(app.Compile.GetFolderPath())
+	crash := ToolsAppCrash_parse(stderr, absFolder)
+	crash.Time = time.Now().Unix()
+	crash.Uptime = uptime.Seconds()
+	if kill_reason != "" {
+		crash.Error = kill_reason
+	} else if sandboxReason != "" {
+		crash.Error = "sandbox: " + sandboxReason
+	}
+	if crash.Error == "" {
+		if waitErr != nil {
+			crash.Error = waitErr.Error()
+		} else {
+			crash.Error = "unknown exit"
+		}
+	}
+
+	app.crashes = append(app.crashes, crash)
+	if len(app.crashes) > ToolsAppProcess_maxCrashes {
+		app.crashes = app.crashes[len(app.crashes)-ToolsAppProcess_maxCrashes:]
+	}
+
+	//backoff
+	if app.restart_delay == 0 || uptime > AppsSupervisor_stableUptime {
+		app.restart_delay = AppsSupervisor_minBackoff
+	} else {
+		app.restart_delay = min(app.restart_delay*2, AppsSupervisor_maxBackoff)
+	}
+	app.restart_time = time.Now().Add(app.restart_delay)
+
+	LogsErrorf("'%s' app crashed after %.1fsec: %s, restart in %v", app.Compile.appName, crash.Uptime, crash.String(), app.restart_delay)
+	return &crash
+}
+
+func (app *ToolsAppProcess) GetCrashes() []ToolsAppCrash {
+	app.crash_lock.Lock()
+	defer app.crash_lock.Unlock()
+
+	return slices.Clone(app.crashes)
+}
+
+// true, when crashed app should be started again
+func (app *ToolsAppProcess) NeedRestart() bool {
+	app.crash_lock.Lock()
+	defer app.crash_lock.Unlock()
+
+	return !app.restart_time.IsZero() && time.Now().After(app.restart_time)
+}
+
+// returns true, if app was killed
+func (app *ToolsAppProcess) CheckPing(err error) bool {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	if err == nil {
+		app.ping_fails = 0
+		return false
+	}
+
+	app.ping_fails++
+	if app.ping_fails < AppsSupervisor_maxPingFails {
+		return false
+	}
+
+	cmd := app.cmd
+	if cmd == nil || cmd.Process == nil {
+		return false
+	}
+	app.kill_reason = fmt.Sprintf("not responding(%d pings failed)", app.ping_fails)
+	cmd.Process.Kill()
+	return true
+}
@@ -17,11 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,12 +43,14 @@ const (
 	ToolsPrompt_FUNCTION
 	ToolsPrompt_TOOL
 	ToolsPrompt_START
+	ToolsPrompt_TEST
 )
 
 type ToolsPromptCode struct {
 	Code   string
 	Errors []ToolsCodeError
 	Usage  LLMMsgUsage
+	Reason string `json:",omitempty"` //why code was regenerated, for example runtime error
 }
 
 type ToolsPrompt struct {
@@ -65,7 +71,14 @@ type ToolsPrompt struct {
 
 	//Usage LLMMsgUsage
 
+	//content hashes from last generation
+	PromptHash string //Prompt text
+	DepsHash   string //declarations from Deps
+
+	Deps []ToolsPromptDep //symbols from Storage and Functions used by code
+
 	previousMessages []byte
+	regenerate       bool
 }
 
 func NewToolsPrompt(Type ToolsPromptTYPE, Name string) *ToolsPrompt {
@@ -76,6 +89,30 @@ func (prompt *ToolsPrompt) IsCodeWithoutErrors() bool {
 	return (len(prompt.CodeVersions) > 0 && len(prompt.CodeVersions[len(prompt.CodeVersions)-1].Errors) == 0)
 }
 
+func (prompt *ToolsPrompt) NeedsGenerate() bool {
+	return prompt.regenerate || !prompt.IsCodeWithoutErrors()
+}
+
+// copy generated code, messages and hashes from previous version of same prompt
+func (prompt *ToolsPrompt) keepGenerated(old *ToolsPrompt) {
+	prompt.Messages = old.Messages
+	prompt.CodeVersions = old.CodeVersions
+	prompt.Schema = old.Schema
+	prompt.PromptHash = old.PromptHash
+	prompt.DepsHash = old.DepsHash
+	prompt.Deps = old.Deps
+	prompt.previousMessages = old.previousMessages
+}
+
+// '#Test <ToolName>' prompt is named '<ToolName>_test'
+func (prompt *ToolsPrompt) GetTestedToolName() string {
+	if prompt.Type != ToolsPrompt_TEST {
+		return ""
+	}
+	name, _ := strings.CutSuffix(prompt.Name, "_test")
+	return name
+}
+
 func (prompt *ToolsPrompt) GetLastCode() string {
 	if len(prompt.CodeVersions) == 0 {
 		return ""
@@ -83,12 +120,13 @@ func (prompt *ToolsPrompt) GetLastCode() string {
 	return prompt.CodeVersions[len(prompt.CodeVersions)-1].Code
 }
 
-func (prompt *ToolsPrompt) updateSchema() error {
+// typesCodes are codes of all app's files, argument's types can be defined there
+func (prompt *ToolsPrompt) updateSchema(typesCodes []string) error {
 	if prompt.Type != ToolsPrompt_TOOL || len(prompt.CodeVersions) == 0 {
 		return nil
 	}
 
-	schema, err := BuildToolsOpenAI_completion_tool(prompt.Name, prompt.Name+".go", prompt.GetLastCode())
+	schema, err := BuildToolsOpenAI_completion_tool(prompt.Name, prompt.Name+".go", prompt.GetLastCode(), typesCodes...)
 	if err != nil {
 		return err
 	}
@@ -215,6 +253,8 @@ func (prompts *ToolsPrompts) SetCodeErrors(errs []ToolsCodeError, app *ToolsApp)
 
 	//add
 	for _, er := range errs {
+		er = prompts._routeTestError(er)
+
 		file_name := filepath.Base(er.File)
 		file_name, _ = strings.CutSuffix(file_name, filepath.Ext(er.File))
 		file_name, _ = strings.CutPrefix(file_name, "./")
@@ -230,6 +270,38 @@ func (prompts *ToolsPrompts) SetCodeErrors(errs []ToolsCodeError, app *ToolsApp)
 	}
 }
 
+// tool, which failed same test this many times, is probably correct and test is wrong
+const ToolsPrompt_maxSameTestFails = 2
+
+// failed test is error of tested tool. Test written by LLM, which failed same way with previous versions of tool, is error of test, so it's regenerated.
+func (prompts *ToolsPrompts) _routeTestError(er ToolsCodeError) ToolsCodeError {
+	if !er.Test {
+		return er
+	}
+	toolName, _ := strings.CutSuffix(filepath.Base(er.File), ".go")
+	toolPrompt := prompts.FindPromptName(toolName)
+	testPrompt := prompts.FindPromptName(toolName + "_test")
+	if toolPrompt == nil || testPrompt == nil || testPrompt.Type != ToolsPrompt_TEST {
+		return er
+	}
+	if _, isJSON, _ := testPrompt._buildJSONTestCode(); isJSON {
+		return er //written by user
+	}
+
+	fails := 0
+	for _, ver := range toolPrompt.CodeVersions {
+		for _, it := range ver.Errors {
+			if it.Test && it.Msg == er.Msg {
+				fails++
+			}
+		}
+	}
+	if fails >= ToolsPrompt_maxSameTestFails {
+		er.File = testPrompt.Name + ".go"
+	}
+	return er
+}
+
 func (prompts *ToolsPrompts) FindStorage() *ToolsPrompt {
 	return prompts.FindPromptName("Storage")
 }
@@ -241,6 +313,14 @@ func (prompts *ToolsPrompts) HasFunction() bool {
 	}
 	return false
 }
+func (prompts *ToolsPrompts) HasTest() bool {
+	for _, prompt := range prompts.Prompts {
+		if prompt.Type == ToolsPrompt_TEST {
+			return true
+		}
+	}
+	return false
+}
 
 func (prompts *ToolsPrompts) FindPromptName(name string) *ToolsPrompt {
 	for _, prompt := range prompts.Prompts {
@@ -264,7 +344,7 @@ func (prompts *ToolsPrompts) _reloadFromCodeFiles(folderPath string) error {
 
 	//add new tools
 	for _, info := range files {
-		if info.IsDir() || filepath.Ext(info.Name()) != ".go" || info.Name() == "main.go" {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".go" || info.Name() == "main.go" || strings.HasSuffix(info.Name(), "_test.go") {
 			continue
 		}
 
@@ -282,11 +362,6 @@ func (prompts *ToolsPrompts) _reloadFromCodeFiles(folderPath string) error {
 
 		item := NewToolsPrompt(tp, toolName)
 		item.CodeVersions = append(item.CodeVersions, ToolsPromptCode{Code: string(code)})
-		err = item.updateSchema()
-		if err != nil {
-			return err
-		}
-
 		prompts.Prompts = append(prompts.Prompts, item)
 
 	}
@@ -305,11 +380,13 @@ func (prompts *ToolsPrompts) _reloadFromCodeFiles(folderPath string) error {
 		}
 	}
 
-	return nil
+	return prompts.UpdateSchemas()
 }
 
 func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, error) {
 
+	oldPrompts := prompts.Prompts
+
 	//reset
 	prompts.Prompts = nil
 	prompts.Err = ""
@@ -321,9 +398,19 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 		return false, err
 	}
 
+	//validate capabilities, they are loaded by router
+	_, errLine, err := ToolsAppPermissions_parse(string(fl))
+	if err != nil {
+		prompts.Err = err.Error()
+		prompts.Err_line = errLine
+		return false, LogsErrorf("%s", prompts.Err)
+	}
+
 	saveFile := false
 	structFound := false
 	startFound := false
+	inPermissions := false
+	testLines := make(map[string]int) //[toolName]line
 	var last_prompt *ToolsPrompt
 	lines := strings.Split(string(fl), "\n")
 	for i, ln := range lines {
@@ -334,17 +421,31 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 		isFunction := strings.HasPrefix(strings.ToLower(ln), "#function")
 		isTool := strings.HasPrefix(strings.ToLower(ln), "#tool")
 		isStart := strings.HasPrefix(strings.ToLower(ln), "#start")
+		isTest := strings.HasPrefix(strings.ToLower(ln), "#test")
+		isPermissions := strings.HasPrefix(strings.ToLower(ln), "#permissions")
+
+		if isHash {
+			inPermissions = isPermissions
+		}
+		if inPermissions {
+			//not a prompt
+			if isPermissions && last_prompt != nil {
+				prompts.Prompts = append(prompts.Prompts, last_prompt)
+				last_prompt = nil
+			}
+			continue
+		}
 
 		if isStorage && structFound {
 			prompts.Err = "second '#storage' is not allowed"
 			prompts.Err_line = i + 1
-			return false, LogsErrorf(prompts.Err)
+			return false, LogsErrorf("%s", prompts.Err)
 		}
 
 		if isStart && startFound {
 			prompts.Err = "second '#start' is not allowed"
 			prompts.Err_line = i + 1
-			return false, LogsErrorf(prompts.Err)
+			return false, LogsErrorf("%s", prompts.Err)
 		}
 
 		if isHash {
@@ -361,16 +462,20 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 				toolName = "Start"
 			} else if isTool {
 				Type = ToolsPrompt_TOOL
+			} else if isTest {
+				Type = ToolsPrompt_TEST
 			} else {
-				prompts.Err = "'#' must follow with 'storage', 'function', 'tool' or 'start'"
+				prompts.Err = "'#' must follow with 'storage', 'function', 'tool', 'test', 'start' or 'permissions'"
 				prompts.Err_line = i + 1
-				return false, LogsErrorf(prompts.Err)
+				return false, LogsErrorf("%s", prompts.Err)
 			}
 
-			if isFunction || isTool {
+			if isFunction || isTool || isTest {
 
 				if isFunction {
 					toolName = ln[len("#function"):] //skip
+				} else if isTest {
+					toolName = ln[len("#test"):] //skip
 				} else {
 					toolName = ln[len("#tool"):] //skip
 				}
@@ -380,7 +485,13 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 				if newToolName == "" {
 					prompts.Err = "missing name"
 					prompts.Err_line = i + 1
-					return false, LogsErrorf(prompts.Err)
+					return false, LogsErrorf("%s", prompts.Err)
+				}
+
+				if strings.HasSuffix(newToolName, "_test") {
+					prompts.Err = "name can't end with '_test'" //reserved for test files
+					prompts.Err_line = i + 1
+					return false, LogsErrorf("%s", prompts.Err)
 				}
 
 				if toolName != newToolName {
@@ -388,6 +499,8 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 
 					if isFunction {
 						ln = "#Function " + newToolName
+					} else if isTest {
+						ln = "#Test " + newToolName
 					} else {
 						ln = "#Tool " + newToolName
 					}
@@ -397,6 +510,16 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 				}
 			}
 
+			if isTest {
+				if _, found := testLines[toolName]; found {
+					prompts.Err = fmt.Sprintf("second '#test %s' is not allowed", toolName)
+					prompts.Err_line = i + 1
+					return false, LogsErrorf("%s", prompts.Err)
+				}
+				testLines[toolName] = i + 1
+				toolName += "_test" //<ToolName>_test.go
+			}
+
 			//save
 			if last_prompt != nil {
 				prompts.Prompts = append(prompts.Prompts, last_prompt)
@@ -413,7 +536,7 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 			if last_prompt == nil && ln != "" {
 				prompts.Err = "missing '#storage' or '#tool' header"
 				prompts.Err_line = i + 1
-				return false, LogsErrorf(prompts.Err)
+				return false, LogsErrorf("%s", prompts.Err)
 			}
 
 			if last_prompt != nil {
@@ -432,6 +555,29 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 		prompt.Prompt = strings.Trim(prompt.Prompt, "\n ")
 	}
 
+	//check tested tools
+	for _, prompt := range prompts.Prompts {
+		if prompt.Type != ToolsPrompt_TEST {
+			continue
+		}
+		tool := prompts.FindPromptName(prompt.GetTestedToolName())
+		if tool == nil || tool.Type != ToolsPrompt_TOOL {
+			prompts.Err = fmt.Sprintf("'#test %s' has no '#tool %s'", prompt.GetTestedToolName(), prompt.GetTestedToolName())
+			prompts.Err_line = testLines[prompt.GetTestedToolName()]
+			return false, LogsErrorf("%s", prompts.Err)
+		}
+	}
+
+	//keep generated code from previous prompts
+	for _, prompt := range prompts.Prompts {
+		for _, old := range oldPrompts {
+			if old.Name == prompt.Name && old.Type == prompt.Type {
+				prompt.keepGenerated(old)
+				break
+			}
+		}
+	}
+
 	//extract start prompt
 	for i, prompt := range prompts.Prompts {
 		if prompt.Type == ToolsPrompt_START {
@@ -454,6 +600,20 @@ func (prompts *ToolsPrompts) _reloadFromPromptFile(folderPath string) (bool, err
 }
 
 func (prompts *ToolsPrompts) generatePromptCode(prompt *ToolsPrompt, msg *AppsRouterMsg, llms *LLMs) error {
+
+	//test with only JSON cases doesn't need LLM
+	if prompt.Type == ToolsPrompt_TEST {
+		code, isJSON, err := prompt._buildJSONTestCode()
+		if err != nil {
+			return err
+		}
+		if isJSON {
+			prompt.CodeVersions = append(prompt.CodeVersions, ToolsPromptCode{Code: code})
+			prompts._updateHashes(prompt)
+			return nil
+		}
+	}
+
 	comp := NewLLMCompletion()
 
 	var err error
@@ -473,6 +633,11 @@ func (prompts *ToolsPrompts) generatePromptCode(prompt *ToolsPrompt, msg *AppsRo
 		if err != nil {
 			return err
 		}
+	case ToolsPrompt_TEST:
+		comp.SystemMessage, comp.UserMessage, err = prompts._getTestMsg(prompt)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("prompt '%d:%s' is unknown type", prompt.Type, prompt.Name)
 	}
@@ -522,15 +687,47 @@ func (prompts *ToolsPrompts) generatePromptCode(prompt *ToolsPrompt, msg *AppsRo
 
 			//add list of errors
 			lines := strings.Split(last_code.Code, "\n")
+			var testErrors []string
+			var runtimeErrors []string
+			var otherErrors []string //compiler/vet errors without line
 			for _, er := range last_code.Errors {
+				if er.Test {
+					testErrors = append(testErrors, "- "+er.Msg)
+				}
+				if er.Runtime {
+					str := "- Error: " + er.Msg
+					if er.Params != "" {
+						str += "\n  Called with parameters: " + er.Params
+					}
+					if er.Stack != "" {
+						str += "\n  Stack trace:\n    " + strings.ReplaceAll(er.Stack, "\n", "\n    ")
+					}
+					runtimeErrors = append(runtimeErrors, str)
+				}
 				ln := er.Line - 1
 				if ln >= 0 && ln < len(lines) {
-					lines[ln] = fmt.Sprintf("%s\t//Error(Col %d): %s", lines[ln], er.Col, er.Msg)
+					lines[ln] = fmt.Sprintf("%s\t//Error(Col %d): %s", lines[ln], er.Col, strings.ReplaceAll(er.Msg, "\n", " "))
+				} else if !er.Test && !er.Runtime {
+					otherErrors = append(otherErrors, "- "+er.Msg)
 				}
 			}
 			code := strings.Join(lines, "\n")
 			comp.UserMessage = "```go" + code + "```\n"
-			comp.UserMessage += "Above code has compiler error(s), marked in line comments(//Error). Please fix them by rewriting above code(you must output single file). Also remove comments with errors."
+			if len(runtimeErrors) > 0 {
+				comp.UserMessage += "Above code compiles, but it failed at runtime:\n" + strings.Join(runtimeErrors, "\n") + "\n"
+				comp.UserMessage += "Please fix the bug by rewriting above code(you must output single file), so the same call passes. Keep the struct and its attributes. Also remove comments with errors(//Error), if there are any."
+			} else if len(testErrors) == 0 {
+				comp.UserMessage += "Above code has compiler or 'go vet' error(s), marked in line comments(//Error). Please fix them by rewriting above code(you must output single file). Also remove comments with errors."
+				if len(otherErrors) > 0 {
+					comp.UserMessage += "\nOther errors:\n" + strings.Join(otherErrors, "\n") + "\n"
+				}
+			} else if prompt.Type == ToolsPrompt_TEST {
+				comp.UserMessage += "Above test keeps failing, although tool's code was fixed several times:\n" + strings.Join(testErrors, "\n") + "\n"
+				comp.UserMessage += "Tool's code is probably correct and test's expectation is wrong. Please check it against the test description and tool's code and fix the test by rewriting above code(you must output single file). Also remove comments with errors(//Error), if there are any."
+			} else {
+				comp.UserMessage += "Above code compiles, but it doesn't pass these test(s):\n" + strings.Join(testErrors, "\n") + "\n"
+				comp.UserMessage += "Please fix the behavior by rewriting above code(you must output single file). Keep the struct and its attributes. Also remove comments with errors(//Error), if there are any."
+			}
 		}
 	}
 
@@ -557,9 +754,45 @@ func (prompts *ToolsPrompts) generatePromptCode(prompt *ToolsPrompt, msg *AppsRo
 		return err
 	}
 
+	prompts._updateHashes(prompt)
+
 	return nil
 }
 
+func (prompts *ToolsPrompts) _updateHashes(prompt *ToolsPrompt) {
+	prompts.updateDeps(prompt)
+	prompt.PromptHash = _ToolsPrompt_getHash(prompt.Prompt)
+	prompt.DepsHash = prompts.getDepsHash(prompt)
+	prompt.regenerate = false
+}
+
+// marks prompts(of type), which prompt text or dependencies has changed since last generation. Returns true if some prompt needs to be generated.
+func (prompts *ToolsPrompts) CheckChanges(tp ToolsPromptTYPE) bool {
+	needs := false
+	for _, prompt := range prompts.Prompts {
+		if prompt.Type != tp {
+			continue
+		}
+
+		if prompt.PromptHash == "" && prompt.DepsHash == "" && prompt.IsCodeWithoutErrors() {
+			prompts._updateHashes(prompt) //tools.json from before hashes, code is up to date
+		}
+
+		if prompt.Deps == nil {
+			prompts.updateDeps(prompt) //older tools.json
+		}
+
+		if prompt.PromptHash != _ToolsPrompt_getHash(prompt.Prompt) || prompt.DepsHash != prompts.getDepsHash(prompt) {
+			prompt.regenerate = true
+		}
+
+		if prompt.NeedsGenerate() {
+			needs = true
+		}
+	}
+	return needs
+}
+
 func (prompts *ToolsPrompts) RemoveOldCodeFiles(folderPath string) error {
 
 	files, err := os.ReadDir(folderPath)
@@ -577,11 +810,12 @@ func (prompts *ToolsPrompts) RemoveOldCodeFiles(folderPath string) error {
 	return nil
 }
 
-func (prompts *ToolsPrompts) WriteFiles(folderPath string, secrets *ToolsSecrets) error {
+// writes prompts up to 'maxType'(Storage < Functions < Tools)
+func (prompts *ToolsPrompts) WriteFiles(folderPath string, secrets *ToolsSecrets, maxType ToolsPromptTYPE) error {
 
 	//write code into files
 	for _, prompt := range prompts.Prompts {
-		if prompt.Name == "" || len(prompt.CodeVersions) == 0 {
+		if prompt.Name == "" || len(prompt.CodeVersions) == 0 || prompt.Type > maxType {
 			continue
 		}
 		new_code := secrets.ReplaceAliases(prompt.GetLastCode())
@@ -600,8 +834,15 @@ func (prompts *ToolsPrompts) WriteFiles(folderPath string, secrets *ToolsSecrets
 }
 
 func (prompts *ToolsPrompts) UpdateSchemas() error {
+	var codes []string
 	for _, prompt := range prompts.Prompts {
-		err := prompt.updateSchema()
+		if code := prompt.GetLastCode(); code != "" {
+			codes = append(codes, code)
+		}
+	}
+
+	for _, prompt := range prompts.Prompts {
+		err := prompt.updateSchema(codes)
 		if err != nil {
 			return err
 		}
@@ -649,9 +890,11 @@ func (prompts *ToolsPrompts) _getStorageMsg(storagePrompt *ToolsPrompt) (string,
 		return "", "", err
 	}
 
+	sysMsg := strings.ReplaceAll(string(systemMessage), "[REPLACE_IMPORTS]", AppsModules_PromptText())
+
 	userMessage := storagePrompt.Prompt
 
-	return string(systemMessage), userMessage, nil
+	return sysMsg, userMessage, nil
 }
 
 func (prompts *ToolsPrompts) _getFunctionMsg(functionPrompt *ToolsPrompt) (string, string, error) {
@@ -669,6 +912,7 @@ func (prompts *ToolsPrompts) _getFunctionMsg(functionPrompt *ToolsPrompt) (strin
 	sysMsg := string(systemMessage)
 	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_FUNC_NAME]", functionPrompt.Name)
 	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_STORAGE_CODE]", storage_code)
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_IMPORTS]", AppsModules_PromptText())
 
 	userMessage := functionPrompt.Prompt
 
@@ -703,12 +947,119 @@ func (prompts *ToolsPrompts) _getToolMsg(prompt *ToolsPrompt) (string, string, e
 	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_STORAGE_CODE]", storage_code)
 	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_FUNCTIONS_CODE]", prompts.getFunctionsHeadersCode())
 	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_EXAMPLE_CODE]", string(example_code))
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_IMPORTS]", AppsModules_PromptText())
+
+	userMessage := prompt.Prompt
+
+	return sysMsg, userMessage, nil
+}
+
+func (prompts *ToolsPrompts) _getTestMsg(prompt *ToolsPrompt) (string, string, error) {
+
+	var storage_code string
+	storagePrompt := prompts.FindStorage()
+	if storagePrompt != nil {
+		storage_code = storagePrompt.GetLastCode()
+	}
+
+	var tool_code string
+	toolPrompt := prompts.FindPromptName(prompt.GetTestedToolName())
+	if toolPrompt != nil {
+		tool_code = toolPrompt.GetLastCode()
+	}
+
+	systemMessage, err := os.ReadFile("sdk/prompt_test.md")
+	if err != nil {
+		return "", "", err
+	}
+	sysMsg := string(systemMessage)
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_TOOL_NAME]", prompt.GetTestedToolName())
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_STORAGE_CODE]", storage_code)
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_FUNCTIONS_CODE]", prompts.getFunctionsHeadersCode())
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_TOOL_CODE]", tool_code)
+	sysMsg = strings.ReplaceAll(sysMsg, "[REPLACE_IMPORTS]", AppsModules_PromptText())
 
 	userMessage := prompt.Prompt
 
 	return sysMsg, userMessage, nil
 }
 
+// Converts '#Test' with only 'Input: <json>' and 'Expected: <json>' lines into test code. Returns false if prompt has other lines or values aren't JSON(natural-language assertions), LLM writes test then.
+func (prompt *ToolsPrompt) _buildJSONTestCode() (string, bool, error) {
+	type TestCase struct {
+		input       string
+		expected    string
+		hasExpected bool
+	}
+	var cases []TestCase
+
+	for i, ln := range strings.Split(prompt.Prompt, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			continue
+		}
+
+		lower := strings.ToLower(ln)
+		isInput := strings.HasPrefix(lower, "input:")
+		isExpected := strings.HasPrefix(lower, "expected:")
+		if !isInput && !isExpected {
+			return "", false, nil
+		}
+
+		js := ""
+		if isInput {
+			js = strings.TrimSpace(ln[len("input:"):])
+		} else {
+			js = strings.TrimSpace(ln[len("expected:"):])
+		}
+		if !json.Valid([]byte(js)) {
+			return "", false, nil //'Input: two numbers 2 and 3'
+		}
+
+		if isInput {
+			cases = append(cases, TestCase{input: js, expected: "{}"}) //without 'Expected:' only checks that tool doesn't return error
+		} else {
+			if len(cases) == 0 || cases[len(cases)-1].hasExpected {
+				return "", false, fmt.Errorf("'#test %s' line %d: 'Expected:' must follow 'Input:'", prompt.GetTestedToolName(), i+1)
+			}
+			cases[len(cases)-1].expected = js
+			cases[len(cases)-1].hasExpected = true
+		}
+	}
+	if len(cases) == 0 {
+		return "", false, nil
+	}
+
+	var code strings.Builder
+	code.WriteString("package main\n\nimport \"testing\"\n\n")
+	code.WriteString(fmt.Sprintf("func Test%s(t *testing.T) {\n", prompt.GetTestedToolName()))
+	code.WriteString("\tcases := []struct {\n\t\tinput    string\n\t\texpected string\n\t}{\n")
+	for _, cs := range cases {
+		code.WriteString(fmt.Sprintf("\t\t{input: %s, expected: %s},\n", strconv.Quote(cs.input), strconv.Quote(cs.expected)))
+	}
+	code.WriteString("\t}\n\n")
+	code.WriteString(fmt.Sprintf(`	for i, cs := range cases {
+		outJs, err := SdkTestCallTool("%s", []byte(cs.input))
+		if err != nil {
+			t.Errorf("case %%d: input %%s: tool returned error: %%v", i+1, cs.input, err)
+			continue
+		}
+		err = SdkTestCheckOut(outJs, []byte(cs.expected))
+		if err != nil {
+			t.Errorf("case %%d: input %%s: %%v", i+1, cs.input, err)
+		}
+	}
+}
+`, prompt.GetTestedToolName()))
+
+	return code.String(), true, nil
+}
+
+func _ToolsPrompt_getHash(str string) string {
+	h := sha256.Sum256([]byte(str))
+	return hex.EncodeToString(h[:])
+}
+
 func _ToolsPrompt_getValidFileName(s string) string {
 	s = strings.TrimSpace(s)
 
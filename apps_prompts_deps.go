@@ -0,0 +1,186 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+)
+
+type ToolsPromptDep struct {
+	Prompt string //Storage, function or tested tool prompt name
+	Symbol string //type, function, method, var or const name
+}
+
+// Storage and Function prompts are providers of symbols for other prompts
+func (prompt *ToolsPrompt) isDepsProvider() bool {
+	return prompt.Type == ToolsPrompt_STORAGE || prompt.Type == ToolsPrompt_FUNCTION
+}
+
+// Tool is provider of symbols(struct) only for its test
+func (prompt *ToolsPrompt) isDepsProviderFor(user *ToolsPrompt) bool {
+	return prompt.isDepsProvider() || (user.Type == ToolsPrompt_TEST && prompt.Type == ToolsPrompt_TOOL && prompt.Name == user.GetTestedToolName())
+}
+
+// updates list of symbols(from Storage and Functions), which prompt's code uses
+func (prompts *ToolsPrompts) updateDeps(prompt *ToolsPrompt) {
+	prompt.Deps = []ToolsPromptDep{} //not nil, so it's saved as already updated
+
+	if prompt.Type == ToolsPrompt_STORAGE {
+		return
+	}
+
+	used := _ToolsPrompt_getUsedIdents(prompt.GetLastCode())
+	if used == nil {
+		return
+	}
+
+	for _, provider := range prompts.Prompts {
+		if provider == prompt || !provider.isDepsProviderFor(prompt) {
+			continue
+		}
+
+		decls := _ToolsPrompt_getDeclarations(provider.GetLastCode())
+
+		var symbols []string
+		for symbol := range decls {
+			if used[symbol] {
+				symbols = append(symbols, symbol)
+			}
+		}
+		sort.Strings(symbols)
+
+		for _, symbol := range symbols {
+			prompt.Deps = append(prompt.Deps, ToolsPromptDep{Prompt: provider.Name, Symbol: symbol})
+		}
+	}
+}
+
+// returns hash of declarations which prompt depends on
+func (prompts *ToolsPrompts) getDepsHash(prompt *ToolsPrompt) string {
+	if prompt.Type == ToolsPrompt_STORAGE {
+		return ""
+	}
+
+	declsCache := make(map[string]map[string]string)
+
+	var str bytes.Buffer
+	for _, dep := range prompt.Deps {
+		decls, found := declsCache[dep.Prompt]
+		if !found {
+			provider := prompts.FindPromptName(dep.Prompt)
+			if provider != nil {
+				decls = _ToolsPrompt_getDeclarations(provider.GetLastCode())
+			}
+			declsCache[dep.Prompt] = decls
+		}
+
+		str.WriteString(dep.Prompt + "." + dep.Symbol + ":")
+		str.WriteString(decls[dep.Symbol]) //empty = removed
+		str.WriteString("\n")
+	}
+
+	return _ToolsPrompt_getHash(str.String())
+}
+
+// returns top-level symbols and their declarations. Functions and methods are without body, so only header changes matter.
+// Methods are part of their receiver type's declaration, because they are called through selector.
+func _ToolsPrompt_getDeclarations(code string) map[string]string {
+	if code == "" {
+		return nil
+	}
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "", code, 0)
+	if err != nil {
+		return nil //code with syntax errors
+	}
+
+	decls := make(map[string]string)
+	add := func(symbol string, n any) {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, n)
+		decls[symbol] += buf.String() + "\n" //methods with same name on different types
+	}
+
+	for _, decl := range node.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			header := *d
+			header.Body = nil
+			header.Doc = nil
+			symbol := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				symbol = _ToolsPrompt_getTypeName(d.Recv.List[0].Type)
+			}
+			add(symbol, &header)
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, s)
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						add(name.Name, s)
+					}
+				}
+			}
+		}
+	}
+
+	return decls
+}
+
+// receiver's type name: 'T', '*T', 'T[K]'
+func _ToolsPrompt_getTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return _ToolsPrompt_getTypeName(t.X)
+	case *ast.IndexExpr:
+		return _ToolsPrompt_getTypeName(t.X)
+	case *ast.IndexListExpr:
+		return _ToolsPrompt_getTypeName(t.X)
+	}
+	return ""
+}
+
+// returns package-level names, which code uses, but doesn't declare. Local variables, parameters, struct fields and selectors('x.Name') are skipped.
+func _ToolsPrompt_getUsedIdents(code string) map[string]bool {
+	if code == "" {
+		return nil
+	}
+
+	node, err := parser.ParseFile(token.NewFileSet(), "", code, 0)
+	if err != nil {
+		return nil
+	}
+
+	//parser resolves identifiers inside file, rest is declared in other files(or builtins)
+	used := make(map[string]bool)
+	for _, ident := range node.Unresolved {
+		used[ident.Name] = true
+	}
+
+	return used
+}
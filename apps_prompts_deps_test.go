@@ -0,0 +1,95 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestToolsPrompts_updateDeps(t *testing.T) {
+	storage := NewToolsPrompt(ToolsPrompt_STORAGE, "Storage")
+	storage.CodeVersions = []ToolsPromptCode{{Code: `package main
+
+type Storage struct {
+	Items []string
+	Count int
+}
+
+func (st *Storage) Add(item string) {
+	st.Items = append(st.Items, item)
+}
+
+func Count(items []string) int {
+	return len(items)
+}
+`}}
+
+	tool := NewToolsPrompt(ToolsPrompt_TOOL, "AddItem")
+	tool.CodeVersions = []ToolsPromptCode{{Code: `package main
+
+type AddItem struct {
+	Item  string
+	Count int //field with same name as Storage's function
+}
+
+func (st *AddItem) run(caller *ToolCaller) error {
+	source, err := NewStorage()
+	if err != nil {
+		return err
+	}
+	Items := source.Items //local variable, selector
+	source.Add(st.Item)
+	st.Count = len(Items)
+	return nil
+}
+`}}
+	prompts := &ToolsPrompts{Prompts: []*ToolsPrompt{storage, tool}}
+
+	prompts.updateDeps(tool)
+	expected := []ToolsPromptDep{} //only fields and methods(selectors) and local names with same names as Storage's symbols
+	if !slices.Equal(tool.Deps, expected) || tool.Deps == nil {
+		t.Errorf("got deps %v, expected %v", tool.Deps, expected)
+	}
+
+	tool.CodeVersions[0].Code = `package main
+
+type AddItem struct {
+	Item string
+}
+
+func (st *AddItem) run(caller *ToolCaller) error {
+	var source *Storage
+	source.Add(st.Item)
+	_ = Count(source.Items)
+	return nil
+}
+`
+	prompts.updateDeps(tool)
+	expected = []ToolsPromptDep{{Prompt: "Storage", Symbol: "Count"}, {Prompt: "Storage", Symbol: "Storage"}}
+	if !slices.Equal(tool.Deps, expected) {
+		t.Errorf("got deps %v, expected %v", tool.Deps, expected)
+	}
+
+	//method change changes type's declaration
+	hash := prompts.getDepsHash(tool)
+	storage.CodeVersions[0].Code = strings.Replace(storage.CodeVersions[0].Code, "Add(item string)", "Add(item string, count int)", 1)
+	if prompts.getDepsHash(tool) == hash {
+		t.Errorf("method change didn't change deps hash")
+	}
+}
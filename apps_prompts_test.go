@@ -0,0 +1,180 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolsPrompts_parseTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		skyalt  string
+		tests   []string //names of test prompts
+		err     string
+		errLine int
+	}{
+		{"test", "#Tool Add\nAdd numbers.\n\n#Test Add\nInput: {\"A\":1}\n", []string{"Add_test"}, "", 0},
+		{"lower case", "#Tool Add\nAdd numbers.\n\n#test Add\nInput: {\"A\":1}\n", []string{"Add_test"}, "", 0},
+		{"two tests", "#Tool Add\nAdd.\n\n#Tool Sub\nSub.\n\n#Test Add\nAdd 1 and 2.\n\n#Test Sub\nSub 2 and 1.\n", []string{"Add_test", "Sub_test"}, "", 0},
+		{"missing tool", "#Tool Add\nAdd numbers.\n\n#Test Sub\nInput: {}\n", nil, "'#test Sub' has no '#tool Sub'", 4},
+		{"test of function", "#Function Add\nAdd numbers.\n\n#Test Add\nInput: {}\n", nil, "'#test Add' has no '#tool Add'", 4},
+		{"second test", "#Tool Add\nAdd.\n\n#Test Add\nInput: {}\n\n#Test Add\nInput: {}\n", nil, "second '#test Add' is not allowed", 7},
+		{"reserved name", "#Tool Add_test\nAdd.\n", nil, "name can't end with '_test'", 1},
+		{"missing name", "#Tool Add\nAdd.\n\n#Test\nInput: {}\n", nil, "missing name", 4},
+	}
+
+	for _, tt := range tests {
+		folder := t.TempDir()
+		err := os.WriteFile(filepath.Join(folder, "skyalt"), []byte(tt.skyalt), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var prompts ToolsPrompts
+		_, err = prompts._reloadFromPromptFile(folder)
+		if tt.err != "" {
+			if err == nil || prompts.Err != tt.err || prompts.Err_line != tt.errLine {
+				t.Errorf("%s: got error '%s' at line %d, want '%s' at line %d", tt.name, prompts.Err, prompts.Err_line, tt.err, tt.errLine)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var names []string
+		for _, prompt := range prompts.Prompts {
+			if prompt.Type == ToolsPrompt_TEST {
+				names = append(names, prompt.Name)
+				if prompt.GetTestedToolName()+"_test" != prompt.Name {
+					t.Errorf("%s: tested tool name '%s'", tt.name, prompt.GetTestedToolName())
+				}
+			}
+		}
+		if strings.Join(names, ",") != strings.Join(tt.tests, ",") {
+			t.Errorf("%s: got tests %v, want %v", tt.name, names, tt.tests)
+		}
+	}
+}
+
+func TestToolsPrompt_buildJSONTestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompt   string
+		isJSON   bool
+		err      bool
+		contains []string
+	}{
+		{"input and expected", "Input: {\"A\":1,\"B\":2}\nExpected: {\"Out_sum\":3}", true, false, []string{
+			"func TestAdd(t *testing.T) {",
+			`{input: "{\"A\":1,\"B\":2}", expected: "{\"Out_sum\":3}"},`,
+			`SdkTestCallTool("Add", []byte(cs.input))`,
+		}},
+		{"input only", "Input: {\"A\":1}\n\ninput: {\"A\":2}", true, false, []string{
+			`{input: "{\"A\":1}", expected: "{}"},`,
+			`{input: "{\"A\":2}", expected: "{}"},`,
+		}},
+		{"natural language", "Input: {\"A\":1}\nResult must be positive.", false, false, nil},
+		{"empty", "", false, false, nil},
+		{"invalid json", "Input: {A:1}", false, false, nil},
+		{"natural language input", "Input: two numbers 2 and 3\nExpected: {\"Out_sum\":5}", false, false, nil},
+		{"expected first", "Expected: {}", false, true, nil},
+		{"second expected", "Input: {}\nExpected: {}\nExpected: {}", false, true, nil},
+	}
+
+	for _, tt := range tests {
+		prompt := NewToolsPrompt(ToolsPrompt_TEST, "Add_test")
+		prompt.Prompt = tt.prompt
+
+		code, isJSON, err := prompt._buildJSONTestCode()
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if isJSON != tt.isJSON {
+			t.Errorf("%s: got isJSON %v, want %v", tt.name, isJSON, tt.isJSON)
+			continue
+		}
+		for _, str := range tt.contains {
+			if !strings.Contains(code, str) {
+				t.Errorf("%s: code doesn't contain '%s':\n%s", tt.name, str, code)
+			}
+		}
+	}
+}
+
+func TestToolsPrompts_routeTestError(t *testing.T) {
+	tool := NewToolsPrompt(ToolsPrompt_TOOL, "Add")
+	test := NewToolsPrompt(ToolsPrompt_TEST, "Add_test")
+	test.Prompt = "Sum of 2 and 3 is 5."
+	prompts := &ToolsPrompts{Prompts: []*ToolsPrompt{tool, test}}
+
+	fail := ToolsCodeError{File: "Add.go", Line: -1, Msg: "Add_test.go:10: got 6, expected 7", Test: true}
+
+	for i := range ToolsPrompt_maxSameTestFails + 1 {
+		er := prompts._routeTestError(fail)
+		expected := "Add.go"
+		if i == ToolsPrompt_maxSameTestFails {
+			expected = "Add_test.go"
+		}
+		if er.File != expected {
+			t.Errorf("fail %d: error is in '%s', expected '%s'", i+1, er.File, expected)
+		}
+		tool.CodeVersions = append(tool.CodeVersions, ToolsPromptCode{Errors: []ToolsCodeError{er}})
+	}
+
+	//JSON test is written by user
+	test.Prompt = "Input: {\"A\": 3, \"B\": 4}\nExpected: {\"Out_sum\": 7}"
+	if er := prompts._routeTestError(fail); er.File != "Add.go" {
+		t.Errorf("JSON test error is in '%s'", er.File)
+	}
+}
+
+func TestToolsPrompts_CheckChanges(t *testing.T) {
+	storage := NewToolsPrompt(ToolsPrompt_STORAGE, "Storage")
+	storage.CodeVersions = []ToolsPromptCode{{Code: "package main\n\ntype Storage struct {\n\tItems []string\n}\n"}}
+	tool := NewToolsPrompt(ToolsPrompt_TOOL, "AddItem")
+	tool.Prompt = "Adds item."
+	tool.CodeVersions = []ToolsPromptCode{{Code: "package main\n\nfunc (st *AddItem) run(storage *Storage) {\n\tstorage.Items = append(storage.Items, st.Item)\n}\n"}}
+	prompts := &ToolsPrompts{Prompts: []*ToolsPrompt{storage, tool}}
+
+	//tools.json from before hashes
+	if prompts.CheckChanges(ToolsPrompt_TOOL) {
+		t.Errorf("tool without hashes is regenerated")
+	}
+	if tool.PromptHash == "" || tool.DepsHash == "" {
+		t.Errorf("hashes weren't set")
+	}
+
+	tool.Prompt = "Adds item at the end."
+	if !prompts.CheckChanges(ToolsPrompt_TOOL) {
+		t.Errorf("changed prompt isn't regenerated")
+	}
+
+	//without hashes and with errors
+	tool.PromptHash, tool.DepsHash = "", ""
+	tool.regenerate = false
+	tool.CodeVersions[0].Errors = []ToolsCodeError{{Msg: "undefined: x"}}
+	if !prompts.CheckChanges(ToolsPrompt_TOOL) {
+		t.Errorf("tool with errors isn't regenerated")
+	}
+}
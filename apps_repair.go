@@ -0,0 +1,325 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Runtime failure of generated tool: error returned from run(), panic or Tool_Error() log. It's fixed by code model with FixRuntimeError().
+type ToolsAppRuntimeError struct {
+	Time   int64
+	Tool   string
+	Params string //JSON, "" = unknown
+	Error  string
+	Frames []ToolsAppCrashFrame
+}
+
+// 'build' call, which is running right now, or failed recently
+type ToolsAppRuntimeCall struct {
+	Msg_id uint64
+	Tool   string
+	Params string
+}
+
+const ToolsApp_maxFailedCalls = 10
+
+// converts runtime error into code error of tool's last version, so generatePromptCode() can fix it
+func (rerr *ToolsAppRuntimeError) CodeError() ToolsCodeError {
+	codeErr := ToolsCodeError{File: rerr.Tool + ".go", Line: -1, Msg: rerr.Error, Runtime: true, Params: rerr.Params}
+
+	var stack []string
+	for _, fr := range rerr.Frames {
+		if codeErr.Line < 0 && fr.Prompt == rerr.Tool {
+			codeErr.Line = fr.Line //deepest line in tool's file
+		}
+		stack = append(stack, fmt.Sprintf("%s:%d %s()", fr.File, fr.Line, fr.Func))
+	}
+	codeErr.Stack = strings.Join(stack, "\n")
+
+	return codeErr
+}
+
+// sdk adds '\n<app>:<tool> - build(<params>)' to errors returned from run(). Returns false for connection errors.
+func _ToolsAppRuntime_cutCallInfo(errStr string, appName string, toolName string) (string, bool) {
+	pos := strings.LastIndex(errStr, fmt.Sprintf("\n%s:%s - build(", appName, toolName))
+	if pos < 0 {
+		return errStr, false
+	}
+	return errStr[:pos], true
+}
+
+func (app *ToolsApp) StartRuntimeCall(msg_id uint64, toolName string, paramsJs []byte) {
+	app.runtime_lock.Lock()
+	defer app.runtime_lock.Unlock()
+
+	if app.runtime_calls == nil {
+		app.runtime_calls = make(map[uint64]ToolsAppRuntimeCall)
+	}
+	app.runtime_calls[msg_id] = ToolsAppRuntimeCall{Msg_id: msg_id, Tool: toolName, Params: string(paramsJs)}
+}
+
+// records error returned from tool's run(). Connection error(app crashed?) keeps call for AddRuntimeErrorFromStack().
+func (app *ToolsApp) EndRuntimeCall(msg_id uint64, callErr error) {
+	app.runtime_lock.Lock()
+	defer app.runtime_lock.Unlock()
+
+	call, found := app.runtime_calls[msg_id]
+	if !found {
+		return
+	}
+	delete(app.runtime_calls, msg_id)
+
+	if callErr == nil || Tools_GetFileTime(app.getPromptFilePath()) <= 0 {
+		return //ok or hand-written app
+	}
+
+	errStr, isToolErr := _ToolsAppRuntime_cutCallInfo(callErr.Error(), app.Process.Compile.appName, call.Tool)
+	if !isToolErr {
+		app.failed_calls = append(app.failed_calls, call)
+		if len(app.failed_calls) > ToolsApp_maxFailedCalls {
+			app.failed_calls = app.failed_calls[len(app.failed_calls)-ToolsApp_maxFailedCalls:]
+		}
+		return
+	}
+	if errStr == "_call_interrupted_" {
+		return //stopped by user
+	}
+
+	app._setRuntimeError(ToolsAppRuntimeError{Tool: call.Tool, Params: call.Params, Error: errStr})
+}
+
+// records panic or Tool_Error() log. Tool is found from stack frames, params from running call of that tool.
+func (app *ToolsApp) AddRuntimeErrorFromStack(errStr string, frames []ToolsAppCrashFrame) {
+	if len(frames) == 0 || Tools_GetFileTime(app.getPromptFilePath()) <= 0 {
+		return //not in app's code
+	}
+
+	app.runtime_lock.Lock()
+	defer app.runtime_lock.Unlock()
+
+	rerr := ToolsAppRuntimeError{Tool: frames[len(frames)-1].Prompt, Error: errStr, Frames: frames}
+
+	//running calls first, then failed calls(newest first)
+	calls := make([]ToolsAppRuntimeCall, 0, len(app.runtime_calls)+len(app.failed_calls))
+	for _, call := range app.runtime_calls {
+		calls = append(calls, call)
+	}
+	for i := len(app.failed_calls) - 1; i >= 0; i-- {
+		calls = append(calls, app.failed_calls[i])
+	}
+
+	found := false
+	for _, call := range calls {
+		for _, fr := range frames {
+			if fr.Prompt == call.Tool {
+				rerr.Tool = call.Tool
+				rerr.Params = call.Params
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	app.failed_calls = nil
+
+	app._setRuntimeError(rerr)
+}
+
+// keeps only last error per tool
+func (app *ToolsApp) _setRuntimeError(rerr ToolsAppRuntimeError) {
+	rerr.Time = time.Now().Unix()
+
+	app.runtime_errors = slices.DeleteFunc(app.runtime_errors, func(it ToolsAppRuntimeError) bool { return it.Tool == rerr.Tool })
+	app.runtime_errors = append(app.runtime_errors, rerr)
+
+	LogsErrorf("'%s' tool '%s' failed at runtime: %s", app.Process.Compile.appName, rerr.Tool, rerr.Error)
+}
+
+func (app *ToolsApp) GetRuntimeErrors() []ToolsAppRuntimeError {
+	app.runtime_lock.Lock()
+	defer app.runtime_lock.Unlock()
+
+	return slices.Clone(app.runtime_errors)
+}
+
+func (app *ToolsApp) findRuntimeError(toolName string) *ToolsAppRuntimeError {
+	app.runtime_lock.Lock()
+	defer app.runtime_lock.Unlock()
+
+	for _, it := range app.runtime_errors {
+		if it.Tool == toolName {
+			return &it
+		}
+	}
+	return nil
+}
+
+func (app *ToolsApp) removeRuntimeError(toolName string) {
+	app.runtime_lock.Lock()
+	defer app.runtime_lock.Unlock()
+
+	app.runtime_errors = slices.DeleteFunc(app.runtime_errors, func(it ToolsAppRuntimeError) bool { return it.Tool == toolName })
+}
+
+// 'Tool_Error()' log from sdk: "error: <msg>\nstack:<debug.Stack()>"
+func (app *ToolsApp) AddRuntimeErrorFromLog(str string) {
+	errPos := strings.Index(str, "error: ")
+	stackPos := strings.Index(str, "\nstack:")
+	if errPos < 0 || stackPos < errPos {
+		return
+	}
+
+	absFolder, _ := filepath.Abs(app.Process.Compile.GetFolderPath())
+	frames := _ToolsAppCrash_parseFrames(strings.Split(str[stackPos+len("\nstack:"):], "\n"), absFolder)
+	app.AddRuntimeErrorFromStack(str[errPos+len("error: "):stackPos], frames)
+}
+
+// Asks code model to fix tool's runtime error, recompiles app and re-runs failed call with same params(it can have side effects, same as original call). Repeats until call passes.
+func (app *ToolsApp) FixRuntimeError(msg *AppsRouterMsg, toolName string) error {
+	rerr := app.findRuntimeError(toolName)
+	if rerr == nil {
+		return fmt.Errorf("tool '%s' has no runtime error", toolName)
+	}
+
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	prompt := app.Prompts.FindPromptName(toolName)
+	if prompt == nil || prompt.Type != ToolsPrompt_TOOL || len(prompt.CodeVersions) == 0 {
+		return fmt.Errorf("'%s' is not generated tool", toolName)
+	}
+	if !prompt.IsCodeWithoutErrors() {
+		return fmt.Errorf("tool '%s' has compilation error(s), generate app first", toolName)
+	}
+
+	sdkFileTime, appFilesTime, hasPrompts, err := app.getPromptFileTime()
+	if err != nil {
+		return err
+	}
+	if !hasPrompts {
+		return fmt.Errorf("'%s' app has no 'skyalt' file", app.Process.Compile.appName)
+	}
+	secrets, err := NewToolsSecrets(app.getSecretsFilePath())
+	if err != nil {
+		return err
+	}
+
+	reason := "runtime error: " + rerr.Error
+
+	MAX_Errors_tries := 5
+	for i := range MAX_Errors_tries {
+		msg.SetProgressLabel(fmt.Sprintf("Fixing runtime error in %s(%d/%d)", toolName, i+1, MAX_Errors_tries))
+
+		last := &prompt.CodeVersions[len(prompt.CodeVersions)-1]
+		if len(last.Errors) == 0 {
+			last.Errors = append(last.Errors, rerr.CodeError())
+		}
+
+		err = app.Prompts.generatePromptCode(prompt, msg, app.router.services.llms)
+		if err != nil {
+			return err
+		}
+		if !msg.GetContinue() {
+			break
+		}
+		prompt.CodeVersions[len(prompt.CodeVersions)-1].Reason = reason
+
+		err = app.Prompts.WriteFiles(app.Process.Compile.GetFolderPath(), secrets, ToolsPrompt_TEST)
+		if err != nil {
+			return err
+		}
+		err = app.Process.Compile.BuildMainFile(app.Prompts.Prompts) //sdk.go -> main.go
+		if err != nil {
+			return err
+		}
+		codeErrors, err := app.Process.Compile._check(sdkFileTime, appFilesTime, false, msg)
+		if err != nil {
+			return err
+		}
+		app.Prompts.SetCodeErrors(codeErrors, app)
+		if len(codeErrors) == 0 && app.Prompts.HasTest() {
+			codeErrors, err = app.Process.Compile._test(app.router.server, app.GetPermissions(), msg)
+			if err != nil {
+				return err
+			}
+			app.Prompts.SetCodeErrors(codeErrors, app)
+		}
+		if len(codeErrors) > 0 {
+			continue //next try fixes compile errors or failed tests
+		}
+
+		//restart
+		err = app.StopProcess(true)
+		if err != nil {
+			return err
+		}
+		err = app.Process.Compile.RemoveOldBins()
+		if err != nil {
+			return err
+		}
+		err = app.Prompts.UpdateSchemas()
+		if err != nil {
+			return err
+		}
+
+		//re-run same call
+		msg.SetProgressLabel(fmt.Sprintf("Checking %s fix", toolName))
+		start_time := time.Now().Unix()
+		err = app.Process.CheckRun(app.router, app.GetPermissions())
+		if err == nil && rerr.Params == "" {
+			//params of crashed call are unknown, fix can't be verified by re-run
+			app.removeRuntimeError(toolName)
+			return app._save()
+		}
+		if err == nil {
+			app.StartRuntimeCall(msg.msg_id, toolName, []byte(rerr.Params))
+			_, _, _, err = _ToolsCaller_CallBuild(app.Process.GetPort(), msg.msg_id, 0, toolName, []byte(rerr.Params))
+			app.EndRuntimeCall(msg.msg_id, err)
+		}
+		if err == nil {
+			app.removeRuntimeError(toolName)
+			return app._save()
+		}
+
+		if _, isToolErr := _ToolsAppRuntime_cutCallInfo(err.Error(), app.Process.Compile.appName, toolName); !isToolErr {
+			app.Process.WaitUntilExited() //crash is recorded after process exits
+		}
+
+		newErr := app.findRuntimeError(toolName)
+		if newErr == nil || newErr.Time < start_time {
+			newErr = &ToolsAppRuntimeError{Tool: toolName, Params: rerr.Params, Error: err.Error()}
+		}
+		rerr = newErr
+		prompt.CodeVersions[len(prompt.CodeVersions)-1].Errors = append(prompt.CodeVersions[len(prompt.CodeVersions)-1].Errors, rerr.CodeError())
+	}
+
+	err = app._save()
+	if err != nil {
+		return err
+	}
+	if !msg.GetContinue() {
+		return nil
+	}
+	return fmt.Errorf("failed to fix runtime error in '%s'", toolName)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestToolsAppRuntime_cutCallInfo(t *testing.T) {
+	errStr, isToolErr := _ToolsAppRuntime_cutCallInfo("division by zero\nCalc:Divide - build({\"A\":1,\"B\":0})", "Calc", "Divide")
+	if !isToolErr || errStr != "division by zero" {
+		t.Errorf("tool error: %q %v", errStr, isToolErr)
+	}
+
+	errStr, isToolErr = _ToolsAppRuntime_cutCallInfo("protocol: 'build' call ended by 'Calc', but bytes was expected", "Calc", "Divide")
+	if isToolErr || errStr == "" {
+		t.Errorf("connection error: %q %v", errStr, isToolErr)
+	}
+}
+
+func TestToolsAppRuntimeError_CodeError(t *testing.T) {
+	rerr := ToolsAppRuntimeError{Tool: "Divide", Params: `{"A":1}`, Error: "panic: runtime error: integer divide by zero",
+		Frames: []ToolsAppCrashFrame{
+			{Func: "main.helper", File: "Functions.go", Line: 7, Prompt: "Functions"},
+			{Func: "main.(*Divide).run", File: "Divide.go", Line: 21, Prompt: "Divide"},
+		}}
+
+	codeErr := rerr.CodeError()
+	if !codeErr.Runtime || codeErr.File != "Divide.go" || codeErr.Line != 21 || codeErr.Params != `{"A":1}` {
+		t.Errorf("code error: %+v", codeErr)
+	}
+	if codeErr.Stack != "Functions.go:7 main.helper()\nDivide.go:21 main.(*Divide).run()" {
+		t.Errorf("stack: %q", codeErr.Stack)
+	}
+
+	//no stack
+	codeErr = (&ToolsAppRuntimeError{Tool: "Divide", Error: "x"}).CodeError()
+	if codeErr.Line != -1 || codeErr.Stack != "" {
+		t.Errorf("code error: %+v", codeErr)
+	}
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -30,6 +31,7 @@ import (
 type AppsRouterMsg struct {
 	msg_id  uint64
 	msg_uid []byte //format: appName_toolNme_msgName
+	appName string //app which runs the msg
 
 	actionName string
 
@@ -41,6 +43,7 @@ type AppsRouterMsg struct {
 	stop     atomic.Bool
 	out_done atomic.Bool
 
+	progress_lock  sync.Mutex //progress is read by UI and headless output while msg runs
 	progress_done  float64
 	progress_label string
 
@@ -74,12 +77,28 @@ func (msg *AppsRouterMsg) Done() {
 	msg.out_done.Store(true)
 }
 func (msg *AppsRouterMsg) Progress(done float64, label string) bool {
-
+	msg.progress_lock.Lock()
 	msg.progress_done = done
 	msg.progress_label = label
+	msg.progress_lock.Unlock()
 
 	return msg.GetContinue()
 }
+func (msg *AppsRouterMsg) SetProgressDone(done float64) {
+	msg.progress_lock.Lock()
+	defer msg.progress_lock.Unlock()
+	msg.progress_done = done
+}
+func (msg *AppsRouterMsg) SetProgressLabel(label string) {
+	msg.progress_lock.Lock()
+	defer msg.progress_lock.Unlock()
+	msg.progress_label = label
+}
+func (msg *AppsRouterMsg) GetProgress() (float64, string) {
+	msg.progress_lock.Lock()
+	defer msg.progress_lock.Unlock()
+	return msg.progress_done, msg.progress_label
+}
 func (msg *AppsRouterMsg) GetContinue() bool {
 	return !msg.stop.Load()
 }
@@ -95,44 +114,53 @@ type AppsRouter struct {
 
 	apps map[string]*ToolsApp
 
+	protocol_errors map[string]error //[app name], only existing apps
+
 	refresh_progress_time float64
 
 	services *Services
+
+	builds *AppsBuildScheduler
+
+	exiting atomic.Bool
 }
 
-func NewAppsRouter(start_port int, services *Services) (*AppsRouter, error) {
+// hotReload=false: apps are reloaded and compiled only on demand(headless mode)
+func NewAppsRouter(start_port int, services *Services, hotReload bool) (*AppsRouter, error) {
+	err := ToolsAppCompile_CheckGoVersion()
+	if err != nil {
+		return nil, err
+	}
+
 	router := &AppsRouter{}
 
 	router.services = services
 	router.services.fnCallBuildAsync = router.CallBuildAsync
 	router.services.fnGetAppPortAndTools = router.GetAppPortAndTools
+	router.services.fnGetAppFolder = router.GetAppFolder
 
 	router.server = NewAppsServer(start_port)
 	router.msgs = make(map[uint64]*AppsRouterMsg)
 	router.apps = make(map[string]*ToolsApp)
+	router.protocol_errors = make(map[string]error)
+	router.builds = NewAppsBuildScheduler(router, AppsBuildScheduler_GetWorkers())
 
 	//hot reload
-	go func() {
-		inited := false
-		for {
-			router._hotReload()
-			if !inited {
-				router.services.sync.Upload_LoadFiles()
-				router.services.sync.Upload_deviceDefaultDPI()
-				inited = true
-			}
-
-			time.Sleep(1000 * time.Millisecond)
-		}
-	}()
+	if hotReload {
+		go router.runHotReload()
+	}
 
 	//apps
 	go router.RunNet()
+	go router.runSupervisor()
 
 	return router, nil
 }
 
 func (router *AppsRouter) Destroy() {
+	router.exiting.Store(true)
+	router.builds.Destroy()
+
 	for _, app := range router.apps {
 		app.Destroy() //send exit
 	}
@@ -178,7 +206,7 @@ func (router *AppsRouter) GetAppPortAndTools(appName string) (int, []*ToolsOpenA
 				return -1, nil, err
 			}
 
-			app_port = app.Process.port
+			app_port = app.Process.GetPort()
 
 		} else {
 			return -1, nil, LogsErrorf("app '%s' not found", appName)
@@ -188,6 +216,15 @@ func (router *AppsRouter) GetAppPortAndTools(appName string) (int, []*ToolsOpenA
 	return app_port, tools, nil
 }
 
+// "" = app not found
+func (router *AppsRouter) GetAppFolder(appName string) string {
+	app := router.FindApp(appName)
+	if app == nil {
+		return ""
+	}
+	return app.Process.Compile.GetFolderPath()
+}
+
 func (router *AppsRouter) Save() {
 	router.lock.Lock()
 	defer router.lock.Unlock()
@@ -214,6 +251,21 @@ func (router *AppsRouter) FindApp(appName string) *ToolsApp {
 	return app
 }
 
+// returns sorted names of all apps
+func (router *AppsRouter) GetAppNames() []string {
+	router._reloadAppList()
+
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	var names []string
+	for appName := range router.apps {
+		names = append(names, appName)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (router *AppsRouter) GetRootApp() *ToolsApp {
 	return router.FindApp("Root")
 }
@@ -258,7 +310,7 @@ func (router *AppsRouter) CallUpdateDev() {
 
 	for _, app := range router.apps {
 		if app.Process.IsRunning() {
-			_ToolsCaller_UpdateDev(app.Process.port)
+			_ToolsCaller_UpdateDev(app.Process.GetPort())
 		}
 	}
 }
@@ -273,6 +325,7 @@ func (router *AppsRouter) CallUpdateAsync(sub_uid uint64, fnProgress func(cmdsGo
 
 	msg_id := router.msgs_counter.Add(1)
 	msg := NewAppsRouterMsg(msg_id, "update", fnProgress, fnDone)
+	msg.appName = app.Process.Compile.appName
 
 	router.lock.Lock()
 	router.msgs[msg_id] = msg
@@ -282,7 +335,7 @@ func (router *AppsRouter) CallUpdateAsync(sub_uid uint64, fnProgress func(cmdsGo
 	go func() {
 		defer msg.Done()
 
-		out_subUiGob, out_cmdsGob, out_error := _ToolsCaller_CallUpdate(app.Process.port, msg_id, ui_uid, sub_uid)
+		out_subUiGob, out_cmdsGob, out_error := _ToolsCaller_CallUpdate(app.Process.GetPort(), msg_id, ui_uid, sub_uid)
 		msg.out_error = out_error
 
 		if out_error == nil {
@@ -303,6 +356,7 @@ func (router *AppsRouter) CallChangeAsync(change ToolsSdkChange, fnProgress func
 
 	msg_id := router.msgs_counter.Add(1)
 	msg := NewAppsRouterMsg(msg_id, "change", fnProgress, fnDone)
+	msg.appName = app.Process.Compile.appName
 
 	msg.drawit = true
 
@@ -314,7 +368,7 @@ func (router *AppsRouter) CallChangeAsync(change ToolsSdkChange, fnProgress func
 	go func() {
 		defer msg.Done()
 
-		out_dataJs, out_cmdsGob, out_error := _ToolsCaller_CallChange(app.Process.port, msg_id, ui_uid, change)
+		out_dataJs, out_cmdsGob, out_error := _ToolsCaller_CallChange(app.Process.GetPort(), msg_id, ui_uid, change)
 		msg.out_error = out_error
 
 		if out_error == nil {
@@ -333,6 +387,7 @@ func (router *AppsRouter) CallBuildAsync(ui_uid uint64, appName, toolName string
 
 	msg_id := router.msgs_counter.Add(1)
 	msg := NewAppsRouterMsg(msg_id, "build", fnProgress, fnDone)
+	msg.appName = app.Process.Compile.appName
 
 	router.lock.Lock()
 	router.msgs[msg_id] = msg
@@ -355,20 +410,32 @@ func (router *AppsRouter) CallBuildAsync(ui_uid uint64, appName, toolName string
 		}
 
 		//call it - no parent!
-		msg.out_dataJs, msg.out_uiGob, msg.out_cmdsGob, msg.out_error = _ToolsCaller_CallBuild(app.Process.port, msg_id, ui_uid, toolName, jsParams)
+		app.StartRuntimeCall(msg_id, toolName, jsParams)
+		msg.out_dataJs, msg.out_uiGob, msg.out_cmdsGob, msg.out_error = _ToolsCaller_CallBuild(app.Process.GetPort(), msg_id, ui_uid, toolName, jsParams)
+		app.EndRuntimeCall(msg_id, msg.out_error)
 	}()
 
 	return msg
 }
 
+// returns msg queued by build scheduler or creates new one
 func (router *AppsRouter) AddLocalRecompileMsg(appName string) *AppsRouterMsg {
-	msg_id := router.msgs_counter.Add(1)
-	msg := NewAppsRouterMsg(msg_id, "compile", nil, nil)
-	msg.msg_uid = []byte(fmt.Sprintf("%s_skyalt_compile", appName))
+	msg_uid := []byte(fmt.Sprintf("%s_skyalt_compile", appName))
 
 	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	for _, msg := range router.msgs {
+		if msg != nil && bytes.Equal(msg.msg_uid, msg_uid) && !msg.out_done.Load() {
+			return msg
+		}
+	}
+
+	msg_id := router.msgs_counter.Add(1)
+	msg := NewAppsRouterMsg(msg_id, "compile", nil, nil)
+	msg.appName = appName
+	msg.msg_uid = msg_uid
 	router.msgs[msg_id] = msg
-	router.lock.Unlock()
 
 	return msg
 }
@@ -454,6 +521,102 @@ func (router *AppsRouter) Flush() bool {
 	return refresh
 }
 
+// returns error, when app was compiled with different sdk.go
+func (router *AppsRouter) GetProtocolError(appName string) error {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	return router.protocol_errors[appName]
+}
+
+// returns app of connection and its capabilities. Unknown connection can call only base commands.
+func (router *AppsRouter) getPermissions(conn *AppsConn) (*ToolsApp, *ToolsAppPermissions) {
+	app := router.FindApp(conn.name)
+	if app == nil {
+		return nil, &ToolsAppPermissions{}
+	}
+	return app, app.GetPermissions()
+}
+
+func (router *AppsRouter) deny(conn *AppsConn, app *ToolsApp, cmd string, detail string) {
+	if app == nil {
+		LogsErrorf("unknown connection '%s' isn't allowed to call '%s'", conn.name, cmd)
+		return
+	}
+	app.AddDenial(cmd, detail)
+}
+
+const AppsRouter_maxProtocolErrors = 100
+
+// records failed handshake. Name is claimed by peer, so only existing apps are recorded.
+func (router *AppsRouter) addProtocolError(name string, err error) {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	if router.apps[name] == nil {
+		return
+	}
+
+	//remove deleted apps
+	for nm := range router.protocol_errors {
+		if router.apps[nm] == nil {
+			delete(router.protocol_errors, nm)
+		}
+	}
+	if router.protocol_errors[name] == nil && len(router.protocol_errors) >= AppsRouter_maxProtocolErrors {
+		return
+	}
+	router.protocol_errors[name] = err
+}
+
+// accepts connections from apps and sends their calls into channel. nil = server closed.
+func (router *AppsRouter) acceptCalls(calls chan *AppsStream) {
+	for {
+		nc, err := router.server.AcceptRaw()
+		if err != nil {
+			LogsError(err)
+			continue
+		}
+		if nc == nil {
+			calls <- nil //close tool
+			return
+		}
+
+		//handshake runs per connection
+		go func() {
+			conn, err := router.server.Handshake(nc)
+			if err != nil {
+				var protoErr *AppsProtocolError
+				var authErr *AppsAuthError
+				if errors.As(err, &protoErr) {
+					router.addProtocolError(protoErr.Name, protoErr)
+				} else if errors.As(err, &authErr) {
+					router.addProtocolError(authErr.Name, authErr)
+				}
+				LogsError(err)
+				return
+			}
+
+			router.lock.Lock()
+			delete(router.protocol_errors, conn.name)
+			router.lock.Unlock()
+
+			defer conn.Destroy()
+			for {
+				cl, err := conn.Accept()
+				if err != nil {
+					var protoErr *AppsProtocolError
+					if errors.As(err, &protoErr) {
+						router.addProtocolError(conn.name, protoErr) //connection was closed
+					}
+					return //app exited
+				}
+				calls <- cl
+			}
+		}()
+	}
+}
+
 func (router *AppsRouter) RunNet() {
 
 	type SdkMsg struct {
@@ -465,11 +628,11 @@ func (router *AppsRouter) RunNet() {
 		Start_time     float64
 	}
 
+	calls := make(chan *AppsStream)
+	go router.acceptCalls(calls)
+
 	for {
-		cl, err := router.server.Accept()
-		if LogsError(err) != nil {
-			continue
-		}
+		cl := <-calls
 		if cl == nil {
 			break //close tool
 		}
@@ -480,6 +643,12 @@ func (router *AppsRouter) RunNet() {
 
 			mode, err := cl.ReadArray()
 			if err == nil {
+				connApp, perm := router.getPermissions(cl.conn)
+				if !perm.IsCommandAllowed(string(mode)) {
+					router.deny(cl.conn, connApp, string(mode), "")
+					return
+				}
+
 				switch string(mode) {
 
 				case "print":
@@ -488,18 +657,47 @@ func (router *AppsRouter) RunNet() {
 						str, err := cl.ReadArray()
 						if err == nil {
 							fmt.Printf("Router's print '%s' app: %s\n", string(appName), string(str))
+
+							if connApp != nil && string(appName) == cl.conn.name {
+								connApp.AddRuntimeErrorFromLog(string(str)) //Tool_Error()
+							}
 						}
 					}
 
 				case "register":
 					appName, err := cl.ReadArray()
 					if err == nil {
-						port, err := cl.ReadInt()
+						if string(appName) != cl.conn.name {
+							LogsErrorf("'%s' tried to register as '%s'", cl.conn.name, appName)
+							break
+						}
+						app := router.FindApp(string(appName))
+						if app != nil {
+							app.Process.SetPort(cl.conn.id)
+						}
+					}
+
+				case "sandbox_violation":
+					errStr, err := cl.ReadArray()
+					if err == nil {
+						if connApp != nil {
+							connApp.AddDenial("sandbox", string(errStr))
+						} else {
+							LogsErrorf("'%s' was blocked by sandbox: %s", cl.conn.name, errStr)
+						}
+					}
+
+				case "forward": //app calls other app
+					port, err := cl.ReadInt()
+					if err == nil {
+						dst, err := AppsConns_Open(int(port))
 						if err == nil {
-							app := router.FindApp(string(appName))
-							if app != nil {
-								app.Process.port = int(port)
+							if perm.IsAppAllowed(dst.conn.name) {
+								AppsStream_Pipe(cl, dst)
+							} else {
+								router.deny(cl.conn, connApp, "forward", dst.conn.name)
 							}
+							dst.Destroy()
 						}
 					}
 
@@ -529,6 +727,36 @@ func (router *AppsRouter) RunNet() {
 						}
 					}
 
+				case "fix_runtime_error":
+					msg_id, err := cl.ReadInt()
+					if err == nil {
+						appName, err := cl.ReadArray()
+						if err == nil {
+							toolName, err := cl.ReadArray()
+							if err == nil {
+								router.lock.Lock()
+								msg, _ := router.msgs[msg_id]
+								router.lock.Unlock()
+
+								var retErr error
+								app := router.FindApp(string(appName))
+								if app == nil {
+									retErr = fmt.Errorf("app '%s' not found", string(appName))
+								} else if msg == nil {
+									retErr = fmt.Errorf("message %d not found", msg_id)
+								} else {
+									retErr = app.FixRuntimeError(msg, string(toolName))
+								}
+
+								var errStr string
+								if retErr != nil {
+									errStr = retErr.Error()
+								}
+								cl.WriteArray([]byte(errStr))
+							}
+						}
+					}
+
 				case "get_llm_usage":
 					usage := router.services.llms.GetUsage()
 
@@ -536,6 +764,13 @@ func (router *AppsRouter) RunNet() {
 
 					cl.WriteArray(usageJs)
 
+				case "get_llm_spendings":
+					spendings := router.services.llms.GetSpendings()
+
+					spendingsJs, _ := LogsJsonMarshal(spendings)
+
+					cl.WriteArray(spendingsJs)
+
 				case "rename_app":
 					oldNameBytes, err := cl.ReadArray()
 					if err == nil {
@@ -578,8 +813,7 @@ func (router *AppsRouter) RunNet() {
 								{
 									msg, found := router.msgs[msg_id]
 									if found && msg != nil {
-										msg.progress_done = float64(done) / 10000
-										msg.progress_label = string(label)
+										msg.Progress(float64(done)/10000, string(label))
 
 										//stop = 0
 										if msg.stop.Load() {
@@ -600,13 +834,19 @@ func (router *AppsRouter) RunNet() {
 						var out_Error error
 						app := router.FindApp(string(appName))
 						var app_port uint64
-						if app != nil {
+						if cl.conn.name == "Root" {
+							router.builds.SetVisible(string(appName)) //build it first
+						}
+						if !perm.IsAppAllowed(string(appName)) {
+							router.deny(cl.conn, connApp, "run_app", string(appName))
+							out_Error = fmt.Errorf("'%s' app isn't allowed to call '%s' app", cl.conn.name, appName)
+						} else if app != nil {
 							//start it
 							out_Error = app.CheckRun()
 
 							//get port
 							if out_Error == nil {
-								app_port = uint64(app.Process.port)
+								app_port = uint64(app.Process.GetPort())
 							}
 
 						} else {
@@ -644,10 +884,10 @@ func (router *AppsRouter) RunNet() {
 					sorted_msgs := router.GetSortedMsgs()
 					for _, msg := range sorted_msgs {
 						if msg != nil && msg.drawit {
-
+							done, label := msg.GetProgress()
 							final_msgs = append(final_msgs, SdkMsg{UID: msg.msg_uid,
 								ActionName:     msg.actionName,
-								Progress_label: msg.progress_label, Progress_done: msg.progress_done,
+								Progress_label: label, Progress_done: done,
 								Start_time: msg.start_time})
 						}
 					}
@@ -673,7 +913,10 @@ func (router *AppsRouter) RunNet() {
 					router.services.mic.FinishAll(false)
 
 				case "get_media_info":
-					infoJs, _ := router.services.media.GetInfo()
+					var infoJs []byte
+					if router.services.media != nil { //headless
+						infoJs, _ = router.services.media.GetInfo()
+					}
 					cl.WriteArray(infoJs)
 
 				case "set_text_highlight":
@@ -708,9 +951,10 @@ func (router *AppsRouter) RunNet() {
 							if msg != nil {
 								cl.WriteInt(1) //exist
 
+								done, label := msg.GetProgress()
 								msg := SdkMsg{UID: msg_uid,
 									ActionName:     msg.actionName,
-									Progress_label: msg.progress_label, Progress_done: msg.progress_done,
+									Progress_label: label, Progress_done: done,
 									Start_time: msg.start_time}
 								msgJs, _ := LogsJsonMarshal(msg)
 								cl.WriteArray(msgJs)
@@ -751,7 +995,16 @@ func (router *AppsRouter) RunNet() {
 						var promptsJs []byte
 						app := router.FindApp(string(appName))
 						if app != nil {
-							promptsJs, _ = LogsJsonMarshalIndent(&app.Prompts)
+							type ToolData struct {
+								*ToolsPrompts
+								Denials []ToolsAppDenial //blocked by '#permissions'
+								Crashes []ToolsAppCrash
+
+								SandboxWarning string
+
+								RuntimeErrors []ToolsAppRuntimeError
+							}
+							promptsJs, _ = LogsJsonMarshalIndent(ToolData{ToolsPrompts: &app.Prompts, Denials: app.GetDenials(), SandboxWarning: app.GetSandboxWarning(), Crashes: app.Process.GetCrashes(), RuntimeErrors: app.GetRuntimeErrors()})
 						}
 						cl.WriteArray(promptsJs)
 					}
@@ -809,7 +1062,12 @@ func (router *AppsRouter) RunNet() {
 										usecase = "tools"
 									}
 
-									err = router.services.llms.Complete(&comp, msg, usecase)
+									if perm.IsLLMAllowed(usecase) {
+										err = router.services.llms.Complete(&comp, msg, usecase)
+									} else {
+										router.deny(cl.conn, connApp, "llm_complete", usecase)
+										err = fmt.Errorf("'%s' app isn't allowed to use LLM for '%s'", cl.conn.name, usecase)
+									}
 									if err == nil {
 										//save back
 										compJs, _ = LogsJsonMarshal(&comp)
@@ -838,7 +1096,12 @@ func (router *AppsRouter) RunNet() {
 								err := LogsJsonUnmarshal(compJs, &comp)
 								if err == nil {
 
-									err = router.services.llms.Transcribe(&comp)
+									if perm.IsLLMAllowed("transcribe") {
+										err = router.services.llms.Transcribe(&comp)
+									} else {
+										router.deny(cl.conn, connApp, "llm_transcribe", "transcribe")
+										err = fmt.Errorf("'%s' app isn't allowed to use LLM for 'transcribe'", cl.conn.name)
+									}
 									if err == nil {
 										//save back
 										compJs, _ = LogsJsonMarshal(&comp)
@@ -907,34 +1170,69 @@ func (router *AppsRouter) _reloadAppList() {
 	}
 }
 
-func (router *AppsRouter) _hotReload() {
+// reloads apps when files change(inotify). Without file watching, it polls every second.
+func (router *AppsRouter) runHotReload() {
+	watcher, err := NewAppsWatcher()
+	if err != nil {
+		fmt.Printf("File watching isn't available, polling apps: %v\n", err)
+	} else {
+		defer watcher.Destroy()
+	}
 
-	router._reloadAppList()
+	inited := false
+	for !router.exiting.Load() {
+		router._hotReload()
+		if !inited && router.builds.IsIdle() {
+			router.services.sync.Upload_LoadFiles()
+			router.services.sync.Upload_deviceDefaultDPI()
+			inited = true
+		}
 
-	var wg sync.WaitGroup
+		if watcher != nil {
+			err = watcher.SetPaths(router.getWatchPaths())
+			if LogsError(err) != nil {
+				watcher.Destroy()
+				watcher = nil //fallback
+			}
+		}
+		if watcher == nil {
+			time.Sleep(AppsWatcher_pollInterval)
+			continue
+		}
 
-	//tick Root app first
-	rootApp := router.FindApp("Root")
-	if rootApp != nil {
-		wg.Add(1)
-		go func() {
-			rootApp.Tick(nil)
-			wg.Done()
-		}()
+		timeout := time.Duration(0) //idle: wait for change
+		if !inited || !router.builds.IsIdle() {
+			timeout = AppsWatcher_pollInterval //re-check finished builds
+		}
+		if !watcher.Wait(timeout) {
+			watcher = nil //closed, fallback
+		}
 	}
+}
 
-	//ticks
+func (router *AppsRouter) getWatchPaths() []string {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	paths := []string{"apps", "sdk"}
 	for _, app := range router.apps {
-		if app == rootApp {
-			continue
+		paths = append(paths, app.Process.Compile.GetFolderPath())
+	}
+	return paths
+}
+
+func (router *AppsRouter) _hotReload() {
+
+	router._reloadAppList()
+
+	for _, app := range router.apps {
+		_, appFilesTime, _, err := app.getPromptFileTime()
+		if err == nil {
+			router.builds.CancelStale(app.Process.Compile.appName, appFilesTime)
 		}
 
-		wg.Add(1)
-		go func() {
-			app.Tick(nil)
-			wg.Done()
-		}()
+		if app.CheckChanges() {
+			router.builds.Add(app)
+		}
 	}
-
-	wg.Wait()
 }
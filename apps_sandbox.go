@@ -0,0 +1,74 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Generated apps(with 'skyalt' file) can run in sandbox, enable it with SKYALT_SANDBOX=1. Router starts
+// them through itself('skyalt sandbox ...'), which sets limits, restricts process and executes app's binary:
+//   - writes only into app's 'data' folder(storage and temp files), code, binary and 'skyalt' file are read-only
+//   - network only with 'network: yes' in '#permissions', connection to router is always allowed. TCP is blocked by landlock(ABI 4+),
+//     other sockets(UDP, raw, unix, ...) by seccomp filter. App with 'network: no' doesn't start, when sandbox can't block its network,
+//     unless SKYALT_SANDBOX_WEAK_NETWORK=1(dev view shows warning)
+//   - memory limit
+//
+// Only Linux is supported(landlock).
+func AppsSandbox_enabled() bool {
+	return os.Getenv("SKYALT_SANDBOX") == "1"
+}
+
+// generated apps keep storage in this subfolder(env SKYALT_APP_DATA, tests get empty temp folder), it's the only writable place in sandbox
+const AppsSandbox_dataFolder = "data"
+
+const AppsSandbox_memory = 4 << 30 //address space
+
+// runs apps with 'network: no', even if sandbox can't block their network
+func AppsSandbox_allowWeakNetwork() bool {
+	return os.Getenv("SKYALT_SANDBOX_WEAK_NETWORK") == "1"
+}
+
+func IsSandboxExec() bool {
+	return len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "sandbox"
+}
+
+// wraps app's binary into sandbox launcher. routerPort is 0 for unix socket.
+func AppsSandbox_command(bin string, args []string, network bool, routerPort int) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	params := []string{"sandbox", OsTrnString(network, "1", "0"), strconv.Itoa(routerPort), bin}
+	return exec.Command(exe, append(params, args...)...), nil
+}
+
+// called by router after sandboxed app exited. Returns why app was killed by sandbox, or "".
+func AppsSandbox_exitReason(state *os.ProcessState, stderr string) string {
+	if state == nil {
+		return ""
+	}
+	if strings.Contains(stderr, "runtime: out of memory") || strings.Contains(stderr, "fatal error: out of memory") {
+		return fmt.Sprintf("memory limit(%dMB) exceeded", AppsSandbox_memory>>20)
+	}
+	return ""
+}
@@ -0,0 +1,326 @@
+//go:build linux
+
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// landlock(Linux 5.13+), see linux/landlock.h
+const (
+	_SYS_landlock_create_ruleset = 444
+	_SYS_landlock_add_rule       = 445
+	_SYS_landlock_restrict_self  = 446
+
+	_landlock_create_ruleset_version = 1 << 0
+
+	_landlock_rule_path_beneath = 1
+	_landlock_rule_net_port     = 2
+
+	_landlock_access_fs_write_file  = 1 << 1
+	_landlock_access_fs_remove_dir  = 1 << 4
+	_landlock_access_fs_remove_file = 1 << 5
+	_landlock_access_fs_make_char   = 1 << 6
+	_landlock_access_fs_make_dir    = 1 << 7
+	_landlock_access_fs_make_reg    = 1 << 8
+	_landlock_access_fs_make_sock   = 1 << 9
+	_landlock_access_fs_make_fifo   = 1 << 10
+	_landlock_access_fs_make_block  = 1 << 11
+	_landlock_access_fs_make_sym    = 1 << 12
+	_landlock_access_fs_refer       = 1 << 13 //ABI 2
+	_landlock_access_fs_truncate    = 1 << 14 //ABI 3
+
+	_landlock_access_net_bind_tcp    = 1 << 0 //ABI 4
+	_landlock_access_net_connect_tcp = 1 << 1 //ABI 4
+
+	_PR_SET_NO_NEW_PRIVS = 38
+)
+
+// seccomp, see linux/seccomp.h and linux/filter.h
+const (
+	_PR_SET_SECCOMP      = 22
+	_SECCOMP_MODE_FILTER = 2
+
+	_SECCOMP_RET_ALLOW = 0x7fff0000
+	_SECCOMP_RET_ERRNO = 0x00050000
+
+	_BPF_LD_W_ABS = 0x20 //BPF_LD | BPF_W | BPF_ABS
+	_BPF_JEQ_K    = 0x15 //BPF_JMP | BPF_JEQ | BPF_K
+	_BPF_JGE_K    = 0x35 //BPF_JMP | BPF_JGE | BPF_K
+	_BPF_AND_K    = 0x54 //BPF_ALU | BPF_AND | BPF_K
+	_BPF_RET_K    = 0x06 //BPF_RET | BPF_K
+
+	//offsets in struct seccomp_data
+	_seccomp_data_nr   = 0
+	_seccomp_data_arch = 4
+	_seccomp_data_arg0 = 16
+	_seccomp_data_arg1 = 24
+	_seccomp_data_arg2 = 32
+
+	_SYS_io_uring_setup = 425 //same on amd64 and arm64
+	_x32_syscall_bit    = 0x40000000
+)
+
+type _sockFilter struct {
+	code uint16
+	jt   uint8
+	jf   uint8
+	k    uint32
+}
+
+type _sockFprog struct {
+	len    uint16
+	filter *_sockFilter
+}
+
+// returns 0, if seccomp filter isn't written for this architecture
+func _AppsSandbox_auditArch() uint32 {
+	switch runtime.GOARCH {
+	case "amd64":
+		return 0xc000003e
+	case "arm64":
+		return 0xc00000b7
+	}
+	return 0
+}
+
+type _landlockRulesetAttr struct {
+	handled_access_fs  uint64
+	handled_access_net uint64
+}
+
+type _landlockPathBeneathAttr struct {
+	allowed_access uint64
+	parent_fd      int32 //struct is packed, kernel reads only 12 bytes
+	_              [4]byte
+}
+
+type _landlockNetPortAttr struct {
+	allowed_access uint64
+	port           uint64
+}
+
+// returns 0, if landlock is not supported
+func _AppsSandbox_landlockABI() int {
+	abi, _, errno := syscall.Syscall(_SYS_landlock_create_ruleset, 0, 0, _landlock_create_ruleset_version)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+// returns why sandbox can't block network of app with 'network: no', or "". routerPort is 0 for unix socket.
+func AppsSandbox_networkWeakness(routerPort int) string {
+	abi := _AppsSandbox_landlockABI()
+	if abi < 4 {
+		return fmt.Sprintf("kernel's landlock(ABI %d) can't block TCP, 4+ is needed", abi)
+	}
+	if _AppsSandbox_auditArch() == 0 {
+		return fmt.Sprintf("seccomp filter isn't supported on %s, UDP and other sockets aren't blocked", runtime.GOARCH)
+	}
+	if routerPort == 0 {
+		return "unix sockets aren't blocked, because router uses them(SKYALT_UNIX_SOCKETS=1)"
+	}
+	return ""
+}
+
+// only TCP(landlock restricts it) and unix sockets(if allowed) can be created, io_uring is blocked, because its operations skip seccomp
+func _AppsSandbox_restrictSockets(allowUnix bool) error {
+	arch := _AppsSandbox_auditArch()
+	if arch == 0 {
+		return fmt.Errorf("sandbox: seccomp filter isn't supported on %s", runtime.GOARCH)
+	}
+
+	deny := uint32(_SECCOMP_RET_ERRNO | uint32(syscall.EACCES))
+	unixRet := deny
+	if allowUnix {
+		unixRet = _SECCOMP_RET_ALLOW
+	}
+
+	//jumps are relative to next instruction
+	filter := []_sockFilter{
+		{_BPF_LD_W_ABS, 0, 0, _seccomp_data_arch},
+		{_BPF_JEQ_K, 1, 0, arch},
+		{_BPF_RET_K, 0, 0, deny}, //other ABI(i386 socketcall(), ...)
+		{_BPF_LD_W_ABS, 0, 0, _seccomp_data_nr},
+		{_BPF_JGE_K, 15, 0, _x32_syscall_bit},
+		{_BPF_JEQ_K, 14, 0, _SYS_io_uring_setup},
+		{_BPF_JEQ_K, 0, 12, syscall.SYS_SOCKET},
+
+		//socket(domain, type, protocol)
+		{_BPF_LD_W_ABS, 0, 0, _seccomp_data_arg0},
+		{_BPF_JEQ_K, 0, 1, syscall.AF_UNIX},
+		{_BPF_RET_K, 0, 0, unixRet},
+		{_BPF_JEQ_K, 1, 0, syscall.AF_INET},
+		{_BPF_JEQ_K, 0, 8, syscall.AF_INET6},
+		{_BPF_LD_W_ABS, 0, 0, _seccomp_data_arg1},
+		{_BPF_AND_K, 0, 0, 0xf}, //without SOCK_NONBLOCK, SOCK_CLOEXEC
+		{_BPF_JEQ_K, 0, 5, syscall.SOCK_STREAM},
+		{_BPF_LD_W_ABS, 0, 0, _seccomp_data_arg2},
+		{_BPF_JEQ_K, 1, 0, 0},
+		{_BPF_JEQ_K, 0, 2, syscall.IPPROTO_TCP}, //not MPTCP, SCTP
+		{_BPF_RET_K, 0, 0, _SECCOMP_RET_ALLOW},
+
+		{_BPF_RET_K, 0, 0, _SECCOMP_RET_ALLOW}, //not socket()
+		{_BPF_RET_K, 0, 0, deny},
+	}
+	prog := _sockFprog{len: uint16(len(filter)), filter: &filter[0]}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_PRCTL, _PR_SET_SECCOMP, _SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("sandbox: prctl(PR_SET_SECCOMP): %w", errno)
+	}
+	return nil
+}
+
+// 'skyalt sandbox <network 0|1> <router port> <binary> [args]'. Restricts itself and executes binary, returns only on error.
+func AppsSandbox_exec(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: sandbox <network 0|1> <router port> <binary> [args]")
+	}
+	network := (args[0] == "1")
+	routerPort, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("sandbox: invalid router port: %w", err)
+	}
+	bin := args[2]
+
+	appFolder, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+
+	//storage and temp files are in app's 'data' folder(tests use temp folder), rest of app's folder is read-only
+	dataFolder := os.Getenv("SKYALT_APP_DATA")
+	if dataFolder == "" {
+		dataFolder = AppsSandbox_dataFolder
+	}
+	if !filepath.IsAbs(dataFolder) {
+		dataFolder = filepath.Join(appFolder, dataFolder)
+	}
+	tmpFolder := filepath.Join(dataFolder, ".tmp")
+	err = os.MkdirAll(tmpFolder, 0700)
+	if err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	os.Setenv("TMPDIR", tmpFolder)
+	os.Setenv("SKYALT_SANDBOXED", "1")
+
+	//limits
+	err = syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: AppsSandbox_memory, Max: AppsSandbox_memory})
+	if err != nil {
+		return fmt.Errorf("sandbox: memory limit: %w", err)
+	}
+
+	abi := _AppsSandbox_landlockABI()
+	if abi < 1 {
+		return fmt.Errorf("sandbox: kernel doesn't support landlock(Linux 5.13+ with 'landlock' in 'lsm=' boot parameter), disable SKYALT_SANDBOX")
+	}
+	if !network {
+		weak := AppsSandbox_networkWeakness(routerPort)
+		if weak != "" {
+			if !AppsSandbox_allowWeakNetwork() {
+				return fmt.Errorf("sandbox: %s. Set SKYALT_SANDBOX_WEAK_NETWORK=1 to run app without network restriction", weak)
+			}
+			fmt.Fprintf(os.Stderr, "sandbox: %s\n", weak)
+		}
+	}
+
+	//landlock restricts only calling thread, exec() keeps it
+	runtime.LockOSThread()
+
+	var attr _landlockRulesetAttr
+	attrSize := unsafe.Sizeof(attr.handled_access_fs)
+	attr.handled_access_fs = _landlock_access_fs_write_file | _landlock_access_fs_remove_dir | _landlock_access_fs_remove_file |
+		_landlock_access_fs_make_char | _landlock_access_fs_make_dir | _landlock_access_fs_make_reg | _landlock_access_fs_make_sock |
+		_landlock_access_fs_make_fifo | _landlock_access_fs_make_block | _landlock_access_fs_make_sym
+	if abi >= 2 {
+		attr.handled_access_fs |= _landlock_access_fs_refer
+	}
+	if abi >= 3 {
+		attr.handled_access_fs |= _landlock_access_fs_truncate
+	}
+	if !network && abi >= 4 {
+		attr.handled_access_net = _landlock_access_net_bind_tcp | _landlock_access_net_connect_tcp
+		attrSize = unsafe.Sizeof(attr)
+	}
+
+	fd, _, errno := syscall.Syscall(_SYS_landlock_create_ruleset, uintptr(unsafe.Pointer(&attr)), attrSize, 0)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: landlock_create_ruleset(): %w", errno)
+	}
+	defer syscall.Close(int(fd))
+
+	//writable
+	err = _AppsSandbox_addPath(int(fd), dataFolder, attr.handled_access_fs)
+	if err != nil {
+		return err
+	}
+	err = _AppsSandbox_addPath(int(fd), os.DevNull, attr.handled_access_fs&(_landlock_access_fs_write_file|_landlock_access_fs_truncate))
+	if err != nil {
+		return err
+	}
+
+	//connection to router
+	if attr.handled_access_net != 0 && routerPort > 0 {
+		rule := _landlockNetPortAttr{allowed_access: _landlock_access_net_connect_tcp, port: uint64(routerPort)}
+		_, _, errno := syscall.Syscall6(_SYS_landlock_add_rule, fd, _landlock_rule_net_port, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("sandbox: landlock_add_rule(port %d): %w", routerPort, errno)
+		}
+	}
+
+	_, _, errno = syscall.Syscall6(syscall.SYS_PRCTL, _PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	_, _, errno = syscall.Syscall(_SYS_landlock_restrict_self, fd, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: landlock_restrict_self(): %w", errno)
+	}
+	if !network && _AppsSandbox_auditArch() != 0 {
+		err = _AppsSandbox_restrictSockets(routerPort == 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return syscall.Exec(bin, append([]string{bin}, args[3:]...), os.Environ())
+}
+
+func _AppsSandbox_addPath(rulesetFd int, path string, access uint64) error {
+	pathFd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("sandbox: open '%s': %w", path, err)
+	}
+	defer syscall.Close(pathFd)
+
+	rule := _landlockPathBeneathAttr{allowed_access: access, parent_fd: int32(pathFd)}
+	_, _, errno := syscall.Syscall6(_SYS_landlock_add_rule, uintptr(rulesetFd), _landlock_rule_path_beneath, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: landlock_add_rule('%s'): %w", path, errno)
+	}
+	return nil
+}
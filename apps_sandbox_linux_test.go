@@ -0,0 +1,142 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// test binary is started as sandbox launcher and then as sandboxed app
+func TestAppsSandbox_helper(t *testing.T) {
+	switch os.Getenv("SKYALT_TEST_SANDBOX") {
+	case "launcher":
+		os.Setenv("SKYALT_TEST_SANDBOX", "app")
+		err := AppsSandbox_exec([]string{os.Getenv("SKYALT_TEST_NETWORK"), os.Getenv("SKYALT_TEST_PORT"), os.Args[0], "-test.run=TestAppsSandbox_helper"})
+		fmt.Println("exec failed:", err)
+		os.Exit(1)
+
+	case "app":
+		fnResult := func(name string, err error) {
+			fmt.Printf("%s: %v\n", name, err == nil)
+		}
+
+		fmt.Printf("sandboxed: %v\n", os.Getenv("SKYALT_SANDBOXED") == "1")
+		fnResult("write_data", os.WriteFile(filepath.Join(AppsSandbox_dataFolder, "storage.json"), []byte("{}"), 0644))
+		fnResult("write_app", os.WriteFile("storage.json", []byte("{}"), 0644))
+		fnResult("remove_code", os.Remove("skyalt"))
+		fnResult("write_outside", os.WriteFile(filepath.Join("..", "outside.txt"), []byte("x"), 0644))
+		f, err := os.CreateTemp("", "x")
+		if err == nil {
+			f.Close()
+		}
+		fnResult("write_temp", err)
+
+		conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", os.Getenv("SKYALT_TEST_PORT")))
+		if err == nil {
+			conn.Close()
+		}
+		fnResult("connect_router", err)
+		conn, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", os.Getenv("SKYALT_TEST_OTHER_PORT")))
+		if err == nil {
+			conn.Close()
+		}
+		fnResult("connect_other", err)
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err == nil {
+			udp.Close()
+		}
+		fnResult("udp", err)
+		os.Exit(0)
+	}
+}
+
+func TestAppsSandbox_exec(t *testing.T) {
+	if _AppsSandbox_landlockABI() < 4 {
+		t.Skip("kernel doesn't support landlock network rules")
+	}
+
+	fnListen := func() (net.Listener, string) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+		go func() {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				c.Close()
+			}
+		}()
+		return l, strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	}
+	_, routerPort := fnListen()
+	_, otherPort := fnListen()
+
+	fnRun := func(network string) map[string]bool {
+		root := t.TempDir()
+		appFolder := filepath.Join(root, "App")
+		os.Mkdir(appFolder, 0755)
+		os.WriteFile(filepath.Join(appFolder, "skyalt"), []byte("#Tool ShowText\n"), 0644)
+
+		cmd := exec.Command(os.Args[0], "-test.run=TestAppsSandbox_helper")
+		cmd.Dir = appFolder
+		cmd.Env = append(os.Environ(), "SKYALT_TEST_SANDBOX=launcher", "SKYALT_TEST_NETWORK="+network, "SKYALT_TEST_PORT="+routerPort, "SKYALT_TEST_OTHER_PORT="+otherPort)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %s", err, out)
+		}
+
+		res := make(map[string]bool)
+		for _, ln := range strings.Split(string(out), "\n") {
+			name, value, found := strings.Cut(ln, ": ")
+			if found {
+				res[name] = (value == "true")
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(root, "outside.txt")); err == nil {
+			t.Errorf("file outside of app's folder was created")
+		}
+		if _, err := os.Stat(filepath.Join(appFolder, "skyalt")); err != nil {
+			t.Errorf("'skyalt' file was removed")
+		}
+		return res
+	}
+
+	res := fnRun("0")
+	for name, expected := range map[string]bool{"sandboxed": true, "write_data": true, "write_app": false, "remove_code": false, "write_outside": false, "write_temp": true, "connect_router": true, "connect_other": false, "udp": false} {
+		if res[name] != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, res)
+		}
+	}
+
+	//'network: yes'
+	res = fnRun("1")
+	if !res["connect_other"] || !res["udp"] || res["write_outside"] {
+		t.Errorf("network granted: %v", res)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func AppsSandbox_exec(args []string) error {
+	return fmt.Errorf("sandbox is supported only on Linux, not on %s. Disable SKYALT_SANDBOX", runtime.GOOS)
+}
+
+func AppsSandbox_networkWeakness(routerPort int) string {
+	return ""
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const AppsBuildScheduler_visibleTimeout = 10 * time.Second //app is visible, if Root has shown it recently
+
+const (
+	AppsBuildScheduler_priorityRoot = iota
+	AppsBuildScheduler_priorityVisible
+	AppsBuildScheduler_priorityOther
+)
+
+type AppsBuildJob struct {
+	appName string
+	app     *ToolsApp
+	msg     *AppsRouterMsg //'<app>_skyalt_compile', Tick() continues with it
+
+	order        uint64 //FIFO for same priority
+	appFilesTime int64  //when build started, 0 = waiting
+}
+
+// Hot reload builds run in bounded worker pool. Queue is sorted by priority: Root app, visible apps, others.
+type AppsBuildScheduler struct {
+	router *AppsRouter
+
+	lock sync.Mutex
+	cond *sync.Cond
+
+	workers int
+	queue   []*AppsBuildJob
+	running map[string]*AppsBuildJob //[app name]
+	visible map[string]time.Time     //[app name]last shown
+	order   uint64
+
+	exiting bool
+}
+
+// number of workers: env SKYALT_BUILD_WORKERS, default is half of CPUs
+func AppsBuildScheduler_GetWorkers() int {
+	n, err := strconv.Atoi(os.Getenv("SKYALT_BUILD_WORKERS"))
+	if err == nil && n > 0 {
+		return n
+	}
+	return max(1, runtime.NumCPU()/2)
+}
+
+func NewAppsBuildScheduler(router *AppsRouter, workers int) *AppsBuildScheduler {
+	bs := &AppsBuildScheduler{router: router, workers: workers}
+	bs.cond = sync.NewCond(&bs.lock)
+	bs.running = make(map[string]*AppsBuildJob)
+	bs.visible = make(map[string]time.Time)
+
+	for range workers {
+		go bs.runWorker()
+	}
+	return bs
+}
+
+func (bs *AppsBuildScheduler) Destroy() {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	bs.exiting = true
+	for _, job := range bs.queue {
+		job.msg.Done()
+	}
+	bs.queue = nil
+	for _, job := range bs.running {
+		job.msg.Stop()
+	}
+	bs.cond.Broadcast()
+}
+
+// Root app has shown app
+func (bs *AppsBuildScheduler) SetVisible(appName string) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	_, wasVisible := bs.visible[appName]
+	bs.visible[appName] = time.Now()
+	if !wasVisible {
+		bs._sortQueue()
+	}
+}
+
+// queues app build. Returns false, if app is already queued or building.
+func (bs *AppsBuildScheduler) Add(app *ToolsApp) bool {
+	return bs._add(app.Process.Compile.appName, app)
+}
+
+func (bs *AppsBuildScheduler) _add(appName string, app *ToolsApp) bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	if bs.exiting || bs._find(appName) != nil {
+		return false
+	}
+
+	bs.order++
+	job := &AppsBuildJob{appName: appName, app: app, order: bs.order}
+	job.msg = bs.router.AddLocalRecompileMsg(appName)
+	bs.queue = append(bs.queue, job)
+
+	bs._sortQueue()
+	bs.cond.Signal()
+	return true
+}
+
+// stops running build, if app's files has changed since build started. Hot reload queues it again.
+func (bs *AppsBuildScheduler) CancelStale(appName string, appFilesTime int64) bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	job := bs.running[appName]
+	if job == nil || job.appFilesTime == 0 || job.appFilesTime == appFilesTime || !job.msg.GetContinue() {
+		return false
+	}
+
+	fmt.Printf("Canceling stale build of '%s'\n", appName)
+	job.msg.Stop()
+	return true
+}
+
+func (bs *AppsBuildScheduler) IsIdle() bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	return len(bs.queue) == 0 && len(bs.running) == 0
+}
+
+func (bs *AppsBuildScheduler) _find(appName string) *AppsBuildJob {
+	if job := bs.running[appName]; job != nil {
+		return job
+	}
+	for _, job := range bs.queue {
+		if job.appName == appName {
+			return job
+		}
+	}
+	return nil
+}
+
+func (bs *AppsBuildScheduler) _priority(appName string) int {
+	if appName == "Root" {
+		return AppsBuildScheduler_priorityRoot
+	}
+	if tm, found := bs.visible[appName]; found && time.Since(tm) < AppsBuildScheduler_visibleTimeout {
+		return AppsBuildScheduler_priorityVisible
+	}
+	return AppsBuildScheduler_priorityOther
+}
+
+// sorts queue and updates waiting labels
+func (bs *AppsBuildScheduler) _sortQueue() {
+	slices.SortFunc(bs.queue, func(a, b *AppsBuildJob) int {
+		pa := bs._priority(a.appName)
+		pb := bs._priority(b.appName)
+		if pa != pb {
+			return pa - pb
+		}
+		return int(a.order - b.order)
+	})
+
+	for i, job := range bs.queue {
+		job.msg.SetProgressLabel(fmt.Sprintf("Waiting for build(%d/%d in queue) %s", i+1, len(bs.queue), job.appName))
+	}
+}
+
+// takes job with highest priority, blocks until there is one. Returns nil, when scheduler is destroyed.
+func (bs *AppsBuildScheduler) _next() *AppsBuildJob {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	for len(bs.queue) == 0 && !bs.exiting {
+		bs.cond.Wait()
+	}
+	if bs.exiting {
+		return nil
+	}
+
+	bs._sortQueue() //visibility may have expired
+	job := bs.queue[0]
+	bs.queue = bs.queue[1:]
+	bs.running[job.appName] = job
+	bs._sortQueue()
+
+	job.msg.SetProgressLabel("Starting build " + job.appName)
+	return job
+}
+
+func (bs *AppsBuildScheduler) _done(job *AppsBuildJob) {
+	job.msg.Done()
+
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	delete(bs.running, job.appName)
+}
+
+func (bs *AppsBuildScheduler) runWorker() {
+	for {
+		job := bs._next()
+		if job == nil {
+			return
+		}
+
+		_, appFilesTime, _, err := job.app.getPromptFileTime()
+		if err == nil {
+			bs.lock.Lock()
+			job.appFilesTime = appFilesTime
+			bs.lock.Unlock()
+		}
+
+		job.app.Tick(nil) //uses job.msg, error is logged
+
+		bs._done(job)
+	}
+}
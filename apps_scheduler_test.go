@@ -0,0 +1,102 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppsBuildScheduler_queue(t *testing.T) {
+	router := &AppsRouter{msgs: make(map[uint64]*AppsRouterMsg)}
+	bs := NewAppsBuildScheduler(router, 0) //no workers
+
+	bs._add("Calc", nil)
+	bs._add("Notes", nil)
+	bs._add("Root", nil)
+	if bs._add("Calc", nil) {
+		t.Fatal("app queued twice")
+	}
+	bs.SetVisible("Notes")
+
+	msg := router.FindMessageName([]byte("Notes_skyalt_compile"))
+	if msg == nil {
+		t.Fatalf("queued msg wasn't found")
+	}
+	if _, label := msg.GetProgress(); !strings.HasPrefix(label, "Waiting for build(2/3 in queue)") {
+		t.Fatalf("queued msg: %s", label)
+	}
+	if router.AddLocalRecompileMsg("Notes") != msg {
+		t.Fatal("queued msg wasn't reused")
+	}
+
+	var order []string
+	for range 3 {
+		job := bs._next()
+		order = append(order, job.appName)
+	}
+	if strings.Join(order, ",") != "Root,Notes,Calc" {
+		t.Fatalf("order: %v", order)
+	}
+	if bs._add("Calc", nil) {
+		t.Fatal("running app queued")
+	}
+
+	//stale
+	job := bs.running["Calc"]
+	if bs.CancelStale("Calc", 10) {
+		t.Fatal("canceled before build started")
+	}
+	job.appFilesTime = 10
+	if bs.CancelStale("Calc", 10) {
+		t.Fatal("canceled up-to-date build")
+	}
+	if !bs.CancelStale("Calc", 11) || job.msg.GetContinue() {
+		t.Fatal("stale build wasn't canceled")
+	}
+	if bs.CancelStale("Calc", 12) {
+		t.Fatal("canceled twice")
+	}
+
+	for _, appName := range order {
+		bs._done(bs.running[appName])
+	}
+	if !bs.IsIdle() || !job.msg.out_done.Load() {
+		t.Fatal("scheduler isn't idle")
+	}
+}
+
+func TestToolsAppCompile_runCanceled(t *testing.T) {
+	msg := NewAppsRouterMsg(1, "compile", nil, nil)
+	msg.Stop()
+
+	st := time.Now()
+	err := _ToolsAppCompile_run(exec.Command("sleep", "10"), msg)
+	if err != ErrToolsAppCompile_canceled {
+		t.Fatalf("expected cancel, got %v", err)
+	}
+	if time.Since(st) > 5*time.Second {
+		t.Fatal("command wasn't killed")
+	}
+
+	err = _ToolsAppCompile_run(exec.Command("true"), NewAppsRouterMsg(2, "compile", nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -22,18 +22,36 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
 	"unicode"
 )
 
+// JSON Schema of tool argument
 type ToolsOpenAI_completion_tool_function_parameters_properties struct {
-	Type        string          `json:"type"` //"number", "string"
+	Type        string          `json:"type,omitempty"`   //"number", "integer", "string", "boolean", "array", "object", ""=any
+	Format      string          `json:"format,omitempty"` //"date-time"
 	Description string          `json:"description,omitempty"`
 	Enum        json.RawMessage `json:"enum,omitempty"`
-	Default     string          `json:"default,omitempty"`
+	Default     json.RawMessage `json:"default,omitempty"`
+	Examples    json.RawMessage `json:"examples,omitempty"`
+
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	MinItems  *int     `json:"minItems,omitempty"`
+	MaxItems  *int     `json:"maxItems,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
 
 	Items *ToolsOpenAI_completion_tool_function_parameters_properties `json:"items,omitempty"` //for arrays
+
+	//for objects
+	Properties           map[string]*ToolsOpenAI_completion_tool_function_parameters_properties `json:"properties,omitempty"`
+	Required             []string                                                               `json:"required,omitempty"`
+	AdditionalProperties any                                                                    `json:"additionalProperties,omitempty"` //false for structs, schema of values for maps
 }
 type ToolsOpenAI_completion_tool_schema struct {
 	Type                 string   `json:"type"` //"object"
@@ -54,6 +72,30 @@ type ToolsOpenAI_completion_tool struct {
 	Function ToolsOpenAI_completion_tool_function `json:"function"`
 }
 
+// '[sequential]' mark in tool's description: tool changes storage, so it doesn't run with other tool calls at once
+func (tool *ToolsOpenAI_completion_tool) IsSequential() bool {
+	return strings.Contains(tool.Function.Description, "[sequential]")
+}
+
+// description without marks, which are only for router
+func (tool *ToolsOpenAI_completion_tool) GetModelDescription() string {
+	return strings.TrimSpace(strings.ReplaceAll(tool.Function.Description, "[sequential]", ""))
+}
+
+// tools sent to model, marks are removed from descriptions
+func ToolsSchema_ForModel(tools []*ToolsOpenAI_completion_tool) []*ToolsOpenAI_completion_tool {
+	var out []*ToolsOpenAI_completion_tool
+	for _, tool := range tools {
+		if desc := tool.GetModelDescription(); desc != tool.Function.Description {
+			cp := *tool
+			cp.Function.Description = desc
+			tool = &cp
+		}
+		out = append(out, tool)
+	}
+	return out
+}
+
 func NewToolsOpenAI_completion_tool(name, description string) *ToolsOpenAI_completion_tool {
 	fn := &ToolsOpenAI_completion_tool{Type: "function"}
 	fn.Function = ToolsOpenAI_completion_tool_function{Name: name, Description: description, Strict: false}
@@ -63,95 +105,374 @@ func NewToolsOpenAI_completion_tool(name, description string) *ToolsOpenAI_compl
 	return fn
 }
 
+// typee is Go type, for example: "float64", "[]string"
 func (prm *ToolsOpenAI_completion_tool_schema) AddParam(name, typee, description string) *ToolsOpenAI_completion_tool_function_parameters_properties {
-	//array
-	var items *ToolsOpenAI_completion_tool_function_parameters_properties
-	if strings.Contains(strings.ToLower(typee), "[]") {
-		subType := strings.ReplaceAll(typee, "[]", "")
-
-		typee = "array"
-		items = &ToolsOpenAI_completion_tool_function_parameters_properties{Type: ToolsOpenAI_convertTypeToSchemaType(subType)}
+	var p *ToolsOpenAI_completion_tool_function_parameters_properties
+	expr, err := parser.ParseExpr(typee)
+	if err == nil {
+		p = _newToolsSchemaTypes().convert(expr)
+	} else {
+		p = &ToolsOpenAI_completion_tool_function_parameters_properties{}
 	}
 
-	isOptional := strings.Contains(description, "[optional]")
-	if isOptional {
-		//description = strings.ReplaceAll(description, "[optional]", "")
-	} else {
+	prm.addProperty(name, p, description)
+	return p
+}
+
+func (prm *ToolsOpenAI_completion_tool_schema) addProperty(name string, p *ToolsOpenAI_completion_tool_function_parameters_properties, description string) {
+	if p.applyMarks(description) {
 		prm.Required = append(prm.Required, name)
 	}
+	prm.Properties[name] = p
+}
 
-	var options []string
-	options_start := "[options:"
-	options_pos := strings.Index(description, options_start)
-	if options_pos > 0 {
-		opt := description[options_pos+len(options_start):] //cut
-		d := strings.Index(opt, "]")
-		if d >= 0 {
-			opt = opt[:d] //cut
-			options = strings.Split(opt, ",")
-
-			description = description[:options_pos] + description[options_pos+len(options_start)+d+1:]
-		}
+// cuts '[<name>: <value>]' from description. Value can have brackets inside, for example: [pattern: ^[a-z]+$]
+func _ToolsSchema_cutMark(description *string, name string) (string, bool) {
+	start := strings.Index(*description, "["+name+":")
+	if start < 0 {
+		return "", false
 	}
-	//clean
-	{
-		description = strings.TrimSpace(description)
-		for o := range options {
-			options[o] = strings.TrimSpace(options[o])
+
+	valueStart := start + len(name) + 2
+	depth := 1
+	for i := valueStart; i < len(*description); i++ {
+		switch (*description)[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				value := strings.TrimSpace((*description)[valueStart:i])
+				*description = (*description)[:start] + (*description)[i+1:]
+				return value, true
+			}
 		}
 	}
+	return "", false //not closed
+}
 
-	//convert string options into enum(ints or floats or strings)
-	var enum json.RawMessage
-	{
-		areOptionInts := true
-		areOptionsFloats := true
-		for _, opt := range options {
-			_, errI := strconv.ParseInt(opt, 10, 64)
-			if errI != nil {
-				areOptionInts = false
+// splits by commas, which are not inside quotes or brackets
+func _ToolsSchema_splitList(str string) []string {
+	var items []string
+	depth := 0
+	inQuotes := false
+	last := 0
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case '[', '{':
+			if !inQuotes {
+				depth++
 			}
-			_, errF := strconv.ParseFloat(opt, 64)
-			if errF != nil {
-				areOptionsFloats = false
+		case ']', '}':
+			if !inQuotes {
+				depth--
 			}
+		case ',':
+			if !inQuotes && depth == 0 {
+				items = append(items, strings.TrimSpace(str[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	if item := strings.TrimSpace(str[last:]); item != "" {
+		items = append(items, item)
+	}
+	return items
+}
+
+// converts mark's value into JSON value of property's type
+func (p *ToolsOpenAI_completion_tool_function_parameters_properties) parseValue(str string) json.RawMessage {
+	if p.Type == "string" {
+		unq, err := strconv.Unquote(str)
+		if err == nil {
+			str = unq
+		}
+		js, _ := json.Marshal(str)
+		return js
+	}
+	if json.Valid([]byte(str)) {
+		return json.RawMessage(str)
+	}
+	js, _ := json.Marshal(str)
+	return js
+}
+
+func (p *ToolsOpenAI_completion_tool_function_parameters_properties) parseValues(str string) json.RawMessage {
+	values := make([]json.RawMessage, 0)
+	for _, it := range _ToolsSchema_splitList(str) {
+		values = append(values, p.parseValue(it))
+	}
+	js, _ := json.Marshal(values)
+	return js
+}
+
+// sets limit based on type: number value, string length or array items
+func (p *ToolsOpenAI_completion_tool_function_parameters_properties) setLimit(str string, isMax bool) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return
+	}
+	n := int(f)
+
+	switch p.Type {
+	case "string":
+		if isMax {
+			p.MaxLength = &n
+		} else {
+			p.MinLength = &n
+		}
+	case "array":
+		if isMax {
+			p.MaxItems = &n
+		} else {
+			p.MinItems = &n
+		}
+	default:
+		if isMax {
+			p.Maximum = &f
+		} else {
+			p.Minimum = &f
+		}
+	}
+}
+
+// parses marks from argument's comment. Returns false for [optional] argument.
+func (p *ToolsOpenAI_completion_tool_function_parameters_properties) applyMarks(description string) bool {
+	required := !strings.Contains(description, "[optional]")
+	description = strings.ReplaceAll(description, "[optional]", "")
+
+	//options and pattern are for array's items
+	item := p
+	if p.Type == "array" && p.Items != nil {
+		item = p.Items
+	}
+
+	if value, found := _ToolsSchema_cutMark(&description, "options"); found {
+		item.Enum = item.parseValues(value)
+	}
+	if value, found := _ToolsSchema_cutMark(&description, "pattern"); found {
+		item.Pattern = value
+	}
+	if value, found := _ToolsSchema_cutMark(&description, "min"); found {
+		p.setLimit(value, false)
+	}
+	if value, found := _ToolsSchema_cutMark(&description, "max"); found {
+		p.setLimit(value, true)
+	}
+	if value, found := _ToolsSchema_cutMark(&description, "default"); found {
+		p.Default = p.parseValue(value)
+	}
+	if value, found := _ToolsSchema_cutMark(&description, "examples"); found {
+		p.Examples = p.parseValues(value)
+	}
+
+	p.Description = strings.TrimSpace(description)
+	return required
+}
+
+// named types from app's code
+type _ToolsSchemaTypes struct {
+	specs    map[string]*ast.TypeSpec
+	visiting map[string]bool //recursive types
+}
+
+func _newToolsSchemaTypes() *_ToolsSchemaTypes {
+	return &_ToolsSchemaTypes{specs: make(map[string]*ast.TypeSpec), visiting: make(map[string]bool)}
+}
+
+func (ts *_ToolsSchemaTypes) add(node *ast.File) {
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
 		}
-		if areOptionInts {
-			options2 := make([]int64, len(options))
-			for i := range options {
-				options2[i], _ = strconv.ParseInt(options[i], 10, 64)
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if ok {
+				ts.specs[typeSpec.Name.Name] = typeSpec
 			}
-			enum, _ = json.Marshal(options2) //ints
+		}
+	}
+}
+
+// converts Go type into JSON Schema. Unknown types accept any value.
+func (ts *_ToolsSchemaTypes) convert(expr ast.Expr) *ToolsOpenAI_completion_tool_function_parameters_properties {
+	type Props = ToolsOpenAI_completion_tool_function_parameters_properties
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return &Props{Type: "string"}
+		case "bool", "boolean":
+			return &Props{Type: "boolean"}
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune", "integer":
+			return _ToolsSchema_integer(t.Name)
+		case "float32", "float64", "number":
+			return &Props{Type: "number"}
+		case "any":
+			return &Props{}
+		}
+
+		spec := ts.specs[t.Name]
+		if spec == nil {
+			return &Props{}
+		}
+		if ts.visiting[t.Name] {
+			return &Props{Type: "object"} //recursive type
+		}
+		ts.visiting[t.Name] = true
+		defer delete(ts.visiting, t.Name)
+		return ts.convert(spec.Type)
+
+	case *ast.StarExpr:
+		return ts.convert(t.X)
+
+	case *ast.ArrayType:
+		if elt, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (elt.Name == "byte" || elt.Name == "uint8") {
+			return &Props{Type: "string"} //json encodes []byte as base64 string
+		}
+		return &Props{Type: "array", Items: ts.convert(t.Elt)}
 
-		} else if areOptionsFloats {
-			options2 := make([]float64, len(options))
-			for i := range options {
-				options2[i], _ = strconv.ParseFloat(options[i], 64)
+	case *ast.MapType:
+		return &Props{Type: "object", AdditionalProperties: ts.convert(t.Value)}
+
+	case *ast.StructType:
+		obj := &Props{Type: "object", Properties: make(map[string]*Props), AdditionalProperties: false}
+		ts.walkFields(t, func(name string, tp ast.Expr, doc string) {
+			p := ts.convert(tp)
+			if p.applyMarks(doc) {
+				obj.Required = append(obj.Required, name)
 			}
-			enum, _ = json.Marshal(options2) //floats
-		} else {
-			enum, _ = json.Marshal(options) //strings
+			obj.Properties[name] = p
+		})
+		return obj
+
+	case *ast.SelectorExpr:
+		switch _exprToString(t) {
+		case "time.Time":
+			return &Props{Type: "string", Format: "date-time"}
+		case "time.Duration":
+			return &Props{Type: "integer"} //nanoseconds
 		}
+		return &Props{}
 	}
 
-	p := &ToolsOpenAI_completion_tool_function_parameters_properties{Type: ToolsOpenAI_convertTypeToSchemaType(typee), Description: description, Items: items, Enum: enum}
-	prm.Properties[name] = p
+	return &Props{} //interface, etc.
+}
 
+// integer limited by range of Go type, so tool doesn't fail on unmarshal
+func _ToolsSchema_integer(typeName string) *ToolsOpenAI_completion_tool_function_parameters_properties {
+	p := &ToolsOpenAI_completion_tool_function_parameters_properties{Type: "integer"}
+
+	var lo, hi float64
+	switch typeName {
+	case "int8":
+		lo, hi = math.MinInt8, math.MaxInt8
+	case "int16":
+		lo, hi = math.MinInt16, math.MaxInt16
+	case "int32", "rune":
+		lo, hi = math.MinInt32, math.MaxInt32
+	case "int", "int64":
+		lo, hi = math.MinInt64, math.MaxInt64
+	case "uint8", "byte":
+		lo, hi = 0, math.MaxUint8
+	case "uint16":
+		lo, hi = 0, math.MaxUint16
+	case "uint32":
+		lo, hi = 0, math.MaxUint32
+	case "uint", "uint64", "uintptr":
+		lo, hi = 0, math.MaxUint64
+	default:
+		return p //"integer"
+	}
+	p.Minimum = &lo
+	p.Maximum = &hi
 	return p
 }
 
-func ToolsOpenAI_convertTypeToSchemaType(tp string) string {
-	if strings.Contains(strings.ToLower(tp), "float") ||
-		strings.Contains(strings.ToLower(tp), "int") ||
-		strings.Contains(strings.ToLower(tp), "number") {
-		tp = "number"
+// calls fn for every exported field. Name is from json tag, if it has one. Fields of embedded structs are inlined same as encoding/json does, outer fields have precedence.
+func (ts *_ToolsSchemaTypes) walkFields(st *ast.StructType, fn func(name string, tp ast.Expr, doc string)) {
+	done := make(map[string]bool)
+	var embedded []string
+
+	for _, field := range st.Fields.List {
+		fieldDoc := ""
+		if field.Doc != nil {
+			fieldDoc = strings.TrimSpace(field.Doc.Text())
+		}
+		if field.Comment != nil {
+			fieldDoc = strings.TrimSpace(field.Comment.Text())
+		}
+
+		jsonName := ""
+		if field.Tag != nil {
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			jsonName, _, _ = strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+			if jsonName == "-" {
+				continue
+			}
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			typeName, local := _ToolsSchema_embeddedName(field.Type)
+			if jsonName == "" && local {
+				if spec := ts.specs[typeName]; spec != nil {
+					if _, isStruct := spec.Type.(*ast.StructType); isStruct {
+						embedded = append(embedded, typeName)
+						continue
+					}
+				}
+			}
+			names = []*ast.Ident{ast.NewIdent(typeName)}
+		}
+
+		for _, name := range names {
+			if name.Name == "" || !unicode.IsUpper(rune(name.Name[0])) {
+				continue //private
+			}
+			nm := name.Name
+			if jsonName != "" {
+				nm = jsonName
+			}
+			done[nm] = true
+			fn(nm, field.Type, fieldDoc)
+		}
 	}
 
-	if strings.Contains(strings.ToLower(tp), "bool") {
-		tp = "boolean"
+	for _, typeName := range embedded {
+		if ts.visiting[typeName] {
+			continue //recursive type
+		}
+		ts.visiting[typeName] = true
+		ts.walkFields(ts.specs[typeName].Type.(*ast.StructType), func(name string, tp ast.Expr, doc string) {
+			if !done[name] {
+				done[name] = true
+				fn(name, tp, doc)
+			}
+		})
+		delete(ts.visiting, typeName)
 	}
+}
 
-	return tp
+// type name of embedded field: T, *T or pkg.T(not local)
+func _ToolsSchema_embeddedName(expr ast.Expr) (string, bool) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name, true
+	case *ast.StarExpr:
+		return _ToolsSchema_embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name, false
+	}
+	return "", false
 }
 
 func _exprToString(expr ast.Expr) string {
@@ -174,14 +495,21 @@ func _exprToString(expr ast.Expr) string {
 	}
 }
 
-func BuildToolsOpenAI_completion_tool(toolName string, fileName string, code any) (*ToolsOpenAI_completion_tool, error) {
+// typesCodes are other app's files, where argument's types can be defined
+func BuildToolsOpenAI_completion_tool(toolName string, fileName string, code any, typesCodes ...string) (*ToolsOpenAI_completion_tool, error) {
 	node, err := parser.ParseFile(token.NewFileSet(), fileName, code, parser.ParseComments)
 	if LogsError(err) != nil {
 		return nil, err
 	}
 
-	var oai *ToolsOpenAI_completion_tool
-	isIgnored := false
+	types := _newToolsSchemaTypes()
+	for _, typesCode := range typesCodes {
+		typesNode, err := parser.ParseFile(token.NewFileSet(), "", typesCode, parser.ParseComments)
+		if err == nil {
+			types.add(typesNode)
+		}
+	}
+	types.add(node) //tool's file has precedence
 
 	for _, decl := range node.Decls {
 		genDecl, ok := decl.(*ast.GenDecl)
@@ -191,7 +519,7 @@ func BuildToolsOpenAI_completion_tool(toolName string, fileName string, code any
 
 		for _, spec := range genDecl.Specs {
 			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok {
+			if !ok || toolName != typeSpec.Name.Name {
 				continue
 			}
 
@@ -204,57 +532,20 @@ func BuildToolsOpenAI_completion_tool(toolName string, fileName string, code any
 			if genDecl.Doc != nil {
 				structDoc = strings.TrimSpace(genDecl.Doc.Text())
 			}
-
-			if toolName != typeSpec.Name.Name {
-				continue
-			}
-
 			if strings.Contains(structDoc, "[ignore]") {
-				isIgnored = true
+				return nil, nil
 			}
 
-			if !isIgnored {
-				oai = NewToolsOpenAI_completion_tool(typeSpec.Name.Name, structDoc)
-			}
-
-			for _, field := range structType.Fields.List {
-				fieldNames := make([]string, len(field.Names))
-				for i, name := range field.Names {
-					fieldNames[i] = name.Name
-				}
-
-				fieldDoc := ""
-				if field.Doc != nil {
-					fieldDoc = strings.TrimSpace(field.Doc.Text())
+			oai := NewToolsOpenAI_completion_tool(typeSpec.Name.Name, structDoc)
+			types.walkFields(structType, func(name string, tp ast.Expr, doc string) {
+				if strings.HasPrefix(name, "Out") {
+					return //output
 				}
-				if field.Comment != nil {
-					fieldDoc = strings.TrimSpace(field.Comment.Text())
-				}
-
-				if len(fieldNames) > 0 {
-					nm := strings.Join(fieldNames, ", ")
-					tp := _exprToString(field.Type)
-
-					//if tp == "UI" {
-					//	tool.UiAttrs = append(tool.UiAttrs, nm)
-					//skip
-					if strings.HasPrefix(nm, "Out") || (len(nm) > 0 && unicode.IsLower(rune(nm[0]))) {
-						//skip
-					} else {
-						if oai != nil {
-							oai.Function.Parameters.AddParam(nm, tp, fieldDoc)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	if !isIgnored {
-		if oai == nil {
-			return nil, LogsErrorf("struct '%s' not found", toolName)
+				oai.Function.Parameters.addProperty(name, types.convert(tp), doc)
+			})
+			return oai, nil
 		}
 	}
 
-	return oai, nil
+	return nil, LogsErrorf("struct '%s' not found", toolName)
 }
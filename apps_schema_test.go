@@ -0,0 +1,120 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuildToolsOpenAI_completion_tool(t *testing.T) {
+	storageCode := `package main
+
+type EventsFilter struct {
+	Title   string //Part of title [optional]
+	GroupID int64  //Group ID
+	next    *EventsFilter
+}
+
+type EventsPaging struct {
+	Offset int //First event [optional]
+	Limit  int //Shadowed by tool's field
+}
+`
+	toolCode := `package main
+
+import "time"
+
+// Returns list of events.
+type ListEvents struct {
+	Start    time.Time         //Event start time
+	GroupIDs []int64           //List of GroupIDs [min: 1] [examples: [1, 2], [5]]
+	Filter   EventsFilter      //Filter [optional]
+	Labels   map[string]string //Labels [optional]
+	Kind     string            //Kind [options: "meeting", "call"] [default: "call"]
+	Code     string            //Code [pattern: ^[A-Z]{3}$] [max: 3]
+	Limit    int               //Max events [min: 1] [max: 100] [default: 10]
+	Ignored  string ` + "`json:\"-\"`" + `
+	EventsPaging
+
+	Out_events []string
+}
+
+func (st *ListEvents) run(caller *ToolCaller, ui *UI) error {
+	return nil
+}
+`
+
+	oai, err := BuildToolsOpenAI_completion_tool("ListEvents", "ListEvents.go", toolCode, storageCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, _ := json.Marshal(oai.Function.Parameters)
+	var schema map[string]any
+	json.Unmarshal(js, &schema)
+
+	fnGet := func(path ...string) string { //keys are sorted
+		var it any = schema
+		for _, p := range path {
+			m, ok := it.(map[string]any)
+			if !ok {
+				return ""
+			}
+			it = m[p]
+		}
+		b, _ := json.Marshal(it)
+		return string(b)
+	}
+
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"required"}, `["Start","GroupIDs","Kind","Code","Limit"]`},
+		{[]string{"properties", "Start"}, `{"description":"Event start time","format":"date-time","type":"string"}`},
+		{[]string{"properties", "GroupIDs"}, `{"description":"List of GroupIDs","examples":[[1,2],[5]],"items":{"maximum":9223372036854776000,"minimum":-9223372036854776000,"type":"integer"},"minItems":1,"type":"array"}`},
+		{[]string{"properties", "Filter", "required"}, `["GroupID"]`},
+		{[]string{"properties", "Filter", "additionalProperties"}, `false`},
+		{[]string{"properties", "Filter", "properties", "Title"}, `{"description":"Part of title","type":"string"}`},
+		{[]string{"properties", "Labels", "additionalProperties"}, `{"type":"string"}`},
+		{[]string{"properties", "Kind"}, `{"default":"call","description":"Kind","enum":["meeting","call"],"type":"string"}`},
+		{[]string{"properties", "Code"}, `{"description":"Code","maxLength":3,"pattern":"^[A-Z]{3}$","type":"string"}`},
+		{[]string{"properties", "Limit"}, `{"default":10,"description":"Max events","maximum":100,"minimum":1,"type":"integer"}`},
+		{[]string{"properties", "Offset"}, `{"description":"First event","maximum":9223372036854776000,"minimum":-9223372036854776000,"type":"integer"}`},
+		{[]string{"properties", "EventsPaging"}, `null`},
+		{[]string{"properties", "Ignored"}, `null`},
+		{[]string{"properties", "Out_events"}, `null`},
+	}
+	for _, tt := range tests {
+		if got := fnGet(tt.path...); got != tt.want {
+			t.Errorf("%v:\n got %s\nwant %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestToolsOpenAI_AddParam(t *testing.T) {
+	tool := NewToolsOpenAI_completion_tool("Sum", "")
+	tool.Function.Parameters.AddParam("A", "float64", "First number")
+	tool.Function.Parameters.AddParam("Names", "[]string", "Names [optional] [options: a, b]")
+
+	js, _ := json.Marshal(tool.Function.Parameters)
+	want := `{"type":"object","required":["A"],"additionalProperties":false,"properties":{"A":{"type":"number","description":"First number"},"Names":{"type":"array","description":"Names","items":{"type":"string","enum":["a","b"]}}}}`
+	if string(js) != want {
+		t.Errorf("got %s\nwant %s", js, want)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// invalid tool call argument, it's sent back to model, so it can fix the call
+type ToolsSchemaValidationError struct {
+	Path  string `json:"path"` //for example: "Filter.GroupIDs[2]", "" = all arguments
+	Error string `json:"error"`
+}
+
+// checks tool call arguments against tool's schema. Values are converted, when it's safe("5" -> 5, 2.0 -> 2, 5 -> "5", "true" -> true). Returns original arguments, if nothing was converted.
+func (tool *ToolsOpenAI_completion_tool) ValidateArguments(argsJs string) ([]byte, []ToolsSchemaValidationError) {
+	if strings.TrimSpace(argsJs) == "" {
+		argsJs = "{}" //tool without arguments
+	}
+
+	var args any
+	dec := json.NewDecoder(strings.NewReader(argsJs))
+	dec.UseNumber()
+	err := dec.Decode(&args)
+	if err == nil && dec.More() {
+		err = fmt.Errorf("unexpected data after JSON object")
+	}
+	if err != nil {
+		return nil, []ToolsSchemaValidationError{{Error: "arguments are not valid JSON: " + err.Error()}}
+	}
+
+	prm := &tool.Function.Parameters
+	root := &ToolsOpenAI_completion_tool_function_parameters_properties{Type: "object", Properties: prm.Properties, Required: prm.Required, AdditionalProperties: prm.AdditionalProperties}
+
+	v := &_ToolsSchemaValidator{}
+	out := v.check(args, root, "")
+	if len(v.errs) > 0 {
+		return nil, v.errs
+	}
+	if !v.converted {
+		return []byte(argsJs), nil
+	}
+
+	js, err := json.Marshal(out)
+	if err != nil {
+		return nil, []ToolsSchemaValidationError{{Error: err.Error()}}
+	}
+	return js, nil
+}
+
+// tool result for model
+func ToolsSchema_ValidationResult(toolName string, errs []ToolsSchemaValidationError) string {
+	type Result struct {
+		Error   string                       `json:"error"`
+		Details []ToolsSchemaValidationError `json:"details"`
+	}
+	js, _ := json.Marshal(Result{Error: fmt.Sprintf("Invalid arguments for tool '%s'. Tool wasn't called. Fix the arguments and call it again.", toolName), Details: errs})
+	return string(js)
+}
+
+// tool result for model, which called tool that doesn't exist
+func ToolsSchema_UnknownToolResult(toolName string, tools []*ToolsOpenAI_completion_tool) string {
+	type Result struct {
+		Error     string   `json:"error"`
+		Available []string `json:"available"`
+	}
+	available := []string{}
+	for _, tool := range tools {
+		available = append(available, tool.Function.Name)
+	}
+	js, _ := json.Marshal(Result{Error: fmt.Sprintf("Unknown tool '%s'. Tool wasn't called. Call one of available tools.", toolName), Available: available})
+	return string(js)
+}
+
+type _ToolsSchemaValidator struct {
+	errs      []ToolsSchemaValidationError
+	converted bool
+}
+
+func (v *_ToolsSchemaValidator) addError(path string, format string, a ...any) {
+	v.errs = append(v.errs, ToolsSchemaValidationError{Path: path, Error: fmt.Sprintf(format, a...)})
+}
+
+func _ToolsSchema_joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// additionalProperties is bool or schema, or map after loading from JSON
+func _ToolsSchema_getAdditional(p *ToolsOpenAI_completion_tool_function_parameters_properties) (bool, *ToolsOpenAI_completion_tool_function_parameters_properties) {
+	switch a := p.AdditionalProperties.(type) {
+	case nil:
+		return true, nil
+	case bool:
+		return a, nil
+	case *ToolsOpenAI_completion_tool_function_parameters_properties:
+		return true, a
+	default:
+		js, err := json.Marshal(a)
+		if err != nil {
+			return true, nil
+		}
+		var sub ToolsOpenAI_completion_tool_function_parameters_properties
+		if json.Unmarshal(js, &sub) != nil {
+			return true, nil
+		}
+		return true, &sub
+	}
+}
+
+func _ToolsSchema_typeName(val any) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+func _ToolsSchema_shorten(val any) string {
+	js, _ := json.Marshal(val)
+	if len(js) > 50 {
+		return string(js[:47]) + "..."
+	}
+	return string(js)
+}
+
+// returns converted value
+func (v *_ToolsSchemaValidator) check(val any, p *ToolsOpenAI_completion_tool_function_parameters_properties, path string) any {
+	val = v.convert(val, p)
+
+	switch p.Type {
+	case "":
+		return val //any
+
+	case "object":
+		obj, ok := val.(map[string]any)
+		if !ok {
+			v.addError(path, "expected object, got %s", _ToolsSchema_typeName(val))
+			return val
+		}
+		for _, name := range p.Required {
+			if _, found := obj[name]; !found {
+				v.addError(_ToolsSchema_joinPath(path, name), "missing required argument")
+			}
+		}
+
+		allowed, additional := _ToolsSchema_getAdditional(p)
+		for _, name := range slices.Sorted(maps.Keys(obj)) {
+			itemPath := _ToolsSchema_joinPath(path, name)
+			sub := p.Properties[name]
+			if sub == nil {
+				sub = additional
+			}
+			if sub == nil {
+				if !allowed {
+					v.addError(itemPath, "unknown argument, expected one of: %s", strings.Join(slices.Sorted(maps.Keys(p.Properties)), ", "))
+				}
+				continue
+			}
+			if obj[name] == nil && !slices.Contains(p.Required, name) {
+				delete(obj, name) //null means not set
+				v.converted = true
+				continue
+			}
+			obj[name] = v.check(obj[name], sub, itemPath)
+		}
+		return obj
+
+	case "array":
+		arr, ok := val.([]any)
+		if !ok {
+			v.addError(path, "expected array, got %s", _ToolsSchema_typeName(val))
+			return val
+		}
+		if p.MinItems != nil && len(arr) < *p.MinItems {
+			v.addError(path, "expected at least %d item(s), got %d", *p.MinItems, len(arr))
+		}
+		if p.MaxItems != nil && len(arr) > *p.MaxItems {
+			v.addError(path, "expected at most %d item(s), got %d", *p.MaxItems, len(arr))
+		}
+		if p.Items != nil {
+			for i := range arr {
+				arr[i] = v.check(arr[i], p.Items, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+		return arr
+
+	case "string":
+		str, ok := val.(string)
+		if !ok {
+			v.addError(path, "expected string, got %s", _ToolsSchema_typeName(val))
+			return val
+		}
+		n := utf8.RuneCountInString(str)
+		if p.MinLength != nil && n < *p.MinLength {
+			v.addError(path, "expected at least %d character(s), got %d", *p.MinLength, n)
+		}
+		if p.MaxLength != nil && n > *p.MaxLength {
+			v.addError(path, "expected at most %d character(s), got %d", *p.MaxLength, n)
+		}
+		if p.Pattern != "" {
+			re, err := regexp.Compile(p.Pattern)
+			if err == nil && !re.MatchString(str) { //unsupported pattern is skipped
+				v.addError(path, "%s doesn't match pattern %s", _ToolsSchema_shorten(str), p.Pattern)
+			}
+		}
+		if p.Format == "date-time" {
+			_, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				v.addError(path, "expected date-time in RFC 3339 format(2006-01-02T15:04:05Z07:00), got %s", _ToolsSchema_shorten(str))
+			}
+		}
+
+	case "number", "integer":
+		num, ok := val.(json.Number)
+		if !ok {
+			v.addError(path, "expected %s, got %s", p.Type, _ToolsSchema_typeName(val))
+			return val
+		}
+		f, err := num.Float64()
+		if err != nil {
+			v.addError(path, "invalid number %s", num)
+			return val
+		}
+		if p.Type == "integer" && f != math.Trunc(f) {
+			v.addError(path, "expected integer, got %s", num)
+		}
+		if p.Minimum != nil && (f < *p.Minimum || _ToolsSchema_overflows(num, *p.Minimum)) {
+			v.addError(path, "must be >= %s, got %s", _ToolsSchema_formatLimit(*p.Minimum), num)
+		}
+		if p.Maximum != nil && (f > *p.Maximum || _ToolsSchema_overflows(num, *p.Maximum)) {
+			v.addError(path, "must be <= %s, got %s", _ToolsSchema_formatLimit(*p.Maximum), num)
+		}
+
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			v.addError(path, "expected boolean, got %s", _ToolsSchema_typeName(val))
+			return val
+		}
+	}
+
+	if len(p.Enum) > 0 {
+		var options []any
+		dec := json.NewDecoder(bytes.NewReader(p.Enum))
+		dec.UseNumber()
+		if dec.Decode(&options) == nil && !slices.ContainsFunc(options, func(opt any) bool { return _ToolsSchema_equal(opt, val) }) {
+			v.addError(path, "%s is not one of options: %s", _ToolsSchema_shorten(val), string(p.Enum))
+		}
+	}
+
+	return val
+}
+
+// float64 can't hold limits of int64 and uint64 exactly, values around them are parsed
+func _ToolsSchema_overflows(num json.Number, limit float64) bool {
+	if (limit < 0) != strings.HasPrefix(string(num), "-") {
+		return false //other side
+	}
+
+	var err error
+	switch limit {
+	case math.MinInt64, math.MaxInt64:
+		_, err = strconv.ParseInt(string(num), 10, 64)
+	case math.MaxUint64:
+		_, err = strconv.ParseUint(string(num), 10, 64)
+	}
+	return errors.Is(err, strconv.ErrRange)
+}
+
+func _ToolsSchema_formatLimit(limit float64) string {
+	switch limit {
+	case math.MinInt64:
+		return strconv.FormatInt(math.MinInt64, 10)
+	case math.MaxInt64:
+		return strconv.FormatInt(math.MaxInt64, 10)
+	case math.MaxUint64:
+		return strconv.FormatUint(math.MaxUint64, 10)
+	}
+	return strconv.FormatFloat(limit, 'f', -1, 64)
+}
+
+func _ToolsSchema_equal(a, b any) bool {
+	na, okA := a.(json.Number)
+	nb, okB := b.(json.Number)
+	if okA && okB {
+		fa, errA := na.Float64()
+		fb, errB := nb.Float64()
+		return errA == nil && errB == nil && fa == fb
+	}
+	return a == b
+}
+
+// safe conversions of scalar values
+func (v *_ToolsSchemaValidator) convert(val any, p *ToolsOpenAI_completion_tool_function_parameters_properties) any {
+	switch p.Type {
+	case "number", "integer":
+		switch t := val.(type) {
+		case string:
+			str := strings.TrimSpace(t)
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil || !json.Valid([]byte(str)) {
+				return val //"abc", "NaN", "0x10", etc.
+			}
+			v.converted = true
+			if p.Type == "integer" && f == math.Trunc(f) && strings.ContainsAny(str, ".eE") {
+				return json.Number(strconv.FormatFloat(f, 'f', -1, 64)) //"2.0" -> 2
+			}
+			return json.Number(str)
+		case json.Number:
+			if p.Type == "integer" && strings.ContainsAny(string(t), ".eE") {
+				f, err := t.Float64()
+				if err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+					v.converted = true
+					return json.Number(strconv.FormatInt(int64(f), 10)) //2.0 -> 2
+				}
+			}
+		}
+
+	case "string":
+		switch t := val.(type) {
+		case json.Number:
+			v.converted = true
+			return string(t)
+		case bool:
+			v.converted = true
+			return strconv.FormatBool(t)
+		}
+
+	case "boolean":
+		if t, ok := val.(string); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(t))
+			if err == nil {
+				v.converted = true
+				return b
+			}
+		}
+	}
+	return val
+}
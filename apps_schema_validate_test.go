@@ -0,0 +1,106 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolsOpenAI_ValidateArguments(t *testing.T) {
+	storageCode := `package main
+
+type EventsFilter struct {
+	Title   string //Part of title [optional]
+	GroupID int64  //Group ID
+}
+`
+	toolCode := `package main
+
+import "time"
+
+type ListEvents struct {
+	Start    time.Time         //Event start time
+	GroupIDs []int64           //List of GroupIDs [min: 1]
+	Filter   EventsFilter      //Filter [optional]
+	Labels   map[string]int    //Labels [optional]
+	Kind     string            //Kind [options: "meeting", "call"] [optional]
+	Code     string            //Code [pattern: ^[A-Z]{3}$] [optional]
+	Limit    int               //Max events [min: 1] [max: 100] [optional]
+	Done     bool              //Done [optional]
+}
+`
+	tool, err := BuildToolsOpenAI_completion_tool("ListEvents", "ListEvents.go", toolCode, storageCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args string
+		want string   //converted arguments, "" = errors
+		errs []string //"path: error prefix"
+	}{
+		{"valid", `{"Start": "2025-03-01T10:00:00Z", "GroupIDs": [1, 2]}`, `{"Start": "2025-03-01T10:00:00Z", "GroupIDs": [1, 2]}`, nil},
+		{"coercion", `{"Start": "2025-03-01T10:00:00Z", "GroupIDs": ["1", 2.0, "9223372036854775807"], "Limit": "5", "Done": "true", "Filter": {"GroupID": "7", "Title": 3}, "Kind": null}`,
+			`{"Done":true,"Filter":{"GroupID":7,"Title":"3"},"GroupIDs":[1,2,9223372036854775807],"Limit":5,"Start":"2025-03-01T10:00:00Z"}`, nil},
+		{"out of range", `{"Start": "2025-03-01T10:00:00Z", "GroupIDs": [12345678901234567890, 9223372036854775808, -9223372036854775809]}`, "", []string{
+			"GroupIDs[0]: must be <= 9223372036854775807",
+			"GroupIDs[1]: must be <= 9223372036854775807",
+			"GroupIDs[2]: must be >= -9223372036854775808",
+		}},
+		{"empty", ``, "", []string{"GroupIDs: missing required", "Start: missing required"}},
+		{"not json", `{"Start": `, "", []string{": arguments are not valid JSON"}},
+		{"wrong types", `{"Start": "yesterday", "GroupIDs": [1.5, "x"], "Done": 1, "Labels": {"a": "b"}}`, "", []string{
+			"Start: expected date-time",
+			"GroupIDs[0]: expected integer",
+			"GroupIDs[1]: expected integer, got string",
+			"Done: expected boolean",
+			"Labels.a: expected integer, got string",
+		}},
+		{"limits", `{"Start": "2025-03-01T10:00:00Z", "GroupIDs": [], "Limit": 500, "Kind": "party", "Code": "abc"}`, "", []string{
+			"GroupIDs: expected at least 1 item(s)",
+			"Limit: must be <= 100",
+			`Kind: "party" is not one of options`,
+			`Code: "abc" doesn't match pattern`,
+		}},
+		{"unknown", `{"Start": "2025-03-01T10:00:00Z", "GroupIDs": [1], "Filter": {"GroupID": 1, "Color": "red"}}`, "", []string{"Filter.Color: unknown argument, expected one of: GroupID, Title"}},
+	}
+
+	for _, tt := range tests {
+		out, errs := tool.ValidateArguments(tt.args)
+
+		if tt.want != "" && string(out) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, out, tt.want)
+		}
+		if len(errs) != len(tt.errs) {
+			t.Errorf("%s: errors %v, want %v", tt.name, errs, tt.errs)
+			continue
+		}
+		for _, want := range tt.errs {
+			found := false
+			for _, er := range errs {
+				if strings.HasPrefix(er.Path+": "+er.Error, want) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s: missing error '%s' in %v", tt.name, want, errs)
+			}
+		}
+	}
+}
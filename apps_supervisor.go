@@ -0,0 +1,144 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const AppsSupervisor_interval = 5 * time.Second
+const AppsSupervisor_pingTimeout = 3 * time.Second
+const AppsSupervisor_maxPingFails = 3 //then app is killed
+
+const AppsSupervisor_minBackoff = 1 * time.Second
+const AppsSupervisor_maxBackoff = 60 * time.Second
+const AppsSupervisor_stableUptime = 60 * time.Second //crash after resets backoff
+
+type ToolsAppCrashFrame struct {
+	Func   string
+	File   string //base name
+	Line   int
+	Prompt string //ToolsPrompt.Name, code of prompt is in <Name>.go
+}
+
+type ToolsAppCrash struct {
+	Time   int64
+	Uptime float64 //sec
+	Error  string  //panic message or exit status
+	Frames []ToolsAppCrashFrame
+
+	Prompt string //where app crashed
+}
+
+var g_appsCrash_frameRe = regexp.MustCompile(`^\s+(.+\.go):(\d+)`)
+
+// parses Go panic from stderr. Only frames from app's code are kept.
+func ToolsAppCrash_parse(stderr string, absFolder string) ToolsAppCrash {
+	var crash ToolsAppCrash
+
+	lines := strings.Split(stderr, "\n")
+	for i, line := range lines {
+		isPanic := strings.HasPrefix(line, "panic: ")
+		isFatal := strings.HasPrefix(line, "fatal error: ")
+		if !isPanic && !isFatal {
+			continue
+		}
+		crash.Error = strings.TrimSpace(line)
+
+		crash.Frames = _ToolsAppCrash_parseFrames(lines[i+1:], absFolder)
+		break
+	}
+
+	if len(crash.Frames) > 0 {
+		crash.Prompt = crash.Frames[0].Prompt
+	}
+	return crash
+}
+
+// parses frames of first goroutine in stack trace(panic or debug.Stack()). Only frames from app's code are kept.
+func _ToolsAppCrash_parseFrames(lines []string, absFolder string) []ToolsAppCrashFrame {
+	var frames []ToolsAppCrashFrame
+
+	fnName := ""
+	for _, line := range lines {
+		m := g_appsCrash_frameRe.FindStringSubmatch(line)
+		if m == nil {
+			fnName = strings.TrimSpace(line)
+			if strings.HasPrefix(fnName, "goroutine ") && len(frames) > 0 {
+				break //only crashed goroutine
+			}
+			continue
+		}
+
+		if filepath.Dir(m[1]) != absFolder || filepath.Base(m[1]) == "main.go" { //main.go is sdk
+			continue
+		}
+
+		file := filepath.Base(m[1])
+		ln, _ := strconv.Atoi(m[2])
+		if paren := strings.LastIndexByte(fnName, '('); paren > 0 {
+			fnName = fnName[:paren] //cut args
+		}
+		frames = append(frames, ToolsAppCrashFrame{Func: fnName, File: file, Line: ln, Prompt: strings.TrimSuffix(file, ".go")})
+	}
+	return frames
+}
+
+func (crash *ToolsAppCrash) String() string {
+	if len(crash.Frames) > 0 {
+		return fmt.Sprintf("%s at %s:%d", crash.Error, crash.Frames[0].File, crash.Frames[0].Line)
+	}
+	return crash.Error
+}
+
+// pings running apps and restarts crashed ones
+func (router *AppsRouter) runSupervisor() {
+	for !router.exiting.Load() {
+		time.Sleep(AppsSupervisor_interval)
+
+		router.lock.Lock()
+		apps := make([]*ToolsApp, 0, len(router.apps))
+		for _, app := range router.apps {
+			apps = append(apps, app)
+		}
+		router.lock.Unlock()
+
+		for _, app := range apps {
+			if router.exiting.Load() {
+				return
+			}
+
+			if app.Process.IsRunning() {
+				port := app.Process.GetPort()
+				if port == 0 {
+					continue //not registered yet
+				}
+				err := _ToolsCaller_Ping(port, AppsSupervisor_pingTimeout)
+				if app.Process.CheckPing(err) {
+					LogsErrorf("'%s' app isn't responding, killing it: %v", app.Process.Compile.appName, err)
+				}
+			} else if app.Process.NeedRestart() {
+				LogsError(app.RestartCrashed())
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToolsAppCrash_parse(t *testing.T) {
+	stderr := `panic: runtime error: index out of range [3] with length 2
+
+goroutine 7 [running]:
+main.(*SumNumbers).run(0xc000126000, 0xc00011e000)
+	/home/user/skyalt/apps/Calc/SumNumbers.go:25 +0x1d4
+main.Average(...)
+	/home/user/skyalt/apps/Calc/Functions.go:12
+main.FindToolRunFunc.func1(0xc00011e000)
+	/home/user/skyalt/apps/Calc/main.go:1045 +0x2b
+encoding/json.Unmarshal(...)
+	/usr/local/go/src/encoding/json/decode.go:107
+main.main.func3()
+	/home/user/skyalt/apps/Calc/main.go:560 +0x125
+created by main.main in goroutine 1
+	/home/user/skyalt/apps/Calc/main.go:530 +0x4c5
+
+goroutine 1 [IO wait]:
+main.(*Other).run(0xc000126000)
+	/home/user/skyalt/apps/Calc/Other.go:5 +0x1d4
+exit status 2
+`
+
+	crash := ToolsAppCrash_parse(stderr, "/home/user/skyalt/apps/Calc")
+	if crash.Error != "panic: runtime error: index out of range [3] with length 2" {
+		t.Errorf("error: %q", crash.Error)
+	}
+	if crash.Prompt != "SumNumbers" {
+		t.Errorf("prompt: %q", crash.Prompt)
+	}
+	if len(crash.Frames) != 2 {
+		t.Fatalf("frames: %+v", crash.Frames)
+	}
+	if f := crash.Frames[0]; f.File != "SumNumbers.go" || f.Line != 25 || f.Func != "main.(*SumNumbers).run" {
+		t.Errorf("frame 0: %+v", f)
+	}
+	if f := crash.Frames[1]; f.Prompt != "Functions" || f.Line != 12 || f.Func != "main.Average" {
+		t.Errorf("frame 1: %+v", f)
+	}
+	if crash.String() != crash.Error+" at SumNumbers.go:25" {
+		t.Errorf("string: %q", crash.String())
+	}
+
+	//no panic
+	crash = ToolsAppCrash_parse("signal: killed", "/home/user/skyalt/apps/Calc")
+	if crash.Error != "" || len(crash.Frames) != 0 {
+		t.Errorf("unexpected crash: %+v", crash)
+	}
+}
+
+func TestToolsApp_RestartCrashed(t *testing.T) {
+	app := &ToolsApp{Process: NewToolsAppProcess("Notes")}
+	app.Process.Compile.Error = "syntax error" //CheckRun fails without starting process
+
+	//busy app(generating) doesn't block supervisor
+	app.lock.Lock()
+	done := make(chan error, 1)
+	go func() { done <- app.RestartCrashed() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("busy app should be skipped: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("supervisor is blocked by app's lock")
+	}
+	app.lock.Unlock()
+
+	if app.RestartCrashed() == nil {
+		t.Errorf("idle app should be checked")
+	}
+
+	//process state is read by router and supervisor in parallel
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				app.Process.SetPort(i*100 + j)
+				app.Process.GetPort()
+				app.Process.IsRunning()
+				app.Process.CheckPing(errors.New("timeout"))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const AppsWatcher_debounce = 50 * time.Millisecond //editors write file in several steps
+const AppsWatcher_pollInterval = 1 * time.Second   //fallback, when file watching isn't available
+
+// Watches folders(not recursive) for changes. Implemented with inotify on Linux.
+type AppsWatcher struct {
+	fd   int
+	file *os.File
+
+	lock  sync.Mutex
+	paths map[string]int //[path]watch descriptor
+	wds   map[int]string //[watch descriptor]path
+
+	changes chan struct{}
+	closed  chan struct{}
+}
+
+func _newAppsWatcher(fd int, file *os.File) *AppsWatcher {
+	w := &AppsWatcher{fd: fd, file: file}
+	w.paths = make(map[string]int)
+	w.wds = make(map[int]string)
+	w.changes = make(chan struct{}, 1)
+	w.closed = make(chan struct{})
+	return w
+}
+
+func (w *AppsWatcher) Destroy() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file != nil {
+		w.file.Close() //stops reading
+		w.file = nil
+	}
+}
+
+// only prompts, secrets and code(app's and sdk's) are watched. Build outputs(binary, go.mod, go.sum), storage, temp files and files written by router(main.go, tools.json) are ignored.
+func _AppsWatcher_ignore(name string, isDir bool) bool {
+	if strings.HasPrefix(name, ".") || name == AppsSandbox_dataFolder {
+		return true
+	}
+	if isDir {
+		return false //app was added, removed or renamed
+	}
+	if name == "main.go" {
+		return true
+	}
+	return name != "skyalt" && name != "secrets" && filepath.Ext(name) != ".go"
+}
+
+func (w *AppsWatcher) _notify(name string, isDir bool) {
+	if name != "" && _AppsWatcher_ignore(filepath.Base(name), isDir) {
+		return
+	}
+	select {
+	case w.changes <- struct{}{}:
+	default: //already notified
+	}
+}
+
+// adds new paths. Deleted folders are removed by kernel.
+func (w *AppsWatcher) SetPaths(paths []string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, path := range paths {
+		if _, found := w.paths[path]; found {
+			continue
+		}
+		wd, err := w._add(path)
+		if err != nil {
+			return err
+		}
+		w.paths[path] = wd
+		w.wds[wd] = path
+	}
+	return nil
+}
+
+func (w *AppsWatcher) _removed(wd int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.paths, w.wds[wd])
+	delete(w.wds, wd)
+}
+
+// blocks until something has changed and changes settle down. timeout=0 waits forever. Returns false, if watcher is closed.
+func (w *AppsWatcher) Wait(timeout time.Duration) bool {
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case <-w.changes:
+	case <-timeoutCh:
+		return true
+	case <-w.closed:
+		return false
+	}
+
+	//debounce
+	for {
+		select {
+		case <-w.changes:
+		case <-time.After(AppsWatcher_debounce):
+			return true
+		case <-w.closed:
+			return false
+		}
+	}
+}
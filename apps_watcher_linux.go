@@ -0,0 +1,80 @@
+//go:build linux
+
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const _AppsWatcher_mask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+func NewAppsWatcher() (*AppsWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	w := _newAppsWatcher(fd, os.NewFile(uintptr(fd), "inotify")) //non-blocking fd uses runtime poller, so Close() interrupts Read()
+	go w.run(w.file)
+	return w, nil
+}
+
+func (w *AppsWatcher) _add(path string) (int, error) {
+	if w.file == nil {
+		return -1, errors.New("watcher is closed")
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, path, _AppsWatcher_mask)
+	if err != nil {
+		return -1, &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	return wd, nil
+}
+
+func (w *AppsWatcher) run(file *os.File) {
+	defer close(w.closed)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			return //closed
+		}
+
+		for pos := 0; pos+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[pos]))
+			nameBytes := buf[pos+syscall.SizeofInotifyEvent : pos+syscall.SizeofInotifyEvent+int(ev.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			pos += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				w._removed(int(ev.Wd)) //folder deleted
+				continue
+			}
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				name = "" //lost events, reload everything
+			}
+			w._notify(name, ev.Mask&syscall.IN_ISDIR != 0)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppsWatcher(t *testing.T) {
+	dir := t.TempDir()
+	appDir := filepath.Join(dir, "Calc")
+	err := os.Mkdir(appDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewAppsWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.SetPaths([]string{dir, appDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fnWait := func(timeout time.Duration) time.Duration {
+		st := time.Now()
+		if !w.Wait(timeout) {
+			t.Fatal("watcher closed")
+		}
+		return time.Since(st)
+	}
+
+	//change
+	os.WriteFile(filepath.Join(appDir, "Sum.go"), []byte("package main"), 0644)
+	if dt := fnWait(5 * time.Second); dt >= 5*time.Second {
+		t.Fatal("change wasn't detected")
+	}
+
+	//router's files, build outputs and storage are ignored
+	for _, name := range []string{"main.go", "tools.json", "go.mod", "go.sum", "Calc_bin", "Sum-Sum.json", ".tmp"} {
+		os.WriteFile(filepath.Join(appDir, name), []byte("x"), 0644)
+		if dt := fnWait(300 * time.Millisecond); dt < 300*time.Millisecond {
+			t.Fatalf("%s change wasn't ignored", name)
+		}
+	}
+	os.Mkdir(filepath.Join(appDir, AppsSandbox_dataFolder), 0755)
+	if dt := fnWait(300 * time.Millisecond); dt < 300*time.Millisecond {
+		t.Fatal("data folder wasn't ignored")
+	}
+
+	//prompts
+	os.WriteFile(filepath.Join(appDir, "skyalt"), []byte("#Tool Sum"), 0644)
+	if dt := fnWait(5 * time.Second); dt >= 5*time.Second {
+		t.Fatal("'skyalt' change wasn't detected")
+	}
+
+	//deleted folder is unwatched
+	os.RemoveAll(appDir)
+	fnWait(5 * time.Second)
+	time.Sleep(100 * time.Millisecond)
+	w.lock.Lock()
+	_, found := w.paths[appDir]
+	w.lock.Unlock()
+	if found {
+		t.Fatal("deleted folder is still watched")
+	}
+
+	w.Destroy()
+	if w.Wait(0) {
+		t.Fatal("closed watcher is waiting")
+	}
+}
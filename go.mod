@@ -1,6 +1,6 @@
 module skyalt
 
-go 1.23.2
+go 1.24
 
 require (
 	github.com/fogleman/gg v1.3.0
@@ -19,11 +19,33 @@ package main
 import (
 	"image/color"
 	"log"
+	"os"
 )
 
 func main() {
 	log.SetFlags(log.Llongfile) //log.LstdFlags | log.Lshortfile
 
+	if IsSandboxExec() {
+		err := AppsSandbox_exec(os.Args[2:]) //returns only on error
+		log.Fatalf("AppsSandbox_exec() failed: %v\n", err)
+	}
+
+	if IsHeadless() {
+		err := RunHeadless(os.Args[2:])
+		if err != nil {
+			log.Fatalf("RunHeadless() failed: %v\n", err)
+		}
+		return
+	}
+
+	if IsMCP() {
+		err := RunMCP(os.Args[2:])
+		if err != nil {
+			log.Fatalf("RunMCP() failed: %v\n", err)
+		}
+		return
+	}
+
 	/*{
 		f, err := os.Create("profile.prof")
 		if err != nil {
@@ -63,7 +85,7 @@ func main() {
 	defer win.Destroy()
 
 	//Tools
-	router, err := NewAppsRouter(9000, services)
+	router, err := NewAppsRouter(9000, services, true)
 	if err != nil {
 		log.Fatalf("NewToolsRouter() failed: %v\n", err)
 	}
@@ -101,7 +123,8 @@ func main() {
 					//compiling
 					pl := ui.GetPalette()
 					particles_cd := Color_Aprox(pl.P, pl.B, 0.5)
-					win.RenderProgress(msg.progress_label, particles_cd, 0, ui.Cell())
+					_, label := msg.GetProgress()
+					win.RenderProgress(label, particles_cd, 0, ui.Cell())
 				} else {
 					//try run it
 					rootApp.CheckRun()
@@ -0,0 +1,237 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func Headless_PrintHelp() {
+	fmt.Println("Usage:")
+	fmt.Println("  skyalt headless generate <app>                  - regenerate app code from 'skyalt' file and compile it")
+	fmt.Println("  skyalt headless compile <app>                   - compile app")
+	fmt.Println("  skyalt headless call <app> <tool> [params_json] - compile app, call tool and print Out_ attributes as JSON")
+	fmt.Println("Options:")
+	fmt.Println("  --port <port> - first port of app router, default is env SKYALT_ROUTER_PORT or 9100(GUI uses 9000, mcp 9200)")
+}
+
+const Headless_defaultPort = 9100
+
+// router port: '--port <port>' argument, env SKYALT_ROUTER_PORT or defaultPort. Returns args without '--port'.
+func Headless_cutPort(args []string, defaultPort int) ([]string, int, error) {
+	port := defaultPort
+	if env := os.Getenv("SKYALT_ROUTER_PORT"); env != "" {
+		p, err := strconv.Atoi(env)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, 0, fmt.Errorf("invalid SKYALT_ROUTER_PORT '%s'", env)
+		}
+		port = p
+	}
+
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		val, found := strings.CutPrefix(args[i], "--port=")
+		if !found && args[i] == "--port" {
+			if i+1 >= len(args) {
+				return nil, 0, fmt.Errorf("missing value of --port")
+			}
+			i++
+			val, found = args[i], true
+		}
+		if !found {
+			rest = append(rest, args[i])
+			continue
+		}
+
+		p, err := strconv.Atoi(val)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, 0, fmt.Errorf("invalid port '%s'", val)
+		}
+		port = p
+	}
+	return rest, port, nil
+}
+
+// Runs only Services and AppsRouter(no SDL window, no media process).
+func RunHeadless(args []string) error {
+	args, port, err := Headless_cutPort(args, Headless_defaultPort)
+	if err != nil {
+		Headless_PrintHelp()
+		return err
+	}
+	if len(args) < 2 {
+		Headless_PrintHelp()
+		return fmt.Errorf("missing command or app name")
+	}
+	cmd := strings.ToLower(args[0])
+	appName := args[1]
+
+	//Services
+	services, err := NewServices(nil)
+	if err != nil {
+		return fmt.Errorf("NewServices() failed: %w", err)
+	}
+	defer services.Destroy()
+
+	//Tools
+	router, err := NewAppsRouter(port, services, false)
+	if err != nil {
+		return fmt.Errorf("NewAppsRouter() failed: %w", err)
+	}
+	defer router.Destroy()
+
+	app := router.FindApp(appName)
+	if app == nil {
+		return fmt.Errorf("app '%s' not found", appName)
+	}
+
+	switch cmd {
+	case "generate":
+		return _Headless_generate(router, app)
+
+	case "compile":
+		return _Headless_compile(app)
+
+	case "call":
+		if len(args) < 3 {
+			Headless_PrintHelp()
+			return fmt.Errorf("missing tool name")
+		}
+		toolName := args[2]
+		paramsJs := []byte("{}")
+		if len(args) > 3 {
+			paramsJs = []byte(args[3])
+		}
+
+		err := _Headless_compile(app)
+		if err != nil {
+			return err
+		}
+
+		outJs, err := _Headless_call(router, app, toolName, paramsJs)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(outJs))
+		return nil
+	}
+
+	Headless_PrintHelp()
+	return fmt.Errorf("unknown command '%s'", cmd)
+}
+
+func _Headless_generate(router *AppsRouter, app *ToolsApp) error {
+	msg := router.AddLocalRecompileMsg(app.Process.Compile.appName)
+	defer msg.Done()
+
+	//print progress
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		last_label := ""
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(1000 * time.Millisecond):
+				_, label := msg.GetProgress()
+				if label != last_label {
+					last_label = label
+					fmt.Printf("Progress: %s\n", last_label)
+				}
+			}
+		}
+	}()
+
+	err := app.Tick(msg)
+	if err != nil {
+		return err
+	}
+
+	if app.Process.Compile.Error != "" {
+		return fmt.Errorf("'%s' app has compilation error: %s", app.Process.Compile.GetFolderPath(), app.Process.Compile.Error)
+	}
+	return nil
+}
+
+func _Headless_compile(app *ToolsApp) error {
+	err := app.Tick(nil)
+	if err != nil {
+		return err
+	}
+
+	if app.Process.Compile.Error != "" {
+		return fmt.Errorf("'%s' app has compilation error: %s", app.Process.Compile.GetFolderPath(), app.Process.Compile.Error)
+	}
+	if !Tools_IsFileExists(app.Process.Compile.GetBinPath()) {
+		return fmt.Errorf("'%s' app binary not found", app.Process.Compile.GetFolderPath())
+	}
+	return nil
+}
+
+// returns Out_ attributes as JSON
+func _Headless_call(router *AppsRouter, app *ToolsApp, toolName string, paramsJs []byte) ([]byte, error) {
+	if !json.Valid(paramsJs) {
+		return nil, fmt.Errorf("params are not valid JSON: %s", string(paramsJs))
+	}
+
+	err := app.CheckRun()
+	if err != nil {
+		return nil, err
+	}
+	if app.Process.GetPort() == 0 {
+		return nil, fmt.Errorf("'%s' app is not running: %s", app.Process.Compile.GetFolderPath(), app.Process.WaitUntilExited())
+	}
+
+	msg_id := router.msgs_counter.Add(1)
+	msg := NewAppsRouterMsg(msg_id, "build", nil, nil)
+	msg.appName = app.Process.Compile.appName
+	router.lock.Lock()
+	router.msgs[msg_id] = msg //tool can call llm_complete, progress, etc.
+	router.lock.Unlock()
+	defer msg.Done()
+
+	app.StartRuntimeCall(msg_id, toolName, paramsJs)
+	dataJs, _, _, err := _ToolsCaller_CallBuild(app.Process.GetPort(), msg_id, 0, toolName, paramsJs)
+	app.EndRuntimeCall(msg_id, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var params map[string]json.RawMessage
+	err = LogsJsonUnmarshal(dataJs, &params)
+	if err != nil {
+		return nil, err
+	}
+	outs := make(map[string]json.RawMessage)
+	for nm, val := range params {
+		if strings.HasPrefix(strings.ToLower(nm), "out") {
+			outs[nm] = val
+		}
+	}
+
+	return LogsJsonMarshalIndent(outs)
+}
+
+func IsHeadless() bool {
+	return len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "headless"
+}
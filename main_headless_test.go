@@ -0,0 +1,51 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadless_cutPort(t *testing.T) {
+	tests := []struct {
+		env  string
+		args string
+		want string
+		port int
+		err  bool
+	}{
+		{"", "call Calc Sum", "call Calc Sum", 9100, false},
+		{"9300", "call Calc Sum", "call Calc Sum", 9300, false},
+		{"9300", "--port 9400 call Calc Sum", "call Calc Sum", 9400, false},
+		{"", "call --port=9500 Calc", "call Calc", 9500, false},
+		{"", "call Calc --port", "", 0, true},
+		{"", "--port abc call", "", 0, true},
+		{"x", "call", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("SKYALT_ROUTER_PORT", tt.env)
+		args, port, err := Headless_cutPort(strings.Fields(tt.args), Headless_defaultPort)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v", tt.args, err)
+			continue
+		}
+		if err == nil && (strings.Join(args, " ") != tt.want || port != tt.port) {
+			t.Errorf("%s: got '%s' %d, want '%s' %d", tt.args, strings.Join(args, " "), port, tt.want, tt.port)
+		}
+	}
+}
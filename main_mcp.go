@@ -0,0 +1,136 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func MCP_PrintHelp() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  skyalt mcp [stdio]     - Model Context Protocol server on stdin/stdout")
+	fmt.Fprintln(os.Stderr, "  skyalt mcp http [addr] - Model Context Protocol server on 'POST /mcp' and 'GET /sse', default addr is env SKYALT_MCP_ADDR or 127.0.0.1:8090")
+	fmt.Fprintln(os.Stderr, "                           clients must send 'Authorization: Bearer <token>', token is env SKYALT_MCP_TOKEN or random one printed at start")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --port <port> - first port of app router, default is env SKYALT_ROUTER_PORT or 9200(GUI uses 9000, headless 9100)")
+}
+
+const MCP_defaultPort = 9200
+
+// address for http mode: env SKYALT_MCP_ADDR, default is localhost only
+func MCP_GetAddr() string {
+	addr := os.Getenv("SKYALT_MCP_ADDR")
+	if addr != "" {
+		return addr
+	}
+	return "127.0.0.1:8090"
+}
+
+// Runs Services, AppsRouter(no SDL window, no media process) and MCP server, which publishes tools of all apps.
+func RunMCP(args []string) error {
+	args, port, err := Headless_cutPort(args, MCP_defaultPort)
+	if err != nil {
+		MCP_PrintHelp()
+		return err
+	}
+
+	mode := "stdio"
+	if len(args) > 0 {
+		mode = strings.ToLower(args[0])
+	}
+	if mode != "stdio" && mode != "http" {
+		MCP_PrintHelp()
+		return fmt.Errorf("unknown mode '%s'", mode)
+	}
+
+	//stdout is protocol channel, everything else goes into stderr
+	stdout := os.Stdout
+	if mode == "stdio" {
+		os.Stdout = os.Stderr
+	}
+
+	//Services
+	services, err := NewServices(nil)
+	if err != nil {
+		return fmt.Errorf("NewServices() failed: %w", err)
+	}
+	defer services.Destroy()
+
+	//Tools
+	router, err := NewAppsRouter(port, services, false)
+	if err != nil {
+		return fmt.Errorf("NewAppsRouter() failed: %w", err)
+	}
+	defer router.Destroy()
+
+	//calls are finished by Flush()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				router.Flush()
+			}
+		}
+	}()
+
+	mcp := NewAppsMCPServer(router)
+
+	if mode == "stdio" {
+		return mcp.RunStdio(os.Stdin, stdout)
+	}
+
+	addr := MCP_GetAddr()
+	if len(args) > 1 {
+		addr = args[1]
+	}
+	token := AppsMCPHttp_GetToken()
+	hs, err := NewAppsMCPHttp(mcp, addr, token)
+	if err != nil {
+		return err
+	}
+	defer hs.Destroy()
+
+	//Ctrl+C
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		select {
+		case <-sig:
+			hs.Destroy()
+		case <-done:
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "MCP server is listening on http://%s/mcp and http://%s/sse\n", hs.GetAddr(), hs.GetAddr())
+	if os.Getenv("SKYALT_MCP_TOKEN") == "" {
+		fmt.Fprintf(os.Stderr, "MCP token: %s\n", token)
+	}
+	return hs.Run()
+}
+
+func IsMCP() bool {
+	return len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "mcp"
+}
@@ -19,8 +19,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
-	"strconv"
 	"strings"
 	"sync"
 )
@@ -37,8 +37,7 @@ type Media struct {
 
 	cmd_running bool
 
-	client_tasks   *AppsServerClient
-	client_updates *AppsServerClient
+	conn *AppsConn
 
 	changed []MediaChanged
 }
@@ -56,10 +55,14 @@ func (media *Media) Destroy() {
 	media.lock.Lock()
 	defer media.lock.Unlock()
 
-	if media.client_tasks != nil {
-		media.client_tasks.WriteArray([]byte("exit"))
-		media.client_tasks.Destroy()
-		media.client_tasks = nil
+	if media.conn != nil {
+		cl, err := media.conn.Open()
+		if err == nil {
+			cl.WriteArray([]byte("exit"))
+			cl.Destroy()
+		}
+		media.conn.Destroy()
+		media.conn = nil
 	}
 
 	media.server.Destroy()
@@ -70,18 +73,20 @@ func (media *Media) Destroy() {
 func (media *Media) runProgram() error {
 	//reset
 	media.cmd_running = false
-	media.client_tasks = nil
-	media.client_updates = nil
+	media.conn = nil
 
 	//start
-	cmd := exec.Command("./media/media", strconv.Itoa(media.server.port))
+	secret := media.server.NewSecret("media")
+	cmd := exec.Command("./media/media", media.server.addr)
 	cmd.Dir = ""
+	cmd.Env = append(os.Environ(), "SKYALT_SESSION_SECRET="+secret)
 	OutStr := new(strings.Builder)
 	ErrStr := new(strings.Builder)
 	cmd.Stdout = OutStr
 	cmd.Stderr = ErrStr
 	err := cmd.Start()
 	if err != nil {
+		media.server.RemoveSecret(secret)
 		return LogsErrorf("media start failed: %w", err)
 	}
 
@@ -91,6 +96,7 @@ func (media *Media) runProgram() error {
 	//run tool
 	go func() {
 		cmd.Wait()
+		media.server.RemoveSecret(secret)
 
 		if OutStr.Len() > 0 {
 			fmt.Printf("Media app output: %s\n", OutStr.String())
@@ -113,90 +119,94 @@ func (media *Media) check() error {
 		}
 
 		//wait for media to connect
-		media.client_tasks, err = media.server.Accept()
-		if err != nil {
-			return LogsErrorf("media client_tasks Accept() failed: %w", err)
-		}
-		media.client_updates, err = media.server.Accept()
+		media.conn, err = media.server.Accept()
 		if err != nil {
-			return LogsErrorf("media client_updates Accept() failed: %w", err)
+			return LogsErrorf("media Accept() failed: %w", err)
 		}
 
-		go func() {
-			defer func() {
-				media.client_updates.Destroy()
-				media.client_updates = nil
-			}()
-
+		//updates from media
+		go func(conn *AppsConn) {
 			for {
-				msg, err := media.client_updates.ReadArray()
+				cl, err := conn.Accept()
 				if err != nil {
 					return
 				}
-
-				switch string(msg) {
-				case "exit":
+				exit := media.readUpdate(cl)
+				cl.Destroy()
+				if exit {
 					return
-
-				case "image":
-					img_path, err := media.client_updates.ReadArray()
-					if err != nil {
-						return
-					}
-					media.lock.Lock()
-					{
-						//must be unique
-						found := false
-						for _, it := range media.changed {
-							if it.Type == 0 && it.path == string(img_path) {
-								found = true
-								break
-							}
-						}
-						if !found {
-							media.changed = append(media.changed, MediaChanged{Type: 0, path: string(img_path)})
-						}
-					}
-					media.lock.Unlock()
-
-				case "video": //vlc
-					img_path, err := media.client_updates.ReadArray()
-					if err != nil {
-						return
-					}
-					playerID, err := media.client_updates.ReadInt()
-					if err != nil {
-						return
-					}
-					media.lock.Lock()
-					{
-						//must be unique
-						found := false
-						for _, it := range media.changed {
-							if it.Type == 1 && it.playerID == playerID {
-								found = true
-								break
-							}
-						}
-						if !found {
-							media.changed = append(media.changed, MediaChanged{Type: 1, path: string(img_path), playerID: playerID})
-						}
-					}
-					media.lock.Unlock()
 				}
 			}
-
-		}()
-
+		}(media.conn)
 	}
 
-	if media.client_tasks == nil || media.client_updates == nil {
-		return LogsErrorf("media.client == nil")
+	if media.conn == nil {
+		return LogsErrorf("media.conn == nil")
 	}
 
 	return nil
 }
 
+// returns true for "exit"
+func (media *Media) readUpdate(cl *AppsStream) bool {
+	msg, err := cl.ReadArray()
+	if err != nil {
+		return false
+	}
+
+	switch string(msg) {
+	case "exit":
+		return true
+
+	case "image":
+		img_path, err := cl.ReadArray()
+		if err != nil {
+			return false
+		}
+		media.lock.Lock()
+		{
+			//must be unique
+			found := false
+			for _, it := range media.changed {
+				if it.Type == 0 && it.path == string(img_path) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				media.changed = append(media.changed, MediaChanged{Type: 0, path: string(img_path)})
+			}
+		}
+		media.lock.Unlock()
+
+	case "video": //vlc
+		img_path, err := cl.ReadArray()
+		if err != nil {
+			return false
+		}
+		playerID, err := cl.ReadInt()
+		if err != nil {
+			return false
+		}
+		media.lock.Lock()
+		{
+			//must be unique
+			found := false
+			for _, it := range media.changed {
+				if it.Type == 1 && it.playerID == playerID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				media.changed = append(media.changed, MediaChanged{Type: 1, path: string(img_path), playerID: playerID})
+			}
+		}
+		media.lock.Unlock()
+	}
+	return false
+}
+
 func (media *Media) GetChanged() []MediaChanged {
 	media.lock.Lock()
 	defer media.lock.Unlock()
@@ -215,9 +225,15 @@ func (media *Media) GetInfo() ([]byte, error) {
 		return nil, err
 	}
 
+	cl, err := media.conn.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer cl.Destroy()
+
 	//write
 	{
-		err := media.client_tasks.WriteArray([]byte("info"))
+		err := cl.WriteArray([]byte("info"))
 		if err != nil {
 			return nil, err
 		}
@@ -225,7 +241,7 @@ func (media *Media) GetInfo() ([]byte, error) {
 
 	//read
 	{
-		js, err := media.client_tasks.ReadArray()
+		js, err := cl.ReadArray()
 		if err != nil {
 			return nil, err
 		}
@@ -243,13 +259,19 @@ func (media *Media) Type(path string) (int, error) {
 		return -1, err
 	}
 
+	cl, err := media.conn.Open()
+	if err != nil {
+		return -1, err
+	}
+	defer cl.Destroy()
+
 	//write
 	{
-		err := media.client_tasks.WriteArray([]byte("type"))
+		err := cl.WriteArray([]byte("type"))
 		if err != nil {
 			return -1, err
 		}
-		err = media.client_tasks.WriteArray([]byte(path))
+		err = cl.WriteArray([]byte(path))
 		if err != nil {
 			return -1, err
 		}
@@ -257,7 +279,7 @@ func (media *Media) Type(path string) (int, error) {
 
 	//read
 	{
-		tp, err := media.client_tasks.ReadInt()
+		tp, err := cl.ReadInt()
 		if err != nil {
 			return -1, err
 		}
@@ -275,28 +297,34 @@ func (media *Media) Play(path string, playerID uint64, playIt bool) error {
 		return err
 	}
 
+	cl, err := media.conn.Open()
+	if err != nil {
+		return err
+	}
+	defer cl.Destroy()
+
 	//write
 	{
-		err = media.client_tasks.WriteArray([]byte("play"))
+		err = cl.WriteArray([]byte("play"))
 		if err != nil {
 			return err
 		}
-		err = media.client_tasks.WriteArray([]byte(path))
+		err = cl.WriteArray([]byte(path))
 		if err != nil {
 			return err
 		}
-		err = media.client_tasks.WriteInt(playerID)
+		err = cl.WriteInt(playerID)
 		if err != nil {
 			return err
 		}
 
 		if playIt {
-			err = media.client_tasks.WriteInt(1)
+			err = cl.WriteInt(1)
 			if err != nil {
 				return err
 			}
 		} else {
-			err = media.client_tasks.WriteInt(0)
+			err = cl.WriteInt(0)
 			if err != nil {
 				return err
 			}
@@ -306,7 +334,7 @@ func (media *Media) Play(path string, playerID uint64, playIt bool) error {
 
 	//read
 	{
-		errBytes, err := media.client_tasks.ReadArray()
+		errBytes, err := cl.ReadArray()
 		if err != nil {
 			return err
 		}
@@ -327,21 +355,27 @@ func (media *Media) Seek(path string, playerID uint64, play_pos uint64) error {
 		return err
 	}
 
+	cl, err := media.conn.Open()
+	if err != nil {
+		return err
+	}
+	defer cl.Destroy()
+
 	//write
 	{
-		err = media.client_tasks.WriteArray([]byte("seek"))
+		err = cl.WriteArray([]byte("seek"))
 		if err != nil {
 			return err
 		}
-		err = media.client_tasks.WriteArray([]byte(path))
+		err = cl.WriteArray([]byte(path))
 		if err != nil {
 			return err
 		}
-		err = media.client_tasks.WriteInt(playerID)
+		err = cl.WriteInt(playerID)
 		if err != nil {
 			return err
 		}
-		err = media.client_tasks.WriteInt(play_pos)
+		err = cl.WriteInt(play_pos)
 		if err != nil {
 			return err
 		}
@@ -349,7 +383,7 @@ func (media *Media) Seek(path string, playerID uint64, play_pos uint64) error {
 
 	//read
 	{
-		errBytes, err := media.client_tasks.ReadArray()
+		errBytes, err := cl.ReadArray()
 		if err != nil {
 			return err
 		}
@@ -370,21 +404,27 @@ func (media *Media) Frame(path string, blob []byte, playerID uint64, addError bo
 		return 0, 0, nil, 0, 0, -1, err
 	}
 
+	cl, err := media.conn.Open()
+	if err != nil {
+		return 0, 0, nil, 0, 0, -1, err
+	}
+	defer cl.Destroy()
+
 	//write
 	{
-		err = media.client_tasks.WriteArray([]byte("frame"))
+		err = cl.WriteArray([]byte("frame"))
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		err = media.client_tasks.WriteArray([]byte(path))
+		err = cl.WriteArray([]byte(path))
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		err = media.client_tasks.WriteArray(blob)
+		err = cl.WriteArray(blob)
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		err = media.client_tasks.WriteInt(playerID)
+		err = cl.WriteInt(playerID)
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
@@ -392,32 +432,32 @@ func (media *Media) Frame(path string, blob []byte, playerID uint64, addError bo
 
 	//read
 	{
-		errBytes, err := media.client_tasks.ReadArray()
+		errBytes, err := cl.ReadArray()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		width, err := media.client_tasks.ReadInt()
+		width, err := cl.ReadInt()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		height, err := media.client_tasks.ReadInt()
+		height, err := cl.ReadInt()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		data, err := media.client_tasks.ReadArray()
+		data, err := cl.ReadArray()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		play_pos, err := media.client_tasks.ReadInt()
+		play_pos, err := cl.ReadInt()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
-		play_duration, err := media.client_tasks.ReadInt()
+		play_duration, err := cl.ReadInt()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
 
-		tp, err := media.client_tasks.ReadInt()
+		tp, err := cl.ReadInt()
 		if err != nil {
 			return 0, 0, nil, 0, 0, -1, err
 		}
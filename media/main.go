@@ -8,9 +8,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 	"unsafe"
 )
@@ -123,24 +121,19 @@ func main() {
 	}*/
 
 	if len(os.Args) < 2 {
-		log.Fatal("missing 'server port' argument(s): ", os.Args)
+		log.Fatal("missing 'server address' argument(s): ", os.Args)
 	}
 
-	server_port, err := strconv.Atoi(os.Args[1])
-	if err != nil || server_port <= 0 {
-		log.Fatal(err)
-	}
+	secret := os.Getenv("SKYALT_SESSION_SECRET")
+	os.Unsetenv("SKYALT_SESSION_SECRET")
 
-	cl_tasks := NewNetClient("localhost", server_port)
-	defer cl_tasks.Destroy()
+	conn := NewNetConn(os.Args[1], "media", secret)
+	defer conn.Destroy()
 
-	var client_updates_lock sync.Mutex
-	client_updates := NewNetClient("localhost", server_port)
 	defer func() {
-		client_updates_lock.Lock()
-		defer client_updates_lock.Unlock()
-		client_updates.WriteArray([]byte("exit")) //exit
-		client_updates.Destroy()
+		cl := conn.Open()
+		cl.WriteArray([]byte("exit")) //exit
+		cl.Destroy()
 	}()
 
 	//check if files changed
@@ -159,18 +152,18 @@ func main() {
 			min_time := time.Now().Add(-60 * time.Second).UnixNano()
 
 			fnImgChanged := func(path string) {
-				client_updates_lock.Lock()
-				defer client_updates_lock.Unlock()
-				client_updates.WriteArray([]byte("image"))
-				client_updates.WriteArray([]byte(path))
+				cl := conn.Open()
+				defer cl.Destroy()
+				cl.WriteArray([]byte("image"))
+				cl.WriteArray([]byte(path))
 
 			}
 			fnVlcChanged := func(path string, playerID uint64) {
-				client_updates_lock.Lock()
-				defer client_updates_lock.Unlock()
-				client_updates.WriteArray([]byte("video"))
-				client_updates.WriteArray([]byte(path))
-				client_updates.WriteInt(playerID)
+				cl := conn.Open()
+				defer cl.Destroy()
+				cl.WriteArray([]byte("video"))
+				cl.WriteArray([]byte(path))
+				cl.WriteInt(playerID)
 			}
 
 			imgs.Maintenance(min_time, fnImgChanged)
@@ -181,6 +174,7 @@ func main() {
 	}()
 
 	for {
+		cl_tasks := conn.Accept()
 		command := cl_tasks.ReadArray()
 
 		switch string(command) {
@@ -241,6 +235,7 @@ func main() {
 			} else { //image
 				pathStr := string(path)
 				if isUrl {
+					var err error
 					pathStr, err = cache.Get(string(path)) //download or get /temp path
 					if err != nil {
 						errBytes = []byte(err.Error())
@@ -324,5 +319,6 @@ func main() {
 			}
 
 		}
+		cl_tasks.Destroy()
 	}
 }
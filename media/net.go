@@ -3,81 +3,214 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"strings"
+	"sync"
 )
 
-type NetClient struct {
-	conn *net.TCPConn
+// Same protocol as router's apps_net.go
+const NetProtocol_version = 3
+
+var NetProtocol_magic = [4]byte{'S', 'K', 'Y', 'A'}
+
+const (
+	NetFrame_int   = 1
+	NetFrame_bytes = 2
+	NetFrame_end   = 3
+
+	NetFrame_headerSize = 8 + 1 + 4
+)
+
+type NetFrame struct {
+	Type byte
+	Data []byte
+}
+
+// One persistent connection with multiplexed streams
+type NetConn struct {
+	conn net.Conn
+
+	write_lock sync.Mutex
+
+	lock         sync.Mutex
+	streams      map[uint64]*NetStream
+	next_id      uint64
+	last_peer_id uint64
+	accepted     chan *NetStream
 }
 
-func NewNetClient(addr string, port int) *NetClient {
-	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", addr, port))
+// addr is "<port>" or "unix:<path>"
+func NewNetConn(addr string, name string, secret string) *NetConn {
+	var conn net.Conn
+	var err error
+	if path, isUnix := strings.CutPrefix(addr, "unix:"); isUnix {
+		conn, err = net.Dial("unix", path)
+	} else {
+		conn, err = net.Dial("tcp", net.JoinHostPort("localhost", addr))
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	hello := make([]byte, 16+len(name)+len(secret))
+	copy(hello, NetProtocol_magic[:])
+	binary.LittleEndian.PutUint32(hello[4:], NetProtocol_version)
+	binary.LittleEndian.PutUint32(hello[8:], uint32(len(name)))
+	copy(hello[12:], name)
+	binary.LittleEndian.PutUint32(hello[12+len(name):], uint32(len(secret)))
+	copy(hello[16+len(name):], secret)
+	_, err = conn.Write(hello)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return &NetClient{conn: conn}
+	var reply [12]byte
+	_, err = io.ReadFull(conn, reply[:])
+	if err != nil {
+		log.Fatal(fmt.Errorf("handshake failed: %w", err))
+	}
+	version := binary.LittleEndian.Uint32(reply[4:])
+	if [4]byte(reply[:4]) != NetProtocol_magic || version != NetProtocol_version {
+		log.Fatalf("media uses protocol version %d, but router uses version %d. Recompile it", NetProtocol_version, version)
+	}
+	if binary.LittleEndian.Uint32(reply[8:]) != 0 {
+		log.Fatal("router rejected media: invalid session secret")
+	}
+
+	c := &NetConn{conn: conn, next_id: 1}
+	c.streams = make(map[uint64]*NetStream)
+	c.accepted = make(chan *NetStream, 1000)
+
+	go c.run()
+	return c
 }
-func (client *NetClient) Destroy() {
-	client.conn.Close()
+
+func (c *NetConn) Destroy() {
+	c.conn.Close()
 }
 
-func (client *NetClient) ReadInt() uint64 {
-	var sz [8]byte
-	_, err := client.conn.Read(sz[:])
-	if err != nil {
-		log.Fatal(err)
+func (c *NetConn) run() {
+	var hdr [NetFrame_headerSize]byte
+	for {
+		_, err := io.ReadFull(c.conn, hdr[:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		id := binary.LittleEndian.Uint64(hdr[0:])
+		tp := hdr[8]
+		size := binary.LittleEndian.Uint32(hdr[9:])
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(c.conn, data)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		c.lock.Lock()
+		st, found := c.streams[id]
+		if !found && id%2 == 0 && id > c.last_peer_id {
+			//new task from router
+			c.last_peer_id = id
+			st = c._newStream(id, true)
+			c.accepted <- st
+		}
+		c.lock.Unlock()
+
+		if st != nil {
+			st.frames <- NetFrame{Type: tp, Data: data}
+		}
 	}
+}
 
-	return binary.LittleEndian.Uint64(sz[:])
+// waits for next task from router
+func (c *NetConn) Accept() *NetStream {
+	return <-c.accepted
 }
 
-func (client *NetClient) WriteInt(value uint64) {
-	var val [8]byte
-	binary.LittleEndian.PutUint64(val[:], value)
-	_, err := client.conn.Write(val[:])
+func (c *NetConn) Open() *NetStream {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	st := c._newStream(c.next_id, false)
+	c.next_id += 2
+	return st
+}
+
+func (c *NetConn) _newStream(id uint64, opened_by_peer bool) *NetStream {
+	st := &NetStream{conn: c, id: id, written: opened_by_peer}
+	st.frames = make(chan NetFrame, 100)
+	c.streams[id] = st
+	return st
+}
+
+func (c *NetConn) writeFrame(id uint64, tp byte, data []byte) {
+	var hdr [NetFrame_headerSize]byte
+	binary.LittleEndian.PutUint64(hdr[0:], id)
+	hdr[8] = tp
+	binary.LittleEndian.PutUint32(hdr[9:], uint32(len(data)))
+
+	c.write_lock.Lock()
+	defer c.write_lock.Unlock()
+
+	bufs := net.Buffers{hdr[:], data}
+	_, err := bufs.WriteTo(c.conn)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
-func (client *NetClient) WriteBool(value bool) {
-	if value {
-		client.WriteInt(1)
-	} else {
-		client.WriteInt(0)
+
+type NetStream struct {
+	conn    *NetConn
+	id      uint64
+	frames  chan NetFrame
+	written bool
+}
+
+func (st *NetStream) read(tp byte) []byte {
+	frame := <-st.frames
+	if frame.Type == NetFrame_end {
+		log.Fatalf("protocol: call ended by router, but frame type %d was expected", tp)
 	}
+	if frame.Type != tp {
+		log.Fatalf("protocol: received frame type %d, but %d was expected", frame.Type, tp)
+	}
+	return frame.Data
 }
-func (client *NetClient) ReadArray() []byte {
-	//recv size
-	size := client.ReadInt()
 
-	//recv data
-	data := make([]byte, size)
-	p := 0
-	for p < int(size) {
-		n, err := client.conn.Read(data[p:])
-		if err != nil {
-			log.Fatal(err)
-		}
-		p += n
+func (st *NetStream) Destroy() {
+	if st.written {
+		st.conn.writeFrame(st.id, NetFrame_end, nil)
 	}
 
-	return data
+	st.conn.lock.Lock()
+	delete(st.conn.streams, st.id)
+	st.conn.lock.Unlock()
 }
 
-func (client *NetClient) WriteArray(data []byte) {
-	//send size
-	client.WriteInt(uint64(len(data)))
+func (st *NetStream) ReadInt() uint64 {
+	return binary.LittleEndian.Uint64(st.read(NetFrame_int))
+}
 
-	//send data
-	_, err := client.conn.Write(data)
-	if err != nil {
-		log.Fatal(err)
+func (st *NetStream) WriteInt(value uint64) {
+	var val [8]byte
+	binary.LittleEndian.PutUint64(val[:], value)
+	st.written = true
+	st.conn.writeFrame(st.id, NetFrame_int, val[:])
+}
+func (st *NetStream) WriteBool(value bool) {
+	if value {
+		st.WriteInt(1)
+	} else {
+		st.WriteInt(0)
 	}
 }
+func (st *NetStream) ReadArray() []byte {
+	return st.read(NetFrame_bytes)
+}
+
+func (st *NetStream) WriteArray(data []byte) {
+	st.written = true
+	st.conn.writeFrame(st.id, NetFrame_bytes, data)
+}
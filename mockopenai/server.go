@@ -0,0 +1,302 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mockopenai is fake OpenAI-compatible server(chat/completions) for testing providers. Every request gets next scripted Response.
+package mockopenai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ToolCall struct {
+	Id        string
+	Name      string
+	Arguments string //JSON
+}
+
+type Usage struct {
+	Prompt_tokens       int
+	Input_cached_tokens int
+	Completion_tokens   int
+	Reasoning_tokens    int
+	Num_sources_used    int
+}
+
+// Scripted answer for one request
+type Response struct {
+	StatusCode int               //0 = 200
+	Body       string            //body for error StatusCode
+	Header     map[string]string //e.g. "Retry-After"
+
+	Reasoning []string //streamed parts
+	Content   []string //streamed parts
+	ToolCalls []ToolCall
+	Citations []string
+	Usage     *Usage
+
+	ChunkDelay time.Duration //delay between streamed chunks
+}
+
+// Received request
+type Request struct {
+	Path          string
+	Authorization string
+	Body          []byte
+
+	Model    string
+	Stream   bool
+	Messages []map[string]interface{}
+	Tools    []interface{}
+}
+
+type Server struct {
+	URL string //with "/v1"
+
+	srv *httptest.Server
+
+	lock      sync.Mutex
+	responses []Response
+	requests  []Request
+}
+
+func NewServer() *Server {
+	server := &Server{}
+	server.srv = httptest.NewServer(http.HandlerFunc(server.handle))
+	server.URL = server.srv.URL + "/v1"
+	return server
+}
+
+func (server *Server) Close() {
+	server.srv.Close()
+}
+
+// adds responses into queue
+func (server *Server) Add(responses ...Response) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	server.responses = append(server.responses, responses...)
+}
+
+func (server *Server) GetRequests() []Request {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	return append([]Request{}, server.requests...)
+}
+
+func (server *Server) NumPending() int {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	return len(server.responses)
+}
+
+func (server *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := Request{Path: r.URL.Path, Authorization: r.Header.Get("Authorization"), Body: body}
+	var props struct {
+		Model    string
+		Stream   bool
+		Messages []map[string]interface{}
+		Tools    []interface{}
+	}
+	err = json.Unmarshal(body, &props)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Model = props.Model
+	req.Stream = props.Stream
+	req.Messages = props.Messages
+	req.Tools = props.Tools
+
+	//pop
+	server.lock.Lock()
+	server.requests = append(server.requests, req)
+	var resp Response
+	found := len(server.responses) > 0
+	if found {
+		resp = server.responses[0]
+		server.responses = server.responses[1:]
+	}
+	server.lock.Unlock()
+
+	if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		http.Error(w, fmt.Sprintf("unknown path '%s'", r.URL.Path), http.StatusNotFound)
+		return
+	}
+	if !found {
+		http.Error(w, "no scripted response", http.StatusInternalServerError)
+		return
+	}
+
+	for key, val := range resp.Header {
+		w.Header().Set(key, val)
+	}
+
+	if resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+		http.Error(w, resp.Body, resp.StatusCode)
+		return
+	}
+
+	if req.Stream {
+		server.writeStream(w, r, &resp)
+	} else {
+		server.writeJSON(w, &resp)
+	}
+}
+
+func (resp *Response) getUsage() map[string]interface{} {
+	if resp.Usage == nil {
+		return nil
+	}
+	u := resp.Usage
+	return map[string]interface{}{
+		"prompt_tokens":             u.Prompt_tokens,
+		"input_cached_tokens":       u.Input_cached_tokens,
+		"completion_tokens":         u.Completion_tokens,
+		"total_tokens":              u.Prompt_tokens + u.Completion_tokens + u.Reasoning_tokens,
+		"num_sources_used":          u.Num_sources_used,
+		"completion_tokens_details": map[string]interface{}{"reasoning_tokens": u.Reasoning_tokens},
+	}
+}
+
+func (resp *Response) getToolCalls() []map[string]interface{} {
+	var calls []map[string]interface{}
+	for i, call := range resp.ToolCalls {
+		calls = append(calls, map[string]interface{}{
+			"id":       call.Id,
+			"index":    i,
+			"type":     "function",
+			"function": map[string]interface{}{"name": call.Name, "arguments": call.Arguments},
+		})
+	}
+	return calls
+}
+
+func (server *Server) writeJSON(w http.ResponseWriter, resp *Response) {
+	out := map[string]interface{}{
+		"choices": []interface{}{
+			map[string]interface{}{
+				"message": map[string]interface{}{
+					"content":           strings.Join(resp.Content, ""),
+					"reasoning_content": strings.Join(resp.Reasoning, ""),
+					"tool_calls":        resp.getToolCalls(),
+				},
+			},
+		},
+		"citations": resp.Citations,
+	}
+	if resp.Usage != nil {
+		out["usage"] = resp.getUsage()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func (server *Server) writeStream(w http.ResponseWriter, r *http.Request, resp *Response) {
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// returns false when client disconnected
+	send := func(chunk interface{}) bool {
+		js, _ := json.Marshal(chunk)
+		_, err := fmt.Fprintf(w, "data: %s\n\n", js)
+		if err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if resp.ChunkDelay > 0 {
+			select {
+			case <-r.Context().Done():
+				return false
+			case <-time.After(resp.ChunkDelay):
+			}
+		}
+		return r.Context().Err() == nil
+	}
+	delta := func(d map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"choices": []interface{}{map[string]interface{}{"delta": d}}}
+	}
+
+	for _, part := range resp.Reasoning {
+		if !send(delta(map[string]interface{}{"reasoning_content": part})) {
+			return
+		}
+	}
+	for _, part := range resp.Content {
+		if !send(delta(map[string]interface{}{"content": part})) {
+			return
+		}
+	}
+
+	//tool calls: header with first half of arguments, then rest of arguments
+	for i, call := range resp.ToolCalls {
+		half := len(call.Arguments) / 2
+		first := map[string]interface{}{
+			"id":       call.Id,
+			"index":    i,
+			"type":     "function",
+			"function": map[string]interface{}{"name": call.Name, "arguments": call.Arguments[:half]},
+		}
+		if !send(delta(map[string]interface{}{"tool_calls": []interface{}{first}})) {
+			return
+		}
+		rest := map[string]interface{}{
+			"index":    i,
+			"function": map[string]interface{}{"arguments": call.Arguments[half:]},
+		}
+		if !send(delta(map[string]interface{}{"tool_calls": []interface{}{rest}})) {
+			return
+		}
+	}
+
+	if len(resp.Citations) > 0 {
+		if !send(map[string]interface{}{"choices": []interface{}{}, "citations": resp.Citations}) {
+			return
+		}
+	}
+	if resp.Usage != nil {
+		if !send(map[string]interface{}{"choices": []interface{}{}, "usage": resp.getUsage()}) {
+			return
+		}
+	}
+
+	fmt.Fprintf(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
@@ -40,12 +40,35 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
-func ReadJSONFile[T any](path string, defaultValues *T) (*T, error) {
+// storage of generated apps is in 'data' folder(env SKYALT_APP_DATA), rest of app's folder is read-only in sandbox
+func _storagePath(file string) string {
+	if g_main.data_dir == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(g_main.data_dir, file)
+}
+
+// reads storage file, file saved by older version(before 'data' folder) is used if new one doesn't exist yet. Returns saved data, which is nil for old file, so it's moved by next save.
+func _readStorageFile(file string, path string) ([]byte, []byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path != file && !g_main.testing {
+		data, err = os.ReadFile(file)
+		if err == nil {
+			return data, nil, nil
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	return data, data, err
+}
+
+func ReadJSONFile[T any](file string, defaultValues *T) (*T, error) {
 	save := true
+	path := _storagePath(file)
 
 	//find
 	g_files_lock.Lock()
@@ -57,12 +80,9 @@ func ReadJSONFile[T any](path string, defaultValues *T) (*T, error) {
 	}
 
 	//get file data
-	data, err := os.ReadFile(path)
+	data, saved, err := _readStorageFile(file, path)
 	if err != nil {
-		//is file exist
-		if _, err := os.Stat(path); err == nil {
-			Tool_Error(err)
-		}
+		Tool_Error(err)
 	}
 
 	// Unpack
@@ -71,7 +91,7 @@ func ReadJSONFile[T any](path string, defaultValues *T) (*T, error) {
 	}
 
 	g_files_lock.Lock()
-	g_files[path] = &_Instance{data: data, st: defaultValues, save: save}
+	g_files[path] = &_Instance{data: saved, st: defaultValues, save: save}
 	g_files_lock.Unlock()
 	return defaultValues, nil
 }
@@ -80,10 +100,11 @@ func LoadFile[T any](file string, structName string, format string, defInst *T,
 	if file == "" {
 		file = fmt.Sprintf("%s-%s.%s", structName, structName, format)
 	}
+	path := _storagePath(file)
 
 	//find
 	g_files_lock.Lock()
-	inst, found := g_files[file]
+	inst, found := g_files[path]
 	g_files_lock.Unlock()
 	if found {
 		inst.save = save
@@ -91,12 +112,9 @@ func LoadFile[T any](file string, structName string, format string, defInst *T,
 	}
 
 	//get file data
-	data, err := os.ReadFile(file)
+	data, saved, err := _readStorageFile(file, path)
 	if err != nil {
-		//is file exist
-		if _, err := os.Stat(file); err == nil {
-			Tool_Error(err)
-		}
+		Tool_Error(err)
 	}
 
 	// Unpack
@@ -118,7 +136,7 @@ func LoadFile[T any](file string, structName string, format string, defInst *T,
 	}
 
 	g_files_lock.Lock()
-	g_files[file] = &_Instance{data: data, st: defInst, save: save}
+	g_files[path] = &_Instance{data: saved, st: defInst, save: save}
 	g_files_lock.Unlock()
 	return defInst, nil
 }
@@ -158,7 +176,7 @@ func _saveInstances() {
 	}
 
 	if changed {
-		cl, err := NewToolClient("localhost", g_main.router_port)
+		cl, err := NewToolClient()
 		if Tool_Error(err) == nil {
 			defer cl.Destroy()
 
@@ -198,8 +216,14 @@ func UI_GetDateFormat() string {
 
 type ToolProgram struct {
 	appName     string
-	router_port int
-	server      *ToolServer
+	router_addr string //"<port>" or "unix:<path>"
+	secret      string //session secret from router
+	sandboxed   bool   //started by router's sandbox
+	data_dir    string //storage folder, "" for hand-written apps
+	testing     bool   //storage is empty temp folder, files from app's folder aren't read
+
+	router_lock sync.Mutex
+	router      *ToolConn
 }
 
 type _Instance struct {
@@ -293,26 +317,28 @@ func main() {
 	log.SetFlags(log.Llongfile) //log.LstdFlags | log.Lshortfile
 
 	if len(os.Args) < 3 {
-		log.Fatal("missing 'app name' and 'port' argument(s): ", os.Args)
+		log.Fatal("missing 'app name' and 'router address' argument(s): ", os.Args)
 	}
 
 	g_main.appName = os.Args[1]
+	g_main.router_addr = os.Args[2]
+	g_main.secret = os.Getenv("SKYALT_SESSION_SECRET")
+	os.Unsetenv("SKYALT_SESSION_SECRET") //don't leak into child processes
+	g_main.sandboxed = (os.Getenv("SKYALT_SANDBOXED") == "1")
+	g_main.data_dir = os.Getenv("SKYALT_APP_DATA")
 
-	var err error
-	g_main.router_port, err = strconv.Atoi(os.Args[2])
+	router, err := _getRouterConn()
 	if err != nil {
 		log.Fatal(err)
 	}
-	g_main.server = NewToolServer(g_main.router_port + 100)
+	defer router.Destroy()
 
 	g_uis = make(map[uint64]*ToolUI)
 	g_files = make(map[string]*_Instance)
 
-	defer g_main.server.Destroy()
-
 	//report app into router server
 	{
-		cl, err := NewToolClient("localhost", g_main.router_port)
+		cl, err := router.Open()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -325,11 +351,6 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		err = cl.WriteInt(uint64(g_main.server.port))
-		if err != nil {
-			log.Fatal(err)
-		}
 		cl.Destroy()
 	}
 
@@ -341,12 +362,9 @@ func main() {
 
 	// main loop
 	for {
-		cl, err := g_main.server.Accept()
-		if Tool_Error(err) != nil {
-			continue
-		}
-		if cl == nil {
-			break //close tool
+		cl, err := router.Accept()
+		if err != nil {
+			break //router closed connection
 		}
 
 		mode, err := cl.ReadArray()
@@ -358,6 +376,10 @@ func main() {
 				cl.Destroy()
 				return
 
+			case "ping":
+				cl.WriteArray([]byte("pong"))
+				cl.Destroy()
+
 			case "update_dev":
 				_updateDev()
 				cl.Destroy()
@@ -385,6 +407,7 @@ func main() {
 									ui.Caller.cmds = nil
 
 									subUiGob, out_error := ui.ui.runUpdate(sub_uid, ui.Caller)
+									out_error = _sandboxError(out_error)
 
 									if out_error == nil {
 										if !ui.Caller._sendProgress(1, "") {
@@ -456,6 +479,7 @@ func main() {
 									ui.Caller.cmds = nil
 
 									out_error := ui.ui.runChange(changeJs, ui.Caller)
+									out_error = _sandboxError(out_error)
 
 									if out_error == nil {
 										if !ui.Caller._sendProgress(1, "") {
@@ -533,7 +557,7 @@ func main() {
 									out_error := err
 									if Tool_Error(out_error) == nil {
 										if fnRun != nil {
-											out_error = fnRun(caller, ui)
+											out_error = _sandboxError(fnRun(caller, ui))
 										}
 									}
 
@@ -624,7 +648,7 @@ func (caller *ToolCaller) Progress(done float64, label string) bool {
 }
 
 func (caller *ToolCaller) _sendProgress(done float64, label string) bool {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -652,7 +676,7 @@ func (caller *ToolCaller) _sendProgress(done float64, label string) bool {
 func (caller *ToolCaller) SendFlushCmd() {
 	cmdsGob := LogsGobMarshal(caller.cmds)
 
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -737,7 +761,7 @@ func (msg *SdkMsg) GetLabel() string {
 func callFuncGetMsgs() []SdkMsg {
 	var msgs []SdkMsg
 
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -757,7 +781,7 @@ func callFuncGetLogs() []SdkLog {
 	g_logs_lock.Lock()
 	defer g_logs_lock.Unlock()
 
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -794,7 +818,7 @@ type SdkMicInfo struct {
 }
 
 func callFuncGetMicInfo() SdkMicInfo {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -821,7 +845,7 @@ type SdkMediaItem struct {
 }
 
 func callFuncGetMediaInfo() map[uint64]SdkMediaItem {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -840,7 +864,7 @@ func callFuncGetMediaInfo() map[uint64]SdkMediaItem {
 }
 
 func callFuncSetTextHighlight(text string) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -853,7 +877,7 @@ func callFuncSetTextHighlight(text string) {
 }
 
 func callFuncStopMic() {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -867,7 +891,7 @@ func (caller *ToolCaller) CreateMsgUID(msg_name string) []byte {
 }
 
 func (caller *ToolCaller) SetMsgName(msg_uid []byte) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 		err = cl.WriteArray([]byte("set_msg_uid"))
@@ -883,7 +907,7 @@ func (caller *ToolCaller) SetMsgName(msg_uid []byte) {
 }
 
 func (caller *ToolCaller) callFuncMsgStop(msg_uid []byte) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -896,7 +920,7 @@ func (caller *ToolCaller) callFuncMsgStop(msg_uid []byte) {
 }
 
 func (caller *ToolCaller) callFuncFindMsgName(msg_uid []byte) *SdkMsg {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -921,7 +945,7 @@ func (caller *ToolCaller) callFuncFindMsgName(msg_uid []byte) *SdkMsg {
 }
 
 func callFuncGetToolsShemas(appName string) []byte {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -941,7 +965,7 @@ func callFuncGetToolsShemas(appName string) []byte {
 }
 
 func callFuncGetToolData(appName string) ([]byte, error) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -962,7 +986,7 @@ func callFuncGetToolData(appName string) ([]byte, error) {
 }
 
 func (caller *ToolCaller) _callFuncRunApp(appName string) (int, error) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -994,7 +1018,7 @@ func (caller *ToolCaller) callFuncBuild(ui_uid uint64, appName string, toolName
 		return nil, nil, err
 	}
 
-	cl, err := NewToolClient("localhost", port)
+	cl, err := NewToolClientApp(port)
 	if Tool_Error(err) == nil {
 
 		defer cl.Destroy()
@@ -1053,7 +1077,7 @@ func (caller *ToolCaller) callFuncUpdate(ui_uid uint64, appName string, sub_uid
 		return nil, err
 	}
 
-	cl, err := NewToolClient("localhost", port)
+	cl, err := NewToolClientApp(port)
 	if Tool_Error(err) == nil {
 
 		defer cl.Destroy()
@@ -1106,7 +1130,7 @@ func (caller *ToolCaller) callFuncChange(ui_uid uint64, appName string, changeJs
 		return nil, err
 	}
 
-	cl, err := NewToolClient("localhost", port)
+	cl, err := NewToolClientApp(port)
 	if Tool_Error(err) == nil {
 
 		defer cl.Destroy()
@@ -1153,7 +1177,7 @@ func (caller *ToolCaller) callFuncChange(ui_uid uint64, appName string, changeJs
 }
 
 func callFuncGenerateApp(app_name string, caller *ToolCaller) error {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -1168,7 +1192,7 @@ func callFuncGenerateApp(app_name string, caller *ToolCaller) error {
 					errBytes, err := cl.ReadArray()
 					if Tool_Error(err) == nil {
 						if len(errBytes) > 0 {
-							return fmt.Errorf(string(errBytes))
+							return errors.New(string(errBytes))
 						}
 						return nil //ok
 					}
@@ -1180,8 +1204,59 @@ func callFuncGenerateApp(app_name string, caller *ToolCaller) error {
 	return fmt.Errorf("Connection failed")
 }
 
+func callFuncFixRuntimeError(app_name string, tool_name string, caller *ToolCaller) error {
+	cl, err := NewToolClient()
+	if Tool_Error(err) == nil {
+		defer cl.Destroy()
+
+		err = cl.WriteArray([]byte("fix_runtime_error"))
+		if Tool_Error(err) == nil {
+
+			err = cl.WriteInt(caller.msg_id)
+			if Tool_Error(err) == nil {
+				err = cl.WriteArray([]byte(app_name))
+				if Tool_Error(err) == nil {
+					err = cl.WriteArray([]byte(tool_name))
+					if Tool_Error(err) == nil {
+
+						errBytes, err := cl.ReadArray()
+						if Tool_Error(err) == nil {
+							if len(errBytes) > 0 {
+								return errors.New(string(errBytes))
+							}
+							return nil //ok
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("Connection failed")
+}
+
+// adds explanation to errors caused by sandbox and reports them to router
+func _sandboxError(err error) error {
+	if err == nil || !g_main.sandboxed || !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+
+	cl, err2 := NewToolClient()
+	if Tool_Error(err2) == nil {
+		defer cl.Destroy()
+
+		err2 = cl.WriteArray([]byte("sandbox_violation"))
+		if Tool_Error(err2) == nil {
+			err2 = cl.WriteArray([]byte(err.Error()))
+			Tool_Error(err2)
+		}
+	}
+
+	return fmt.Errorf("%w (blocked by sandbox: app can write only into its 'data' folder and use network only with 'network: yes' in #Permissions)", err)
+}
+
 func callFuncPrint(str string) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -1197,7 +1272,7 @@ func callFuncPrint(str string) {
 }
 
 func callFuncGetLLMUsage() []byte {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -1212,12 +1287,47 @@ func callFuncGetLLMUsage() []byte {
 	return []byte("[]")
 }
 
+type SdkLLMSpendingsGroup struct {
+	Name  string
+	Spent float64 //this month
+	Limit float64
+	Calls int
+}
+
+type SdkLLMSpendings struct {
+	Daily_spent   float64
+	Daily_limit   float64
+	Monthly_spent float64
+	Monthly_limit float64
+
+	Apps   []SdkLLMSpendingsGroup
+	Models []SdkLLMSpendingsGroup
+}
+
+func callFuncGetLLMSpendings() SdkLLMSpendings {
+	var spendings SdkLLMSpendings
+
+	cl, err := NewToolClient()
+	if Tool_Error(err) == nil {
+		defer cl.Destroy()
+
+		err = cl.WriteArray([]byte("get_llm_spendings"))
+		if Tool_Error(err) == nil {
+			dataJs, err := cl.ReadArray()
+			if Tool_Error(err) == nil {
+				LogsJsonUnmarshal(dataJs, &spendings)
+			}
+		}
+	}
+	return spendings
+}
+
 func callFuncRenameApp(oldName, newName string) (string, error) {
 	if oldName == newName {
 		return newName, nil
 	}
 
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -1427,6 +1537,87 @@ func CallToolApp(appName string, toolName string, jsParams []byte, caller *ToolC
 	return dataJs, &ui, err
 }
 
+var g_test_once sync.Once
+
+// init for generated tests(<Tool>_test.go). Router sets env variables before 'go test' is executed.
+func _sdkTestInit() {
+	g_main.appName = os.Getenv("SKYALT_APP_NAME")
+	g_main.router_addr = os.Getenv("SKYALT_ROUTER_ADDR")
+	g_main.secret = os.Getenv("SKYALT_SESSION_SECRET")
+	os.Unsetenv("SKYALT_SESSION_SECRET")
+	g_main.data_dir = os.Getenv("SKYALT_APP_DATA")
+	g_main.testing = true
+
+	g_uis = make(map[uint64]*ToolUI)
+	g_files = make(map[string]*_Instance) //storage is never saved during tests
+
+	_callGlobalInits()
+
+	_updateDev()
+}
+
+// Runs tool inside test and returns all tool's attributes(inputs and Out_) as JSON.
+func SdkTestCallTool(toolName string, jsParams []byte) ([]byte, error) {
+	g_test_once.Do(_sdkTestInit)
+
+	fnRun, out_params, err := FindToolRunFunc(toolName, jsParams)
+	if err != nil {
+		return nil, err
+	}
+
+	caller := NewToolCaller()
+	caller.toolName = toolName
+	caller.msg_id, _ = strconv.ParseUint(os.Getenv("SKYALT_MSG_ID"), 10, 64)
+
+	err = fnRun(caller, &UI{})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(out_params)
+}
+
+// Compares attributes in 'expectedJs' with tool's output. Attributes, which are not in 'expectedJs', are ignored.
+func SdkTestCheckOut(outJs []byte, expectedJs []byte) error {
+	var out map[string]interface{}
+	err := json.Unmarshal(outJs, &out)
+	if err != nil {
+		return err
+	}
+	var expected map[string]interface{}
+	err = json.Unmarshal(expectedJs, &expected)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for nm := range expected {
+		names = append(names, nm)
+	}
+	slices.Sort(names)
+
+	var errs []string
+	for _, nm := range names {
+		expJs, _ := json.Marshal(expected[nm])
+
+		val, found := out[nm]
+		if !found {
+			errs = append(errs, fmt.Sprintf("attribute '%s' not found, expected %s", nm, string(expJs)))
+			continue
+		}
+
+		valJs, _ := json.Marshal(val)
+		if !bytes.Equal(valJs, expJs) { //keys in maps are sorted by Marshal()
+			errs = append(errs, fmt.Sprintf("'%s' is %s, expected %s", nm, string(valJs), string(expJs)))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
 func (ui *UI) updateHasFnUpdate(caller *ToolCaller) {
 	ui.HasUpdateFn = (ui.update != nil)
 
@@ -1952,214 +2143,394 @@ func Layout_MoveElement[T any](array_src *[]T, array_dst *[]T, src int, dst int)
 
 //--- Network ---
 
-type ToolServerInfo struct {
-	bytes_written atomic.Int64
-	bytes_read    atomic.Int64
-}
+// Same protocol as router's apps_net.go. Router rejects apps compiled with different version.
+const ToolProtocol_version = 3
+
+var ToolProtocol_magic = [4]byte{'S', 'K', 'Y', 'A'}
+
+const (
+	ToolFrame_int   = 1
+	ToolFrame_bytes = 2
+	ToolFrame_end   = 3 //sender will not write into stream anymore
+
+	ToolFrame_headerSize = 8 + 1 + 4
+)
 
-func (info *ToolServerInfo) AddReadBytes(size int) {
-	info.bytes_read.Add(int64(size))
+func ToolFrame_getTypeName(tp byte) string {
+	switch tp {
+	case ToolFrame_int:
+		return "int"
+	case ToolFrame_bytes:
+		return "array"
+	case ToolFrame_end:
+		return "end"
+	}
+	return fmt.Sprintf("unknown(%d)", tp)
 }
-func (info *ToolServerInfo) AddWrittenBytes(size int) {
-	info.bytes_written.Add(int64(size))
+
+type ToolFrame struct {
+	Type byte
+	Data []byte
 }
 
-type ToolServerClient struct {
-	info *ToolServerInfo
+// One persistent connection to router with multiplexed streams
+type ToolConn struct {
 	conn net.Conn
+
+	write_lock sync.Mutex
+
+	lock         sync.Mutex
+	streams      map[uint64]*ToolStream
+	next_id      uint64
+	last_peer_id uint64
+	accepted     []*ToolStream //opened by router, waiting for Accept()
+	err          error         //connection is closed
+
+	accepted_ready chan struct{}
+	closed         chan struct{}
 }
 
-type ToolServer struct {
-	port     int
-	listener net.Listener
-	exiting  bool
+// addr is "<port>" or "unix:<path>"
+func NewToolConn(addr string, name string, secret string) (*ToolConn, error) {
+	var conn net.Conn
+	var err error
+	if path, isUnix := strings.CutPrefix(addr, "unix:"); isUnix {
+		conn, err = net.Dial("unix", path)
+	} else {
+		conn, err = net.Dial("tcp", net.JoinHostPort("localhost", addr))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hello := make([]byte, 16+len(name)+len(secret))
+	copy(hello, ToolProtocol_magic[:])
+	binary.LittleEndian.PutUint32(hello[4:], ToolProtocol_version)
+	binary.LittleEndian.PutUint32(hello[8:], uint32(len(name)))
+	copy(hello[12:], name)
+	binary.LittleEndian.PutUint32(hello[12+len(name):], uint32(len(secret)))
+	copy(hello[16+len(name):], secret)
+	_, err = conn.Write(hello)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 
-	info *ToolServerInfo
+	var reply [12]byte
+	_, err = io.ReadFull(conn, reply[:8])
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake with router failed(router may use different protocol version): %w", err)
+	}
+	version := binary.LittleEndian.Uint32(reply[4:])
+	if [4]byte(reply[:4]) != ToolProtocol_magic || version != ToolProtocol_version {
+		conn.Close()
+		return nil, fmt.Errorf("'%s' uses protocol version %d, but router uses version %d. Recompile it", name, ToolProtocol_version, version)
+	}
+	_, err = io.ReadFull(conn, reply[8:])
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake with router failed: %w", err)
+	}
+	if binary.LittleEndian.Uint32(reply[8:]) != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("router rejected '%s': invalid session secret", name)
+	}
+
+	c := &ToolConn{conn: conn, next_id: 1}
+	c.streams = make(map[uint64]*ToolStream)
+	c.accepted_ready = make(chan struct{}, 1)
+	c.closed = make(chan struct{})
+
+	go c.run()
+	return c, nil
 }
 
-func NewToolServer(port int) *ToolServer {
-	server := &ToolServer{}
+func (c *ToolConn) Destroy() {
+	c.conn.Close()
+	<-c.closed
+}
 
-	port_last := port + 1000
-	for port < port_last {
-		var err error
-		server.listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
-		if err == nil {
+// reads frames and sends them into streams
+func (c *ToolConn) run() {
+	var err error
+	var hdr [ToolFrame_headerSize]byte
+	for {
+		_, err = io.ReadFull(c.conn, hdr[:])
+		if err != nil {
 			break
 		}
-		port++
-	}
-	if port == port_last {
-		log.Fatal(fmt.Errorf("can not Listen()"))
+		id := binary.LittleEndian.Uint64(hdr[0:])
+		tp := hdr[8]
+		size := binary.LittleEndian.Uint32(hdr[9:])
+
+		if tp != ToolFrame_int && tp != ToolFrame_bytes && tp != ToolFrame_end {
+			err = fmt.Errorf("protocol: unknown frame type %d", tp)
+			break
+		}
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(c.conn, data)
+		if err != nil {
+			break
+		}
+
+		c.lock.Lock()
+		st, found := c.streams[id]
+		if !found && id%2 == 0 && id > c.last_peer_id {
+			//new call from router
+			c.last_peer_id = id
+			st = c._newStream(id, true)
+			c.accepted = append(c.accepted, st)
+			select {
+			case c.accepted_ready <- struct{}{}:
+			default:
+			}
+		}
+		c.lock.Unlock()
+
+		if st != nil { //nil = stream was destroyed
+			st.push(ToolFrame{Type: tp, Data: data})
+		}
 	}
-	server.port = port
-	server.info = &ToolServerInfo{}
 
-	fmt.Printf("Server is running on port: %d\n", server.port)
-	return server
-}
+	c.conn.Close()
 
-func (server *ToolServer) Destroy() {
-	server.exiting = true
-	server.listener.Close()
+	c.lock.Lock()
+	c.err = fmt.Errorf("connection with router closed: %w", err)
+	streams := c.streams
+	c.streams = make(map[uint64]*ToolStream)
+	c.lock.Unlock()
 
-	fmt.Printf("Server port: %d closed\n", server.port)
+	for _, st := range streams {
+		st.setError(c.err)
+	}
+	close(c.closed)
 }
 
-func (server *ToolServer) Accept() (*ToolServerClient, error) {
-	conn, err := server.listener.Accept()
-	if err != nil {
-		if server.exiting {
-			return nil, nil
+// returns next stream opened by router
+func (c *ToolConn) Accept() (*ToolStream, error) {
+	for {
+		c.lock.Lock()
+		if len(c.accepted) > 0 {
+			st := c.accepted[0]
+			c.accepted = c.accepted[1:]
+			c.lock.Unlock()
+			return st, nil
+		}
+		err := c.err
+		c.lock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-c.accepted_ready:
+		case <-c.closed:
 		}
-		return nil, err
 	}
-	return &ToolServerClient{info: server.info, conn: conn}, nil
 }
 
-func (client *ToolServerClient) Destroy() {
-	client.conn.Close()
-}
+// starts new call
+func (c *ToolConn) Open() (*ToolStream, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-func (client *ToolServerClient) ReadInt() (uint64, error) {
-	var sz [8]byte
-	_, err := client.conn.Read(sz[:])
-	if err != nil {
-		return 0, err
+	if c.err != nil {
+		return nil, c.err
 	}
-	client.info.AddReadBytes(8)
 
-	return binary.LittleEndian.Uint64(sz[:]), nil
+	st := c._newStream(c.next_id, false)
+	c.next_id += 2
+	return st, nil
 }
 
-func (client *ToolServerClient) WriteInt(value uint64) error {
-	var val [8]byte
-	binary.LittleEndian.PutUint64(val[:], value)
-	_, err := client.conn.Write(val[:])
-	if err != nil {
-		return err
+func (c *ToolConn) _newStream(id uint64, opened_by_peer bool) *ToolStream {
+	st := &ToolStream{conn: c, id: id, written: opened_by_peer}
+	st.ready = make(chan struct{}, 1)
+	c.streams[id] = st
+	return st
+}
+
+func (c *ToolConn) writeFrame(id uint64, tp byte, data []byte) error {
+	var hdr [ToolFrame_headerSize]byte
+	binary.LittleEndian.PutUint64(hdr[0:], id)
+	hdr[8] = tp
+	binary.LittleEndian.PutUint32(hdr[9:], uint32(len(data)))
+
+	c.write_lock.Lock()
+	defer c.write_lock.Unlock()
+
+	bufs := net.Buffers{hdr[:], data}
+	_, err := bufs.WriteTo(c.conn)
+	return err
+}
+
+// One call. Values must be read in same order and type as they were written.
+type ToolStream struct {
+	conn *ToolConn
+	id   uint64
+
+	lock    sync.Mutex
+	cmd     string //1st array, for errors
+	frames  []ToolFrame
+	err     error
+	written bool //router knows about stream
+	ended   bool
+
+	ready chan struct{}
+}
+
+func (st *ToolStream) push(frame ToolFrame) {
+	st.lock.Lock()
+	if st.cmd == "" && frame.Type == ToolFrame_bytes {
+		st.cmd = string(frame.Data)
 	}
+	st.frames = append(st.frames, frame)
+	st.lock.Unlock()
 
-	client.info.AddWrittenBytes(8)
-	return nil
+	select {
+	case st.ready <- struct{}{}:
+	default:
+	}
 }
 
-func (client *ToolServerClient) ReadArray() ([]byte, error) {
-	//recv size
-	size, err := client.ReadInt()
-	if err != nil {
-		return nil, err
+func (st *ToolStream) setError(err error) {
+	st.lock.Lock()
+	st.err = err
+	st.lock.Unlock()
+
+	select {
+	case st.ready <- struct{}{}:
+	default:
 	}
+}
+
+func (st *ToolStream) read(tp byte) ([]byte, error) {
+	for {
+		st.lock.Lock()
+		if len(st.frames) > 0 {
+			frame := st.frames[0]
+			if frame.Type == ToolFrame_end {
+				cmd := st.cmd
+				st.lock.Unlock()
+				return nil, fmt.Errorf("protocol: '%s' call ended by router, but %s was expected", cmd, ToolFrame_getTypeName(tp))
+			}
+			st.frames = st.frames[1:]
+			cmd := st.cmd
+			st.lock.Unlock()
+
+			if frame.Type != tp {
+				return nil, fmt.Errorf("protocol: '%s' call received %s, but %s was expected", cmd, ToolFrame_getTypeName(frame.Type), ToolFrame_getTypeName(tp))
+			}
+			return frame.Data, nil
+		}
+		err := st.err
+		st.lock.Unlock()
 
-	//recv data
-	data := make([]byte, size)
-	p := 0
-	for p < int(size) {
-		n, err := client.conn.Read(data[p:])
 		if err != nil {
 			return nil, err
 		}
-		p += n
+		<-st.ready
 	}
-
-	client.info.AddReadBytes(int(size))
-
-	return data, nil
 }
 
-func (client *ToolServerClient) WriteArray(data []byte) error {
-	//send size
-	err := client.WriteInt(uint64(len(data)))
-	if err != nil {
-		return err
+func (st *ToolStream) write(tp byte, data []byte) error {
+	st.lock.Lock()
+	if st.ended {
+		st.lock.Unlock()
+		return fmt.Errorf("protocol: write into ended '%s' call", st.cmd)
 	}
-
-	//send data
-	_, err = client.conn.Write(data)
-	if err != nil {
-		return err
+	if st.cmd == "" && tp == ToolFrame_bytes {
+		st.cmd = string(data)
 	}
-	client.info.AddWrittenBytes(len(data))
-
-	return nil
-}
+	st.written = true
+	st.lock.Unlock()
 
-type ToolClient struct {
-	conn *net.TCPConn
+	return st.conn.writeFrame(st.id, tp, data)
 }
 
-func NewToolClient(addr string, port int) (*ToolClient, error) {
-	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", addr, port))
-	if err != nil {
-		return nil, err
-	}
+func (st *ToolStream) Destroy() {
+	st.lock.Lock()
+	send := st.written && !st.ended
+	st.ended = true
+	st.lock.Unlock()
 
-	conn, err := net.DialTCP("tcp", nil, tcpAddr)
-	if err != nil {
-		return nil, err
+	if send {
+		st.conn.writeFrame(st.id, ToolFrame_end, nil)
 	}
 
-	return &ToolClient{conn: conn}, nil
-}
-func (client *ToolClient) Destroy() {
-	client.conn.Close()
+	st.conn.lock.Lock()
+	delete(st.conn.streams, st.id)
+	st.conn.lock.Unlock()
 }
 
-func (client *ToolClient) ReadInt() (uint64, error) {
-	var sz [8]byte
-	_, err := client.conn.Read(sz[:])
+func (st *ToolStream) ReadInt() (uint64, error) {
+	data, err := st.read(ToolFrame_int)
 	if err != nil {
 		return 0, err
 	}
-
-	return binary.LittleEndian.Uint64(sz[:]), nil
+	if len(data) != 8 {
+		return 0, fmt.Errorf("protocol: int has %d bytes", len(data))
+	}
+	return binary.LittleEndian.Uint64(data), nil
 }
 
-func (client *ToolClient) WriteInt(value uint64) error {
+func (st *ToolStream) WriteInt(value uint64) error {
 	var val [8]byte
 	binary.LittleEndian.PutUint64(val[:], value)
-	_, err := client.conn.Write(val[:])
-	if err != nil {
-		return err
-	}
-	return nil
+	return st.write(ToolFrame_int, val[:])
 }
 
-func (client *ToolClient) ReadArray() ([]byte, error) {
-	//recv size
-	size, err := client.ReadInt()
-	if err != nil {
-		return nil, err
-	}
+func (st *ToolStream) ReadArray() ([]byte, error) {
+	return st.read(ToolFrame_bytes)
+}
+
+func (st *ToolStream) WriteArray(data []byte) error {
+	return st.write(ToolFrame_bytes, data)
+}
 
-	//recv data
-	data := make([]byte, size)
-	p := 0
-	for p < int(size) {
-		n, err := client.conn.Read(data[p:])
+// returns connection to router, connects on first use
+func _getRouterConn() (*ToolConn, error) {
+	g_main.router_lock.Lock()
+	defer g_main.router_lock.Unlock()
+
+	if g_main.router == nil {
+		conn, err := NewToolConn(g_main.router_addr, g_main.appName, g_main.secret)
 		if err != nil {
 			return nil, err
 		}
-		p += n
+		g_main.router = conn
 	}
-
-	return data, nil
+	return g_main.router, nil
 }
 
-func (client *ToolClient) WriteArray(data []byte) error {
-	//send size
-	err := client.WriteInt(uint64(len(data)))
+// opens new call to router
+func NewToolClient() (*ToolStream, error) {
+	conn, err := _getRouterConn()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return conn.Open()
+}
 
-	//send data
-	_, err = client.conn.Write(data)
+// opens new call to other app. Router forwards it.
+func NewToolClientApp(port int) (*ToolStream, error) {
+	cl, err := NewToolClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	err = cl.WriteArray([]byte("forward"))
+	if err == nil {
+		err = cl.WriteInt(uint64(port))
+	}
+	if err != nil {
+		cl.Destroy()
+		return nil, err
+	}
+	return cl, nil
 }
 
 //--- Ui ---
@@ -3440,11 +3811,15 @@ type LLMMsgUsage struct {
 	Reasoning_tokens    int
 	Num_sources_used    int
 
+	Input_cache_write_tokens int
+
 	Prompt_price       float64
 	Input_cached_price float64
 	Completion_price   float64
 	Reasoning_price    float64
 	Sources_price      float64
+
+	Input_cache_write_price float64
 }
 
 type LLMCompletion struct {
@@ -3470,7 +3845,8 @@ type LLMCompletion struct {
 
 	Response_format string //"", "json_object"
 
-	Max_iteration int
+	Max_iteration      int
+	Max_parallel_tools int //tool calls from one answer running at once, 0 = default
 
 	Out_StatusCode int
 	Out_messages   []byte //[]*ChatMsg
@@ -3529,7 +3905,7 @@ func (comp *LLMCompletion) Run(caller *ToolCaller) error {
 
 	compJs := LogsJsonMarshal(comp)
 
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -3581,7 +3957,7 @@ func (comp *LLMCompletion) Stop(caller *ToolCaller) error {
 }
 
 func (comp *LLMCompletion) Find(caller *ToolCaller) (running bool, answer string) {
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -3620,7 +3996,7 @@ type LLMTranscribe struct {
 func (comp *LLMTranscribe) Run(caller *ToolCaller) error {
 	compJs := LogsJsonMarshal(comp)
 
-	cl, err := NewToolClient("localhost", g_main.router_port)
+	cl, err := NewToolClient()
 	if Tool_Error(err) == nil {
 		defer cl.Destroy()
 
@@ -27,6 +27,7 @@ type Services struct {
 
 	fnCallBuildAsync     func(ui_uid uint64, appName, toolName string, params interface{}, fnProgress func(cmdsGob [][]byte, err error, start_time float64), fnDone func(dataJs []byte, uiGob []byte, cmdsGob []byte, err error, start_time float64)) *AppsRouterMsg
 	fnGetAppPortAndTools func(appName string) (int, []*ToolsOpenAI_completion_tool, error)
+	fnGetAppFolder       func(appName string) string
 }
 
 func NewServices(media *Media) (*Services, error) {
@@ -0,0 +1,516 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type LLMAnthropicLanguageModel struct {
+	Id               string
+	Created          int64
+	Version          string
+	Input_modalities []string //"text", "image"
+
+	Max_output_tokens int
+
+	Prompt_text_token_price             int //USD cents per million token
+	Cached_prompt_text_token_price      int //cache read
+	Cache_write_prompt_text_token_price int
+	Completion_text_token_price         int
+
+	Aliases []string
+}
+
+// Anthropic LLM settings.
+type LLMAnthropic struct {
+	Provider      string
+	Anthropic_url string
+	DevUrl        string
+	API_key       string
+
+	LanguageModels []*LLMAnthropicLanguageModel
+
+	Stats []LLMMsgStats
+}
+
+func (ant *LLMAnthropic) Check() error {
+	if ant.API_key == "" {
+		return LogsErrorf("%s API key is empty", ant.Provider)
+	}
+
+	return nil
+}
+
+func (ant *LLMAnthropic) FindModel(name string) *LLMAnthropicLanguageModel {
+	name = strings.ToLower(name)
+
+	for _, model := range ant.LanguageModels {
+		if strings.ToLower(model.Id) == name {
+			return model
+		}
+		for _, alias := range model.Aliases {
+			if strings.ToLower(alias) == name {
+				return model
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ant *LLMAnthropic) GetPricingString(model string) string {
+	model = strings.ToLower(model)
+
+	convert_to_dolars := float64(10000)
+
+	lang := ant.FindModel(model)
+	if lang != nil {
+		//in, cache read, cache write, out
+		return fmt.Sprintf("$%.2f/$%.2f/$%.2f/$%.2f", float64(lang.Prompt_text_token_price)/convert_to_dolars, float64(lang.Cached_prompt_text_token_price)/convert_to_dolars, float64(lang.Cache_write_prompt_text_token_price)/convert_to_dolars, float64(lang.Completion_text_token_price)/convert_to_dolars)
+	}
+
+	return fmt.Sprintf("model %s not found", model)
+}
+
+func (model *LLMAnthropicLanguageModel) GetTextPrice(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64) {
+
+	convert_to_dolars := float64(10000)
+
+	Input_price := float64(model.Prompt_text_token_price) / convert_to_dolars / 1000000
+	Reason_price := float64(model.Completion_text_token_price) / convert_to_dolars / 1000000 //thinking is billed as output
+	Cached_price := float64(model.Cached_prompt_text_token_price) / convert_to_dolars / 1000000
+	Cache_write_price := float64(model.Cache_write_prompt_text_token_price) / convert_to_dolars / 1000000
+	Output_price := float64(model.Completion_text_token_price) / convert_to_dolars / 1000000
+
+	return float64(in) * Input_price, float64(reason) * Reason_price, float64(cached) * Cached_price, float64(out) * Output_price, 0, float64(cache_write) * Cache_write_price
+}
+
+func (ant *LLMAnthropic) Complete(st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg) error {
+	err := ant.Check()
+	if err != nil {
+		return err
+	}
+
+	mod := ant.FindModel(st.Out_usage.Model)
+	if mod == nil {
+		return fmt.Errorf("model '%s' not found", st.Out_usage.Model)
+	}
+
+	stats, err := LLM_CompleteLoop(ant.Provider, st, app_port, tools, msg, mod.GetTextPrice, func(props OpenAI_completion_props, msgs *ChatMsgs, fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error) {
+		aprops := Anthropic_buildProps(st, mod, tools, msgs)
+
+		jsProps, err := LogsJsonMarshal(aprops)
+		if err != nil {
+			return OpenAIOut{}, -1, 0, -1, err
+		}
+		return Anthropic_completion_Run(jsProps, ant.Anthropic_url, ant.API_key, fnStreaming, msg)
+	})
+	ant.Stats = append(ant.Stats, stats...)
+	return err
+}
+
+type Anthropic_cache_control struct {
+	Type string `json:"type"` //"ephemeral"
+}
+
+type Anthropic_content_source struct {
+	Type       string `json:"type"` //"base64"
+	Media_type string `json:"media_type"`
+	Data       string `json:"data"`
+}
+
+type Anthropic_content struct {
+	Type string `json:"type"` //"text", "image", "tool_use", "tool_result", "thinking", "redacted_thinking"
+
+	Text   string                    `json:"text,omitempty"`
+	Source *Anthropic_content_source `json:"source,omitempty"`
+
+	//tool_use
+	Id    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	//tool_result
+	Tool_use_id string `json:"tool_use_id,omitempty"`
+	Content     any    `json:"content,omitempty"` //string or []Anthropic_content(text, image)
+
+	//thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"` //redacted_thinking
+
+	Cache_control *Anthropic_cache_control `json:"cache_control,omitempty"`
+}
+
+type Anthropic_message struct {
+	Role    string              `json:"role"` //"user", "assistant"
+	Content []Anthropic_content `json:"content"`
+}
+
+type Anthropic_tool struct {
+	Name         string                             `json:"name"`
+	Description  string                             `json:"description,omitempty"`
+	Input_schema ToolsOpenAI_completion_tool_schema `json:"input_schema"`
+
+	Cache_control *Anthropic_cache_control `json:"cache_control,omitempty"`
+}
+
+type Anthropic_thinking struct {
+	Type          string `json:"type"` //"enabled"
+	Budget_tokens int    `json:"budget_tokens"`
+}
+
+type Anthropic_completion_props struct {
+	Model      string              `json:"model"`
+	Max_tokens int                 `json:"max_tokens"`
+	System     []Anthropic_content `json:"system,omitempty"`
+	Messages   []Anthropic_message `json:"messages"`
+	Tools      []Anthropic_tool    `json:"tools,omitempty"`
+	Stream     bool                `json:"stream"`
+
+	Temperature *float64            `json:"temperature,omitempty"` //must be empty with thinking
+	Thinking    *Anthropic_thinking `json:"thinking,omitempty"`
+}
+
+func Anthropic_getThinkingBudget(reasoning_effort string) int {
+	switch strings.ToLower(reasoning_effort) {
+	case "low":
+		return 1024
+	case "medium":
+		return 4096
+	case "high":
+		return 16384
+	}
+	return 0
+}
+
+// converts tool into Anthropic tool definition
+func Anthropic_convertTool(tool *ToolsOpenAI_completion_tool) Anthropic_tool {
+	return Anthropic_tool{Name: tool.Function.Name, Description: tool.Function.Description, Input_schema: tool.Function.Parameters}
+}
+
+// converts message content(text, image_url) into Anthropic content blocks
+func Anthropic_convertContent(content []OpenAI_completion_msg_Content) []Anthropic_content {
+	var blocks []Anthropic_content
+	for _, it := range content {
+		switch it.Type {
+		case "text":
+			blocks = append(blocks, Anthropic_content{Type: "text", Text: it.Text})
+		case "image_url":
+			if it.Image_url == nil {
+				continue
+			}
+			//"data:image/png;base64,..."
+			media_type, data, found := strings.Cut(strings.TrimPrefix(it.Image_url.Url, "data:"), ";base64,")
+			if !found {
+				continue
+			}
+			blocks = append(blocks, Anthropic_content{Type: "image", Source: &Anthropic_content_source{Type: "base64", Media_type: media_type, Data: data}})
+		}
+	}
+	return blocks
+}
+
+// converts messages into Anthropic format: tool results go into user message, consecutive messages with same role are merged
+func Anthropic_convertMessages(msgs *ChatMsgs) []Anthropic_message {
+	var messages []Anthropic_message
+	add := func(role string, blocks []Anthropic_content) {
+		if len(blocks) == 0 {
+			return
+		}
+		if len(messages) > 0 && messages[len(messages)-1].Role == role {
+			messages[len(messages)-1].Content = append(messages[len(messages)-1].Content, blocks...)
+			return
+		}
+		messages = append(messages, Anthropic_message{Role: role, Content: blocks})
+	}
+
+	for _, m := range msgs.Messages {
+		if m.Content.Msg != nil {
+			role := "user"
+			if m.Content.Msg.Role == "assistant" {
+				role = "assistant"
+			}
+			add(role, Anthropic_convertContent(m.Content.Msg.Content))
+		}
+
+		if m.Content.Calls != nil {
+			var blocks []Anthropic_content
+
+			text := m.Content.Calls.Content
+			if m.ReasoningSize > 0 && m.ReasoningSize <= len(text) {
+				reasoning := text[:m.ReasoningSize-len(ChatMsg_GetDivAfterReasoning())]
+				text = text[m.ReasoningSize:]
+
+				//thinking without signature can't be send back
+				if m.Reasoning_signature != "" {
+					blocks = append(blocks, Anthropic_content{Type: "thinking", Thinking: reasoning, Signature: m.Reasoning_signature})
+				}
+			}
+			for _, data := range m.Reasoning_redacted {
+				blocks = append(blocks, Anthropic_content{Type: "redacted_thinking", Data: data})
+			}
+
+			if text != "" {
+				blocks = append(blocks, Anthropic_content{Type: "text", Text: text})
+			}
+
+			for _, call := range m.Content.Calls.Tool_calls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, Anthropic_content{Type: "tool_use", Id: call.Id, Name: call.Function.Name, Input: input})
+			}
+			add("assistant", blocks)
+		}
+
+		if m.Content.Result != nil {
+			var content any = m.Content.Result.Content
+			if m.Content.ResultImages != nil {
+				blocks := []Anthropic_content{{Type: "text", Text: m.Content.Result.Content}}
+				content = append(blocks, Anthropic_convertContent(m.Content.ResultImages.Content)...)
+			}
+			add("user", []Anthropic_content{{Type: "tool_result", Tool_use_id: m.Content.Result.Tool_call_id, Content: content}})
+		}
+	}
+	return messages
+}
+
+func Anthropic_buildProps(st *LLMComplete, model *LLMAnthropicLanguageModel, tools []*ToolsOpenAI_completion_tool, msgs *ChatMsgs) Anthropic_completion_props {
+	props := Anthropic_completion_props{
+		Model:      st.Out_usage.Model,
+		Max_tokens: st.Max_tokens,
+		Stream:     true,
+		Messages:   Anthropic_convertMessages(msgs),
+	}
+
+	if model.Max_output_tokens > 0 && (props.Max_tokens <= 0 || props.Max_tokens > model.Max_output_tokens) {
+		props.Max_tokens = model.Max_output_tokens
+	}
+	if props.Max_tokens <= 0 {
+		props.Max_tokens = 4096
+	}
+
+	//system and tools are cached(prefix)
+	if st.SystemMessage != "" {
+		props.System = []Anthropic_content{{Type: "text", Text: st.SystemMessage, Cache_control: &Anthropic_cache_control{Type: "ephemeral"}}}
+	}
+	for _, tool := range ToolsSchema_ForModel(tools) {
+		props.Tools = append(props.Tools, Anthropic_convertTool(tool))
+	}
+	if len(props.Tools) > 0 {
+		props.Tools[len(props.Tools)-1].Cache_control = &Anthropic_cache_control{Type: "ephemeral"}
+	}
+
+	budget := Anthropic_getThinkingBudget(st.Reasoning_effort)
+	if budget > 0 && budget < props.Max_tokens {
+		props.Thinking = &Anthropic_thinking{Type: "enabled", Budget_tokens: budget}
+	} else {
+		temperature := st.Temperature
+		props.Temperature = &temperature
+	}
+
+	return props
+}
+
+type Anthropic_stream_usage struct {
+	Input_tokens                int `json:"input_tokens"`
+	Cache_creation_input_tokens int `json:"cache_creation_input_tokens"`
+	Cache_read_input_tokens     int `json:"cache_read_input_tokens"`
+	Output_tokens               int `json:"output_tokens"`
+}
+
+type Anthropic_stream_event struct {
+	Type string `json:"type"` //"message_start", "content_block_start", "content_block_delta", "content_block_stop", "message_delta", "message_stop", "ping", "error"
+
+	Message struct {
+		Usage Anthropic_stream_usage `json:"usage"`
+	} `json:"message"`
+
+	Index         int               `json:"index"`
+	Content_block Anthropic_content `json:"content_block"`
+
+	Delta struct {
+		Type         string `json:"type"` //"text_delta", "input_json_delta", "thinking_delta", "signature_delta"
+		Text         string `json:"text"`
+		Partial_json string `json:"partial_json"`
+		Thinking     string `json:"thinking"`
+		Signature    string `json:"signature"`
+		Stop_reason  string `json:"stop_reason"`
+	} `json:"delta"`
+
+	Usage *Anthropic_stream_usage `json:"usage"`
+
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// sends request to Messages API and converts streamed answer into OpenAIOut
+func Anthropic_completion_Run(jsProps []byte, Completion_url string, api_key string, fnStreaming func(msg *ChatMsg) bool, msg *AppsRouterMsg) (OpenAIOut, int, float64, float64, error) {
+	st := time.Now().UnixMicro()
+
+	if !strings.HasSuffix(Completion_url, "/") {
+		Completion_url += "/"
+	}
+	Completion_url += "messages"
+
+	req, err := http.NewRequest(http.MethodPost, Completion_url, bytes.NewReader(jsProps))
+	if LogsError(err) != nil {
+		return OpenAIOut{}, -1, 0, -1, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("x-api-key", api_key)
+	req.Header.Add("anthropic-version", "2023-06-01")
+	req.Header.Add("Accept", "text/event-stream")
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if LogsError(err) != nil {
+		return OpenAIOut{}, -1, 0, -1, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return OpenAIOut{}, res.StatusCode, 0, -1, LLM_NewStatusError(res, body)
+	}
+
+	var ret OpenAIOut
+	ret.Choices = append(ret.Choices, OpenAIOutChoice{})
+	out := &ret.Choices[0].Message
+
+	time_to_first_token := -1.0
+	tool_indexes := make(map[int]int) //[block index]index into Tool_calls
+
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("error reading stream: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue //"event: ..." has same type as data
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event Anthropic_stream_event
+		err = LogsJsonUnmarshal([]byte(data), &event)
+		if err != nil {
+			return OpenAIOut{}, res.StatusCode, 0, -1, err
+		}
+
+		changed := false
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("%s: %s", event.Error.Type, event.Error.Message)
+			}
+			return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("stream error: %s", data)
+
+		case "message_start":
+			u := event.Message.Usage
+			ret.Usage.Prompt_tokens = u.Input_tokens
+			ret.Usage.Input_cached_tokens = u.Cache_read_input_tokens
+			ret.Usage.Input_cache_write_tokens = u.Cache_creation_input_tokens
+			ret.Usage.Completion_tokens = u.Output_tokens
+
+		case "content_block_start":
+			switch event.Content_block.Type {
+			case "tool_use":
+				tool_indexes[event.Index] = len(out.Tool_calls)
+				out.Tool_calls = append(out.Tool_calls, OpenAI_completion_msg_Content_ToolCall{Id: event.Content_block.Id, Index: len(out.Tool_calls), Type: "function", Function: OpenAI_completion_msg_Content_ToolCall_Function{Name: event.Content_block.Name}})
+				changed = true
+			case "redacted_thinking":
+				out.Reasoning_redacted = append(out.Reasoning_redacted, event.Content_block.Data)
+			case "text":
+				out.Content += event.Content_block.Text
+			case "thinking":
+				out.Reasoning_content += event.Content_block.Thinking
+			}
+
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				out.Content += event.Delta.Text
+				changed = true
+			case "thinking_delta":
+				out.Reasoning_content += event.Delta.Thinking
+				changed = true
+			case "signature_delta":
+				out.Reasoning_signature += event.Delta.Signature
+			case "input_json_delta":
+				i, found := tool_indexes[event.Index]
+				if found {
+					out.Tool_calls[i].Function.Arguments += event.Delta.Partial_json
+				}
+			}
+			if time_to_first_token < 0 && changed {
+				time_to_first_token = float64(time.Now().UnixMicro()-st) / 1000000
+			}
+
+		case "content_block_stop":
+			i, found := tool_indexes[event.Index]
+			if found && out.Tool_calls[i].Function.Arguments == "" {
+				out.Tool_calls[i].Function.Arguments = "{}" //tool without arguments
+			}
+
+		case "message_delta":
+			if event.Usage != nil {
+				ret.Usage.Completion_tokens = event.Usage.Output_tokens //cumulative
+			}
+
+		case "message_stop":
+			//done
+		}
+
+		if changed && fnStreaming != nil {
+			var msgs ChatMsgs
+			msgs.AddAssistentCalls(out.Reasoning_content, out.Content, out.Tool_calls, nil, LLMMsgUsage{})
+			if !fnStreaming(msgs.Messages[0]) {
+				return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("interrupted")
+			}
+		}
+
+		if event.Type == "message_stop" {
+			break
+		}
+		if !msg.GetContinue() {
+			return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("interrupted")
+		}
+	}
+
+	ret.Usage.Total_tokens = ret.Usage.Prompt_tokens + ret.Usage.Input_cached_tokens + ret.Usage.Input_cache_write_tokens + ret.Usage.Completion_tokens
+
+	dt := float64(time.Now().UnixMicro()-st) / 1000000
+	return ret, res.StatusCode, dt, time_to_first_token, nil
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// returns scripted SSE streams, one per request
+func _test_startFakeAnthropic(t *testing.T, streams ...[]string) (string, func() []Anthropic_completion_props, func() []http.Header) {
+	var lock sync.Mutex
+	var reqs []Anthropic_completion_props
+	var headers []http.Header
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var props Anthropic_completion_props
+		json.Unmarshal(body, &props)
+
+		lock.Lock()
+		i := len(reqs)
+		reqs = append(reqs, props)
+		headers = append(headers, r.Header.Clone())
+		lock.Unlock()
+
+		if r.URL.Path != "/v1/messages" || i >= len(streams) {
+			http.Error(w, `{"type":"error","error":{"type":"not_found_error","message":"no scripted response"}}`, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range streams[i] {
+			var ev struct{ Type string }
+			json.Unmarshal([]byte(event), &ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, event)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL + "/v1",
+		func() []Anthropic_completion_props {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]Anthropic_completion_props{}, reqs...)
+		},
+		func() []http.Header {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]http.Header{}, headers...)
+		}
+}
+
+func _test_getAnthropicModel() *LLMAnthropicLanguageModel {
+	return &LLMAnthropicLanguageModel{
+		Id:                                  "claude-test",
+		Max_output_tokens:                   8000,
+		Prompt_text_token_price:             30000,  //$3
+		Cached_prompt_text_token_price:      3000,   //$0.3
+		Cache_write_prompt_text_token_price: 37500,  //$3.75
+		Completion_text_token_price:         150000, //$15
+	}
+}
+
+func TestAnthropic_Complete_toolUse(t *testing.T) {
+	url, getRequests, getHeaders := _test_startFakeAnthropic(t,
+		[]string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":100,"cache_creation_input_tokens":2000,"cache_read_input_tokens":0,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Need to "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"sum."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"SIG"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Let me sum."}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"Sum","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"A\": 1, "}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"B\": 2}"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"content_block_start","index":3,"content_block":{"type":"tool_use","id":"toolu_2","name":"Now","input":{}}}`,
+			`{"type":"content_block_stop","index":3}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":50}}`,
+			`{"type":"message_stop"}`,
+		},
+		[]string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":20,"cache_creation_input_tokens":0,"cache_read_input_tokens":2000,"output_tokens":1}}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Result is 3."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":10}}`,
+			`{"type":"message_stop"}`,
+		},
+	)
+
+	var lock sync.Mutex //fake app runs in other goroutine
+	var gotParams []string
+	app_port, _ := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		lock.Lock()
+		gotParams = append(gotParams, toolName+string(paramsJs))
+		lock.Unlock()
+		if toolName == "Sum" {
+			return []byte(`{"A": 1, "B": 2, "Out_sum": 3}`), nil
+		}
+		return []byte(`{"Out_time": "noon"}`), nil
+	})
+
+	ant := &LLMAnthropic{Provider: "Anthropic", Anthropic_url: url, API_key: "secret", LanguageModels: []*LLMAnthropicLanguageModel{_test_getAnthropicModel()}}
+
+	tool := NewToolsOpenAI_completion_tool("Sum", "Sums two numbers.")
+	tool.Function.Parameters.AddParam("A", "float64", "First number")
+	tool.Function.Parameters.AddParam("B", "float64", "Second number")
+	tools := []*ToolsOpenAI_completion_tool{tool, NewToolsOpenAI_completion_tool("Now", "Returns current time.")}
+
+	st, msg := _test_newCompletion("What is 1+2?", 5)
+	st.Out_usage.Model = "claude-test"
+	st.Reasoning_effort = "low"
+	st.Max_tokens = 32768
+
+	err := ant.Complete(st, app_port, tools, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//headers
+	hdrs := getHeaders()
+	if len(hdrs) != 2 {
+		t.Fatalf("requests: %d, expected 2", len(hdrs))
+	}
+	if hdrs[0].Get("x-api-key") != "secret" || hdrs[0].Get("anthropic-version") == "" {
+		t.Errorf("headers: %v", hdrs[0])
+	}
+
+	//1st request
+	reqs := getRequests()
+	r0 := reqs[0]
+	if r0.Max_tokens != 8000 {
+		t.Errorf("max_tokens: %d, expected model's limit 8000", r0.Max_tokens)
+	}
+	if r0.Thinking == nil || r0.Thinking.Budget_tokens != 1024 || r0.Temperature != nil {
+		t.Errorf("thinking: %v, temperature: %v", r0.Thinking, r0.Temperature)
+	}
+	if len(r0.System) != 1 || r0.System[0].Text != "You are a test." || r0.System[0].Cache_control == nil {
+		t.Errorf("system: %+v", r0.System)
+	}
+	if len(r0.Tools) != 2 || r0.Tools[0].Name != "Sum" || r0.Tools[0].Input_schema.Properties["A"] == nil || r0.Tools[1].Cache_control == nil {
+		t.Errorf("tools: %+v", r0.Tools)
+	}
+
+	//tools were called(at once, in any order)
+	lock.Lock()
+	slices.Sort(gotParams)
+	if len(gotParams) != 2 || gotParams[0] != `Now{}` || gotParams[1] != `Sum{"A": 1, "B": 2}` {
+		t.Errorf("tool calls: %v", gotParams)
+	}
+	lock.Unlock()
+
+	//2nd request: user, assistant(thinking, text, tool_use x2), user(tool_result x2)
+	msgs := reqs[1].Messages
+	if len(msgs) != 3 {
+		t.Fatalf("messages in 2nd request: %d, expected 3: %+v", len(msgs), msgs)
+	}
+	as := msgs[1]
+	if as.Role != "assistant" || len(as.Content) != 4 {
+		t.Fatalf("assistant message: %+v", as)
+	}
+	if as.Content[0].Type != "thinking" || as.Content[0].Thinking != "Need to sum." || as.Content[0].Signature != "SIG" {
+		t.Errorf("thinking block: %+v", as.Content[0])
+	}
+	if as.Content[1].Type != "text" || as.Content[1].Text != "Let me sum." {
+		t.Errorf("text block: %+v", as.Content[1])
+	}
+	if as.Content[2].Type != "tool_use" || as.Content[2].Id != "toolu_1" || string(as.Content[2].Input) != `{"A":1,"B":2}` {
+		t.Errorf("tool_use block: %+v", as.Content[2])
+	}
+	res := msgs[2]
+	if res.Role != "user" || len(res.Content) != 2 || res.Content[0].Type != "tool_result" || res.Content[0].Tool_use_id != "toolu_1" || res.Content[0].Content != "3" || res.Content[1].Content != "noon" {
+		t.Errorf("tool results: %+v", res)
+	}
+
+	//answer
+	if st.Out_answer != "Result is 3." {
+		t.Errorf("answer: '%s'", st.Out_answer)
+	}
+
+	//usage
+	u := st.Out_usage
+	if u.Prompt_tokens != 120 || u.Input_cached_tokens != 2000 || u.Input_cache_write_tokens != 2000 || u.Completion_tokens != 60 {
+		t.Errorf("usage: %+v", u)
+	}
+	expected := (120*3.0 + 2000*0.3 + 2000*3.75 + 60*15.0) / 1000000
+	if math.Abs(u.TotalPrice()-expected) > 1e-12 {
+		t.Errorf("price: %g, expected %g", u.TotalPrice(), expected)
+	}
+}
+
+func TestAnthropic_completion_Run_error(t *testing.T) {
+	url, _, _ := _test_startFakeAnthropic(t,
+		[]string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":10}}}`,
+			`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+		},
+	)
+
+	ant := &LLMAnthropic{Provider: "Anthropic", Anthropic_url: url, API_key: "secret", LanguageModels: []*LLMAnthropicLanguageModel{_test_getAnthropicModel()}}
+
+	st, msg := _test_newCompletion("Hi", 1)
+	st.Out_usage.Model = "claude-test"
+	err := ant.Complete(st, 0, nil, msg)
+	if err == nil || err.Error() != "overloaded_error: Overloaded" {
+		t.Errorf("error: %v", err)
+	}
+
+	//2nd call has no scripted stream
+	err = ant.Complete(st, 0, nil, msg)
+	if err == nil {
+		t.Errorf("expected status error")
+	}
+}
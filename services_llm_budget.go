@@ -0,0 +1,317 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// One paid LLM call
+type LLMSpending struct {
+	Time     float64 //sec
+	AppName  string
+	Provider string
+	Model    string
+	Price    float64 //USD
+}
+
+// USD, 0 = no limit
+type LLMBudgetLimits struct {
+	Daily   float64
+	Monthly float64
+	Apps    map[string]float64 //[app name]monthly limit
+}
+
+// error returned when call would cross the budget
+type LLMBudgetError struct {
+	Period    string //"daily", "monthly", "app"
+	AppName   string
+	Limit     float64
+	Spent     float64
+	Projected float64
+}
+
+func (e *LLMBudgetError) Error() string {
+	name := "Daily budget"
+	switch e.Period {
+	case "monthly":
+		name = "Monthly budget"
+	case "app":
+		name = fmt.Sprintf("Monthly budget of app '%s'", e.AppName)
+	}
+	return fmt.Sprintf("%s $%.2f reached: spent $%.4f, next call ~$%.4f. Change limit in Settings.", name, e.Limit, e.Spent, e.Projected)
+}
+
+type LLMSpendingsGroup struct {
+	Name  string
+	Spent float64 //this month
+	Limit float64
+	Calls int
+}
+
+// Summary for dashboard
+type LLMSpendingsSummary struct {
+	Daily_spent   float64
+	Daily_limit   float64
+	Monthly_spent float64
+	Monthly_limit float64
+
+	Apps   []LLMSpendingsGroup //this month
+	Models []LLMSpendingsGroup //this month
+}
+
+// Spendings of one app and model in one day
+type LLMSpendingDay struct {
+	Day      string //"2006-01-02", local time
+	AppName  string
+	Provider string
+	Model    string
+	Price    float64 //USD
+	Calls    int
+}
+
+// Projected price of running call. It's counted by Check() until call is settled by Add().
+type LLMBudgetReservation struct {
+	AppName string
+	Price   float64
+}
+
+const LLMBudget_keepDays = 400
+
+// Spending ledger aggregated per day, app and model, saved into file after every call
+type LLMBudget struct {
+	path string
+
+	lock     sync.Mutex
+	Days     []LLMSpendingDay
+	reserved []*LLMBudgetReservation
+}
+
+func NewLLMBudget(path string) *LLMBudget {
+	budget := &LLMBudget{path: path}
+
+	fl, err := os.ReadFile(path)
+	if err == nil {
+		LogsJsonUnmarshal(fl, &budget.Days)
+
+		//old format: list of calls
+		if len(budget.Days) > 0 && budget.Days[0].Day == "" {
+			budget.Days = nil
+			var items []LLMSpending
+			LogsJsonUnmarshal(fl, &items)
+			for _, it := range items {
+				budget._add(it)
+			}
+		}
+	}
+	return budget
+}
+
+func LLMBudget_getDayStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+func LLMBudget_getMonthStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+func LLMBudget_getDay(tm time.Time) string {
+	return tm.Format("2006-01-02")
+}
+
+func (budget *LLMBudget) _add(item LLMSpending) {
+	if item.Price <= 0 {
+		return //local models, cache
+	}
+
+	day := LLMBudget_getDay(time.UnixMicro(int64(item.Time * 1000000)))
+	for i := range budget.Days {
+		it := &budget.Days[i]
+		if it.Day == day && it.AppName == item.AppName && it.Provider == item.Provider && it.Model == item.Model {
+			it.Price += item.Price
+			it.Calls++
+			return
+		}
+	}
+	budget.Days = append(budget.Days, LLMSpendingDay{Day: day, AppName: item.AppName, Provider: item.Provider, Model: item.Model, Price: item.Price, Calls: 1})
+}
+
+// settles call: removes its reservation(can be nil) and adds real price
+func (budget *LLMBudget) Add(item LLMSpending, reservation *LLMBudgetReservation) {
+	budget.lock.Lock()
+	defer budget.lock.Unlock()
+
+	if reservation != nil {
+		budget.reserved = slices.DeleteFunc(budget.reserved, func(it *LLMBudgetReservation) bool { return it == reservation })
+	}
+
+	if item.Price <= 0 {
+		return //local models, cache
+	}
+	budget._add(item)
+
+	//remove old days
+	oldest := LLMBudget_getDay(time.UnixMicro(int64(item.Time*1000000)).AddDate(0, 0, -LLMBudget_keepDays))
+	budget.Days = slices.DeleteFunc(budget.Days, func(it LLMSpendingDay) bool { return it.Day < oldest })
+
+	if budget.path != "" {
+		Tools_WriteJSONFile(budget.path, budget.Days)
+	}
+}
+
+func (budget *LLMBudget) _getSpent(from time.Time, appName string, withReserved bool) float64 {
+	start := LLMBudget_getDay(from)
+	sum := 0.0
+	for _, it := range budget.Days {
+		if it.Day >= start && (appName == "" || it.AppName == appName) {
+			sum += it.Price
+		}
+	}
+	if withReserved {
+		for _, it := range budget.reserved {
+			if appName == "" || it.AppName == appName {
+				sum += it.Price
+			}
+		}
+	}
+	return sum
+}
+
+// appName == "" = all apps. from is rounded to day.
+func (budget *LLMBudget) GetSpent(from time.Time, appName string) float64 {
+	budget.lock.Lock()
+	defer budget.lock.Unlock()
+
+	return budget._getSpent(from, appName, false)
+}
+
+// returns error if spent + running calls + projected would cross any limit. Otherwise projected price is reserved until Add().
+func (budget *LLMBudget) Check(limits *LLMBudgetLimits, appName string, projected float64, now time.Time) (*LLMBudgetReservation, error) {
+	budget.lock.Lock()
+	defer budget.lock.Unlock()
+
+	if limits.Daily > 0 {
+		spent := budget._getSpent(LLMBudget_getDayStart(now), "", true)
+		if spent+projected > limits.Daily {
+			return nil, &LLMBudgetError{Period: "daily", Limit: limits.Daily, Spent: spent, Projected: projected}
+		}
+	}
+
+	if limits.Monthly > 0 {
+		spent := budget._getSpent(LLMBudget_getMonthStart(now), "", true)
+		if spent+projected > limits.Monthly {
+			return nil, &LLMBudgetError{Period: "monthly", Limit: limits.Monthly, Spent: spent, Projected: projected}
+		}
+	}
+
+	limit := limits.Apps[appName]
+	if appName != "" && limit > 0 {
+		spent := budget._getSpent(LLMBudget_getMonthStart(now), appName, true)
+		if spent+projected > limit {
+			return nil, &LLMBudgetError{Period: "app", AppName: appName, Limit: limit, Spent: spent, Projected: projected}
+		}
+	}
+
+	reservation := &LLMBudgetReservation{AppName: appName, Price: projected}
+	budget.reserved = append(budget.reserved, reservation)
+	return reservation, nil
+}
+
+func (budget *LLMBudget) GetSummary(limits *LLMBudgetLimits, now time.Time) LLMSpendingsSummary {
+	sum := LLMSpendingsSummary{
+		Daily_spent:   budget.GetSpent(LLMBudget_getDayStart(now), ""),
+		Daily_limit:   limits.Daily,
+		Monthly_spent: budget.GetSpent(LLMBudget_getMonthStart(now), ""),
+		Monthly_limit: limits.Monthly,
+	}
+
+	budget.lock.Lock()
+	defer budget.lock.Unlock()
+
+	apps := make(map[string]*LLMSpendingsGroup)
+	models := make(map[string]*LLMSpendingsGroup)
+	add := func(groups map[string]*LLMSpendingsGroup, name string, price float64, calls int) {
+		g, found := groups[name]
+		if !found {
+			g = &LLMSpendingsGroup{Name: name}
+			groups[name] = g
+		}
+		g.Spent += price
+		g.Calls += calls
+	}
+
+	start := LLMBudget_getDay(LLMBudget_getMonthStart(now))
+	for _, it := range budget.Days {
+		if it.Day >= start {
+			add(apps, it.AppName, it.Price, it.Calls)
+			add(models, it.Provider+":"+it.Model, it.Price, it.Calls)
+		}
+	}
+
+	//apps with limit, but without spending
+	for name := range limits.Apps {
+		if apps[name] == nil {
+			apps[name] = &LLMSpendingsGroup{Name: name}
+		}
+	}
+	for name, g := range apps {
+		g.Limit = limits.Apps[name]
+		sum.Apps = append(sum.Apps, *g)
+	}
+	for _, g := range models {
+		sum.Models = append(sum.Models, *g)
+	}
+
+	//most expensive first
+	fnSort := func(a, b LLMSpendingsGroup) int {
+		if a.Spent != b.Spent {
+			if a.Spent > b.Spent {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	}
+	slices.SortFunc(sum.Apps, fnSort)
+	slices.SortFunc(sum.Models, fnSort)
+
+	return sum
+}
+
+// rough price of whole tool-call loop: prompt is ~4 characters per token, output is capped and every round sends previous outputs again
+func LLM_GetProjectedPrice(st *LLMComplete, fnGetTextPrice func(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64)) float64 {
+	if fnGetTextPrice == nil {
+		return 0
+	}
+
+	chars := len(st.SystemMessage) + len(st.UserMessage) + len(st.PreviousMessages) + len(st.Out_tools)
+	in := chars/4 + 1
+	out := 4096
+	if st.Max_tokens > 0 {
+		out = min(out, st.Max_tokens)
+	}
+
+	iters := max(1, st.Max_iteration)
+	in_total := iters*in + out*iters*(iters-1)/2
+	out_total := iters * out
+
+	in_price, _, _, out_price, _, _ := fnGetTextPrice(in_total, 0, 0, out_total, 0, 0)
+	return in_price + out_price
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func _test_addSpending(budget *LLMBudget, tm time.Time, appName, model string, price float64) {
+	budget.Add(LLMSpending{Time: float64(tm.UnixMicro()) / 1000000, AppName: appName, Provider: "xAI", Model: model, Price: price}, nil)
+}
+
+func TestLLMBudget_Check(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local)
+	path := filepath.Join(t.TempDir(), "spendings.json")
+
+	budget := NewLLMBudget(path)
+	_test_addSpending(budget, now.AddDate(0, -1, 0), "Calendar", "grok-4", 100) //previous month
+	_test_addSpending(budget, now.AddDate(0, 0, -3), "Calendar", "grok-4", 6)
+	_test_addSpending(budget, now.Add(-time.Hour), "Calendar", "grok-4", 1.5)
+	_test_addSpending(budget, now.Add(-time.Hour), "Calendar", "grok-4", 0.5) //same day, app and model
+	_test_addSpending(budget, now.Add(-time.Hour), "Notes", "grok-3-mini", 1)
+	_test_addSpending(budget, now.Add(-time.Hour), "Notes", "grok-3-mini", 0) //ignored
+
+	if d := budget.GetSpent(LLMBudget_getDayStart(now), ""); d != 3 {
+		t.Errorf("daily: %g", d)
+	}
+	if m := budget.GetSpent(LLMBudget_getMonthStart(now), "Calendar"); m != 8 {
+		t.Errorf("monthly Calendar: %g", m)
+	}
+
+	//no limits
+	limits := LLMBudgetLimits{}
+	res, err := budget.Check(&limits, "Calendar", 1000, now)
+	if err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+	budget.Add(LLMSpending{}, res)
+
+	//daily
+	limits.Daily = 4
+	res, err = budget.Check(&limits, "Calendar", 0.5, now)
+	if err != nil {
+		t.Errorf("under daily: %v", err)
+	}
+	budget.Add(LLMSpending{}, res) //free call releases reservation
+	var budgetErr *LLMBudgetError
+	_, err = budget.Check(&limits, "Calendar", 1.5, now)
+	if !errors.As(err, &budgetErr) || budgetErr.Period != "daily" || budgetErr.Spent != 3 {
+		t.Errorf("daily: %v", err)
+	}
+
+	//monthly
+	limits = LLMBudgetLimits{Monthly: 10}
+	_, err = budget.Check(&limits, "Notes", 1.5, now)
+	if !errors.As(err, &budgetErr) || budgetErr.Period != "monthly" || budgetErr.Spent != 9 {
+		t.Errorf("monthly: %v", err)
+	}
+
+	//per app
+	limits = LLMBudgetLimits{Apps: map[string]float64{"Calendar": 8}}
+	if _, err := budget.Check(&limits, "Notes", 5, now); err != nil {
+		t.Errorf("other app: %v", err)
+	}
+	_, err = budget.Check(&limits, "Calendar", 0.01, now)
+	if !errors.As(err, &budgetErr) || budgetErr.Period != "app" || budgetErr.AppName != "Calendar" {
+		t.Errorf("app: %v", err)
+	}
+
+	//persisted, aggregated per day
+	loaded := NewLLMBudget(path)
+	if len(loaded.Days) != 4 {
+		t.Errorf("loaded days: %d", len(loaded.Days))
+	}
+	if m := loaded.GetSpent(LLMBudget_getMonthStart(now), "Calendar"); m != 8 {
+		t.Errorf("loaded monthly Calendar: %g", m)
+	}
+}
+
+func TestLLMBudget_reservation(t *testing.T) {
+	now := time.Now()
+	budget := NewLLMBudget("")
+	limits := LLMBudgetLimits{Daily: 10}
+
+	//running calls are counted
+	res1, err := budget.Check(&limits, "Calendar", 6, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var budgetErr *LLMBudgetError
+	_, err = budget.Check(&limits, "Notes", 6, now)
+	if !errors.As(err, &budgetErr) || budgetErr.Spent != 6 {
+		t.Errorf("reserved: %v", err)
+	}
+
+	//settled with real price
+	budget.Add(LLMSpending{Time: float64(now.UnixMicro()) / 1000000, AppName: "Calendar", Provider: "xAI", Model: "grok-4", Price: 1}, res1)
+	if d := budget.GetSpent(LLMBudget_getDayStart(now), ""); d != 1 {
+		t.Errorf("spent: %g", d)
+	}
+	res2, err := budget.Check(&limits, "Notes", 6, now)
+	if err != nil {
+		t.Errorf("after settle: %v", err)
+	}
+	budget.Add(LLMSpending{}, res2)
+	if len(budget.reserved) != 0 {
+		t.Errorf("reservations: %d", len(budget.reserved))
+	}
+}
+
+func TestLLMBudget_oldFormat(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "spendings.json")
+
+	tm := float64(now.UnixMicro()) / 1000000
+	items := []LLMSpending{
+		{Time: tm, AppName: "Calendar", Provider: "xAI", Model: "grok-4", Price: 1},
+		{Time: tm, AppName: "Calendar", Provider: "xAI", Model: "grok-4", Price: 2},
+		{Time: tm, AppName: "Notes", Provider: "xAI", Model: "grok-4", Price: 4},
+	}
+	_, err := Tools_WriteJSONFile(path, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	budget := NewLLMBudget(path)
+	if len(budget.Days) != 2 || budget.Days[0].Calls != 2 || budget.GetSpent(LLMBudget_getDayStart(now), "") != 7 {
+		t.Errorf("days: %+v", budget.Days)
+	}
+}
+
+func TestLLMBudget_GetSummary(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local)
+
+	budget := NewLLMBudget("")
+	_test_addSpending(budget, now.Add(-time.Hour), "Calendar", "grok-4", 2)
+	_test_addSpending(budget, now.Add(-time.Hour), "Calendar", "grok-3-mini", 0.5)
+	_test_addSpending(budget, now.Add(-time.Hour), "Notes", "grok-4", 3)
+
+	limits := LLMBudgetLimits{Daily: 10, Apps: map[string]float64{"Calendar": 20, "Map": 5}}
+	sum := budget.GetSummary(&limits, now)
+
+	if sum.Daily_spent != 5.5 || sum.Daily_limit != 10 || sum.Monthly_spent != 5.5 {
+		t.Errorf("totals: %+v", sum)
+	}
+	if len(sum.Apps) != 3 || sum.Apps[0].Name != "Notes" || sum.Apps[1].Name != "Calendar" || sum.Apps[1].Limit != 20 || sum.Apps[1].Calls != 2 || sum.Apps[2].Name != "Map" || sum.Apps[2].Spent != 0 {
+		t.Errorf("apps: %+v", sum.Apps)
+	}
+	if len(sum.Models) != 2 || sum.Models[0].Name != "xAI:grok-4" || sum.Models[0].Spent != 5 {
+		t.Errorf("models: %+v", sum.Models)
+	}
+}
+
+func TestLLM_GetProjectedPrice(t *testing.T) {
+	st := NewLLMCompletion()
+	st.SystemMessage = string(make([]byte, 3999))
+	st.Max_tokens = 1000
+
+	model := &LLMOpenaiLanguageModel{Prompt_text_token_price: 10000, Completion_text_token_price: 40000} //$1, $4
+	price := LLM_GetProjectedPrice(st, model.GetTextPrice)
+
+	expected := (1000*1.0 + 1000*4.0) / 1000000
+	if math.Abs(price-expected) > 1e-12 {
+		t.Errorf("price: %g, expected %g", price, expected)
+	}
+
+	//every round sends previous outputs again
+	st.Max_iteration = 3
+	price = LLM_GetProjectedPrice(st, model.GetTextPrice)
+	expected = ((3*1000+1000*3)*1.0 + 3*1000*4.0) / 1000000
+	if math.Abs(price-expected) > 1e-12 {
+		t.Errorf("price with 3 iterations: %g, expected %g", price, expected)
+	}
+
+	if LLM_GetProjectedPrice(st, nil) != 0 {
+		t.Errorf("local model should be free")
+	}
+}
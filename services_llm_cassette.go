@@ -0,0 +1,147 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// One LLM call(one iteration of tool-call loop) saved in cassette folder
+type LLMCassetteTurn struct {
+	Request json.RawMessage //without model
+
+	Out              OpenAIOut
+	StatusCode       int
+	DTime            float64
+	TimeToFirstToken float64
+
+	Deltas []LLMCassetteDelta //streamed
+}
+
+// Streamed messages are cumulative, so only sizes are saved and messages are re-built from final Out
+type LLMCassetteDelta struct {
+	Reasoning int
+	Content   int
+}
+
+// Records or replays LLM calls. Tools are still executed, only LLM answers(text, tool calls, deltas) are recorded.
+type LLMCassette struct {
+	folder string
+	replay bool //false = record
+}
+
+func NewLLMCassette(mode string, folder string) *LLMCassette {
+	switch mode {
+	case "record":
+		return &LLMCassette{folder: folder}
+	case "replay":
+		return &LLMCassette{folder: folder, replay: true}
+	}
+	return nil
+}
+
+// model is not part of key, so recorded cassettes can be replayed with any model settings
+func (cs *LLMCassette) getRequest(props OpenAI_completion_props) ([]byte, string, error) {
+	props.Model = ""
+	js, err := LogsJsonMarshal(props)
+	if err != nil {
+		return nil, "", err
+	}
+	return js, _ToolsPrompt_getHash(string(js)), nil
+}
+
+func (cs *LLMCassette) getPath(key string) string {
+	return filepath.Join(cs.folder, key+".json")
+}
+
+func (cs *LLMCassette) Run(props OpenAI_completion_props, fnRun func(fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error), fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error) {
+	request, key, err := cs.getRequest(props)
+	if err != nil {
+		return OpenAIOut{}, -1, 0, -1, err
+	}
+
+	if cs.replay {
+		fl, err := os.ReadFile(cs.getPath(key))
+		if err != nil {
+			return OpenAIOut{}, -1, 0, -1, LogsErrorf("replay: cassette '%s' not found(record it first): %w", cs.getPath(key), err)
+		}
+		var turn LLMCassetteTurn
+		err = LogsJsonUnmarshal(fl, &turn)
+		if err != nil {
+			return OpenAIOut{}, -1, 0, -1, err
+		}
+
+		if len(turn.Out.Choices) > 0 {
+			outMsg := turn.Out.Choices[0].Message
+			for i, delta := range turn.Deltas {
+				var calls []OpenAI_completion_msg_Content_ToolCall
+				if i+1 == len(turn.Deltas) {
+					calls = outMsg.Tool_calls //last
+				}
+
+				var msgs ChatMsgs
+				msgs.AddAssistentCalls(outMsg.Reasoning_content[:min(delta.Reasoning, len(outMsg.Reasoning_content))], outMsg.Content[:min(delta.Content, len(outMsg.Content))], calls, turn.Out.Citations, LLMMsgUsage{})
+				if !fnStreaming(msgs.Messages[0]) {
+					break
+				}
+			}
+		}
+
+		return turn.Out, turn.StatusCode, turn.DTime, turn.TimeToFirstToken, nil
+	}
+
+	//record
+	turn := LLMCassetteTurn{Request: request}
+	interrupted := false
+	out, status, dt, time_to_first_token, err := fnRun(func(msg *ChatMsg) bool {
+		if msg.Content.Calls != nil {
+			delta := LLMCassetteDelta{Content: len(msg.Content.Calls.Content)}
+			if msg.ReasoningSize > 0 {
+				delta.Reasoning = msg.ReasoningSize - len(ChatMsg_GetDivAfterReasoning())
+				delta.Content -= msg.ReasoningSize
+			}
+			turn.Deltas = append(turn.Deltas, delta)
+		}
+
+		if !fnStreaming(msg) {
+			interrupted = true
+			return false
+		}
+		return true
+	})
+	if err != nil || interrupted {
+		return out, status, dt, time_to_first_token, err //errors and partial answers are not recorded
+	}
+
+	turn.Out = out
+	turn.StatusCode = status
+	turn.DTime = dt
+	turn.TimeToFirstToken = time_to_first_token
+
+	err = os.MkdirAll(cs.folder, os.ModePerm)
+	if err != nil {
+		return out, status, dt, time_to_first_token, err
+	}
+	_, err = Tools_WriteJSONFile(cs.getPath(key), &turn)
+	if err != nil {
+		return out, status, dt, time_to_first_token, err
+	}
+
+	return out, status, dt, time_to_first_token, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"skyalt/mockopenai"
+)
+
+func TestLLMCassette_recordReplay(t *testing.T) {
+	folder := t.TempDir()
+
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+	srv.Add(
+		mockopenai.Response{
+			Reasoning: []string{"Need to ", "sum."},
+			ToolCalls: []mockopenai.ToolCall{{Id: "call_1", Name: "Sum", Arguments: `{"A": 1, "B": 2}`}},
+			Usage:     &mockopenai.Usage{Prompt_tokens: 10, Completion_tokens: 5},
+		},
+		mockopenai.Response{
+			Content: []string{"Result ", "is ", "3."},
+			Usage:   &mockopenai.Usage{Prompt_tokens: 20, Completion_tokens: 3},
+		},
+	)
+
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		return []byte(`{"A": 1, "B": 2, "Out_sum": 3}`), nil
+	})
+
+	tool := NewToolsOpenAI_completion_tool("Sum", "Sums two numbers.")
+	tool.Function.Parameters.AddParam("A", "float64", "First number")
+	tool.Function.Parameters.AddParam("B", "float64", "Second number")
+	tools := []*ToolsOpenAI_completion_tool{tool}
+
+	//record
+	st, msg := _test_newCompletion("What is 1+2?", 5)
+	st.cassette = NewLLMCassette("record", folder)
+	var recDeltas []string
+	st.delta = func(m *ChatMsg) {
+		if m.Content.Calls != nil {
+			recDeltas = append(recDeltas, m.Content.Calls.Content)
+		}
+	}
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, tools, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.GetRequests()); n != 2 {
+		t.Fatalf("recorded requests: %d, expected 2", n)
+	}
+	files, _ := os.ReadDir(folder)
+	if len(files) != 2 {
+		t.Fatalf("cassette files: %d, expected 2", len(files))
+	}
+
+	//replay without server, with other model
+	st2, msg2 := _test_newCompletion("What is 1+2?", 5)
+	st2.Out_usage.Model = "other-model"
+	st2.cassette = NewLLMCassette("replay", folder)
+	var repDeltas []string
+	st2.delta = func(m *ChatMsg) {
+		if m.Content.Calls != nil {
+			repDeltas = append(repDeltas, m.Content.Calls.Content)
+		}
+	}
+	_, err = OpenAI_Complete("test", "http://127.0.0.1:1", "", st2, app_port, tools, msg2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(srv.GetRequests()); n != 2 {
+		t.Errorf("replay sent requests: %d", n-2)
+	}
+	if num_calls.Load() != 2 {
+		t.Errorf("tool calls: %d, expected 2(tools are executed in replay too)", num_calls.Load())
+	}
+	if st2.Out_answer != st.Out_answer || st2.Out_answer != "Result is 3." {
+		t.Errorf("replayed answer: '%s', recorded: '%s'", st2.Out_answer, st.Out_answer)
+	}
+	if st2.Out_usage.Prompt_tokens != st.Out_usage.Prompt_tokens || st2.Out_usage.Completion_tokens != st.Out_usage.Completion_tokens {
+		t.Errorf("replayed usage: %+v, recorded: %+v", st2.Out_usage, st.Out_usage)
+	}
+	if strings.Join(repDeltas, "|") != strings.Join(recDeltas, "|") {
+		t.Errorf("replayed deltas:\n%v\nrecorded:\n%v", repDeltas, recDeltas)
+	}
+	r1, r2 := _test_getResults(t, st), _test_getResults(t, st2)
+	if strings.Join(r1, "|") != strings.Join(r2, "|") {
+		t.Errorf("replayed results: %v, recorded: %v", r2, r1)
+	}
+}
+
+func TestLLMCassette_replayMiss(t *testing.T) {
+	folder := t.TempDir()
+
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+	srv.Add(mockopenai.Response{Content: []string{"Hello."}})
+
+	//record one question
+	st, msg := _test_newCompletion("Say hello", 1)
+	st.cassette = NewLLMCassette("record", folder)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, 0, nil, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//replay other question
+	st2, msg2 := _test_newCompletion("Say goodbye", 1)
+	st2.cassette = NewLLMCassette("replay", folder)
+	_, err = OpenAI_Complete("test", srv.URL, "", st2, 0, nil, msg2, nil)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected cassette miss error, got %v", err)
+	}
+	if n := len(srv.GetRequests()); n != 1 {
+		t.Errorf("replay miss sent request to server: %d requests", n)
+	}
+	if st2.Out_answer != "" {
+		t.Errorf("answer on miss: '%s'", st2.Out_answer)
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// error returned by provider when HTTP status is not 200
+type LLMStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration //from Retry-After header, 0 = not set
+	Body       string
+}
+
+func (e *LLMStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+func LLM_NewStatusError(res *http.Response, body []byte) error {
+	return LogsError(&LLMStatusError{StatusCode: res.StatusCode, RetryAfter: LLM_parseRetryAfter(res.Header.Get("Retry-After"), time.Now()), Body: string(body)})
+}
+
+// Retry-After is seconds or HTTP date
+func LLM_parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	secs, err := strconv.Atoi(value)
+	if err == nil {
+		return max(0, time.Duration(secs)*time.Second)
+	}
+
+	tm, err := http.ParseTime(value)
+	if err == nil {
+		return max(0, tm.Sub(now))
+	}
+	return 0
+}
+
+// returns true for errors, which may pass when request is send again(rate limit, server overloaded, network)
+func LLM_isTransientError(err error) (bool, time.Duration) {
+	var statusErr *LLMStatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests,
+			statusErr.StatusCode == http.StatusRequestTimeout,
+			statusErr.StatusCode >= 500:
+			return true, statusErr.RetryAfter
+		}
+		return false, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, 0
+	}
+	return false, 0
+}
+
+type LLMRetryPolicy struct {
+	Max_retries     int           //per provider, 0 = only one try
+	Base_delay      time.Duration //1st retry, doubles with every retry
+	Max_delay       time.Duration
+	Max_retry_after time.Duration //longer Retry-After skips to next provider
+
+	Breaker_threshold int           //consecutive failures to open circuit
+	Breaker_cooldown  time.Duration //how long is provider skipped
+}
+
+func NewLLMRetryPolicy() LLMRetryPolicy {
+	return LLMRetryPolicy{
+		Max_retries:     2,
+		Base_delay:      1 * time.Second,
+		Max_delay:       20 * time.Second,
+		Max_retry_after: 60 * time.Second,
+
+		Breaker_threshold: 3,
+		Breaker_cooldown:  60 * time.Second,
+	}
+}
+
+// exponential backoff with jitter. Retry-After wins if it's longer.
+func (policy *LLMRetryPolicy) GetDelay(retry int, retry_after time.Duration) time.Duration {
+	delay := policy.Base_delay << retry
+	if delay <= 0 || delay > policy.Max_delay {
+		delay = policy.Max_delay
+	}
+	if delay > 0 {
+		delay = delay/2 + rand.N(delay/2+1) //[50%, 100%]
+	}
+
+	return max(delay, retry_after)
+}
+
+type llmCircuitState struct {
+	failures   int
+	open_until time.Time
+}
+
+// skips provider after too many consecutive failures
+type LLMCircuitBreaker struct {
+	lock      sync.Mutex
+	providers map[string]*llmCircuitState
+}
+
+func (cb *LLMCircuitBreaker) get(provider string) *llmCircuitState {
+	if cb.providers == nil {
+		cb.providers = make(map[string]*llmCircuitState)
+	}
+	provider = strings.ToLower(provider)
+	state, found := cb.providers[provider]
+	if !found {
+		state = &llmCircuitState{}
+		cb.providers[provider] = state
+	}
+	return state
+}
+
+// after cooldown one request is let through(half-open)
+func (cb *LLMCircuitBreaker) Allow(provider string, now time.Time) bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return !now.Before(cb.get(provider).open_until)
+}
+
+func (cb *LLMCircuitBreaker) Success(provider string) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	state := cb.get(provider)
+	state.failures = 0
+	state.open_until = time.Time{}
+}
+
+func (cb *LLMCircuitBreaker) Failure(provider string, now time.Time, policy *LLMRetryPolicy) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	state := cb.get(provider)
+	state.failures++
+	if policy.Breaker_threshold > 0 && state.failures >= policy.Breaker_threshold {
+		state.open_until = now.Add(policy.Breaker_cooldown)
+	}
+}
+
+type LLMChainItem struct {
+	Provider string
+	Model    string
+}
+
+// returns false when msg was stopped
+func LLM_sleep(d time.Duration, msg *AppsRouterMsg) bool {
+	end := time.Now().Add(d)
+	for time.Now().Before(end) {
+		if !msg.GetContinue() {
+			return false
+		}
+		time.Sleep(min(100*time.Millisecond, time.Until(end)))
+	}
+	return msg.GetContinue()
+}
+
+// error of one request after retries, next provider can continue tool-call loop from last finished turn
+type LLMRequestError struct {
+	Provider string
+	Err      error
+}
+
+func (e *LLMRequestError) Error() string {
+	return e.Err.Error()
+}
+func (e *LLMRequestError) Unwrap() error {
+	return e.Err
+}
+
+// error of tool call. Tools already changed app's state, so completion can't be repeated with other provider.
+type LLMToolError struct {
+	Tool string
+	Err  error
+}
+
+func (e *LLMToolError) Error() string {
+	return e.Err.Error()
+}
+func (e *LLMToolError) Unwrap() error {
+	return e.Err
+}
+
+// returns true for errors, which must not go to next provider
+func LLM_isFatalError(err error) bool {
+	var toolErr *LLMToolError
+	var budgetErr *LLMBudgetError
+	return errors.As(err, &toolErr) || errors.As(err, &budgetErr)
+}
+
+// sends one request of tool-call loop. Transient errors are retried with backoff, policy == nil = only one try.
+func LLM_RunRequest(provider string, policy *LLMRetryPolicy, breaker *LLMCircuitBreaker, msg *AppsRouterMsg, fnRun func() (OpenAIOut, int, float64, float64, error)) (OpenAIOut, int, float64, float64, error) {
+	for retry := 0; ; retry++ {
+		out, status, dt, time_to_first_token, err := fnRun()
+		if policy == nil || breaker == nil {
+			return out, status, dt, time_to_first_token, err
+		}
+		if err == nil {
+			breaker.Success(provider)
+			return out, status, dt, time_to_first_token, nil
+		}
+		if !msg.GetContinue() {
+			return out, status, dt, time_to_first_token, err
+		}
+
+		transient, retry_after := LLM_isTransientError(err)
+		if !transient {
+			return out, status, dt, time_to_first_token, err
+		}
+
+		breaker.Failure(provider, time.Now(), policy)
+		if retry >= policy.Max_retries || retry_after > policy.Max_retry_after || !breaker.Allow(provider, time.Now()) {
+			return out, status, dt, time_to_first_token, err
+		}
+
+		if !LLM_sleep(policy.GetDelay(retry, retry_after), msg) {
+			return out, status, dt, time_to_first_token, err
+		}
+	}
+}
+
+// tries providers in order. Every request is retried inside tool-call loop(LLM_RunRequest), failed request goes to next provider,
+// which continues from last finished turn, so tools are never called twice. Tool and budget errors are returned immediately.
+func LLM_CompleteChain(chain []LLMChainItem, policy *LLMRetryPolicy, breaker *LLMCircuitBreaker, st *LLMComplete, msg *AppsRouterMsg, fnComplete func(provider string, st *LLMComplete) error) error {
+	if len(chain) == 0 {
+		return LogsErrorf("no provider")
+	}
+
+	orig_reasoning_effort := st.Reasoning_effort
+	st.retry = policy
+	st.breaker = breaker
+	st.resume = nil
+	defer func() {
+		st.retry = nil
+		st.breaker = nil
+		st.resume = nil
+	}()
+
+	var lastErr error
+	var errs []string
+	for _, item := range chain {
+		if !breaker.Allow(item.Provider, time.Now()) {
+			errs = append(errs, fmt.Sprintf("%s: skipped(circuit open)", item.Provider))
+			continue
+		}
+
+		//usage from finished turns is kept
+		st.Out_usage.Provider = item.Provider
+		st.Out_usage.Model = item.Model
+		st.Reasoning_effort = orig_reasoning_effort
+		if strings.Contains(item.Model, "gpt-oss") {
+			st.Reasoning_effort = "medium"
+		}
+
+		err := fnComplete(item.Provider, st)
+		lastErr = err
+		if err == nil {
+			st.Out_usage.Provider = item.Provider
+			st.Out_usage.Model = item.Model
+			return nil
+		}
+		if !msg.GetContinue() || LLM_isFatalError(err) {
+			return err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", item.Provider, err))
+	}
+
+	if len(chain) == 1 && lastErr != nil {
+		return lastErr //keep original error
+	}
+	return LogsErrorf("all providers failed:\n%s", strings.Join(errs, "\n"))
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"skyalt/mockopenai"
+	"strings"
+	"testing"
+	"time"
+)
+
+func _test_newRetryPolicy() LLMRetryPolicy {
+	policy := NewLLMRetryPolicy()
+	policy.Base_delay = time.Millisecond
+	policy.Max_delay = 5 * time.Millisecond
+	return policy
+}
+
+// returns provider, which answers with scripted errors(nil = success). Requests are retried same way as in tool-call loop.
+func _test_fakeProviders(calls map[string]int, script map[string][]error) func(provider string, st *LLMComplete) error {
+	return func(provider string, st *LLMComplete) error {
+		_, _, _, _, err := LLM_RunRequest(provider, st.retry, st.breaker, st.msg, func() (OpenAIOut, int, float64, float64, error) {
+			i := calls[provider]
+			calls[provider]++
+
+			errs := script[provider]
+			if i < len(errs) && errs[i] != nil {
+				return OpenAIOut{}, -1, 0, -1, errs[i]
+			}
+			if i >= len(errs) && len(errs) > 0 && errs[len(errs)-1] != nil {
+				return OpenAIOut{}, -1, 0, -1, errs[len(errs)-1] //keep failing
+			}
+			return OpenAIOut{}, 200, 0, -1, nil
+		})
+		if err != nil {
+			return &LLMRequestError{Provider: provider, Err: err}
+		}
+
+		st.Out_answer = "answer from " + provider
+		return nil
+	}
+}
+
+func TestLLM_CompleteChain_fallback(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	var breaker LLMCircuitBreaker
+
+	calls := make(map[string]int)
+	fn := _test_fakeProviders(calls, map[string][]error{
+		"xAI":    {&LLMStatusError{StatusCode: 503}},
+		"OpenAI": {&LLMStatusError{StatusCode: 401}},
+	})
+
+	chain := []LLMChainItem{{Provider: "xAI", Model: "grok-4"}, {Provider: "OpenAI", Model: "o4-mini"}, {Provider: "llama.cpp"}}
+
+	st, msg := _test_newCompletion("Hi", 1)
+	err := LLM_CompleteChain(chain, &policy, &breaker, st, msg, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//transient error is retried, other goes to next provider
+	if calls["xAI"] != policy.Max_retries+1 || calls["OpenAI"] != 1 || calls["llama.cpp"] != 1 {
+		t.Errorf("calls: %v", calls)
+	}
+	if st.Out_answer != "answer from llama.cpp" || st.Out_usage.Provider != "llama.cpp" || st.Out_usage.Model != "" {
+		t.Errorf("answer: '%s', usage: %s/%s", st.Out_answer, st.Out_usage.Provider, st.Out_usage.Model)
+	}
+}
+
+func TestLLM_CompleteChain_retrySuccess(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	var breaker LLMCircuitBreaker
+
+	calls := make(map[string]int)
+	fn := _test_fakeProviders(calls, map[string][]error{
+		"xAI": {&LLMStatusError{StatusCode: 429}, nil},
+	})
+
+	st, msg := _test_newCompletion("Hi", 1)
+	err := LLM_CompleteChain([]LLMChainItem{{Provider: "xAI", Model: "grok-4"}, {Provider: "OpenAI"}}, &policy, &breaker, st, msg, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls["xAI"] != 2 || calls["OpenAI"] != 0 || st.Out_usage.Provider != "xAI" || st.Out_usage.Model != "grok-4" {
+		t.Errorf("calls: %v, usage: %s/%s", calls, st.Out_usage.Provider, st.Out_usage.Model)
+	}
+}
+
+func TestLLM_CompleteChain_allFailed(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	policy.Max_retries = 0
+	var breaker LLMCircuitBreaker
+
+	calls := make(map[string]int)
+	fn := _test_fakeProviders(calls, map[string][]error{
+		"xAI":    {errors.New("xai is down")},
+		"OpenAI": {errors.New("openai is down")},
+	})
+
+	st, msg := _test_newCompletion("Hi", 1)
+	err := LLM_CompleteChain([]LLMChainItem{{Provider: "xAI"}, {Provider: "OpenAI"}}, &policy, &breaker, st, msg, fn)
+	if err == nil || !strings.Contains(err.Error(), "xai is down") || !strings.Contains(err.Error(), "openai is down") {
+		t.Errorf("error: %v", err)
+	}
+
+	//single provider keeps original error
+	orig := &LLMStatusError{StatusCode: 400, Body: "bad request"}
+	fn = _test_fakeProviders(calls, map[string][]error{"Groq": {orig}})
+	err = LLM_CompleteChain([]LLMChainItem{{Provider: "Groq"}}, &policy, &breaker, st, msg, fn)
+	if !errors.Is(err, orig) {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestLLM_CompleteChain_circuitBreaker(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	policy.Breaker_threshold = 2
+	policy.Breaker_cooldown = time.Hour
+	var breaker LLMCircuitBreaker
+
+	calls := make(map[string]int)
+	fn := _test_fakeProviders(calls, map[string][]error{
+		"xAI": {&LLMStatusError{StatusCode: 500}},
+	})
+	chain := []LLMChainItem{{Provider: "xAI"}, {Provider: "OpenAI"}}
+
+	//opens after 2 failures, no 3rd retry
+	st, msg := _test_newCompletion("Hi", 1)
+	err := LLM_CompleteChain(chain, &policy, &breaker, st, msg, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls["xAI"] != 2 || calls["OpenAI"] != 1 {
+		t.Errorf("calls: %v", calls)
+	}
+
+	//skipped
+	err = LLM_CompleteChain(chain, &policy, &breaker, st, msg, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls["xAI"] != 2 || calls["OpenAI"] != 2 {
+		t.Errorf("calls: %v", calls)
+	}
+
+	//half-open after cooldown
+	if !breaker.Allow("xai", time.Now().Add(2*time.Hour)) {
+		t.Errorf("circuit should be half-open after cooldown")
+	}
+}
+
+func TestLLM_CompleteChain_longRetryAfter(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	var breaker LLMCircuitBreaker
+
+	calls := make(map[string]int)
+	fn := _test_fakeProviders(calls, map[string][]error{
+		"xAI": {&LLMStatusError{StatusCode: 429, RetryAfter: 10 * time.Minute}},
+	})
+
+	st, msg := _test_newCompletion("Hi", 1)
+	err := LLM_CompleteChain([]LLMChainItem{{Provider: "xAI"}, {Provider: "OpenAI"}}, &policy, &breaker, st, msg, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls["xAI"] != 1 || calls["OpenAI"] != 1 {
+		t.Errorf("calls: %v", calls)
+	}
+}
+
+func TestLLMRetryPolicy_GetDelay(t *testing.T) {
+	policy := NewLLMRetryPolicy()
+	for retry := range 10 {
+		exp := min(policy.Base_delay<<retry, policy.Max_delay)
+		d := policy.GetDelay(retry, 0)
+		if d < exp/2 || d > exp {
+			t.Errorf("retry %d: delay %v not in [%v, %v]", retry, d, exp/2, exp)
+		}
+	}
+	if d := policy.GetDelay(0, 7*time.Second); d != 7*time.Second {
+		t.Errorf("Retry-After should win: %v", d)
+	}
+}
+
+func TestLLM_parseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if d := LLM_parseRetryAfter("30", now); d != 30*time.Second {
+		t.Errorf("seconds: %v", d)
+	}
+	if d := LLM_parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now); d != 90*time.Second {
+		t.Errorf("date: %v", d)
+	}
+	if d := LLM_parseRetryAfter("soon", now); d != 0 {
+		t.Errorf("invalid: %v", d)
+	}
+}
+
+func TestOpenAI_completion_Run_retryAfter(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(mockopenai.Response{StatusCode: 429, Body: "rate limit", Header: map[string]string{"Retry-After": "12"}})
+
+	st, msg := _test_newCompletion("Fail", 1)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, 0, nil, msg, nil)
+
+	transient, retry_after := LLM_isTransientError(err)
+	if !transient || retry_after != 12*time.Second {
+		t.Errorf("error: %v, transient: %v, retry after: %v", err, transient, retry_after)
+	}
+	if st.Out_StatusCode != 429 {
+		t.Errorf("status code: %d", st.Out_StatusCode)
+	}
+}
+
+func TestLLM_CompleteChain_continueLoop(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	policy.Max_retries = 1
+	var breaker LLMCircuitBreaker
+
+	//1st provider calls tool, then fails
+	srvA := mockopenai.NewServer()
+	defer srvA.Close()
+	srvA.Add(
+		mockopenai.Response{ToolCalls: []mockopenai.ToolCall{{Id: "call_1", Name: "Save", Arguments: `{}`}}},
+		mockopenai.Response{StatusCode: 503, Body: "overloaded"},
+		mockopenai.Response{StatusCode: 503, Body: "overloaded"},
+	)
+	srvB := mockopenai.NewServer()
+	defer srvB.Close()
+	srvB.Add(mockopenai.Response{Content: []string{"Saved."}})
+
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		return []byte(`{"Out_ok": true}`), nil
+	})
+
+	tools := []*ToolsOpenAI_completion_tool{NewToolsOpenAI_completion_tool("Save", "Saves it.")}
+	st, msg := _test_newCompletion("Save it", 5)
+	var num_user_deltas int
+	st.delta = func(m *ChatMsg) {
+		if m.Content.Msg != nil {
+			num_user_deltas++
+		}
+	}
+	urls := map[string]string{"A": srvA.URL, "B": srvB.URL}
+	err := LLM_CompleteChain([]LLMChainItem{{Provider: "A", Model: "a"}, {Provider: "B", Model: "b"}}, &policy, &breaker, st, msg, func(provider string, st *LLMComplete) error {
+		_, err := OpenAI_Complete(provider, urls[provider], "", st, app_port, tools, msg, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//request was retried, tool wasn't
+	if n := len(srvA.GetRequests()); n != 3 {
+		t.Errorf("requests to A: %d, expected 3", n)
+	}
+	if num_calls.Load() != 1 {
+		t.Errorf("tool calls: %d, expected 1", num_calls.Load())
+	}
+	if num_user_deltas != 1 {
+		t.Errorf("user message deltas: %d, expected 1", num_user_deltas)
+	}
+	if len(msg.out_flushed_cmdsGob) != 1 {
+		t.Errorf("cmds: %d, expected 1", len(msg.out_flushed_cmdsGob))
+	}
+
+	//B continues with tool result
+	reqs := srvB.GetRequests()
+	if len(reqs) != 1 {
+		t.Fatalf("requests to B: %d, expected 1", len(reqs))
+	}
+	last := reqs[0].Messages[len(reqs[0].Messages)-1]
+	if last["role"] != "tool" || last["tool_call_id"] != "call_1" || reqs[0].Model != "b" {
+		t.Errorf("B request: model %s, last message %v", reqs[0].Model, last)
+	}
+	if st.Out_answer != "Saved." || st.Out_usage.Provider != "B" {
+		t.Errorf("answer: '%s', provider: %s", st.Out_answer, st.Out_usage.Provider)
+	}
+}
+
+func TestLLM_CompleteChain_fatalError(t *testing.T) {
+	policy := _test_newRetryPolicy()
+	var breaker LLMCircuitBreaker
+
+	srvA := mockopenai.NewServer()
+	defer srvA.Close()
+	srvA.Add(mockopenai.Response{ToolCalls: []mockopenai.ToolCall{{Id: "call_1", Name: "Save", Arguments: `{}`}}})
+
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		return nil, errors.New("disk is full")
+	})
+
+	tools := []*ToolsOpenAI_completion_tool{NewToolsOpenAI_completion_tool("Save", "Saves it.")}
+	calls := make(map[string]int)
+	st, msg := _test_newCompletion("Save it", 5)
+	err := LLM_CompleteChain([]LLMChainItem{{Provider: "A"}, {Provider: "B"}}, &policy, &breaker, st, msg, func(provider string, st *LLMComplete) error {
+		calls[provider]++
+		if provider == "B" {
+			return nil
+		}
+		_, err := OpenAI_Complete(provider, srvA.URL, "", st, app_port, tools, msg, nil)
+		return err
+	})
+
+	var toolErr *LLMToolError
+	if !errors.As(err, &toolErr) || !strings.Contains(err.Error(), "disk is full") {
+		t.Errorf("error: %v", err)
+	}
+	if calls["B"] != 0 || num_calls.Load() != 1 {
+		t.Errorf("calls: %v, tool calls: %d", calls, num_calls.Load())
+	}
+
+	//budget
+	err = LLM_CompleteChain([]LLMChainItem{{Provider: "A"}, {Provider: "B"}}, &policy, &breaker, st, msg, func(provider string, st *LLMComplete) error {
+		calls[provider]++
+		return &LLMBudgetError{Period: "daily", Limit: 1}
+	})
+	var budgetErr *LLMBudgetError
+	if !errors.As(err, &budgetErr) || calls["B"] != 0 {
+		t.Errorf("error: %v, calls: %v", err, calls)
+	}
+}
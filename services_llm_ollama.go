@@ -0,0 +1,347 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type LLMOllamaModel struct {
+	Name               string
+	Size               int64 //bytes
+	Parameter_size     string
+	Quantization_level string
+
+	Capabilities   []string //"completion", "tools", "vision", "thinking", "embedding"
+	Context_length int
+
+	Loaded bool
+}
+
+// Ollama LLM settings. Models are filled by Device app.
+type LLMOllama struct {
+	Address    string
+	Port       int
+	OpenAI_api bool //use OpenAI-compatible endpoint(/v1) instead of native(/api/chat)
+
+	Models []*LLMOllamaModel
+
+	Stats []LLMMsgStats
+}
+
+func (oll *LLMOllama) Check() error {
+	if oll.Address == "" {
+		return LogsErrorf("Ollama address is empty")
+	}
+
+	return nil
+}
+
+func (oll *LLMOllama) FindModel(name string) *LLMOllamaModel {
+	name = strings.ToLower(name)
+	for _, model := range oll.Models {
+		if strings.ToLower(model.Name) == name {
+			return model
+		}
+	}
+	return nil
+}
+
+func (model *LLMOllamaModel) HasCapability(capability string) bool {
+	return slices.Contains(model.Capabilities, capability)
+}
+
+func (oll *LLMOllama) Complete(st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg) error {
+	err := oll.Check()
+	if err != nil {
+		return err
+	}
+
+	//unknown model(not refreshed yet) is tried anyway
+	mod := oll.FindModel(st.Out_usage.Model)
+	if mod != nil && len(tools) > 0 && !mod.HasCapability("tools") {
+		return LogsErrorf("model '%s' doesn't support tool calling", st.Out_usage.Model)
+	}
+
+	var stats []LLMMsgStats
+	if oll.OpenAI_api {
+		stats, err = OpenAI_Complete("Ollama", fmt.Sprintf("%s:%d/v1", oll.Address, oll.Port), "", st, app_port, tools, msg, nil)
+	} else {
+		stats, err = LLM_CompleteLoop("Ollama", st, app_port, tools, msg, nil, func(props OpenAI_completion_props, msgs *ChatMsgs, fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error) {
+			oprops := Ollama_buildProps(props, mod, msgs)
+
+			jsProps, err := LogsJsonMarshal(oprops)
+			if err != nil {
+				return OpenAIOut{}, -1, 0, -1, err
+			}
+			return Ollama_completion_Run(jsProps, fmt.Sprintf("%s:%d/api/chat", oll.Address, oll.Port), fnStreaming, msg)
+		})
+	}
+	oll.Stats = append(oll.Stats, stats...)
+	return err
+}
+
+type Ollama_tool_call_function struct {
+	Index     int             `json:"index,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"` //object, not string
+}
+type Ollama_tool_call struct {
+	Function Ollama_tool_call_function `json:"function"`
+}
+
+type Ollama_message struct {
+	Role       string             `json:"role"` //"system", "user", "assistant", "tool"
+	Content    string             `json:"content"`
+	Thinking   string             `json:"thinking,omitempty"`
+	Images     []string           `json:"images,omitempty"` //base64
+	Tool_calls []Ollama_tool_call `json:"tool_calls,omitempty"`
+	Tool_name  string             `json:"tool_name,omitempty"`
+}
+
+type Ollama_options struct {
+	Temperature float64 `json:"temperature"`
+	Top_p       float64 `json:"top_p,omitempty"`
+	Num_predict int     `json:"num_predict,omitempty"`
+	Num_ctx     int     `json:"num_ctx,omitempty"`
+	Seed        int     `json:"seed,omitempty"`
+}
+
+type Ollama_completion_props struct {
+	Model    string                         `json:"model"`
+	Messages []Ollama_message               `json:"messages"`
+	Tools    []*ToolsOpenAI_completion_tool `json:"tools,omitempty"`
+	Stream   bool                           `json:"stream"`
+	Think    *bool                          `json:"think,omitempty"`
+	Format   string                         `json:"format,omitempty"` //"json"
+
+	Options Ollama_options `json:"options"`
+}
+
+// converts messages into Ollama format: images are base64 without "data:" prefix, tool arguments are objects
+func Ollama_convertMessages(system string, msgs *ChatMsgs) []Ollama_message {
+	var messages []Ollama_message
+	if system != "" {
+		messages = append(messages, Ollama_message{Role: "system", Content: system})
+	}
+
+	for _, m := range msgs.Messages {
+		if m.Content.Msg != nil {
+			om := Ollama_message{Role: m.Content.Msg.Role}
+			for _, it := range m.Content.Msg.Content {
+				if it.Type == "text" {
+					om.Content += it.Text
+				}
+			}
+			om.Images = Ollama_convertImages(m.Content.Msg.Content)
+			messages = append(messages, om)
+		}
+
+		if m.Content.Calls != nil {
+			om := Ollama_message{Role: "assistant", Content: m.Content.Calls.Content}
+			if m.ReasoningSize > 0 && m.ReasoningSize <= len(om.Content) {
+				om.Thinking = om.Content[:m.ReasoningSize-len(ChatMsg_GetDivAfterReasoning())]
+				om.Content = om.Content[m.ReasoningSize:]
+			}
+
+			for i, call := range m.Content.Calls.Tool_calls {
+				args := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				om.Tool_calls = append(om.Tool_calls, Ollama_tool_call{Function: Ollama_tool_call_function{Index: i, Name: call.Function.Name, Arguments: args}})
+			}
+			messages = append(messages, om)
+		}
+
+		if m.Content.Result != nil {
+			om := Ollama_message{Role: "tool", Content: m.Content.Result.Content, Tool_name: m.Content.Result.Name}
+			if m.Content.ResultImages != nil {
+				om.Images = Ollama_convertImages(m.Content.ResultImages.Content)
+			}
+			messages = append(messages, om)
+		}
+	}
+	return messages
+}
+
+// extracts base64 data of images
+func Ollama_convertImages(content []OpenAI_completion_msg_Content) []string {
+	var images []string
+	for _, it := range content {
+		if it.Type != "image_url" || it.Image_url == nil {
+			continue
+		}
+		_, data, found := strings.Cut(it.Image_url.Url, ";base64,")
+		if found {
+			images = append(images, data)
+		}
+	}
+	return images
+}
+
+func Ollama_buildProps(props OpenAI_completion_props, model *LLMOllamaModel, msgs *ChatMsgs) Ollama_completion_props {
+	system := ""
+	if len(props.Messages) > 0 {
+		sys, ok := props.Messages[0].(OpenAI_completion_msgSystem)
+		if ok {
+			system = sys.Content
+		}
+	}
+
+	oprops := Ollama_completion_props{
+		Model:    props.Model,
+		Messages: Ollama_convertMessages(system, msgs),
+		Tools:    props.Tools,
+		Stream:   true,
+		Options: Ollama_options{
+			Temperature: props.Temperature,
+			Top_p:       props.Top_p,
+			Num_predict: props.Max_tokens,
+			Seed:        props.Seed,
+		},
+	}
+
+	if bytes.Contains(props.Response_format, []byte("json_object")) {
+		oprops.Format = "json"
+	}
+
+	if model != nil {
+		//default context(2K-4K) is too small for tools
+		oprops.Options.Num_ctx = min(model.Context_length, 32768)
+
+		if model.HasCapability("thinking") {
+			think := (props.Reasoning_effort != "")
+			oprops.Think = &think
+		}
+	}
+
+	return oprops
+}
+
+type Ollama_stream_chunk struct {
+	Message struct {
+		Content    string
+		Thinking   string
+		Tool_calls []Ollama_tool_call
+	}
+	Done bool
+
+	Prompt_eval_count int
+	Eval_count        int
+
+	Error string
+}
+
+// sends request to /api/chat and converts streamed answer(NDJSON) into OpenAIOut
+func Ollama_completion_Run(jsProps []byte, Completion_url string, fnStreaming func(msg *ChatMsg) bool, msg *AppsRouterMsg) (OpenAIOut, int, float64, float64, error) {
+	st := time.Now().UnixMicro()
+
+	req, err := http.NewRequest(http.MethodPost, Completion_url, bytes.NewReader(jsProps))
+	if LogsError(err) != nil {
+		return OpenAIOut{}, -1, 0, -1, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if LogsError(err) != nil {
+		return OpenAIOut{}, -1, 0, -1, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return OpenAIOut{}, res.StatusCode, 0, -1, LLM_NewStatusError(res, body)
+	}
+
+	var ret OpenAIOut
+	ret.Choices = append(ret.Choices, OpenAIOutChoice{})
+	out := &ret.Choices[0].Message
+
+	time_to_first_token := -1.0
+
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("error reading stream: %w", err)
+		}
+		eof := (err == io.EOF)
+
+		line = strings.TrimSpace(line)
+		if line != "" {
+			var chunk Ollama_stream_chunk
+			err = LogsJsonUnmarshal([]byte(line), &chunk)
+			if err != nil {
+				return OpenAIOut{}, res.StatusCode, 0, -1, err
+			}
+			if chunk.Error != "" {
+				return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("%s", chunk.Error)
+			}
+
+			out.Content += chunk.Message.Content
+			out.Reasoning_content += chunk.Message.Thinking
+			for _, call := range chunk.Message.Tool_calls {
+				args := string(call.Function.Arguments)
+				if args == "" || args == "null" {
+					args = "{}"
+				}
+				out.Tool_calls = append(out.Tool_calls, OpenAI_completion_msg_Content_ToolCall{Id: fmt.Sprintf("call_%d", len(out.Tool_calls)), Index: len(out.Tool_calls), Type: "function", Function: OpenAI_completion_msg_Content_ToolCall_Function{Name: call.Function.Name, Arguments: args}})
+			}
+
+			changed := (chunk.Message.Content != "" || chunk.Message.Thinking != "" || len(chunk.Message.Tool_calls) > 0)
+			if time_to_first_token < 0 && changed {
+				time_to_first_token = float64(time.Now().UnixMicro()-st) / 1000000
+			}
+
+			if changed && fnStreaming != nil {
+				var msgs ChatMsgs
+				msgs.AddAssistentCalls(out.Reasoning_content, out.Content, out.Tool_calls, nil, LLMMsgUsage{})
+				if !fnStreaming(msgs.Messages[0]) {
+					return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("interrupted")
+				}
+			}
+
+			if chunk.Done {
+				ret.Usage.Prompt_tokens = chunk.Prompt_eval_count
+				ret.Usage.Completion_tokens = chunk.Eval_count
+				break
+			}
+		}
+
+		if eof {
+			break
+		}
+		if !msg.GetContinue() {
+			return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("interrupted")
+		}
+	}
+
+	ret.Usage.Total_tokens = ret.Usage.Prompt_tokens + ret.Usage.Completion_tokens
+
+	dt := float64(time.Now().UnixMicro()-st) / 1000000
+	return ret, res.StatusCode, dt, time_to_first_token, nil
+}
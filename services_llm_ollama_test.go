@@ -0,0 +1,185 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// returns scripted NDJSON streams, one per request
+func _test_startFakeOllama(t *testing.T, streams ...[]string) (string, int, func() []Ollama_completion_props) {
+	var lock sync.Mutex
+	var reqs []Ollama_completion_props
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var props Ollama_completion_props
+		json.Unmarshal(body, &props)
+
+		lock.Lock()
+		i := len(reqs)
+		reqs = append(reqs, props)
+		lock.Unlock()
+
+		if r.URL.Path != "/api/chat" || i >= len(streams) {
+			http.Error(w, `{"error":"no scripted response"}`, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, chunk := range streams[i] {
+			fmt.Fprintf(w, "%s\n", chunk)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	//"http://127.0.0.1:port"
+	addr, port, _ := strings.Cut(strings.TrimPrefix(srv.URL, "http://"), ":")
+	p, _ := strconv.Atoi(port)
+
+	return "http://" + addr, p,
+		func() []Ollama_completion_props {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]Ollama_completion_props{}, reqs...)
+		}
+}
+
+func TestOllama_Complete_toolCalls(t *testing.T) {
+	address, port, getRequests := _test_startFakeOllama(t,
+		[]string{
+			`{"message":{"role":"assistant","content":"","thinking":"Need to sum."},"done":false}`,
+			`{"message":{"role":"assistant","content":"Let me sum.","tool_calls":[{"function":{"name":"Sum","arguments":{"A":1,"B":2}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":100,"eval_count":20}`,
+		},
+		[]string{
+			`{"message":{"role":"assistant","content":"Result "},"done":false}`,
+			`{"message":{"role":"assistant","content":"is 3."},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":150,"eval_count":5}`,
+		},
+	)
+
+	var lock sync.Mutex //fake app runs in other goroutine
+	var gotParams []string
+	app_port, _ := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		lock.Lock()
+		gotParams = append(gotParams, toolName+string(paramsJs))
+		lock.Unlock()
+		return []byte(`{"A": 1, "B": 2, "Out_sum": 3}`), nil
+	})
+
+	oll := &LLMOllama{Address: address, Port: port, Models: []*LLMOllamaModel{{Name: "test-model", Capabilities: []string{"completion", "tools", "thinking"}, Context_length: 131072}}}
+
+	tool := NewToolsOpenAI_completion_tool("Sum", "Sums two numbers.")
+	tool.Function.Parameters.AddParam("A", "float64", "First number")
+	tool.Function.Parameters.AddParam("B", "float64", "Second number")
+	tools := []*ToolsOpenAI_completion_tool{tool}
+
+	st, msg := _test_newCompletion("What is 1+2?", 5)
+	st.Reasoning_effort = "low"
+
+	err := oll.Complete(st, app_port, tools, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//1st request
+	reqs := getRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("requests: %d, expected 2", len(reqs))
+	}
+	r0 := reqs[0]
+	if r0.Options.Num_ctx != 32768 || r0.Think == nil || !*r0.Think || !r0.Stream {
+		t.Errorf("options: %+v, think: %v", r0.Options, r0.Think)
+	}
+	if len(r0.Messages) != 2 || r0.Messages[0].Role != "system" || r0.Messages[0].Content != "You are a test." || r0.Messages[1].Content != "What is 1+2?" {
+		t.Errorf("messages: %+v", r0.Messages)
+	}
+	if len(r0.Tools) != 1 || r0.Tools[0].Function.Name != "Sum" {
+		t.Errorf("tools: %+v", r0.Tools)
+	}
+
+	//tool was called
+	lock.Lock()
+	if len(gotParams) != 1 || gotParams[0] != `Sum{"A":1,"B":2}` {
+		t.Errorf("tool calls: %v", gotParams)
+	}
+	lock.Unlock()
+
+	//2nd request: system, user, assistant(thinking, tool_calls), tool
+	msgs := reqs[1].Messages
+	if len(msgs) != 4 {
+		t.Fatalf("messages in 2nd request: %d, expected 4: %+v", len(msgs), msgs)
+	}
+	as := msgs[2]
+	if as.Role != "assistant" || as.Thinking != "Need to sum." || as.Content != "Let me sum." || len(as.Tool_calls) != 1 || string(as.Tool_calls[0].Function.Arguments) != `{"A":1,"B":2}` {
+		t.Errorf("assistant message: %+v", as)
+	}
+	if msgs[3].Role != "tool" || msgs[3].Tool_name != "Sum" || msgs[3].Content != "3" {
+		t.Errorf("tool result: %+v", msgs[3])
+	}
+
+	//answer
+	if st.Out_answer != "Result is 3." {
+		t.Errorf("answer: '%s'", st.Out_answer)
+	}
+	if st.Out_usage.Prompt_tokens != 250 || st.Out_usage.Completion_tokens != 25 {
+		t.Errorf("usage: %+v", st.Out_usage)
+	}
+}
+
+func TestOllama_Complete_noToolsCapability(t *testing.T) {
+	address, port, getRequests := _test_startFakeOllama(t)
+
+	oll := &LLMOllama{Address: address, Port: port, Models: []*LLMOllamaModel{{Name: "test-model", Capabilities: []string{"completion"}}}}
+
+	tools := []*ToolsOpenAI_completion_tool{NewToolsOpenAI_completion_tool("Sum", "Sums two numbers.")}
+
+	st, msg := _test_newCompletion("What is 1+2?", 5)
+	err := oll.Complete(st, 0, tools, msg)
+	if err == nil || !strings.Contains(err.Error(), "tool calling") {
+		t.Errorf("error: %v", err)
+	}
+	if len(getRequests()) != 0 {
+		t.Errorf("request was sent")
+	}
+}
+
+func TestOllama_completion_Run_error(t *testing.T) {
+	address, port, _ := _test_startFakeOllama(t,
+		[]string{
+			`{"message":{"role":"assistant","content":"Hi"},"done":false}`,
+			`{"error":"model runner has unexpectedly stopped"}`,
+		},
+	)
+
+	oll := &LLMOllama{Address: address, Port: port}
+
+	st, msg := _test_newCompletion("Hi", 1)
+	err := oll.Complete(st, 0, nil, msg)
+	if err == nil || err.Error() != "model runner has unexpectedly stopped" {
+		t.Errorf("error: %v", err)
+	}
+}
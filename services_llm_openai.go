@@ -141,7 +141,7 @@ func (oai *LLMOpenai) GetPricingString(model string) string {
 	return fmt.Sprintf("model %s not found", model)
 }
 
-func (model *LLMOpenaiLanguageModel) GetTextPrice(in, reason, cached, out int, sources int) (float64, float64, float64, float64, float64) {
+func (model *LLMOpenaiLanguageModel) GetTextPrice(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64) {
 
 	convert_to_dolars := float64(10000)
 
@@ -150,7 +150,7 @@ func (model *LLMOpenaiLanguageModel) GetTextPrice(in, reason, cached, out int, s
 	Cached_price := float64(model.Cached_prompt_text_token_price) / convert_to_dolars / 1000000
 	Output_price := float64(model.Completion_text_token_price) / convert_to_dolars / 1000000
 
-	return float64(in) * Input_price, float64(reason) * Reason_price, float64(cached) * Cached_price, float64(out) * Output_price, 0
+	return float64(in) * Input_price, float64(reason) * Reason_price, float64(cached) * Cached_price, float64(out) * Output_price, 0, 0
 }
 
 func (oai *LLMOpenai) Complete(st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg) error {
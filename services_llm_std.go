@@ -11,8 +11,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,6 +20,9 @@ type OpenAIOutChoice_Message struct {
 	Content           string //Final answer
 	Reasoning_content string
 
+	Reasoning_signature string   //Anthropic: thinking must be send back with signature
+	Reasoning_redacted  []string //Anthropic: encrypted thinking
+
 	Tool_calls []OpenAI_completion_msg_Content_ToolCall
 }
 
@@ -36,6 +39,8 @@ type OpenAIOut_Usage struct {
 	Total_tokens        int
 	Num_sources_used    int
 
+	Input_cache_write_tokens int //Anthropic
+
 	Completion_tokens_details OpenAIOut_UsageDetails
 }
 
@@ -137,7 +142,7 @@ func OpenAI_completion_Run(jsProps []byte, Completion_url string, api_key string
 		// Check response status
 		if res.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(res.Body)
-			return OpenAIOut{}, -1, 0, -1, LogsErrorf("unexpected status code: %d, body: %s", res.StatusCode, string(body))
+			return OpenAIOut{}, res.StatusCode, 0, -1, LLM_NewStatusError(res, body)
 		}
 
 		// Read streaming response
@@ -212,8 +217,8 @@ func OpenAI_completion_Run(jsProps []byte, Completion_url string, api_key string
 				//reasoning content
 				ret.Choices[0].Message.Reasoning_content += choice.Delta.Reasoning_content
 
-				fmt.Printf(choice.Delta.Reasoning_content)
-				fmt.Printf(choice.Delta.Content)
+				fmt.Print(choice.Delta.Reasoning_content)
+				fmt.Print(choice.Delta.Content)
 
 				//add tools
 				for _, tool_call := range choice.Delta.Tool_calls {
@@ -250,7 +255,7 @@ func OpenAI_completion_Run(jsProps []byte, Completion_url string, api_key string
 		}
 
 		if res.StatusCode != http.StatusOK {
-			return OpenAIOut{}, res.StatusCode, 0, -1, LogsErrorf("statusCode %d != %d, response: %s", res.StatusCode, http.StatusOK, string(js))
+			return OpenAIOut{}, res.StatusCode, 0, -1, LLM_NewStatusError(res, js)
 		}
 
 		if len(js) == 0 {
@@ -276,57 +281,79 @@ type LLMMsgStats struct {
 	Usage    LLMMsgUsage
 }
 
-func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg, fnGetTextPrice func(in, reason, cached, out int, sources int) (float64, float64, float64, float64, float64)) ([]LLMMsgStats, error) {
-
-	//Messages
-	var msgs ChatMsgs
-	if len(st.PreviousMessages) > 0 {
-		err := LogsJsonUnmarshal(st.PreviousMessages, &msgs)
+func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg, fnGetTextPrice func(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64)) ([]LLMMsgStats, error) {
+	return LLM_CompleteLoop(Provider, st, app_port, tools, msg, fnGetTextPrice, func(props OpenAI_completion_props, msgs *ChatMsgs, fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error) {
+		jsProps, err := LogsJsonMarshal(props)
 		if err != nil {
-			return nil, err
+			return OpenAIOut{}, -1, 0, -1, err
 		}
-	}
+		return OpenAI_completion_Run(jsProps, OpenAI_url, API_key, fnStreaming, msg)
+	})
+}
 
-	if st.UserMessage != "" || len(st.UserFiles) > 0 {
-		m1, err := msgs.AddUserMessage(st.UserMessage, st.UserFiles)
-		if err != nil {
-			return nil, err
+// progress of tool-call loop, which is continued by next provider
+type llmCompleteResume struct {
+	msgs               ChatMsgs
+	seed               int
+	iter               int
+	last_final_msg     string
+	last_reasoning_msg string
+	last_citations     []string
+}
+
+// Tool-calling loop shared by all providers. fnRun sends one request(props are in OpenAI format, msgs are original messages) and returns answer converted into OpenAIOut.
+func LLM_CompleteLoop(Provider string, st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg, fnGetTextPrice func(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64), fnRun func(props OpenAI_completion_props, msgs *ChatMsgs, fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error)) ([]LLMMsgStats, error) {
+
+	var msgs ChatMsgs
+	seed := 1
+	iter := 0
+	last_final_msg := ""
+	last_reasoning_msg := ""
+	var last_citations []string
+
+	if st.resume != nil {
+		//continue after failed provider
+		msgs = st.resume.msgs
+		seed = st.resume.seed
+		iter = st.resume.iter
+		last_final_msg = st.resume.last_final_msg
+		last_reasoning_msg = st.resume.last_reasoning_msg
+		last_citations = st.resume.last_citations
+		st.resume = nil
+	} else {
+		//Messages
+		if len(st.PreviousMessages) > 0 {
+			err := LogsJsonUnmarshal(st.PreviousMessages, &msgs)
+			if err != nil {
+				return nil, err
+			}
 		}
-		if st.delta != nil {
-			st.delta(m1)
+
+		if st.UserMessage != "" || len(st.UserFiles) > 0 {
+			m1, err := msgs.AddUserMessage(st.UserMessage, st.UserFiles)
+			if err != nil {
+				return nil, err
+			}
+			if st.delta != nil {
+				st.delta(m1)
+			}
 		}
-	}
 
-	seed := 1
-	if len(msgs.Messages) > 0 {
-		seed = msgs.Messages[len(msgs.Messages)-1].Seed
-		if seed <= 0 {
-			seed = 1
+		if len(msgs.Messages) > 0 {
+			seed = msgs.Messages[len(msgs.Messages)-1].Seed
+			if seed <= 0 {
+				seed = 1
+			}
 		}
 	}
 
 	var ret_stats []LLMMsgStats
 
-	last_final_msg := ""
-	last_reasoning_msg := ""
-	var last_citations []string
-
-	iter := 0
 	for iter < st.Max_iteration {
 		//convert msgs to OpenAI
 		var messages []interface{}
 		messages = append(messages, OpenAI_completion_msgSystem{Role: "system", Content: st.SystemMessage})
-		for _, msg := range msgs.Messages {
-			if msg.Content.Msg != nil {
-				messages = append(messages, msg.Content.Msg)
-			}
-			if msg.Content.Calls != nil {
-				messages = append(messages, msg.Content.Calls)
-			}
-			if msg.Content.Result != nil {
-				messages = append(messages, msg.Content.Result)
-			}
-		}
+		messages = append(messages, OpenAI_convertMessages(&msgs)...)
 
 		props := OpenAI_completion_props{
 			Stream:         true,
@@ -335,7 +362,7 @@ func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLM
 			Seed:  seed,
 			Model: st.Out_usage.Model,
 
-			Tools:    tools,
+			Tools:    ToolsSchema_ForModel(tools),
 			Messages: messages,
 
 			Temperature:       st.Temperature,
@@ -365,14 +392,19 @@ func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLM
 			return msg.GetContinue()
 		}
 
-		jsProps, err := LogsJsonMarshal(props)
-		if err != nil {
-			return nil, err
-		}
-		out, status, dt, time_to_first_token, err := OpenAI_completion_Run(jsProps, OpenAI_url, API_key, fnStreaming, msg)
+		out, status, dt, time_to_first_token, err := LLM_RunRequest(Provider, st.retry, st.breaker, msg, func() (OpenAIOut, int, float64, float64, error) {
+			if st.cassette != nil {
+				return st.cassette.Run(props, func(fnStreaming func(msg *ChatMsg) bool) (OpenAIOut, int, float64, float64, error) {
+					return fnRun(props, &msgs, fnStreaming)
+				}, fnStreaming)
+			}
+			return fnRun(props, &msgs, fnStreaming)
+		})
 		st.Out_StatusCode = status
 		if err != nil {
-			return nil, err
+			//nothing from this turn was used, next provider can continue
+			st.resume = &llmCompleteResume{msgs: msgs, seed: seed, iter: iter, last_final_msg: last_final_msg, last_reasoning_msg: last_reasoning_msg, last_citations: last_citations}
+			return ret_stats, &LLMRequestError{Provider: Provider, Err: err}
 		}
 
 		if !msg.GetContinue() {
@@ -385,6 +417,7 @@ func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLM
 			{
 				usage.Prompt_tokens = out.Usage.Prompt_tokens
 				usage.Input_cached_tokens = out.Usage.Input_cached_tokens
+				usage.Input_cache_write_tokens = out.Usage.Input_cache_write_tokens
 				usage.Completion_tokens = out.Usage.Completion_tokens
 				usage.Reasoning_tokens = out.Usage.Completion_tokens_details.Reasoning_tokens
 				usage.Num_sources_used = out.Usage.Num_sources_used
@@ -396,7 +429,7 @@ func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLM
 				usage.DTime = dt
 
 				if fnGetTextPrice != nil {
-					usage.Prompt_price, usage.Reasoning_price, usage.Input_cached_price, usage.Completion_price, usage.Sources_price = fnGetTextPrice(usage.Prompt_tokens, usage.Reasoning_tokens, usage.Input_cached_tokens, usage.Completion_tokens, usage.Num_sources_used)
+					usage.Prompt_price, usage.Reasoning_price, usage.Input_cached_price, usage.Completion_price, usage.Sources_price, usage.Input_cache_write_price = fnGetTextPrice(usage.Prompt_tokens, usage.Reasoning_tokens, usage.Input_cached_tokens, usage.Completion_tokens, usage.Num_sources_used, usage.Input_cache_write_tokens)
 				}
 
 				//add
@@ -407,6 +440,8 @@ func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLM
 
 			calls := out.Choices[0].Message.Tool_calls
 			m2 := msgs.AddAssistentCalls(out.Choices[0].Message.Reasoning_content, out.Choices[0].Message.Content, calls, out.Citations, usage)
+			m2.Reasoning_signature = out.Choices[0].Message.Reasoning_signature
+			m2.Reasoning_redacted = out.Choices[0].Message.Reasoning_redacted
 			if st.delta != nil {
 				st.delta(m2)
 			}
@@ -415,77 +450,43 @@ func OpenAI_Complete(Provider string, OpenAI_url string, API_key string, st *LLM
 			last_reasoning_msg = out.Choices[0].Message.Reasoning_content
 			last_citations = out.Citations
 
-			for _, call := range calls {
-				var result string
+			//call them
+			callsRes := _OpenAI_runToolCalls(calls, tools, st.Max_parallel_tools, app_port, msg)
+			if !msg.GetContinue() {
+				return nil, nil
+			}
+
+			for i, call := range calls {
+				//unknown tool or invalid arguments, model can fix them in next iteration
+				if callsRes[i].invalid != "" {
+					res_msg := msgs.AddCallResult(call.Function.Name, call.Id, callsRes[i].invalid)
+					if st.delta != nil {
+						st.delta(res_msg)
+					}
+					continue
+				}
 
-				//call it
-				resJs, uiGob, cmdsGob, err := _ToolsCaller_CallBuild(app_port, msg.msg_id, 0, call.Function.Name, []byte(call.Function.Arguments))
+				resJs, uiGob, cmdsGob, err := callsRes[i].resJs, callsRes[i].uiGob, callsRes[i].cmdsGob, callsRes[i].err
 				if err != nil {
-					return nil, err
+					return ret_stats, &LLMToolError{Tool: call.Function.Name, Err: err}
 				}
 				//resJs, tool_ui, err := CallToolApp(st.AppName, call.Function.Name, []byte(call.Function.Arguments), caller)
 
 				//add cmds
 				msg.out_flushed_cmdsGob = append(msg.out_flushed_cmdsGob, cmdsGob)
 
-				resMap := make(map[string]interface{})
-				err = LogsJsonUnmarshal(resJs, &resMap)
-				if err != nil {
-					return nil, err
-				}
-
-				//Out_ -> result
-				{
-					num_outs := 0
-					for nm := range resMap {
-						if strings.HasPrefix(strings.ToLower(nm), "out") {
-							num_outs++
-						}
-					}
-					for nm, val := range resMap {
-						if strings.HasPrefix(strings.ToLower(nm), "out") {
-							var vv string
-							var tp string
-							switch v := val.(type) {
-							case string:
-								tp = "string"
-								vv = v
-							case float64:
-								tp = "float64"
-								vv = strconv.FormatFloat(v, 'f', -1, 64)
-							case int:
-								tp = "int"
-								vv = strconv.FormatInt(int64(v), 10)
-							case int64:
-								tp = "int64"
-								vv = strconv.FormatInt(int64(v), 10)
-							default:
-								tp = "unknown"
-								vv = fmt.Sprintf("%v", v)
-							}
-
-							if num_outs == 1 {
-								result = vv
-								break
-							} else {
-								result += fmt.Sprintf("%s(%s): %s\n", nm, tp, vv)
-							}
-						}
-					}
-				}
-
 				var tool_ui UI
 				LogsGobUnmarshal(uiGob, &tool_ui)
-
 				hasUI := tool_ui.Is()
-				if hasUI {
-					if result != "" {
-						result += "\n"
-					}
-					result += "Successfully shown on screen."
+
+				//Out_ + UI summary -> result
+				result, images, err := LLMToolResult_build(resJs, &tool_ui, st.vision, st.app_folder)
+				if err != nil {
+					return ret_stats, &LLMToolError{Tool: call.Function.Name, Err: err}
 				}
 
 				res_msg := msgs.AddCallResult(call.Function.Name, call.Id, result)
+				res_msg.Content.ResultImages = images
 				if hasUI {
 					res_msg.UI_func = call.Function.Name
 					res_msg.UI_paramsJs = string(resJs)
@@ -617,6 +618,44 @@ func OpenAI_genImage_Run(jsProps []byte, Completion_url string, api_key string)
 	return out, res.StatusCode, tm, nil
 }
 
+// converts messages into OpenAI format. Tool message can't have images, so they are sent in user message after all results of the turn.
+func OpenAI_convertMessages(msgs *ChatMsgs) []interface{} {
+	var messages []interface{}
+	var images []*OpenAI_completion_msgContent
+	flushImages := func() {
+		for _, img := range images {
+			messages = append(messages, img)
+		}
+		images = nil
+	}
+
+	for _, msg := range msgs.Messages {
+		if msg.Content.Result == nil {
+			flushImages()
+		}
+
+		if msg.Content.Msg != nil {
+			messages = append(messages, msg.Content.Msg)
+		}
+		if msg.Content.Calls != nil {
+			messages = append(messages, msg.Content.Calls)
+		}
+		if msg.Content.Result != nil {
+			messages = append(messages, msg.Content.Result)
+
+			if msg.Content.ResultImages != nil {
+				img := &OpenAI_completion_msgContent{Role: "user"}
+				img.AddText(fmt.Sprintf("Images shown by tool '%s'(%s):", msg.Content.Result.Name, msg.Content.Result.Tool_call_id))
+				img.Content = append(img.Content, msg.Content.ResultImages.Content...)
+				images = append(images, img)
+			}
+		}
+	}
+	flushImages()
+
+	return messages
+}
+
 func (msgs *ChatMsgs) AddUserMessage(text string, files []string) (*ChatMsg, error) {
 	content := OpenAI_content{}
 	content.Msg = &OpenAI_completion_msgContent{Role: "user"}
@@ -652,6 +691,81 @@ func (msgs *ChatMsgs) AddAssistentCalls(reasoning_text, final_text string, tool_
 	msgs.Messages = append(msgs.Messages, msg)
 	return msg
 }
+
+type _OpenAI_toolCallResult struct {
+	invalid string //result for model, tool wasn't called
+
+	resJs   []byte
+	uiGob   []byte
+	cmdsGob []byte
+	err     error
+}
+
+// runs turn's tool calls concurrently(maxParallel at once). [sequential] tool waits for previous calls and runs alone. Results are in calls' order.
+func _OpenAI_runToolCalls(calls []OpenAI_completion_msg_Content_ToolCall, tools []*ToolsOpenAI_completion_tool, maxParallel int, app_port int, msg *AppsRouterMsg) []_OpenAI_toolCallResult {
+	if maxParallel <= 0 {
+		maxParallel = LLMComplete_defaultParallelTools
+	}
+
+	results := make([]_OpenAI_toolCallResult, len(calls))
+	slots := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+
+	for i, call := range calls {
+		res := &results[i]
+
+		var argsJs []byte
+		argsJs, res.invalid = _OpenAI_validateToolCall(tools, call.Function)
+		if res.invalid != "" {
+			continue
+		}
+
+		sequential := false
+		for _, tool := range tools {
+			if tool.Function.Name == call.Function.Name {
+				sequential = tool.IsSequential()
+			}
+		}
+		if sequential {
+			wg.Wait()
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if !msg.GetContinue() {
+				return //stopped, don't start
+			}
+			res.resJs, res.uiGob, res.cmdsGob, res.err = _ToolsCaller_CallTool(app_port, msg, call.Function.Name, argsJs)
+		}()
+
+		if sequential {
+			wg.Wait()
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
+// returns arguments for tool or result for model, if tool is unknown or arguments are invalid
+func _OpenAI_validateToolCall(tools []*ToolsOpenAI_completion_tool, call OpenAI_completion_msg_Content_ToolCall_Function) ([]byte, string) {
+	for _, tool := range tools {
+		if tool.Function.Name == call.Name {
+			argsJs, verrs := tool.ValidateArguments(call.Arguments)
+			if len(verrs) > 0 {
+				return nil, ToolsSchema_ValidationResult(call.Name, verrs)
+			}
+			return argsJs, ""
+		}
+	}
+	return nil, ToolsSchema_UnknownToolResult(call.Name, tools)
+}
+
 func (msgs *ChatMsgs) AddCallResult(tool_name string, tool_use_id string, result string) *ChatMsg {
 	content := OpenAI_content{}
 	content.Result = &OpenAI_completion_msgResult{Role: "tool", Tool_call_id: tool_use_id, Name: tool_name, Content: result}
@@ -0,0 +1,555 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"skyalt/mockopenai"
+)
+
+// fake app process, which answers 'build' calls from OpenAI_Complete()
+func _test_startFakeApp(t *testing.T, fnBuild func(toolName string, paramsJs []byte) ([]byte, error)) (int, *atomic.Int64) {
+	var num_calls atomic.Int64
+
+	conn, app := _test_connectApp(t, "FakeApp")
+
+	go func() {
+		for {
+			cl, err := app.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer cl.Destroy()
+
+				cmd, err := cl.ReadArray()
+				if err != nil || string(cmd) != "build" {
+					return
+				}
+				cl.ReadInt() //msg_id
+				cl.ReadInt() //ui_uid
+				toolName, _ := cl.ReadArray()
+				paramsJs, _ := cl.ReadArray()
+
+				num_calls.Add(1)
+				outJs, err := fnBuild(string(toolName), paramsJs)
+				var errBytes []byte
+				if err != nil {
+					errBytes = []byte(err.Error())
+				}
+				uiGob, _ := LogsGobMarshal(&UI{})
+
+				cl.WriteArray(errBytes)
+				cl.WriteArray(outJs)
+				cl.WriteArray(uiGob)
+				cl.WriteArray(nil) //cmds
+			}()
+		}
+	}()
+
+	return conn.id, &num_calls
+}
+
+// tools without schema of arguments, any arguments are accepted
+func _test_newTools(names ...string) []*ToolsOpenAI_completion_tool {
+	var tools []*ToolsOpenAI_completion_tool
+	for _, name := range names {
+		tool := NewToolsOpenAI_completion_tool(name, "")
+		tool.Function.Parameters.AdditionalProperties = true
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+func _test_newCompletion(user_msg string, max_iteration int) (*LLMComplete, *AppsRouterMsg) {
+	st := NewLLMCompletion()
+	st.SystemMessage = "You are a test."
+	st.UserMessage = user_msg
+	st.Max_iteration = max_iteration
+	st.Out_usage.Model = "test-model"
+
+	msg := NewAppsRouterMsg(1, "test", nil, nil)
+	st.msg = msg
+	return st, msg
+}
+
+func _test_getResults(t *testing.T, st *LLMComplete) []string {
+	var msgs ChatMsgs
+	err := LogsJsonUnmarshal(st.Out_messages, &msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, m := range msgs.Messages {
+		if m.Content.Result != nil {
+			results = append(results, m.Content.Result.Content)
+		}
+	}
+	return results
+}
+
+func TestOpenAI_Complete_toolCalls(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(
+		mockopenai.Response{
+			Reasoning: []string{"Need to ", "sum."},
+			ToolCalls: []mockopenai.ToolCall{{Id: "call_1", Name: "Sum", Arguments: `{"A": 1, "B": 2}`}},
+			Usage:     &mockopenai.Usage{Prompt_tokens: 10, Completion_tokens: 5},
+		},
+		mockopenai.Response{
+			Content:   []string{"Result ", "is ", "3."},
+			Citations: []string{"https://example.com"},
+			Usage:     &mockopenai.Usage{Prompt_tokens: 20, Completion_tokens: 3},
+		},
+	)
+
+	var lock sync.Mutex //fake app runs in other goroutine
+	var gotParams string
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		if toolName != "Sum" {
+			return nil, errors.New("unknown tool " + toolName)
+		}
+		lock.Lock()
+		gotParams = string(paramsJs)
+		lock.Unlock()
+		return []byte(`{"A": 1, "B": 2, "Out_sum": 3}`), nil
+	})
+
+	st, msg := _test_newCompletion("What is 1+2?", 5)
+
+	var num_deltas int
+	st.delta = func(m *ChatMsg) {
+		num_deltas++
+	}
+
+	stats, err := OpenAI_Complete("test", srv.URL, "secret", st, app_port, _test_newTools("Sum"), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.GetRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("requests: %d, expected 2", len(reqs))
+	}
+	if reqs[0].Authorization != "Bearer secret" {
+		t.Errorf("authorization: '%s'", reqs[0].Authorization)
+	}
+	if reqs[0].Model != "test-model" || !reqs[0].Stream {
+		t.Errorf("request model '%s', stream %v", reqs[0].Model, reqs[0].Stream)
+	}
+	if num_calls.Load() != 1 {
+		t.Errorf("tool calls: %d, expected 1", num_calls.Load())
+	}
+	lock.Lock()
+	if gotParams != `{"A": 1, "B": 2}` {
+		t.Errorf("tool params: %s", gotParams)
+	}
+	lock.Unlock()
+
+	//2nd request must have tool result
+	last := reqs[1].Messages[len(reqs[1].Messages)-1]
+	if last["role"] != "tool" || last["content"] != "3" || last["tool_call_id"] != "call_1" {
+		t.Errorf("last message of 2nd request: %v", last)
+	}
+
+	if st.Out_answer != "Result is 3." {
+		t.Errorf("answer: '%s'", st.Out_answer)
+	}
+	if len(st.Out_citation_urls) != 1 || st.Out_citation_urls[0] != "https://example.com" {
+		t.Errorf("citations: %v", st.Out_citation_urls)
+	}
+	if len(stats) != 2 {
+		t.Errorf("stats: %d, expected 2", len(stats))
+	}
+	if st.Out_usage.Prompt_tokens != 30 || st.Out_usage.Completion_tokens != 8 {
+		t.Errorf("usage: prompt %d, completion %d", st.Out_usage.Prompt_tokens, st.Out_usage.Completion_tokens)
+	}
+	if num_deltas == 0 {
+		t.Errorf("no deltas")
+	}
+}
+
+func TestOpenAI_Complete_maxIteration(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	for range 3 {
+		srv.Add(mockopenai.Response{ToolCalls: []mockopenai.ToolCall{{Id: "call", Name: "Loop", Arguments: `{}`}}})
+	}
+
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		return []byte(`{"Out_ok": true}`), nil
+	})
+
+	st, msg := _test_newCompletion("Loop forever", 2)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, _test_newTools("Loop"), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(srv.GetRequests()); n != 2 {
+		t.Errorf("requests: %d, expected 2", n)
+	}
+	if srv.NumPending() != 1 {
+		t.Errorf("pending responses: %d, expected 1", srv.NumPending())
+	}
+	if num_calls.Load() != 2 {
+		t.Errorf("tool calls: %d, expected 2", num_calls.Load())
+	}
+}
+
+func TestOpenAI_Complete_outResult(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(
+		mockopenai.Response{ToolCalls: []mockopenai.ToolCall{
+			{Id: "call_1", Name: "Single", Arguments: `{}`},
+			{Id: "call_2", Name: "Multi", Arguments: `{}`},
+		}},
+		mockopenai.Response{Content: []string{"done"}},
+	)
+
+	app_port, _ := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		switch toolName {
+		case "Single":
+			return []byte(`{"Input": "ignored", "Out_text": "hello"}`), nil
+		case "Multi":
+			return []byte(`{"Input": "ignored", "Out_name": "Milan", "Out_age": 42}`), nil
+		}
+		return nil, errors.New("unknown tool " + toolName)
+	})
+
+	st, msg := _test_newCompletion("Call tools", 3)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, _test_newTools("Single", "Multi"), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := _test_getResults(t, st)
+	if len(results) != 2 {
+		t.Fatalf("results: %d, expected 2", len(results))
+	}
+
+	//single Out_ attribute is passed as raw value
+	if results[0] != "hello" {
+		t.Errorf("single result: '%s'", results[0])
+	}
+
+	//more Out_ attributes are passed as JSON object, inputs are ignored
+	if results[1] != `{"Out_age":42,"Out_name":"Milan"}` {
+		t.Errorf("multi result: '%s'", results[1])
+	}
+}
+
+func TestOpenAI_Complete_invalidArguments(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(
+		mockopenai.Response{ToolCalls: []mockopenai.ToolCall{
+			{Id: "call_1", Name: "Sum", Arguments: `{"A": "one", "C": 2}`},
+			{Id: "call_2", Name: "Multiply", Arguments: `{"A": 1, "B": 2}`},
+		}},
+		mockopenai.Response{ToolCalls: []mockopenai.ToolCall{{Id: "call_3", Name: "Sum", Arguments: `{"A": "1", "B": 2}`}}},
+		mockopenai.Response{Content: []string{"done"}},
+	)
+
+	var lock sync.Mutex
+	var gotParams []string
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		lock.Lock()
+		gotParams = append(gotParams, string(paramsJs))
+		lock.Unlock()
+		return []byte(`{"Out_sum": 3}`), nil
+	})
+
+	tool := NewToolsOpenAI_completion_tool("Sum", "")
+	tool.Function.Parameters.AddParam("A", "float64", "First number")
+	tool.Function.Parameters.AddParam("B", "float64", "Second number")
+
+	st, msg := _test_newCompletion("What is 1+2?", 5)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, []*ToolsOpenAI_completion_tool{tool}, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//invalid calls and unknown tools are not called, model gets errors
+	if num_calls.Load() != 1 {
+		t.Errorf("tool calls: %d, expected 1", num_calls.Load())
+	}
+	lock.Lock()
+	if len(gotParams) != 1 || gotParams[0] != `{"A":1,"B":2}` {
+		t.Errorf("tool params: %v", gotParams)
+	}
+	lock.Unlock()
+
+	results := _test_getResults(t, st)
+	if len(results) != 3 {
+		t.Fatalf("results: %d, expected 3", len(results))
+	}
+	for _, want := range []string{`"path":"A","error":"expected number, got string"`, `"path":"B","error":"missing required argument"`, `"path":"C","error":"unknown argument`} {
+		if !strings.Contains(results[0], want) {
+			t.Errorf("result '%s' doesn't contain '%s'", results[0], want)
+		}
+	}
+	if !strings.Contains(results[1], `Unknown tool 'Multiply'`) || !strings.Contains(results[1], `"available":["Sum"]`) {
+		t.Errorf("unknown tool result: '%s'", results[1])
+	}
+	if results[2] != "3" {
+		t.Errorf("fixed call result: '%s'", results[2])
+	}
+}
+
+func TestOpenAI_Complete_parallelToolCalls(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(
+		mockopenai.Response{ToolCalls: []mockopenai.ToolCall{
+			{Id: "call_1", Name: "Report", Arguments: `{"N": 1}`},
+			{Id: "call_2", Name: "Report", Arguments: `{"N": 2}`},
+			{Id: "call_3", Name: "Report", Arguments: `{"N": 3}`},
+			{Id: "call_4", Name: "Save", Arguments: `{"N": 4}`},
+			{Id: "call_5", Name: "Report", Arguments: `{"N": 5}`},
+		}},
+		mockopenai.Response{Content: []string{"done"}},
+	)
+
+	var lock sync.Mutex
+	running := 0
+	max_running := 0
+	save_alone := true
+	app_port, _ := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		lock.Lock()
+		running++
+		max_running = max(max_running, running)
+		if toolName == "Save" && running > 1 {
+			save_alone = false
+		}
+		lock.Unlock()
+
+		var params struct{ N int }
+		json.Unmarshal(paramsJs, &params)
+		time.Sleep(time.Duration(60-params.N*10) * time.Millisecond) //first call finishes last
+
+		lock.Lock()
+		if running > 1 && toolName == "Save" {
+			save_alone = false
+		}
+		running--
+		lock.Unlock()
+		return []byte(fmt.Sprintf(`{"Out_n": %d}`, params.N)), nil
+	})
+
+	report := NewToolsOpenAI_completion_tool("Report", "Returns report.")
+	report.Function.Parameters.AddParam("N", "int", "Report number")
+	save := NewToolsOpenAI_completion_tool("Save", "Saves report. [sequential]")
+	save.Function.Parameters.AddParam("N", "int", "Report number")
+
+	st, msg := _test_newCompletion("Get reports", 3)
+	st.Max_parallel_tools = 2
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, []*ToolsOpenAI_completion_tool{report, save}, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	if max_running != 2 {
+		t.Errorf("max running calls: %d, expected 2", max_running)
+	}
+	if !save_alone {
+		t.Errorf("sequential tool ran with other calls")
+	}
+	lock.Unlock()
+
+	//mark is only for router
+	toolsJs, _ := json.Marshal(srv.GetRequests()[0].Tools)
+	if strings.Contains(string(toolsJs), "[sequential]") || !strings.Contains(string(toolsJs), `"Saves report."`) {
+		t.Errorf("tools sent to model: %s", toolsJs)
+	}
+	if !save.IsSequential() {
+		t.Errorf("tool's schema lost [sequential] mark")
+	}
+
+	//model's order
+	results := _test_getResults(t, st)
+	if strings.Join(results, ",") != "1,2,3,4,5" {
+		t.Errorf("results: %v", results)
+	}
+}
+
+func TestOpenAI_Complete_stop(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(mockopenai.Response{
+		Content:    []string{"one ", "two ", "three ", "four ", "five"},
+		ToolCalls:  []mockopenai.ToolCall{{Id: "call_1", Name: "Never", Arguments: `{}`}},
+		ChunkDelay: 20 * time.Millisecond,
+	})
+
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		return []byte(`{}`), nil
+	})
+
+	st, msg := _test_newCompletion("Stop me", 3)
+
+	var num_deltas int
+	st.delta = func(m *ChatMsg) {
+		if m.Stream {
+			num_deltas++
+			msg.Stop() //stop after first streamed delta
+		}
+	}
+
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, _test_newTools("Never"), msg, nil)
+	if err == nil {
+		t.Errorf("expected 'interrupted' error")
+	}
+
+	if num_deltas != 1 {
+		t.Errorf("streamed deltas after stop: %d, expected 1", num_deltas)
+	}
+	if num_calls.Load() != 0 {
+		t.Errorf("tool was called after stop")
+	}
+	if n := len(srv.GetRequests()); n != 1 {
+		t.Errorf("requests: %d, expected 1", n)
+	}
+	if st.Out_answer != "" {
+		t.Errorf("answer after stop: '%s'", st.Out_answer)
+	}
+}
+
+func TestOpenAI_Complete_stopRunningTool(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(
+		mockopenai.Response{ToolCalls: []mockopenai.ToolCall{{Id: "call_1", Name: "Slow", Arguments: `{}`}, {Id: "call_2", Name: "Slow", Arguments: `{}`}}},
+		mockopenai.Response{Content: []string{"never"}},
+	)
+
+	st, msg := _test_newCompletion("Stop running tool", 3)
+
+	release := make(chan struct{})
+	defer close(release)
+	app_port, num_calls := _test_startFakeApp(t, func(toolName string, paramsJs []byte) ([]byte, error) {
+		msg.Stop()
+		<-release //tool doesn't finish until test ends
+		return []byte(`{}`), nil
+	})
+
+	tools := _test_newTools("Slow")
+	tools[0].Function.Description = "Waits. [sequential]" //2nd call would start after 1st
+
+	start := time.Now()
+	_, err := OpenAI_Complete("test", srv.URL, "", st, app_port, tools, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dt := time.Since(start); dt > 2*time.Second {
+		t.Errorf("stopped completion waited for running tool: %v", dt)
+	}
+	if n := num_calls.Load(); n != 1 {
+		t.Errorf("tool calls: %d, expected 1", n)
+	}
+	if n := len(srv.GetRequests()); n != 1 {
+		t.Errorf("requests: %d, expected 1", n)
+	}
+}
+
+func TestOpenAI_Complete_pricing(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(mockopenai.Response{
+		Content: []string{"priced"},
+		Usage:   &mockopenai.Usage{Prompt_tokens: 1000, Input_cached_tokens: 200, Completion_tokens: 500, Reasoning_tokens: 100, Num_sources_used: 4},
+	})
+
+	//USD cents per million tokens
+	model := &LLMxAILanguageModel{
+		Prompt_text_token_price:        20000,  //$2
+		Cached_prompt_text_token_price: 5000,   //$0.5
+		Completion_text_token_price:    100000, //$10
+		Search_source_price:            250,    //$0.025 per 1K sources
+	}
+
+	st, msg := _test_newCompletion("Price me", 1)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, 0, nil, msg, model.GetTextPrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := st.Out_usage
+	if u.Prompt_tokens != 1000 || u.Input_cached_tokens != 200 || u.Completion_tokens != 500 || u.Reasoning_tokens != 100 || u.Num_sources_used != 4 {
+		t.Fatalf("usage tokens: %+v", u)
+	}
+
+	check := func(name string, value, expected float64) {
+		if math.Abs(value-expected) > 1e-12 {
+			t.Errorf("%s: %g, expected %g", name, value, expected)
+		}
+	}
+	check("Prompt_price", u.Prompt_price, 1000*2.0/1000000)
+	check("Input_cached_price", u.Input_cached_price, 200*0.5/1000000)
+	check("Completion_price", u.Completion_price, 500*10.0/1000000)
+	check("Reasoning_price", u.Reasoning_price, 100*2.0/1000000)
+	check("Sources_price", u.Sources_price, 4*0.025/1000)
+	check("TotalPrice", u.TotalPrice(), (1000*2.0+200*0.5+500*10.0+100*2.0)/1000000)
+
+	//message has its own usage
+	var msgs ChatMsgs
+	err = LogsJsonUnmarshal(st.Out_messages, &msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu := msgs.Messages[len(msgs.Messages)-1].Usage
+	if mu.Provider != "test" || mu.Model != "test-model" {
+		t.Errorf("message usage provider '%s', model '%s'", mu.Provider, mu.Model)
+	}
+	check("message TotalPrice", mu.TotalPrice(), u.TotalPrice())
+}
+
+func TestOpenAI_completion_Run_errorStatus(t *testing.T) {
+	srv := mockopenai.NewServer()
+	defer srv.Close()
+
+	srv.Add(mockopenai.Response{StatusCode: 429, Body: "rate limit"})
+
+	st, msg := _test_newCompletion("Fail", 1)
+	_, err := OpenAI_Complete("test", srv.URL, "", st, 0, nil, msg, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("error: %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const LLMToolResult_maxImages = 4
+const LLMToolResult_maxImageSize = 5 * 1024 * 1024
+const LLMToolResult_maxSummary = 4000 //characters
+
+// Builds tool call result for model. Single scalar Out_ attribute is passed as raw value, otherwise result is JSON object with Out_ attributes and 'shown_on_screen' summary of tool's UI.
+// Images shown by tool's UI are returned as content parts, only for vision models and only from app's folder.
+func LLMToolResult_build(resJs []byte, tool_ui *UI, vision bool, appFolder string) (string, *OpenAI_completion_msgContent, error) {
+	var resMap map[string]json.RawMessage
+	err := LogsJsonUnmarshal(resJs, &resMap)
+	if err != nil {
+		return "", nil, err
+	}
+
+	outs := make(map[string]json.RawMessage)
+	for nm, val := range resMap {
+		if strings.HasPrefix(strings.ToLower(nm), "out") {
+			outs[nm] = val
+		}
+	}
+
+	hasUI := tool_ui != nil && tool_ui.Is()
+
+	if !hasUI {
+		if len(outs) == 0 {
+			return "", nil, nil
+		}
+		if len(outs) == 1 {
+			for _, val := range outs {
+				if str, ok := _LLMToolResult_scalar(val); ok {
+					return str, nil, nil
+				}
+			}
+		}
+	}
+
+	result := make(map[string]any)
+	for nm, val := range outs {
+		result[nm] = val
+	}
+
+	var images *OpenAI_completion_msgContent
+	if hasUI {
+		result["shown_on_screen"] = tool_ui.GetSummary(LLMToolResult_maxSummary)
+
+		if vision {
+			images = &OpenAI_completion_msgContent{Role: "user"}
+			tool_ui.addImages(images, appFolder)
+			if len(images.Content) == 0 {
+				images = nil
+			}
+		}
+	}
+
+	js, err := json.Marshal(result) //keys are sorted
+	if LogsError(err) != nil {
+		return "", nil, err
+	}
+	return string(js), images, nil
+}
+
+// string, number or bool as plain text
+func _LLMToolResult_scalar(val json.RawMessage) (string, bool) {
+	val = bytes.TrimSpace(val)
+	if len(val) == 0 {
+		return "", false
+	}
+	switch val[0] {
+	case '{', '[':
+		return "", false
+	case '"':
+		var str string
+		if json.Unmarshal(val, &str) != nil {
+			return "", false
+		}
+		return str, true
+	}
+	if string(val) == "null" {
+		return "", false
+	}
+	return string(val), true
+}
+
+// compact text description of what UI shows, one item per line
+func (ui *UI) GetSummary(maxLen int) string {
+	var lines []string
+	ui._summary(&lines)
+
+	str := strings.Join(lines, "\n")
+	if maxLen > 0 && len(str) > maxLen {
+		str = strings.ToValidUTF8(str[:maxLen], "") + "\n..."
+	}
+	return str
+}
+
+func (ui *UI) _summary(lines *[]string) {
+	add := func(format string, a ...any) {
+		*lines = append(*lines, fmt.Sprintf(format, a...))
+	}
+	fmtFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	fmtTime := func(unix int64) string {
+		return time.Unix(unix, 0).Format("2006-01-02 15:04")
+	}
+
+	switch {
+	case ui.Text != nil:
+		if ui.Text.Label != "" {
+			add("Text: %s", ui.Text.Label)
+		}
+	case ui.Editbox != nil:
+		val := ""
+		switch {
+		case ui.Editbox.Password:
+			val = "<password>"
+		case ui.Editbox.Value != nil:
+			val = *ui.Editbox.Value
+		case ui.Editbox.ValueInt != nil:
+			val = strconv.Itoa(*ui.Editbox.ValueInt)
+		case ui.Editbox.ValueFloat != nil:
+			val = strconv.FormatFloat(*ui.Editbox.ValueFloat, 'f', ui.Editbox.Precision, 64)
+		}
+		if val == "" && ui.Editbox.Ghost != "" {
+			add("Editbox: empty(%s)", ui.Editbox.Ghost)
+		} else {
+			add("Editbox: %s", val)
+		}
+	case ui.Button != nil:
+		label := ui.Button.Label
+		if label == "" {
+			label = ui.Tooltip
+		}
+		if ui.Button.BrowserUrl != "" {
+			add("Link: %s(%s)", label, ui.Button.BrowserUrl)
+		} else if label != "" {
+			add("Button: %s", label)
+		}
+	case ui.Slider != nil:
+		if ui.Slider.Value != nil {
+			add("Slider %s: %s(%s..%s)", ui.Slider.Label, fmtFloat(*ui.Slider.Value), fmtFloat(ui.Slider.Min), fmtFloat(ui.Slider.Max))
+		}
+	case ui.FilePickerButton != nil:
+		if ui.FilePickerButton.Path != nil {
+			add("File: %s", *ui.FilePickerButton.Path)
+		}
+	case ui.DatePickerButton != nil:
+		if ui.DatePickerButton.Date != nil {
+			add("Date: %s", fmtTime(*ui.DatePickerButton.Date))
+		}
+	case ui.ColorPickerButton != nil:
+		if ui.ColorPickerButton.Cd != nil {
+			cd := *ui.ColorPickerButton.Cd
+			add("Color: #%02x%02x%02x", cd.R, cd.G, cd.B)
+		}
+	case ui.DropDown != nil:
+		if ui.DropDown.Value != nil {
+			label := *ui.DropDown.Value
+			if i := slices.Index(ui.DropDown.Values, label); i >= 0 && i < len(ui.DropDown.Labels) {
+				label = ui.DropDown.Labels[i]
+			}
+			add("DropDown: %s(options: %s)", label, strings.Join(ui.DropDown.Labels, ", "))
+		}
+	case ui.Switch != nil:
+		if ui.Switch.Value != nil {
+			add("Switch %s: %s", ui.Switch.Label, OsTrnString(*ui.Switch.Value, "on", "off"))
+		}
+	case ui.Checkbox != nil:
+		if ui.Checkbox.Value != nil {
+			add("Checkbox %s: %s", ui.Checkbox.Label, OsTrnString(*ui.Checkbox.Value != 0, "checked", "unchecked"))
+		}
+	case ui.Divider != nil:
+		if ui.Divider.Label != "" {
+			add("Divider: %s", ui.Divider.Label)
+		}
+	case ui.Map != nil:
+		str := "Map"
+		if ui.Map.Lat != nil && ui.Map.Lon != nil {
+			str += fmt.Sprintf(" at lat %s, lon %s", fmtFloat(*ui.Map.Lat), fmtFloat(*ui.Map.Lon))
+		}
+		var labels []string
+		for _, locs := range ui.Map.Locators {
+			for _, loc := range locs.Locators {
+				labels = append(labels, loc.Label)
+			}
+		}
+		if len(labels) > 0 {
+			str += fmt.Sprintf(", locators: %s", strings.Join(labels, ", "))
+		}
+		if len(ui.Map.Routes) > 0 {
+			str += fmt.Sprintf(", %d routes", len(ui.Map.Routes))
+		}
+		add("%s", str)
+	case ui.ChartLines != nil:
+		add("Line chart(x: %s, y: %s)", ui.ChartLines.X_unit, ui.ChartLines.Y_unit)
+		for _, line := range ui.ChartLines.Lines {
+			var pts []string
+			for _, pt := range line.Points {
+				pts = append(pts, fmt.Sprintf("[%s, %s]", fmtFloat(pt.X), fmtFloat(pt.Y)))
+			}
+			add("- %s: %s", line.Label, strings.Join(pts, " "))
+		}
+	case ui.ChartColumns != nil:
+		add("Column chart(x: %s, y: %s)", ui.ChartColumns.X_unit, ui.ChartColumns.Y_unit)
+		for i, col := range ui.ChartColumns.Columns {
+			var vals []string
+			for _, v := range col.Values {
+				vals = append(vals, strings.TrimSpace(v.Label+" "+fmtFloat(v.Value)))
+			}
+			label := strconv.Itoa(i)
+			if i < len(ui.ChartColumns.X_Labels) {
+				label = ui.ChartColumns.X_Labels[i]
+			}
+			add("- %s: %s", label, strings.Join(vals, ", "))
+		}
+	case ui.Media != nil:
+		if ui.Media.Path != "" {
+			add("Media: %s", ui.Media.Path)
+		} else if len(ui.Media.Blob) > 0 {
+			add("Media: %d bytes", len(ui.Media.Blob))
+		}
+	case ui.YearCalendar != nil:
+		add("Calendar: year %d", ui.YearCalendar.Year)
+	case ui.MonthCalendar != nil:
+		add("Calendar: %d-%02d, %d events", ui.MonthCalendar.Year, ui.MonthCalendar.Month, len(ui.MonthCalendar.Events))
+		for _, ev := range ui.MonthCalendar.Events {
+			add("- %s: %s", fmtTime(ev.Start), ev.Title)
+		}
+	case ui.DayCalendar != nil:
+		add("Calendar: %d days, %d events", len(ui.DayCalendar.Days), len(ui.DayCalendar.Events))
+		for _, ev := range ui.DayCalendar.Events {
+			add("- %s: %s", fmtTime(ev.Start), ev.Title)
+		}
+	case ui.Microphone != nil:
+		add("Microphone")
+	case ui.Chat != nil:
+		add("Chat")
+	case ui.PromptMenu != nil:
+		add("Prompts: %s", strings.Join(ui.PromptMenu.Prompts, ", "))
+	}
+
+	for _, it := range ui.Items {
+		it._summary(lines)
+	}
+}
+
+// adds images from Media items. Files outside app's folder are ignored.
+func (ui *UI) addImages(msg *OpenAI_completion_msgContent, appFolder string) {
+	if len(msg.Content) >= LLMToolResult_maxImages {
+		return
+	}
+
+	if ui.Media != nil {
+		data := ui.Media.Blob
+		if len(data) == 0 && ui.Media.Path != "" {
+			data = _LLMToolResult_readImage(ui.Media.Path, appFolder)
+		}
+
+		if len(data) > 0 && len(data) <= LLMToolResult_maxImageSize {
+			media_type := http.DetectContentType(data)
+			switch media_type {
+			case "image/png", "image/jpeg", "image/webp", "image/gif":
+				msg.AddImage(data, media_type)
+			}
+		}
+	}
+
+	for _, it := range ui.Items {
+		it.addImages(msg, appFolder)
+	}
+}
+
+// relative path is inside app's folder(process's working dir). Symlinks are resolved, so they can't point outside.
+func _LLMToolResult_readImage(path string, appFolder string) []byte {
+	if appFolder == "" || !_LLMToolResult_isImageExt(path) {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(appFolder, path)
+	}
+
+	folder, err := filepath.Abs(appFolder)
+	if err != nil {
+		return nil
+	}
+	folder, err = filepath.EvalSymlinks(folder)
+	if err != nil {
+		return nil
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil
+	}
+	rel, err := filepath.Rel(folder, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil //outside
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() > LLMToolResult_maxImageSize {
+		return nil
+	}
+	data, _ := os.ReadFile(path)
+	return data
+}
+
+func _LLMToolResult_isImageExt(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".webp", ".gif":
+		return true
+	}
+	return false
+}
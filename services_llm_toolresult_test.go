@@ -0,0 +1,195 @@
+/*
+Copyright 2025 Milan Suk
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this db except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func _test_pngImage(t *testing.T) []byte {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLLMToolResult_build(t *testing.T) {
+	tests := []struct {
+		name  string
+		resJs string
+		want  string
+	}{
+		{"empty", `{"A": 1}`, ``},
+		{"single string", `{"A": 1, "Out_text": "hello"}`, `hello`},
+		{"single number", `{"Out_n": 12345678901234567890}`, `12345678901234567890`},
+		{"single object", `{"Out_item": {"Name": "Milan", "Tags": ["a"]}}`, `{"Out_item":{"Name": "Milan", "Tags": ["a"]}}`},
+		{"multi", `{"Out_b": [1, 2], "Out_a": null}`, `{"Out_a":null,"Out_b":[1,2]}`},
+	}
+	for _, tt := range tests {
+		got, images, err := LLMToolResult_build([]byte(tt.resJs), &UI{}, true, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.name == "single object" { //RawMessage is compacted by Marshal
+			var a, b any
+			json.Unmarshal([]byte(got), &a)
+			json.Unmarshal([]byte(tt.want), &b)
+			gotJs, _ := json.Marshal(a)
+			wantJs, _ := json.Marshal(b)
+			got, tt.want = string(gotJs), string(wantJs)
+		}
+		if got != tt.want || images != nil {
+			t.Errorf("%s: got '%s', want '%s'", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLLMToolResult_build_UI(t *testing.T) {
+	name := "Milan"
+	on := true
+	pngImg := _test_pngImage(t)
+	ui := &UI{Items: []*UI{
+		{Text: &UIText{Label: "Weather in Prague"}},
+		{Editbox: &UIEditbox{Value: &name}},
+		{Switch: &UISwitch{Label: "Celsius", Value: &on}},
+		{ChartColumns: &UIChartColumns{Y_unit: "°C", X_Labels: []string{"Mon", "Tue"}, Columns: []ChartColumn{{Values: []ChartColumnValue{{Value: 21.5}}}, {Values: []ChartColumnValue{{Value: 18}}}}}},
+		{Items: []*UI{{Media: &UIMedia{Blob: pngImg}}}},
+		{Media: &UIMedia{Blob: []byte("not an image")}},
+	}}
+
+	result, images, err := LLMToolResult_build([]byte(`{"City": "Prague", "Out_temp": 21.5}`), ui, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]any
+	err = json.Unmarshal([]byte(result), &res)
+	if err != nil {
+		t.Fatalf("result is not JSON: %s", result)
+	}
+	if res["Out_temp"] != 21.5 || len(res) != 2 {
+		t.Errorf("result: %s", result)
+	}
+	summary := fmt.Sprintf("Text: Weather in Prague\nEditbox: Milan\nSwitch Celsius: on\nColumn chart(x: , y: °C)\n- Mon: 21.5\n- Tue: 18\nMedia: %d bytes\nMedia: 12 bytes", len(pngImg))
+	if res["shown_on_screen"] != summary {
+		t.Errorf("summary:\n%s\nwant:\n%s", res["shown_on_screen"], summary)
+	}
+
+	if images == nil || len(images.Content) != 1 || images.Content[0].Type != "image_url" || images.Content[0].Image_url == nil {
+		t.Fatalf("images: %+v", images)
+	}
+
+	//model without vision
+	result2, images, err := LLMToolResult_build([]byte(`{"City": "Prague", "Out_temp": 21.5}`), ui, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images != nil || result2 != result {
+		t.Errorf("no vision: images %+v, result %s", images, result2)
+	}
+}
+
+func TestLLMToolResult_imagesFromAppFolder(t *testing.T) {
+	pngImg := _test_pngImage(t)
+
+	root := t.TempDir()
+	appFolder := filepath.Join(root, "app")
+	os.MkdirAll(filepath.Join(appFolder, "data"), 0700)
+	os.WriteFile(filepath.Join(appFolder, "data", "in.png"), pngImg, 0600)
+	os.WriteFile(filepath.Join(root, "out.png"), pngImg, 0600)
+	os.Symlink(filepath.Join(root, "out.png"), filepath.Join(appFolder, "link.png"))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"data/in.png", 1},
+		{filepath.Join(appFolder, "data", "in.png"), 1},
+		{filepath.Join(root, "out.png"), 0},
+		{"../out.png", 0},
+		{"link.png", 0}, //symlink outside
+	}
+	for _, tt := range tests {
+		ui := &UI{Items: []*UI{{Media: &UIMedia{Path: tt.path}}}}
+		_, images, err := LLMToolResult_build([]byte(`{}`), ui, true, appFolder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		if images != nil {
+			n = len(images.Content)
+		}
+		if n != tt.want {
+			t.Errorf("'%s': images %d, want %d", tt.path, n, tt.want)
+		}
+	}
+}
+
+func TestLLMToolResult_convertMessages(t *testing.T) {
+	var msgs ChatMsgs
+	msgs.AddUserMessage("Show chart", nil)
+	msgs.AddAssistentCalls("", "", []OpenAI_completion_msg_Content_ToolCall{{Id: "call_1", Function: OpenAI_completion_msg_Content_ToolCall_Function{Name: "Chart"}}, {Id: "call_2", Function: OpenAI_completion_msg_Content_ToolCall_Function{Name: "Sum"}}}, nil, LLMMsgUsage{})
+
+	images := &OpenAI_completion_msgContent{Role: "user"}
+	images.AddImage(_test_pngImage(t), "image/png")
+	msgs.AddCallResult("Chart", "call_1", `{"shown_on_screen":"Media: 1 image"}`).Content.ResultImages = images
+	msgs.AddCallResult("Sum", "call_2", "3")
+
+	//OpenAI: tool results must follow calls, images are sent after them
+	oai := OpenAI_convertMessages(&msgs)
+	if len(oai) != 5 {
+		t.Fatalf("messages: %d", len(oai))
+	}
+	if r, ok := oai[2].(*OpenAI_completion_msgResult); !ok || r.Tool_call_id != "call_1" {
+		t.Errorf("3rd message: %+v", oai[2])
+	}
+	if r, ok := oai[3].(*OpenAI_completion_msgResult); !ok || r.Tool_call_id != "call_2" {
+		t.Errorf("4th message: %+v", oai[3])
+	}
+	img, ok := oai[4].(*OpenAI_completion_msgContent)
+	if !ok || img.Role != "user" || len(img.Content) != 2 || img.Content[0].Type != "text" || img.Content[1].Type != "image_url" {
+		t.Errorf("images message: %+v", oai[4])
+	}
+
+	//Anthropic: images are inside tool_result
+	ant := Anthropic_convertMessages(&msgs)
+	if len(ant) != 3 || len(ant[2].Content) != 2 {
+		t.Fatalf("anthropic messages: %+v", ant)
+	}
+	blocks, ok := ant[2].Content[0].Content.([]Anthropic_content)
+	if !ok || len(blocks) != 2 || blocks[0].Type != "text" || blocks[1].Type != "image" || blocks[1].Source.Media_type != "image/png" {
+		t.Errorf("anthropic tool_result: %+v", ant[2].Content[0])
+	}
+	if ant[2].Content[1].Content != "3" {
+		t.Errorf("anthropic 2nd tool_result: %+v", ant[2].Content[1])
+	}
+
+	//Ollama: images are in tool message
+	oll := Ollama_convertMessages("", &msgs)
+	if len(oll) != 4 || oll[2].Role != "tool" || len(oll[2].Images) != 1 || len(oll[3].Images) != 0 {
+		t.Errorf("ollama messages: %+v", oll)
+	}
+}
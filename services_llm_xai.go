@@ -100,7 +100,7 @@ func (xai *LLMxAI) GetPricingString(model string) string {
 	return fmt.Sprintf("model %s not found", model)
 }
 
-func (model *LLMxAILanguageModel) GetTextPrice(in, reason, cached, out int, sources int) (float64, float64, float64, float64, float64) {
+func (model *LLMxAILanguageModel) GetTextPrice(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64) {
 
 	convert_to_dolars := float64(10000)
 
@@ -110,7 +110,7 @@ func (model *LLMxAILanguageModel) GetTextPrice(in, reason, cached, out int, sour
 	Output_price := float64(model.Completion_text_token_price) / convert_to_dolars / 1000000
 	Source_price := float64(model.Search_source_price) / convert_to_dolars / 1000 //1K
 
-	return float64(in) * Input_price, float64(reason) * Reason_price, float64(cached) * Cached_price, float64(out) * Output_price, float64(sources) * Source_price
+	return float64(in) * Input_price, float64(reason) * Reason_price, float64(cached) * Cached_price, float64(out) * Output_price, float64(sources) * Source_price, 0
 }
 
 /*func (xai *LLMxAI) downloadList(url_part string) ([]byte, error) {
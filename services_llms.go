@@ -21,9 +21,12 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-audio/audio"
 )
@@ -82,6 +85,8 @@ type OpenAI_content struct {
 	Msg    *OpenAI_completion_msgContent `json:",omitempty"`
 	Calls  *OpenAI_completion_msgCalls   `json:",omitempty"`
 	Result *OpenAI_completion_msgResult  `json:",omitempty"`
+
+	ResultImages *OpenAI_completion_msgContent `json:",omitempty"` //images shown by tool
 }
 type ChatMsg struct {
 	Seed int
@@ -92,6 +97,9 @@ type ChatMsg struct {
 	ReasoningSize int //Final text is after
 	ShowReasoning bool
 
+	Reasoning_signature string   `json:",omitempty"` //Anthropic
+	Reasoning_redacted  []string `json:",omitempty"` //Anthropic
+
 	UI_func     string
 	UI_paramsJs string
 
@@ -123,11 +131,15 @@ type LLMMsgUsage struct {
 	Reasoning_tokens    int
 	Num_sources_used    int
 
+	Input_cache_write_tokens int
+
 	Prompt_price       float64
 	Input_cached_price float64
 	Completion_price   float64
 	Reasoning_price    float64
 	Sources_price      float64
+
+	Input_cache_write_price float64
 }
 
 func (usage *LLMMsgUsage) GetSpeed() float64 {
@@ -139,7 +151,7 @@ func (usage *LLMMsgUsage) GetSpeed() float64 {
 }
 
 func (u *LLMMsgUsage) TotalPrice() float64 {
-	return u.Prompt_price + u.Input_cached_price + u.Completion_price + u.Reasoning_price
+	return u.Prompt_price + u.Input_cached_price + u.Input_cache_write_price + u.Completion_price + u.Reasoning_price
 }
 func (dst *LLMMsgUsage) Add(src *LLMMsgUsage) {
 
@@ -158,12 +170,14 @@ func (dst *LLMMsgUsage) Add(src *LLMMsgUsage) {
 	dst.Completion_tokens += src.Completion_tokens
 	dst.Reasoning_tokens += src.Reasoning_tokens
 	dst.Num_sources_used += src.Num_sources_used
+	dst.Input_cache_write_tokens += src.Input_cache_write_tokens
 
 	dst.Prompt_price += src.Prompt_price
 	dst.Input_cached_price += src.Input_cached_price
 	dst.Completion_price += src.Completion_price
 	dst.Reasoning_price += src.Reasoning_price
 	dst.Sources_price += src.Sources_price
+	dst.Input_cache_write_price += src.Input_cache_write_price
 }
 
 type LLMComplete struct {
@@ -187,7 +201,8 @@ type LLMComplete struct {
 
 	Response_format string //"", "json_object"
 
-	Max_iteration int
+	Max_iteration      int
+	Max_parallel_tools int //tool calls from one answer running at once, 0 = default
 
 	Out_StatusCode int
 	Out_messages   []byte //[]*ChatMsg
@@ -202,8 +217,17 @@ type LLMComplete struct {
 	delta      func(msg *ChatMsg)
 	wip_answer string
 	msg        *AppsRouterMsg
+	cassette   *LLMCassette
+	vision     bool   //model accepts images, otherwise tool results are without images
+	app_folder string //tool results have only images from app's folder
+
+	retry   *LLMRetryPolicy    //set by LLM_CompleteChain()
+	breaker *LLMCircuitBreaker //set by LLM_CompleteChain()
+	resume  *llmCompleteResume //progress of failed provider
 }
 
+const LLMComplete_defaultParallelTools = 4
+
 func NewLLMCompletion() *LLMComplete {
 	comp := &LLMComplete{}
 	comp.Temperature = 0.2
@@ -228,6 +252,24 @@ func (a *LLMComplete) Cmp(b *LLMComplete) bool {
 		a.Response_format == b.Response_format
 }
 
+// hash of attributes compared in Cmp()
+func (a *LLMComplete) GetCacheKey() string {
+	var str bytes.Buffer
+	str.WriteString(a.Out_usage.Model + "\n")
+	str.WriteString(strconv.FormatFloat(a.Temperature, 'f', -1, 64) + "\n")
+	str.WriteString(strconv.FormatFloat(a.Top_p, 'f', -1, 64) + "\n")
+	str.WriteString(a.SystemMessage + "\n")
+	str.WriteString(a.UserMessage + "\n")
+	str.WriteString(a.Reasoning_effort + "\n")
+	str.Write(a.Out_tools)
+	str.WriteString("\n")
+	str.Write(a.PreviousMessages)
+	str.WriteString("\n")
+	str.WriteString(a.Response_format)
+
+	return _ToolsPrompt_getHash(str.String())
+}
+
 type LLMGenerateImage struct {
 	Prompt     string //Prompt for image generation.
 	Num_images int    //Number of images to be generated
@@ -262,12 +304,18 @@ type LLMs struct {
 	running      []*LLMComplete
 	running_lock sync.Mutex
 
-	Cache      []LLMComplete
-	cache_lock sync.Mutex
+	Cache       []LLMComplete
+	cache_index map[string]int //[GetCacheKey()]index into Cache
+	cache_lock  sync.Mutex
+
+	retry   LLMRetryPolicy
+	breaker LLMCircuitBreaker
+
+	budget *LLMBudget
 }
 
 func NewLLMs(services *Services) (*LLMs, error) {
-	llms := &LLMs{services: services}
+	llms := &LLMs{services: services, retry: NewLLMRetryPolicy(), budget: NewLLMBudget("temp/llms_spendings.json")}
 
 	//open
 	{
@@ -276,6 +324,13 @@ func NewLLMs(services *Services) (*LLMs, error) {
 			LogsJsonUnmarshal(fl, &llms.Cache)
 		}
 	}
+
+	//index
+	llms.cache_index = make(map[string]int)
+	for i := range llms.Cache {
+		llms.cache_index[llms.Cache[i].GetCacheKey()] = i //later duplicates win
+	}
+
 	return llms, nil
 }
 
@@ -283,11 +338,10 @@ func (llms *LLMs) findCache(st *LLMComplete) bool {
 	llms.cache_lock.Lock()
 	defer llms.cache_lock.Unlock()
 
-	for i := range llms.Cache {
-		if llms.Cache[i].Cmp(st) {
-			*st = llms.Cache[i]
-			return true
-		}
+	i, found := llms.cache_index[st.GetCacheKey()]
+	if found && llms.Cache[i].Cmp(st) {
+		*st = llms.Cache[i]
+		return true
 	}
 	return false
 }
@@ -296,7 +350,14 @@ func (llms *LLMs) addCache(st *LLMComplete) {
 	llms.cache_lock.Lock()
 	defer llms.cache_lock.Unlock()
 
-	llms.Cache = append(llms.Cache, *st)
+	key := st.GetCacheKey()
+	i, found := llms.cache_index[key]
+	if found {
+		llms.Cache[i] = *st //replace
+	} else {
+		llms.cache_index[key] = len(llms.Cache)
+		llms.Cache = append(llms.Cache, *st)
+	}
 	Tools_WriteJSONFile("temp/llms_cache.json", llms.Cache)
 }
 
@@ -318,22 +379,16 @@ func (llms *LLMs) Complete(st *LLMComplete, msg *AppsRouterMsg, usecase string)
 
 	dev := &llms.services.sync.Device
 
-	provider := dev.App_provider
-	model := dev.App_model
-
-	switch strings.ToLower(usecase) {
-	case "code":
-		provider = dev.Code_provider
-		model = dev.Code_model
-
-	}
+	chain := llms.getChain(usecase)
+	if len(chain) > 0 {
+		if strings.Contains(chain[0].Model, "gpt-oss") {
+			st.Reasoning_effort = "medium"
+		}
 
-	if strings.Contains(model, "gpt-oss") {
-		st.Reasoning_effort = "medium"
+		st.Out_usage.Provider = chain[0].Provider
+		st.Out_usage.Model = chain[0].Model
 	}
 
-	st.Out_usage.Model = model
-
 	//Tools
 	if llms.services.fnGetAppPortAndTools == nil {
 		log.Fatalf("fnGetAppPortAndTools is nill")
@@ -343,6 +398,9 @@ func (llms *LLMs) Complete(st *LLMComplete, msg *AppsRouterMsg, usecase string)
 	if err != nil {
 		return err
 	}
+	if st.AppName != "" && llms.services.fnGetAppFolder != nil {
+		st.app_folder = llms.services.fnGetAppFolder(st.AppName)
+	}
 	if len(tools) > 0 {
 		var err error
 		st.Out_tools, err = LogsJsonMarshal(tools)
@@ -371,8 +429,11 @@ func (llms *LLMs) Complete(st *LLMComplete, msg *AppsRouterMsg, usecase string)
 		}()
 	}
 
+	//record/replay
+	st.cassette = NewLLMCassette(dev.LLM_cassette, llms.getCassetteFolder(st, msg))
+
 	//find in cache
-	if llms.findCache(st) {
+	if st.cassette == nil && llms.findCache(st) {
 		return nil
 	}
 
@@ -395,35 +456,35 @@ func (llms *LLMs) Complete(st *LLMComplete, msg *AppsRouterMsg, usecase string)
 	}*/
 
 	//call
-	switch strings.ToLower(provider) {
-	case "xai":
-		err := llms.services.sync.LLM_xai.Complete(st, app_port, tools, msg)
-		if err != nil {
-			return err
-		}
-	case "mistral":
-		err := llms.services.sync.LLM_mistral.Complete(st, app_port, tools, msg)
-		if err != nil {
-			return err
-		}
-	case "openai":
-		err := llms.services.sync.LLM_openai.Complete(st, app_port, tools, msg)
-		if err != nil {
-			return err
-		}
-	case "groq":
-		err := llms.services.sync.LLM_groq.Complete(st, app_port, tools, msg)
+	if st.cassette != nil && st.cassette.replay {
+		st.vision = llms.hasVision(st.Out_usage.Provider, st.Out_usage.Model)      //same requests as recorded
+		_, err := OpenAI_Complete("replay", "", "", st, app_port, tools, msg, nil) //no network
 		if err != nil {
 			return err
 		}
+	} else {
+		appName := llms.getAppName(st, msg)
+		limits := llms.getBudgetLimits()
+
+		err := LLM_CompleteChain(chain, &llms.retry, &llms.breaker, st, msg, func(provider string, st *LLMComplete) error {
+			projected := LLM_GetProjectedPrice(st, llms.getTextPrice(provider, st.Out_usage.Model))
+			reservation, err := llms.budget.Check(&limits, appName, projected, time.Now())
+			if err != nil {
+				return LogsError(err)
+			}
 
-	case "llama.cpp":
-		err := llms.services.sync.LLM_llama.Complete(st, app_port, tools, msg)
+			price := st.Out_usage.TotalPrice() + st.Out_usage.Sources_price
+			st.vision = llms.hasVision(provider, st.Out_usage.Model)
+			err = llms.completeProvider(provider, st, app_port, tools, msg)
+
+			//failed call can cost too
+			price = st.Out_usage.TotalPrice() + st.Out_usage.Sources_price - price
+			llms.budget.Add(LLMSpending{Time: float64(time.Now().UnixMicro()) / 1000000, AppName: appName, Provider: provider, Model: st.Out_usage.Model, Price: price}, reservation)
+			return err
+		})
 		if err != nil {
 			return err
 		}
-	default:
-		return LogsErrorf("provider '%s' not found", provider)
 	}
 
 	//print
@@ -440,6 +501,160 @@ func (llms *LLMs) Complete(st *LLMComplete, msg *AppsRouterMsg, usecase string)
 	return nil
 }
 
+// usecase: "tools", "code", "chat". Returns main provider and fallbacks.
+func (llms *LLMs) getChain(usecase string) []LLMChainItem {
+	dev := &llms.services.sync.Device
+
+	var chain []LLMChainItem
+	add := func(provider, model string) {
+		if provider != "" {
+			chain = append(chain, LLMChainItem{Provider: provider, Model: model})
+		}
+	}
+
+	switch strings.ToLower(usecase) {
+	case "code":
+		add(dev.Code_provider, dev.Code_model)
+		for _, it := range dev.Code_fallbacks {
+			add(it.Provider, it.Model)
+		}
+	case "chat":
+		add(dev.App_provider, dev.App_model)
+		for _, it := range dev.Chat_fallbacks {
+			add(it.Provider, it.Model)
+		}
+	default: //"tools"
+		add(dev.App_provider, dev.App_model)
+		for _, it := range dev.Tools_fallbacks {
+			add(it.Provider, it.Model)
+		}
+	}
+	return chain
+}
+
+func (llms *LLMs) getBudgetLimits() LLMBudgetLimits {
+	dev := &llms.services.sync.Device
+
+	limits := LLMBudgetLimits{Daily: dev.Budget_daily, Monthly: dev.Budget_monthly, Apps: make(map[string]float64)}
+	for _, it := range dev.Budget_apps {
+		limits.Apps[it.App] = it.Monthly
+	}
+	return limits
+}
+
+func (llms *LLMs) GetSpendings() LLMSpendingsSummary {
+	limits := llms.getBudgetLimits()
+	return llms.budget.GetSummary(&limits, time.Now())
+}
+
+// returns nil for unknown or free(local) models
+func (llms *LLMs) getTextPrice(provider string, model string) func(in, reason, cached, out int, sources int, cache_write int) (float64, float64, float64, float64, float64, float64) {
+	snc := llms.services.sync
+
+	switch strings.ToLower(provider) {
+	case "xai":
+		mod, _ := snc.LLM_xai.FindModel(model)
+		if mod != nil {
+			return mod.GetTextPrice
+		}
+	case "mistral":
+		mod, _ := snc.LLM_mistral.FindModel(model)
+		if mod != nil {
+			return mod.GetTextPrice
+		}
+	case "openai":
+		mod, _ := snc.LLM_openai.FindModel(model)
+		if mod != nil {
+			return mod.GetTextPrice
+		}
+	case "groq":
+		mod, _ := snc.LLM_groq.FindModel(model)
+		if mod != nil {
+			return mod.GetTextPrice
+		}
+	case "anthropic":
+		mod := snc.LLM_anthropic.FindModel(model)
+		if mod != nil {
+			return mod.GetTextPrice
+		}
+	}
+	return nil
+}
+
+// model can see images. Unknown models can't.
+func (llms *LLMs) hasVision(provider string, model string) bool {
+	snc := llms.services.sync
+
+	switch strings.ToLower(provider) {
+	case "xai":
+		mod, _ := snc.LLM_xai.FindModel(model)
+		return mod != nil && slices.Contains(mod.Input_modalities, "image")
+	case "mistral":
+		mod, _ := snc.LLM_mistral.FindModel(model)
+		return mod != nil && slices.Contains(mod.Input_modalities, "image")
+	case "openai":
+		mod, _ := snc.LLM_openai.FindModel(model)
+		return mod != nil && slices.Contains(mod.Input_modalities, "image")
+	case "groq":
+		mod, _ := snc.LLM_groq.FindModel(model)
+		return mod != nil && slices.Contains(mod.Input_modalities, "image")
+	case "anthropic":
+		mod := snc.LLM_anthropic.FindModel(model)
+		return mod != nil && slices.Contains(mod.Input_modalities, "image")
+	case "ollama":
+		mod := snc.LLM_ollama.FindModel(model)
+		return mod != nil && mod.HasCapability("vision")
+	}
+	return false
+}
+
+func (llms *LLMs) completeProvider(provider string, st *LLMComplete, app_port int, tools []*ToolsOpenAI_completion_tool, msg *AppsRouterMsg) error {
+	switch strings.ToLower(provider) {
+	case "xai":
+		return llms.services.sync.LLM_xai.Complete(st, app_port, tools, msg)
+	case "mistral":
+		return llms.services.sync.LLM_mistral.Complete(st, app_port, tools, msg)
+	case "openai":
+		return llms.services.sync.LLM_openai.Complete(st, app_port, tools, msg)
+	case "groq":
+		return llms.services.sync.LLM_groq.Complete(st, app_port, tools, msg)
+	case "anthropic":
+		return llms.services.sync.LLM_anthropic.Complete(st, app_port, tools, msg)
+	case "ollama":
+		return llms.services.sync.LLM_ollama.Complete(st, app_port, tools, msg)
+	case "llama.cpp":
+		return llms.services.sync.LLM_llama.Complete(st, app_port, tools, msg)
+	}
+	return LogsErrorf("provider '%s' not found", provider)
+}
+
+// app which called LLM
+func (llms *LLMs) getAppName(st *LLMComplete, msg *AppsRouterMsg) string {
+	if msg != nil && msg.appName != "" {
+		return msg.appName
+	}
+	return st.AppName
+}
+
+// cassettes are saved per app, outside of app's folder(it has only code and storage)
+func (llms *LLMs) getCassetteFolder(st *LLMComplete, msg *AppsRouterMsg) string {
+	appName := llms.getAppName(st, msg)
+	if appName == "" {
+		return filepath.Join("temp", "cassettes")
+	}
+	folder := filepath.Join("temp", "cassettes", appName)
+
+	//move cassettes from older version
+	old := filepath.Join("apps", appName, "cassettes")
+	if _, err := os.Stat(old); err == nil {
+		if _, err := os.Stat(folder); os.IsNotExist(err) {
+			os.MkdirAll(filepath.Dir(folder), os.ModePerm)
+			LogsError(os.Rename(old, folder))
+		}
+	}
+	return folder
+}
+
 func (llms *LLMs) GetUsage() []LLMMsgUsage {
 	var ret []LLMMsgUsage
 	for _, it := range llms.Cache {
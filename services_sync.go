@@ -56,6 +56,27 @@ type ServicesSyncDeviceSettings struct {
 	Image_model    string
 
 	STT_provider string
+
+	Tools_fallbacks []ServicesSyncLLMFallback
+	Chat_fallbacks  []ServicesSyncLLMFallback
+	Code_fallbacks  []ServicesSyncLLMFallback
+
+	Budget_daily   float64 //USD, 0 = no limit
+	Budget_monthly float64
+	Budget_apps    []ServicesSyncAppBudget
+
+	LLM_cassette string //"", "record", "replay"
+}
+
+type ServicesSyncAppBudget struct {
+	App     string
+	Monthly float64
+}
+
+type ServicesSyncLLMFallback struct {
+	Provider string
+	Smarter  bool
+	Model    string
 }
 
 type ServicesSyncMapSettings struct {
@@ -69,15 +90,17 @@ type ServicesSyncMapSettings struct {
 type ServicesSync struct {
 	services *Services
 
-	Device      ServicesSyncDeviceSettings
-	Map         ServicesSyncMapSettings
-	Mic         ServicesSyncMicrophoneSettings
-	LLM_xai     LLMxAI
-	LLM_mistral LLMMistral
-	LLM_openai  LLMOpenai
-	LLM_groq    LLMGroq
-	LLM_wsp     LLMWhispercpp
-	LLM_llama   LLMLlamacpp
+	Device        ServicesSyncDeviceSettings
+	Map           ServicesSyncMapSettings
+	Mic           ServicesSyncMicrophoneSettings
+	LLM_xai       LLMxAI
+	LLM_mistral   LLMMistral
+	LLM_openai    LLMOpenai
+	LLM_groq      LLMGroq
+	LLM_anthropic LLMAnthropic
+	LLM_wsp       LLMWhispercpp
+	LLM_llama     LLMLlamacpp
+	LLM_ollama    LLMOllama
 
 	last_dev_storage_change int64
 }
@@ -245,6 +268,18 @@ func (snc *ServicesSync) _readOrInitFiles() error {
 		LogsJsonUnmarshal(groqJs, &snc.LLM_groq)
 	}
 
+	path = "apps/Device/LLMAnthropic-LLMAnthropic.json"
+	anthropicJs, err := os.ReadFile(path)
+	if err != nil {
+		snc.LLM_anthropic.Provider = "Anthropic"
+		snc.LLM_anthropic.Anthropic_url = "https://api.anthropic.com/v1"
+		snc.LLM_anthropic.DevUrl = "https://console.anthropic.com/settings/keys"
+
+		Tools_WriteJSONFile(path, &snc.LLM_anthropic)
+	} else {
+		LogsJsonUnmarshal(anthropicJs, &snc.LLM_anthropic)
+	}
+
 	wspJs, err := os.ReadFile("apps/Device/LLMWhispercpp-LLMWhispercpp.json")
 	if err != nil {
 		snc.LLM_wsp.Address = "http://localhost"
@@ -264,6 +299,16 @@ func (snc *ServicesSync) _readOrInitFiles() error {
 		LogsJsonUnmarshal(llamas, &snc.LLM_llama)
 	}
 
+	path = "apps/Device/LLMOllama-LLMOllama.json"
+	ollamaJs, err := os.ReadFile(path)
+	if err != nil {
+		snc.LLM_ollama.Address = "http://localhost"
+		snc.LLM_ollama.Port = 11434
+		Tools_WriteJSONFile(path, &snc.LLM_ollama)
+	} else {
+		LogsJsonUnmarshal(ollamaJs, &snc.LLM_ollama)
+	}
+
 	return nil
 }
 
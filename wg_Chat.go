@@ -330,19 +330,22 @@ func (st *Chat) AddChatMsg(layout *Layout, msg_i int, MsgsDiv *Layout) {
 			{
 				in := msg.Usage.Prompt_price
 				inCached := msg.Usage.Input_cached_price
+				inCacheWrite := msg.Usage.Input_cache_write_price
 				out := msg.Usage.Completion_price + msg.Usage.Reasoning_price
 				sources := msg.Usage.Sources_price
-				inf := fmt.Sprintf("<b>%s</b>\n%s\nTime to first token: %s sec\nTime: %s\n%s tokens/sec\nTotal: $%s\n- Input: $%s(%d toks)\n- Cached: $%s(%d toks)\n- Output: $%s(%d+%d toks)\n- Sources: $%s(%d links)",
+				inf := fmt.Sprintf("<b>%s</b>\n%s\nTime to first token: %s sec\nTime: %s\n%s tokens/sec\nTotal: $%s\n- Input: $%s(%d toks)\n- Cached: $%s(%d toks)\n- Cache write: $%s(%d toks)\n- Output: $%s(%d+%d toks)\n- Sources: $%s(%d links)",
 					msg.Usage.Provider+":"+msg.Usage.Model,
 					layout.ConvertTextDateTime(int64(msg.Usage.CreatedTimeSec)),
 					strconv.FormatFloat(msg.Usage.TimeToFirstToken, 'f', 3, 64),
 					layout.ConvertDTime(msg.Usage.DTime),
 					strconv.FormatFloat(msg.Usage.GetSpeed(), 'f', 3, 64),
-					strconv.FormatFloat(in+inCached+out+sources, 'f', -1, 64),
+					strconv.FormatFloat(in+inCached+inCacheWrite+out+sources, 'f', -1, 64),
 					strconv.FormatFloat(in, 'f', -1, 64),
 					msg.Usage.Prompt_tokens,
 					strconv.FormatFloat(inCached, 'f', -1, 64),
 					msg.Usage.Input_cached_tokens,
+					strconv.FormatFloat(inCacheWrite, 'f', -1, 64),
+					msg.Usage.Input_cache_write_tokens,
 					strconv.FormatFloat(out, 'f', -1, 64),
 					msg.Usage.Reasoning_tokens,
 					msg.Usage.Completion_tokens,
@@ -400,7 +403,7 @@ func (st *Chat) GetTotalPrice(st_i, en_i int) (input, inCached, output, sources
 	}
 	for i := st_i; i < en_i; i++ {
 		input += st.Messages[i].Usage.Prompt_price
-		inCached += st.Messages[i].Usage.Input_cached_price
+		inCached += st.Messages[i].Usage.Input_cached_price + st.Messages[i].Usage.Input_cache_write_price
 		output += st.Messages[i].Usage.Completion_price + st.Messages[i].Usage.Reasoning_price
 		sources += st.Messages[i].Usage.Sources_price
 	}